@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var baseCmd = &cobra.Command{
+	Short: "Manage Mint base layers",
+	Use:   "base",
+}
+
+var (
+	BaseListJson bool
+
+	baseListCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := service.ListBaseLayers(cli.ListBaseLayersConfig{Json: BaseListJson})
+			return err
+		},
+		Short: "List the available base layers",
+		Long: "List the available base layers.\n" +
+			"Shows every operating system, tag and architecture that can be passed to\n" +
+			"'mint resolve base' with --os, --tag and --arch, along with deprecation and\n" +
+			"end-of-life dates and the current default.",
+		Use: "list [flags]",
+	}
+)
+
+func init() {
+	baseListCmd.Flags().BoolVar(&BaseListJson, "json", false, "output the base layers as JSON")
+	baseCmd.AddCommand(baseListCmd)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Short: "Manage the local cache of Mint API responses",
+	Use:   "cache",
+}
+
+var cacheClearCmd = &cobra.Command{
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return service.ClearCache(cli.ClearCacheConfig{Cache: apiCache})
+	},
+	Short: "Remove all cached leaf versions and base layers",
+	Long: "Remove all cached leaf versions and base layers.\n" +
+		"Leaf versions and base layer resolutions are cached in ~/.mint/cache for an hour\n" +
+		"and used by '--offline'.",
+	Use: "clear",
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheClearCmd)
+}
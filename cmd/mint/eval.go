@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+	"github.com/rwx-research/mint-cli/internal/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	EvalInitParameters []string
+
+	evalCmd = &cobra.Command{
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initParams, err := ParseInitParameters(EvalInitParameters)
+			if err != nil {
+				return errors.Wrap(err, "unable to parse init parameters")
+			}
+
+			_, err = service.Evaluate(cli.EvaluateConfig{
+				Expression:     args[0],
+				InitParameters: initParams,
+				MintDirectory:  MintDirectory,
+			})
+			return err
+		},
+		Short: "Evaluate a Mint expression locally",
+		Long: "Evaluate a Mint expression locally.\n" +
+			"Takes an expression such as \"init.branch || 'main'\", or a string with ${{ }} expressions such as\n" +
+			"'${{ run.mint-dir }}/deploy.yml'. The init context holds the given init parameters and run.mint-dir\n" +
+			"is the .mint directory; other contexts are only known while running.",
+		Use: "eval [flags] <expression>",
+	}
+)
+
+func init() {
+	evalCmd.Flags().StringArrayVar(&EvalInitParameters, flagInit, []string{}, "initialization parameters, available in the `init` context. Can be specified multiple times")
+	addMintDirFlag(evalCmd)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+	"github.com/rwx-research/mint-cli/internal/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	UnformattedFiles = errors.Wrap(HandledError, "unformatted files")
+
+	FormatCheck bool
+
+	fmtCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := service.Format(cli.FormatConfig{
+				Files:         args,
+				MintDirectory: MintDirectory,
+				Check:         FormatCheck,
+			})
+			if err != nil {
+				return err
+			}
+
+			if FormatCheck && len(result.ChangedFiles) > 0 {
+				return UnformattedFiles
+			}
+
+			return nil
+		},
+		Short: "Format Mint YAML files",
+		Long: "Format Mint YAML files.\n" +
+			"Takes a list of files as arguments, or formats all toplevel YAML files in .mint if no files are given.\n" +
+			"Files are indented by two spaces, task keys are ordered canonically (key, use, call, with, run, ...),\n" +
+			"quotes are removed where they aren't needed and multi-line run scripts are written as literal blocks.\n" +
+			"Comments are preserved.",
+		Use: "fmt [flags] [files...]",
+	}
+)
+
+func init() {
+	fmtCmd.Flags().BoolVar(&FormatCheck, "check", false, "print a diff and exit with a non-zero status when files aren't formatted, without formatting them")
+	addMintDirFlag(fmtCmd)
+}
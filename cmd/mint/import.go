@@ -0,0 +1,83 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Short: "Import run definitions from other CI systems",
+	Use:   "import",
+}
+
+var (
+	ImportRepository string
+	ImportVault      string
+	ImportForce      bool
+
+	importGitHubActionsCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cli.ImportSourceGitHubActions, args)
+		},
+		Short: "Import GitHub Actions workflows",
+		Long: "Import GitHub Actions workflows.\n" +
+			"Takes a list of workflows as arguments, or imports every workflow in .github/workflows if none are given.\n" +
+			"Each workflow is written to a run definition of the same name in .mint: jobs and steps become tasks,\n" +
+			"needs become use, setup actions become the matching Mint leaves, secrets are read from a vault and\n" +
+			"triggers become Mint triggers. Anything that can't be translated is marked with a TODO comment.",
+		Use: "github-actions [flags] [workflows...]",
+	}
+
+	importGitLabCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cli.ImportSourceGitLab, args)
+		},
+		Short: "Import GitLab CI/CD pipelines",
+		Long: "Import GitLab CI/CD pipelines.\n" +
+			"Takes a list of pipeline configurations as arguments, or imports .gitlab-ci.yml if none are given.\n" +
+			"Each configuration is written to a run definition of the same name in .mint: jobs become tasks using\n" +
+			"the jobs of the previous stage or the jobs they need, and images of languages become the matching\n" +
+			"Mint leaves. Anything that can't be translated is marked with a TODO comment.",
+		Use: "gitlab [flags] [files...]",
+	}
+
+	importCircleCICmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cli.ImportSourceCircleCI, args)
+		},
+		Short: "Import CircleCI configurations",
+		Long: "Import CircleCI configurations.\n" +
+			"Takes a list of configurations as arguments, or imports .circleci/config.yml if none are given.\n" +
+			"Each configuration is written to .mint/circleci.yml: steps become tasks, jobs use the jobs they require\n" +
+			"in workflows, images of languages become the matching Mint leaves and scheduled workflows become cron\n" +
+			"triggers. Anything that can't be translated is marked with a TODO comment.",
+		Use: "circleci [flags] [files...]",
+	}
+)
+
+func runImport(source string, args []string) error {
+	_, err := service.Import(cli.ImportConfig{
+		Files:         args,
+		Force:         ImportForce,
+		MintDirectory: MintDirectory,
+		Repository:    ImportRepository,
+		Source:        source,
+		Vault:         ImportVault,
+	})
+	return err
+}
+
+func addImportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ImportRepository, "repository", "", "the repository to clone, eg. rwx-research/mint-cli")
+	cmd.Flags().StringVar(&ImportVault, "vault", "default", "the vault to read secrets from")
+	cmd.Flags().BoolVar(&ImportForce, "force", false, "overwrite run definitions that already exist")
+	addMintDirFlag(cmd)
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{importGitHubActionsCmd, importGitLabCmd, importCircleCICmd} {
+		addImportFlags(cmd)
+		importCmd.AddCommand(cmd)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	InitRepository string
+	InitForce      bool
+
+	initCmd = &cobra.Command{
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := service.Init(cli.InitConfig{
+				Force:         InitForce,
+				MintDirectory: MintDirectory,
+				Repository:    InitRepository,
+			})
+			return err
+		},
+		Short: "Create a starter run definition for the project",
+		Long: "Create a starter run definition for the project.\n" +
+			"Detects the languages of the project from files such as go.mod, package.json, Gemfile, pyproject.toml\n" +
+			"and Cargo.toml, and writes .mint/ci.yml with tasks cloning the repository, installing each language,\n" +
+			"installing dependencies, cached until the files they're installed from change, and running the tests.\n" +
+			"The base and the versions of leaves are resolved to their latest.",
+		Use: "init [flags]",
+	}
+)
+
+func init() {
+	initCmd.Flags().StringVar(&InitRepository, "repository", "", "the GitHub repository to clone, eg. rwx-research/mint-cli (default: the repository of the origin remote)")
+	initCmd.Flags().BoolVar(&InitForce, "force", false, "overwrite the run definition if it already exists")
+	addMintDirFlag(initCmd)
+}
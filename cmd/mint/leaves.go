@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/rwx-research/mint-cli/internal/cli"
 	"github.com/spf13/cobra"
 )
@@ -12,6 +14,11 @@ var leavesCmd = &cobra.Command{
 
 var (
 	LeavesAllowMajorVersionChange bool
+	LeavesSearchJson              bool
+	LeavesShowJson                bool
+	LeavesAddFile                 string
+	LeavesAddKey                  string
+	LeavesAddParams               []string
 
 	leavesUpdateCmd = &cobra.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -20,21 +27,121 @@ var (
 				replacementVersionPicker = cli.PickLatestMajorVersion
 			}
 
-			return service.UpdateLeaves(cli.UpdateLeavesConfig{
+			plan := newUpdatePlan()
+			err := service.UpdateLeaves(cli.UpdateLeavesConfig{
 				Files:                    args,
 				MintDirectory:            MintDirectory,
 				ReplacementVersionPicker: replacementVersionPicker,
+				Plan:                     plan,
 			})
+			if err != nil {
+				return err
+			}
+			return reportUpdatePlan(plan)
 		},
 		Short: "Update all leaves to their latest (minor) version",
 		Long: "Update all leaves to their latest (minor) version.\n" +
-			"Takes a list of files as arguments, or updates all toplevel YAML files in .mint if no files are given.",
+			"Takes a list of files as arguments, or updates all toplevel YAML files in .mint if no files are given.\n" +
+			"Versions are restricted by the update policy in .mint/update.yml, if present.",
 		Use: "update [flags] [file...]",
 	}
+
+	leavesLockCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := service.LockLeaves(cli.LockLeavesConfig{
+				Files:         args,
+				MintDirectory: MintDirectory,
+			})
+			return err
+		},
+		Short: "Record the digest of every leaf version in .mint/leaves.lock",
+		Long: "Record the digest of every leaf version in .mint/leaves.lock.\n" +
+			"Locks all leaves called from YAML files in .mint, along with any files given as arguments.\n" +
+			"Once a lockfile exists, 'mint lint' and 'mint run' verify leaves against it, and\n" +
+			"'mint resolve leaves' and 'mint update leaves' keep it up to date.",
+		Use: "lock [flags] [file...]",
+	}
+
+	leavesSearchCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := service.SearchLeaves(cli.SearchLeavesConfig{
+				Query: strings.Join(args, " "),
+				Json:  LeavesSearchJson,
+			})
+			return err
+		},
+		Short: "Search the leaf registry",
+		Long: "Search the leaf registry.\n" +
+			"Lists the leaves whose name or description matches the query, or all leaves if no query is given.",
+		Use: "search [flags] [query...]",
+	}
+
+	leavesShowCmd = &cobra.Command{
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version := ""
+			if len(args) > 1 {
+				version = args[1]
+			}
+
+			_, err := service.ShowLeaf(cli.ShowLeafConfig{
+				Leaf:    args[0],
+				Version: version,
+				Json:    LeavesShowJson,
+			})
+			return err
+		},
+		Short: "Show the versions, parameters and outputs of a leaf",
+		Long: "Show the versions, parameters and outputs of a leaf.\n" +
+			"Shows the latest version unless a version is given, followed by a task snippet calling the leaf.",
+		Use: "show [flags] <leaf> [version]",
+	}
+
+	leavesAddCmd = &cobra.Command{
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version := ""
+			if len(args) > 1 {
+				version = args[1]
+			}
+
+			_, err := service.AddLeaf(cli.AddLeafConfig{
+				MintDirectory: MintDirectory,
+				File:          LeavesAddFile,
+				Leaf:          args[0],
+				Version:       version,
+				Key:           LeavesAddKey,
+				Params:        LeavesAddParams,
+			})
+			return err
+		},
+		Short: "Add a task calling a leaf to a run definition",
+		Long: "Add a task calling a leaf to a run definition.\n" +
+			"The task is pinned to the latest version of the leaf unless a version is given, and its\n" +
+			"parameters are validated against the leaf. Without --file, the task is added to the only\n" +
+			"run definition in .mint.",
+		Use: "add [flags] <leaf> [version]",
+	}
 )
 
 func init() {
 	leavesUpdateCmd.Flags().BoolVar(&LeavesAllowMajorVersionChange, "allow-major-version-change", false, "update leaves to the latest major version")
 	addMintDirFlag(leavesUpdateCmd)
+	addUpdatePlanFlags(leavesUpdateCmd)
 	leavesCmd.AddCommand(leavesUpdateCmd)
+
+	addMintDirFlag(leavesLockCmd)
+	leavesCmd.AddCommand(leavesLockCmd)
+
+	leavesSearchCmd.Flags().BoolVar(&LeavesSearchJson, "json", false, "output JSON instead of a textual representation")
+	leavesCmd.AddCommand(leavesSearchCmd)
+
+	leavesShowCmd.Flags().BoolVar(&LeavesShowJson, "json", false, "output JSON instead of a textual representation")
+	leavesCmd.AddCommand(leavesShowCmd)
+
+	leavesAddCmd.Flags().StringVarP(&LeavesAddFile, "file", "f", "", "the run definition to add the task to")
+	leavesAddCmd.Flags().StringVar(&LeavesAddKey, "key", "", "the key of the task (defaults to the name of the leaf)")
+	leavesAddCmd.Flags().StringArrayVar(&LeavesAddParams, "param", []string{}, "a parameter for the leaf in form `key=value`. Can be specified multiple times")
+	addMintDirFlag(leavesAddCmd)
+	leavesCmd.AddCommand(leavesAddCmd)
 }
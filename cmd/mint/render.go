@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+	"github.com/rwx-research/mint-cli/internal/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	RenderFile           string
+	RenderInitParameters []string
+	RenderJson           bool
+
+	renderCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initParams, err := ParseInitParameters(RenderInitParameters)
+			if err != nil {
+				return errors.Wrap(err, "unable to parse init parameters")
+			}
+
+			_, err = service.Render(cli.RenderConfig{
+				File:           RenderFile,
+				InitParameters: initParams,
+				Json:           RenderJson,
+				MintDirectory:  MintDirectory,
+			})
+			return err
+		},
+		Short: "Print a run definition with its embedded runs expanded",
+		Long: "Print a run definition with its embedded runs expanded.\n" +
+			"Tasks calling an embedded run such as '${{ run.mint-dir }}/deploy.yml' are replaced by the tasks of\n" +
+			"that run, keyed by the key of the calling task followed by their own (eg. deploy.build).\n" +
+			"Expressions referring to init parameters and run.mint-dir are evaluated; other expressions are only\n" +
+			"known while running and are left as they are. Each task is preceded by a comment pointing to where\n" +
+			"it's defined.",
+		Use: "render [flags] --file <file>",
+	}
+)
+
+func init() {
+	renderCmd.Flags().StringVarP(&RenderFile, "file", "f", "", "the run definition to render (required)")
+	renderCmd.Flags().StringArrayVar(&RenderInitParameters, flagInit, []string{}, "initialization parameters, available in the `init` context. Can be specified multiple times")
+	renderCmd.Flags().BoolVar(&RenderJson, "json", false, "output JSON instead of YAML, without the source comments")
+	addMintDirFlag(renderCmd)
+}
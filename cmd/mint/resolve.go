@@ -33,6 +33,8 @@ var (
 	resolveBaseTag  string
 	resolveBaseArch string
 
+	resolvePinDigests bool
+
 	resolveBaseCmd = &cobra.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return resolveBase(args)
@@ -52,7 +54,11 @@ var (
 		Short: "Add the latest version to all leaf invocations that do not have one",
 		Long: "Add the latest version to all leaf invocations that do not have one.\n" +
 			"Updates all top-level YAML files in .mint that 'call' a leaf without a version\n" +
-			"to use the latest version.",
+			"to use the latest version. Leaves referenced by git repository, eg.\n" +
+			"'git+https://github.com/my-org/my-leaf.git@main', are pinned to the commit their ref points to,\n" +
+			"keeping the ref in a comment, eg. '# ref: main', for `mint update leaves` to follow.\n" +
+			"With --pin-digests, leaves are also pinned to the content digest of their version, eg.\n" +
+			"'mint/install-node 1.2.3@sha256:...', and `mint lint` reports when the registry content changes.",
 		Use: "leaves [flags] [files...]",
 	}
 )
@@ -61,6 +67,9 @@ func resolveBase(files []string) error {
 	base, err := service.ResolveBase(cli.ResolveBaseConfig{
 		Files:         files,
 		MintDirectory: MintDirectory,
+		Os:            resolveBaseOs,
+		Tag:           resolveBaseTag,
+		Arch:          resolveBaseArch,
 	})
 	if err != nil {
 		return err
@@ -76,6 +85,7 @@ func resolveLeaves(files []string) error {
 		Files:               files,
 		MintDirectory:       MintDirectory,
 		LatestVersionPicker: cli.PickLatestMajorVersion,
+		PinDigests:          resolvePinDigests,
 	})
 	return err
 }
@@ -86,9 +96,11 @@ func init() {
 	resolveBaseCmd.Flags().StringVar(&resolveBaseArch, "arch", "", "target architecture")
 	addMintDirFlag(resolveBaseCmd)
 
+	resolveLeavesCmd.Flags().BoolVar(&resolvePinDigests, "pin-digests", false, "pin leaves to the content digest of their version")
 	addMintDirFlag(resolveLeavesCmd)
 
 	resolveCmd.AddCommand(resolveBaseCmd)
 	resolveCmd.AddCommand(resolveLeavesCmd)
+	resolveCmd.Flags().BoolVar(&resolvePinDigests, "pin-digests", false, "pin leaves to the content digest of their version")
 	addMintDirFlag(resolveCmd)
 }
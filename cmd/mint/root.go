@@ -7,6 +7,7 @@ import (
 	"github.com/rwx-research/mint-cli/cmd/mint/config"
 	"github.com/rwx-research/mint-cli/internal/accesstoken"
 	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/apicache"
 	"github.com/rwx-research/mint-cli/internal/cli"
 	"github.com/rwx-research/mint-cli/internal/errors"
 	"github.com/rwx-research/mint-cli/internal/ssh"
@@ -16,11 +17,13 @@ import (
 
 var (
 	AccessToken string
+	Offline     bool
 	Verbose     bool
 
 	mintHost           string
 	service            cli.Service
 	accessTokenBackend accesstoken.Backend
+	apiCache           *apicache.Cache
 
 	// rootCmd represents the main `mint` command
 	rootCmd = &cobra.Command{
@@ -36,7 +39,12 @@ var (
 				return errors.Wrap(err, "unable to initialize access token backend")
 			}
 
-			c, err := api.NewClient(api.Config{AccessToken: AccessToken, Host: mintHost, AccessTokenBackend: accessTokenBackend})
+			apiCache, err = apicache.New(fmt.Sprintf("~%v.mint%vcache", string(os.PathSeparator), string(os.PathSeparator)))
+			if err != nil {
+				return errors.Wrap(err, "unable to initialize API cache")
+			}
+
+			c, err := api.NewClient(api.Config{AccessToken: AccessToken, Host: mintHost, AccessTokenBackend: accessTokenBackend, Cache: apiCache, Offline: Offline, Stderr: os.Stderr})
 			if err != nil {
 				return errors.Wrap(err, "unable to initialize API client")
 			}
@@ -63,6 +71,7 @@ func init() {
 	}
 
 	rootCmd.PersistentFlags().StringVar(&AccessToken, "access-token", os.Getenv("RWX_ACCESS_TOKEN"), "the access token for Mint")
+	rootCmd.PersistentFlags().BoolVar(&Offline, "offline", false, "do not call the Mint API, only using cached leaf versions and base layers and skipping the verification of leaf digests")
 	rootCmd.PersistentFlags().BoolVar(&Verbose, "verbose", false, "enable debug output")
 	_ = rootCmd.PersistentFlags().MarkHidden("verbose")
 
@@ -76,4 +85,12 @@ func init() {
 	rootCmd.AddCommand(lintCmd)
 	rootCmd.AddCommand(resolveCmd)
 	rootCmd.AddCommand(updateCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(baseCmd)
+	rootCmd.AddCommand(fmtCmd)
+	rootCmd.AddCommand(evalCmd)
+	rootCmd.AddCommand(tasksCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(initCmd)
 }
@@ -17,8 +17,10 @@ import (
 const flagInit = "init"
 
 var (
+	Gitignore      bool
 	InitParameters []string
 	Json           bool
+	ListFiles      bool
 	MintDirectory  string
 	MintFilePath   string
 	NoCache        bool
@@ -59,6 +61,7 @@ var (
 			}
 
 			runResult, err := service.InitiateRun(cli.InitiateRunConfig{
+				Gitignore:      Gitignore,
 				InitParameters: initParams,
 				Json:           Json,
 				MintDirectory:  MintDirectory,
@@ -66,6 +69,7 @@ var (
 				NoCache:        NoCache,
 				TargetedTasks:  targetedTasks,
 				Title:          Title,
+				ListFiles:      ListFiles,
 			})
 			if err != nil {
 				return err
@@ -136,6 +140,8 @@ func init() {
 	runCmd.Flags().BoolVar(&Debug, "debug", false, "start a remote debugging session once a breakpoint is hit")
 	runCmd.Flags().StringVar(&Title, "title", "", "the title the UI will display for the Mint run")
 	runCmd.Flags().BoolVar(&Json, "json", false, "output json data to stdout")
+	runCmd.Flags().BoolVar(&ListFiles, "list-files", false, "list the files of the .mint directory included in and excluded from the run, along with their sizes, on stderr")
+	runCmd.Flags().BoolVar(&Gitignore, "gitignore", false, "also exclude the files of the .mint directory ignored by .gitignore, along with those listed in .mint/.mintignore")
 }
 
 // parseInitParameters converts a list of `key=value` pairs to a map. It also reads any `MINT_INIT_` variables from the
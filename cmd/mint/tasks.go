@@ -0,0 +1,45 @@
+package main
+
+import (
+	"github.com/rwx-research/mint-cli/internal/cli"
+	"github.com/rwx-research/mint-cli/internal/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var tasksCmd = &cobra.Command{
+	Short: "Inspect the tasks of Mint run definitions",
+	Use:   "tasks",
+}
+
+var (
+	TasksListInitParameters []string
+
+	tasksListCmd = &cobra.Command{
+		RunE: func(cmd *cobra.Command, args []string) error {
+			initParams, err := ParseInitParameters(TasksListInitParameters)
+			if err != nil {
+				return errors.Wrap(err, "unable to parse init parameters")
+			}
+
+			_, err = service.ListTasks(cli.ListTasksConfig{
+				Files:          args,
+				InitParameters: initParams,
+				MintDirectory:  MintDirectory,
+			})
+			return err
+		},
+		Short: "List the tasks of Mint run definitions",
+		Long: "List the tasks of Mint run definitions.\n" +
+			"Takes a list of files as arguments, or lists the tasks of all toplevel YAML files in .mint if no files are given.\n" +
+			"Shows the leaf or embedded run each task calls; expressions in the calls of embedded runs are evaluated\n" +
+			"with the given init parameters, see 'mint eval'.",
+		Use: "list [flags] [files...]",
+	}
+)
+
+func init() {
+	tasksListCmd.Flags().StringArrayVar(&TasksListInitParameters, flagInit, []string{}, "initialization parameters, available in the `init` context. Can be specified multiple times")
+	addMintDirFlag(tasksListCmd)
+	tasksCmd.AddCommand(tasksListCmd)
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"github.com/rwx-research/mint-cli/internal/cli"
+	"github.com/rwx-research/mint-cli/internal/errors"
+
 	"github.com/spf13/cobra"
 )
 
@@ -9,56 +11,92 @@ var updateCmd = &cobra.Command{
 	Short: "Update versions for base layers and Mint leaves",
 	Use:   "update [flags] [files...]",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		plan := newUpdatePlan()
+
 		if len(args) > 0 {
 			switch args[0] {
 			case "base":
-				return updateBase(args[1:])
+				if err := updateBase(args[1:], plan); err != nil {
+					return err
+				}
+				return reportUpdatePlan(plan)
 			case "leaves":
-				return updateLeaves(args[1:])
+				if err := updateLeaves(args[1:], plan); err != nil {
+					return err
+				}
+				return reportUpdatePlan(plan)
 			}
 		}
 
-		err := updateBase(args)
+		err := updateBase(args, plan)
 		if err != nil {
 			return err
 		}
-		return updateLeaves(args)
+		if err := updateLeaves(args, plan); err != nil {
+			return err
+		}
+		return reportUpdatePlan(plan)
 	},
 }
 
 var (
+	UpdatesAvailable = errors.Wrap(HandledError, "updates available")
+
 	AllowMajorVersionChange bool
+	UpdateDryRun            bool
+	UpdateJson              bool
+	UpdateCheck             bool
+	UpdatePinDigests        bool
+	UpdateBaseOs            string
+	UpdateBaseArch          string
 
 	updateBaseCmd = &cobra.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return updateBase(args)
+			plan := newUpdatePlan()
+			if err := updateBase(args, plan); err != nil {
+				return err
+			}
+			return reportUpdatePlan(plan)
 		},
 		Short: "Update all base layers to their latest (minor) version",
 		Long: "Update all base layers to their latest (minor) version.\n" +
-			"Takes a list of files as arguments, or updates all toplevel YAML files in .mint if no files are given.",
+			"Takes a list of files as arguments, or updates all toplevel YAML files in .mint if no files are given.\n" +
+			"With --os, eg. --os \"ubuntu 24.04\", run definitions are migrated to the latest base layer of that\n" +
+			"operating system, and leaves or commands known to be incompatible with it are reported.\n" +
+			"With --arch, eg. --arch arm64, run definitions are moved to that architecture.",
 		Use: "base [flags] [files...]",
 	}
 
 	updateLeavesCmd = &cobra.Command{
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return updateLeaves(args)
+			plan := newUpdatePlan()
+			if err := updateLeaves(args, plan); err != nil {
+				return err
+			}
+			return reportUpdatePlan(plan)
 		},
 		Short: "Update all leaves to their latest (minor) version",
 		Long: "Update all leaves to their latest (minor) version.\n" +
-			"Takes a list of files as arguments, or updates all toplevel YAML files in .mint if no files are given.",
+			"Takes a list of files as arguments, or updates all toplevel YAML files in .mint if no files are given.\n" +
+			"Versions are restricted by the update policy in .mint/update.yml, if present.\n" +
+			"Leaves pinned to a digest are re-pinned to the digest of their new version, and git leaves pinned\n" +
+			"to a commit are moved to the commit the ref in their comment, eg. '# ref: main', points to.",
 		Use: "leaves [flags] [files...]",
 	}
 )
 
-func updateBase(files []string) error {
+func updateBase(files []string, plan *cli.UpdatePlan) error {
 	_, err := service.UpdateBase(cli.UpdateBaseConfig{
 		Files:         files,
 		MintDirectory: MintDirectory,
+		Os:            UpdateBaseOs,
+		Arch:          UpdateBaseArch,
+		Plan:          plan,
 	})
 	return err
 }
 
-func updateLeaves(files []string) error {
+func updateLeaves(files []string, plan *cli.UpdatePlan) error {
 	replacementVersionPicker := cli.PickLatestMinorVersion
 	if AllowMajorVersionChange {
 		replacementVersionPicker = cli.PickLatestMajorVersion
@@ -68,17 +106,56 @@ func updateLeaves(files []string) error {
 		Files:                    files,
 		MintDirectory:            MintDirectory,
 		ReplacementVersionPicker: replacementVersionPicker,
+		PinDigests:               UpdatePinDigests,
+		Plan:                     plan,
 	})
 }
 
+// newUpdatePlan returns a plan to collect changes in when updates should not be written.
+func newUpdatePlan() *cli.UpdatePlan {
+	if UpdateDryRun || UpdateJson || UpdateCheck {
+		return cli.NewUpdatePlan()
+	}
+	return nil
+}
+
+func reportUpdatePlan(plan *cli.UpdatePlan) error {
+	if plan == nil {
+		return nil
+	}
+
+	if err := service.ReportUpdatePlan(cli.ReportUpdatePlanConfig{Plan: plan, Json: UpdateJson}); err != nil {
+		return err
+	}
+
+	if UpdateCheck && plan.HasChanges() {
+		return UpdatesAvailable
+	}
+
+	return nil
+}
+
+func addUpdatePlanFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&UpdateDryRun, "dry-run", false, "print a diff of the changes instead of writing them")
+	cmd.Flags().BoolVar(&UpdateJson, "json", false, "print the planned changes as JSON instead of writing them")
+	cmd.Flags().BoolVar(&UpdateCheck, "check", false, "exit with a non-zero status when updates are available, without writing them")
+}
+
 func init() {
+	updateBaseCmd.Flags().StringVar(&UpdateBaseOs, "os", "", "migrate run definitions to the given operating system")
+	updateBaseCmd.Flags().StringVar(&UpdateBaseArch, "arch", "", "move run definitions to the given architecture, eg. arm64")
 	addMintDirFlag(updateBaseCmd)
+	addUpdatePlanFlags(updateBaseCmd)
 
 	updateLeavesCmd.Flags().BoolVar(&AllowMajorVersionChange, "allow-major-version-change", false, "update leaves to the latest major version")
+	updateLeavesCmd.Flags().BoolVar(&UpdatePinDigests, "pin-digests", false, "pin leaves to the content digest of their new version")
 	addMintDirFlag(updateLeavesCmd)
+	addUpdatePlanFlags(updateLeavesCmd)
 
 	updateCmd.Flags().BoolVar(&AllowMajorVersionChange, "allow-major-version-change", false, "update to the latest major version")
+	updateCmd.Flags().BoolVar(&UpdatePinDigests, "pin-digests", false, "pin leaves to the content digest of their new version")
 	updateCmd.AddCommand(updateBaseCmd)
 	updateCmd.AddCommand(updateLeavesCmd)
 	addMintDirFlag(updateCmd)
+	addUpdatePlanFlags(updateCmd)
 }
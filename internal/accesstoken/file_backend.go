@@ -1,15 +1,13 @@
 package accesstoken
 
 import (
-	"fmt"
 	"io"
-	"io/fs"
 	"os"
-	"os/user"
 	"path/filepath"
 	"strings"
 
 	"github.com/rwx-research/mint-cli/internal/errors"
+	"github.com/rwx-research/mint-cli/internal/fs"
 )
 
 type FileBackend struct {
@@ -17,7 +15,7 @@ type FileBackend struct {
 }
 
 func NewFileBackend(dir string) (*FileBackend, error) {
-	dir, err := expandTilde(dir)
+	dir, err := fs.ExpandTilde(dir)
 	if err != nil {
 		return nil, err
 	}
@@ -29,7 +27,7 @@ func (f FileBackend) Get() (string, error) {
 	path := filepath.Join(f.Dir, "accesstoken")
 	fd, err := os.Open(path)
 	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
+		if errors.Is(err, os.ErrNotExist) {
 			return "", nil
 		}
 
@@ -65,20 +63,3 @@ func (f FileBackend) Set(value string) error {
 
 	return nil
 }
-
-var tildeSlash = fmt.Sprintf("~%v", string(os.PathSeparator))
-
-func expandTilde(dir string) (string, error) {
-	user, err := user.Current()
-	if err != nil {
-		return "", err
-	}
-
-	if strings.HasPrefix(dir, tildeSlash) {
-		return filepath.Join(user.HomeDir, strings.TrimPrefix(dir, tildeSlash)), nil
-	} else if dir == "~" {
-		return user.HomeDir, nil
-	} else {
-		return dir, nil
-	}
-}
@@ -2,15 +2,22 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/rwx-research/mint-cli/cmd/mint/config"
 	"github.com/rwx-research/mint-cli/internal/accesstoken"
+	"github.com/rwx-research/mint-cli/internal/apicache"
 	"github.com/rwx-research/mint-cli/internal/errors"
 	"github.com/rwx-research/mint-cli/internal/messages"
 	"github.com/rwx-research/mint-cli/internal/versions"
@@ -18,6 +25,13 @@ import (
 
 var ErrNotFound = errors.New("not found")
 
+var ErrOffline = errors.New("unable to call the Mint API in offline mode")
+
+const (
+	leafVersionsCacheTTL = time.Hour
+	baseLayerCacheTTL    = time.Hour
+)
+
 type roundTripFunc func(*http.Request) (*http.Response, error)
 
 func (rtf roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -27,6 +41,12 @@ func (rtf roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
 // Client is an API Client for Mint
 type Client struct {
 	http.RoundTripper
+
+	cache *apicache.Cache
+	// cacheNamespace returns the namespace of cached responses. It's only called once a response
+	// is cached, as it may need to read the access token.
+	cacheNamespace func() (string, error)
+	stderr         io.Writer
 }
 
 func NewClient(cfg Config) (Client, error) {
@@ -35,6 +55,10 @@ func NewClient(cfg Config) (Client, error) {
 	}
 
 	roundTrip := func(req *http.Request) (*http.Response, error) {
+		if cfg.Offline {
+			return nil, ErrOffline
+		}
+
 		if req.URL.Scheme == "" {
 			req.URL.Scheme = "https"
 		}
@@ -54,12 +78,50 @@ func NewClient(cfg Config) (Client, error) {
 		return http.DefaultClient.Do(req)
 	}
 
-	return NewClientWithRoundTrip(roundTrip), nil
+	client := NewClientWithRoundTrip(roundTrip).WithStderr(cfg.Stderr)
+	if cfg.Cache != nil {
+		client.cache = cfg.Cache
+		client.cacheNamespace = sync.OnceValues(func() (string, error) {
+			token, err := accesstoken.Get(cfg.AccessTokenBackend, cfg.AccessToken)
+			if err != nil {
+				return "", errors.Wrap(err, "unable to retrieve access token")
+			}
+			return CacheNamespace(cfg.Host, token), nil
+		})
+	}
+
+	return client, nil
+}
+
+// CacheNamespace returns the namespace of cached responses for a host and access token. Responses
+// may be scoped to the organization of the token, so they're never shared between tokens.
+func CacheNamespace(host string, accessToken string) string {
+	if accessToken == "" {
+		return host
+	}
+
+	digest := sha256.Sum256([]byte(accessToken))
+	return fmt.Sprintf("%s %s", host, hex.EncodeToString(digest[:8]))
 }
 
 func NewClientWithRoundTrip(rt func(*http.Request) (*http.Response, error)) Client {
 	roundTripper := versions.NewRoundTripper(roundTripFunc(rt))
-	return Client{roundTripper}
+	return Client{RoundTripper: roundTripper}
+}
+
+// WithCache returns a client caching responses in the given cache. Entries are namespaced, eg.
+// by host and access token (see CacheNamespace), so that responses from different Mint instances
+// or organizations never mix.
+func (c Client) WithCache(cache *apicache.Cache, namespace string) Client {
+	c.cache = cache
+	c.cacheNamespace = func() (string, error) { return namespace, nil }
+	return c
+}
+
+// WithStderr returns a client writing warnings, such as the use of stale cached responses, to w.
+func (c Client) WithStderr(w io.Writer) Client {
+	c.stderr = w
+	return c
 }
 
 func (c Client) GetDebugConnectionInfo(debugKey string) (DebugConnectionInfo, error) {
@@ -178,6 +240,73 @@ func (c Client) InitiateRun(cfg InitiateRunConfig) (*InitiateRunResult, error) {
 	}
 }
 
+// FindMissingBlobs returns which of the given blobs Mint doesn't have yet, so that only those are uploaded
+func (c Client) FindMissingBlobs(cfg FindMissingBlobsConfig) (*FindMissingBlobsResult, error) {
+	endpoint := "/mint/api/blobs/missing"
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	encodedBody, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode as JSON")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(encodedBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	result := FindMissingBlobsResult{}
+	if err = decodeResponseJSON(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// UploadBlob uploads the contents of a file, compressed with gzip, to be referenced by its digest
+func (c Client) UploadBlob(cfg UploadBlobConfig) error {
+	endpoint := "/mint/api/blobs/" + url.PathEscape(cfg.Digest)
+
+	if err := cfg.Validate(); err != nil {
+		return errors.Wrap(err, "validation failed")
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(cfg.Contents); err != nil {
+		return errors.Wrap(err, "unable to compress blob")
+	}
+	if err := writer.Close(); err != nil {
+		return errors.Wrap(err, "unable to compress blob")
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, &compressed)
+	if err != nil {
+		return errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "gzip")
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		return errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	return decodeResponseJSON(resp, nil)
+}
+
 func (c Client) InitiateDispatch(cfg InitiateDispatchConfig) (*InitiateDispatchResult, error) {
 	endpoint := "/mint/api/runs/dispatches"
 
@@ -448,26 +577,211 @@ func (c Client) GetLeafVersions() (*LeafVersionsResult, error) {
 		return nil, errors.Wrap(err, "unable to create new HTTP request")
 	}
 
+	respBody := LeafVersionsResult{}
+	if err := c.doCached(req, nil, leafVersionsCacheTTL, &respBody); err != nil {
+		return nil, err
+	}
+
+	return &respBody, nil
+}
+
+// ResolveLeafVersions returns the versions of leaves that aren't in the public registry, such as
+// the leaves of the authenticated organization
+func (c Client) ResolveLeafVersions(cfg ResolveLeafVersionsConfig) (*LeafVersionsResult, error) {
+	endpoint := "/mint/api/leaves/versions"
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	encodedBody, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode as JSON")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(encodedBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
 	resp, err := c.RoundTrip(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "HTTP request failed")
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		msg := extractErrorMessage(resp.Body)
-		if msg == "" {
-			msg = fmt.Sprintf("Unable to call Mint API - %s", resp.Status)
-		}
-		return nil, errors.New(msg)
+	result := LeafVersionsResult{}
+	if err = decodeResponseJSON(resp, &result); err != nil {
+		return nil, err
 	}
 
-	respBody := LeafVersionsResult{}
-	if err := json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
-		return nil, errors.Wrap(err, "unable to parse API response")
+	return &result, nil
+}
+
+// ResolveLeafGitRef returns the commit SHA a ref of a leaf's git repository points to
+func (c Client) ResolveLeafGitRef(cfg ResolveLeafGitRefConfig) (*ResolveLeafGitRefResult, error) {
+	endpoint := "/mint/api/leaves/git_refs/resolve"
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validation failed")
 	}
 
-	return &respBody, nil
+	encodedBody, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode as JSON")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(encodedBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	result := ResolveLeafGitRefResult{}
+	if err = decodeResponseJSON(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// SearchLeaves returns the leaves in the registry matching the given query
+func (c Client) SearchLeaves(cfg SearchLeavesConfig) (*SearchLeavesResult, error) {
+	query := url.Values{}
+	query.Set("q", cfg.Query)
+	endpoint := fmt.Sprintf("/mint/api/leaves/search?%s", query.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	result := SearchLeavesResult{}
+	if err = decodeResponseJSON(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetLeaf returns the details of a leaf version, including its parameters and outputs
+func (c Client) GetLeaf(cfg GetLeafConfig) (*LeafDetails, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	endpoint := fmt.Sprintf("/mint/api/leaves/%s", cfg.Name)
+	if cfg.Version != "" {
+		query := url.Values{}
+		query.Set("version", cfg.Version)
+		endpoint = fmt.Sprintf("%s?%s", endpoint, query.Encode())
+	}
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, errors.Wrapf(errors.ErrNotFound, "leaf %q", cfg.Name)
+	}
+
+	result := LeafDetails{}
+	if err = decodeResponseJSON(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetLeafReleases returns the release notes of a leaf between two versions
+func (c Client) GetLeafReleases(cfg GetLeafReleasesConfig) (*LeafReleasesResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	query := url.Values{}
+	if cfg.FromVersion != "" {
+		query.Set("from", cfg.FromVersion)
+	}
+	query.Set("to", cfg.ToVersion)
+	endpoint := fmt.Sprintf("/mint/api/leaves/%s/releases?%s", cfg.Leaf, query.Encode())
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	result := LeafReleasesResult{}
+	if err = decodeResponseJSON(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ResolveLeafDigests returns the content digest the registry holds for each of the given leaf versions
+func (c Client) ResolveLeafDigests(cfg ResolveLeafDigestsConfig) (*ResolveLeafDigestsResult, error) {
+	endpoint := "/mint/api/leaves/digests"
+
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	encodedBody, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode as JSON")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(encodedBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	result := ResolveLeafDigestsResult{}
+	if err = decodeResponseJSON(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
 }
 
 func (c Client) ResolveBaseLayer(cfg ResolveBaseLayerConfig) (ResolveBaseLayerResult, error) {
@@ -486,17 +800,144 @@ func (c Client) ResolveBaseLayer(cfg ResolveBaseLayerConfig) (ResolveBaseLayerRe
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 
+	if err = c.doCached(req, encodedBody, baseLayerCacheTTL, &result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ListBaseLayers returns every base layer available to runs, including deprecated ones
+func (c Client) ListBaseLayers() (*ListBaseLayersResult, error) {
+	endpoint := "/mint/api/base_layers"
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	result := ListBaseLayersResult{}
+	if err = c.doCached(req, nil, baseLayerCacheTTL, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetBaseLayerMigration returns the leaves and commands known to be incompatible with a migration
+// between two operating systems
+func (c Client) GetBaseLayerMigration(cfg GetBaseLayerMigrationConfig) (*BaseLayerMigrationResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	query := url.Values{}
+	query.Set("from", cfg.FromOs)
+	query.Set("to", cfg.ToOs)
+	endpoint := "/mint/api/base_layers/migration?" + query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create new HTTP request")
+	}
+	req.Header.Set("Accept", "application/json")
+
 	resp, err := c.RoundTrip(req)
 	if err != nil {
-		return result, errors.Wrap(err, "HTTP request failed")
+		return nil, errors.Wrap(err, "HTTP request failed")
 	}
 	defer resp.Body.Close()
 
+	result := BaseLayerMigrationResult{}
 	if err = decodeResponseJSON(resp, &result); err != nil {
-		return result, err
+		return nil, err
 	}
 
-	return result, nil
+	return &result, nil
+}
+
+// doCached performs a request and decodes its JSON response into result. When the client has a
+// cache, responses are reused for the given TTL, then revalidated using their ETag. A cached
+// response is also used, regardless of its age, when the API can't be reached.
+func (c Client) doCached(req *http.Request, body []byte, ttl time.Duration, result any) error {
+	if c.cache == nil {
+		resp, err := c.RoundTrip(req)
+		if err != nil {
+			return errors.Wrap(err, "HTTP request failed")
+		}
+		defer resp.Body.Close()
+
+		return decodeResponseJSON(resp, result)
+	}
+
+	namespace, err := c.cacheNamespace()
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s %s %s %s", namespace, req.Method, req.URL.String(), body)
+	entry := c.cache.Get(key)
+	if entry != nil && entry.FreshFor(ttl) {
+		return decodeCachedJSON(entry, result)
+	}
+
+	if entry != nil && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	resp, err := c.RoundTrip(req)
+	if err != nil {
+		// Stale responses stand in for the API only when it can't be reached, not when it fails
+		if entry != nil && isUnreachable(err) {
+			if c.stderr != nil {
+				fmt.Fprintf(c.stderr, "Warning: the Mint API can't be reached, using a response cached %s ago\n", time.Since(entry.StoredAt).Round(time.Minute))
+			}
+			return decodeCachedJSON(entry, result)
+		}
+		if errors.Is(err, ErrOffline) {
+			return errors.Wrap(err, "no cached response is available")
+		}
+		return errors.Wrap(err, "HTTP request failed")
+	}
+	defer resp.Body.Close()
+
+	// Failing to write to the cache only makes the next invocation slower, so errors are ignored
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		entry.StoredAt = time.Now()
+		_ = c.cache.Set(key, *entry)
+		return decodeCachedJSON(entry, result)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return decodeResponseJSON(resp, result)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "unable to read API response")
+	}
+
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return errors.Wrap(err, "unable to parse API response")
+	}
+
+	_ = c.cache.Set(key, apicache.Entry{ETag: resp.Header.Get("ETag"), StoredAt: time.Now(), Body: respBody})
+	return nil
+}
+
+// isUnreachable reports whether a request failed because the API couldn't be reached, either
+// in offline mode or due to a network error.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, ErrOffline) || errors.As(err, &netErr)
+}
+
+func decodeCachedJSON(entry *apicache.Entry, result any) error {
+	if err := json.Unmarshal(entry.Body, result); err != nil {
+		return errors.Wrap(err, "unable to parse cached API response")
+	}
+	return nil
 }
 
 func decodeResponseJSON(resp *http.Response, result any) error {
@@ -2,16 +2,24 @@ package api_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"io"
+	"net"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/apicache"
+	"github.com/rwx-research/mint-cli/internal/errors"
 	"github.com/rwx-research/mint-cli/internal/versions"
 )
 
@@ -217,6 +225,84 @@ var _ = Describe("API Client", func() {
 		})
 	})
 
+	Describe("MintDirectoryEntry", func() {
+		It("omits the contents of files referenced by digest", func() {
+			encoded, err := json.Marshal([]api.MintDirectoryEntry{
+				{Path: ".mint", Type: "dir", Permissions: 0o755},
+				{Path: ".mint/ci.yml", Type: "file", Permissions: 0o644, FileContents: "tasks: []", Digest: "sha256:abc"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(encoded)).To(Equal(`[{"path":".mint","type":"dir","permissions":493,"file_contents":""},{"path":".mint/ci.yml","type":"file","permissions":420,"digest":"sha256:abc"}]`))
+		})
+	})
+
+	Describe("FindMissingBlobs", func() {
+		It("builds the request and parses the response", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/mint/api/blobs/missing"))
+				Expect(req.Method).To(Equal(http.MethodPost))
+				reqBody, err := io.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(reqBody)).To(Equal(`{"digests":["sha256:abc","sha256:def"]}`))
+
+				body := `{"missing": ["sha256:def"]}`
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			result, err := c.FindMissingBlobs(api.FindMissingBlobsConfig{Digests: []string{"sha256:abc", "sha256:def"}})
+			Expect(err).To(BeNil())
+			Expect(result.Missing).To(Equal([]string{"sha256:def"}))
+		})
+
+		It("returns a not found error when blobs aren't supported", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "404 Not Found",
+					StatusCode: 404,
+					Body:       io.NopCloser(bytes.NewReader([]byte(""))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			_, err := c.FindMissingBlobs(api.FindMissingBlobsConfig{Digests: []string{"sha256:abc"}})
+			Expect(errors.Is(err, api.ErrNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("UploadBlob", func() {
+		It("uploads the contents compressed with gzip", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/mint/api/blobs/sha256:abc"))
+				Expect(req.Method).To(Equal(http.MethodPut))
+				Expect(req.Header.Get("Content-Encoding")).To(Equal("gzip"))
+
+				reader, err := gzip.NewReader(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				contents, err := io.ReadAll(reader)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal("tasks: []"))
+
+				return &http.Response{
+					Status:     "201 Created",
+					StatusCode: 201,
+					Body:       io.NopCloser(bytes.NewReader([]byte(""))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			err := c.UploadBlob(api.UploadBlobConfig{Digest: "sha256:abc", Contents: []byte("tasks: []")})
+			Expect(err).To(BeNil())
+		})
+	})
+
 	Describe("InitiateDispatch", func() {
 		It("builds the request and parses the response", func() {
 			body := struct {
@@ -319,4 +405,388 @@ var _ = Describe("API Client", func() {
 			Expect(result.Arch).To(Equal("quantum"))
 		})
 	})
+
+	Describe("ListBaseLayers", func() {
+		It("builds the request and parses the response", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.Method).To(Equal(http.MethodGet))
+				Expect(req.URL.Path).To(Equal("/mint/api/base_layers"))
+
+				body := `{"base_layers": [{"os": "ubuntu 22.04", "tag": "1.1", "arches": ["x86_64", "arm64"], "default": true}, {"os": "ubuntu 20.04", "tag": "1.0", "arches": ["x86_64"], "default": false, "deprecated_on": "2025-01-01", "end_of_life_on": "2025-06-30"}]}`
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			result, err := c.ListBaseLayers()
+			Expect(err).To(BeNil())
+			Expect(result.BaseLayers).To(Equal([]api.BaseLayer{
+				{Os: "ubuntu 22.04", Tag: "1.1", Arches: []string{"x86_64", "arm64"}, Default: true},
+				{Os: "ubuntu 20.04", Tag: "1.0", Arches: []string{"x86_64"}, DeprecatedOn: "2025-01-01", EndOfLifeOn: "2025-06-30"},
+			}))
+		})
+	})
+
+	Describe("GetBaseLayerMigration", func() {
+		It("builds the request and parses the response", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/mint/api/base_layers/migration"))
+				Expect(req.URL.Query().Get("from")).To(Equal("ubuntu 22.04"))
+				Expect(req.URL.Query().Get("to")).To(Equal("ubuntu 24.04"))
+
+				body := `{"incompatibilities": [{"leaf": "mint/install-python", "leaf_versions": "< 2.0.0", "message": "Python 2 is not available"}]}`
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			result, err := c.GetBaseLayerMigration(api.GetBaseLayerMigrationConfig{FromOs: "ubuntu 22.04", ToOs: "ubuntu 24.04"})
+			Expect(err).To(BeNil())
+			Expect(result.Incompatibilities).To(Equal([]api.BaseLayerIncompatibility{
+				{Leaf: "mint/install-python", LeafVersions: "< 2.0.0", Message: "Python 2 is not available"},
+			}))
+		})
+	})
+
+	Describe("ResolveLeafDigests", func() {
+		It("builds the request and parses the response", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/mint/api/leaves/digests"))
+				Expect(req.Method).To(Equal(http.MethodPost))
+				reqBody, err := io.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(reqBody)).To(Equal(`{"leaves":[{"name":"mint/install-node","version":"1.2.3"}]}`))
+
+				body := `{"leaves": [{"name": "mint/install-node", "version": "1.2.3", "digest": "sha256:abc"}]}`
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			result, err := c.ResolveLeafDigests(api.ResolveLeafDigestsConfig{
+				Leaves: []api.LeafReference{{Name: "mint/install-node", Version: "1.2.3"}},
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Leaves).To(Equal([]api.LeafDigest{{Name: "mint/install-node", Version: "1.2.3", Digest: "sha256:abc"}}))
+		})
+	})
+
+	Describe("GetLeafReleases", func() {
+		It("builds the request and parses the response", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/mint/api/leaves/mint/install-node/releases"))
+				Expect(req.Method).To(Equal(http.MethodGet))
+				Expect(req.URL.Query().Get("from")).To(Equal("1.0.0"))
+				Expect(req.URL.Query().Get("to")).To(Equal("2.0.0"))
+
+				body := `{"releases": [{"version": "2.0.0", "notes": "Rewritten", "removed_parameters": ["node-version-file"]}]}`
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			result, err := c.GetLeafReleases(api.GetLeafReleasesConfig{
+				Leaf:        "mint/install-node",
+				FromVersion: "1.0.0",
+				ToVersion:   "2.0.0",
+			})
+			Expect(err).To(BeNil())
+			Expect(result.Releases).To(Equal([]api.LeafRelease{
+				{Version: "2.0.0", Notes: "Rewritten", RemovedParameters: []string{"node-version-file"}},
+			}))
+		})
+	})
+
+	Describe("SearchLeaves", func() {
+		It("builds the request and parses the response", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/mint/api/leaves/search"))
+				Expect(req.URL.Query().Get("q")).To(Equal("install node"))
+
+				body := `{"leaves": [{"name": "mint/install-node", "description": "Install Node.js", "latest_version": "1.2.3"}]}`
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			result, err := c.SearchLeaves(api.SearchLeavesConfig{Query: "install node"})
+			Expect(err).To(BeNil())
+			Expect(result.Leaves).To(Equal([]api.LeafSummary{
+				{Name: "mint/install-node", Description: "Install Node.js", LatestVersion: "1.2.3"},
+			}))
+		})
+	})
+
+	Describe("GetLeaf", func() {
+		It("requests the given version", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				Expect(req.URL.Path).To(Equal("/mint/api/leaves/mint/install-node"))
+				Expect(req.URL.Query().Get("version")).To(Equal("1.0.0"))
+
+				body := `{"name": "mint/install-node", "version": "1.0.0", "parameters": [{"name": "node-version", "required": true}]}`
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(bytes.NewReader([]byte(body))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			result, err := c.GetLeaf(api.GetLeafConfig{Name: "mint/install-node", Version: "1.0.0"})
+			Expect(err).To(BeNil())
+			Expect(result.Version).To(Equal("1.0.0"))
+			Expect(result.Parameters).To(Equal([]api.LeafParameter{{Name: "node-version", Required: true}}))
+		})
+
+		It("returns a not found error for unknown leaves", func() {
+			roundTrip := func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "404 Not Found",
+					StatusCode: 404,
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{}`))),
+				}, nil
+			}
+
+			c := api.NewClientWithRoundTrip(roundTrip)
+
+			_, err := c.GetLeaf(api.GetLeafConfig{Name: "mint/missing"})
+			Expect(errors.Is(err, errors.ErrNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("caching", func() {
+		var tmp string
+		var cache *apicache.Cache
+		var requests []*http.Request
+		var respond func(req *http.Request) (*http.Response, error)
+		var c api.Client
+
+		BeforeEach(func() {
+			var err error
+			tmp, err = os.MkdirTemp(os.TempDir(), "api-cache")
+			Expect(err).NotTo(HaveOccurred())
+
+			cache, err = apicache.New(tmp)
+			Expect(err).NotTo(HaveOccurred())
+
+			requests = nil
+			respond = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Header:     http.Header{"Etag": []string{`"v1"`}},
+					Body:       io.NopCloser(bytes.NewReader([]byte(`{"latest_major": {"mint/install-node": "1.2.3"}}`))),
+				}, nil
+			}
+
+			c = api.NewClientWithRoundTrip(func(req *http.Request) (*http.Response, error) {
+				requests = append(requests, req)
+				return respond(req)
+			}).WithCache(cache, "cloud.rwx.com")
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(tmp)).To(Succeed())
+		})
+
+		expireCache := func() {
+			files, err := filepath.Glob(filepath.Join(tmp, "*.json"))
+			Expect(err).NotTo(HaveOccurred())
+			for _, file := range files {
+				contents, err := os.ReadFile(file)
+				Expect(err).NotTo(HaveOccurred())
+				entry := apicache.Entry{}
+				Expect(json.Unmarshal(contents, &entry)).To(Succeed())
+				entry.StoredAt = entry.StoredAt.Add(-2 * time.Hour)
+				contents, err = json.Marshal(entry)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(file, contents, 0o644)).To(Succeed())
+			}
+		}
+
+		It("reuses fresh responses", func() {
+			result, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.LatestMajor).To(HaveKeyWithValue("mint/install-node", "1.2.3"))
+
+			result, err = c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.LatestMajor).To(HaveKeyWithValue("mint/install-node", "1.2.3"))
+			Expect(requests).To(HaveLen(1))
+		})
+
+		It("revalidates stale responses with their ETag", func() {
+			_, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			expireCache()
+
+			respond = func(req *http.Request) (*http.Response, error) {
+				Expect(req.Header.Get("If-None-Match")).To(Equal(`"v1"`))
+				return &http.Response{
+					Status:     "304 Not Modified",
+					StatusCode: 304,
+					Body:       io.NopCloser(bytes.NewReader([]byte{})),
+				}, nil
+			}
+
+			result, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.LatestMajor).To(HaveKeyWithValue("mint/install-node", "1.2.3"))
+			Expect(requests).To(HaveLen(2))
+
+			// The revalidated response is fresh again
+			_, err = c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requests).To(HaveLen(2))
+		})
+
+		It("doesn't share responses between access tokens", func() {
+			client := func(token string) api.Client {
+				return api.NewClientWithRoundTrip(func(req *http.Request) (*http.Response, error) {
+					requests = append(requests, req)
+					return respond(req)
+				}).WithCache(cache, api.CacheNamespace("cloud.rwx.com", token))
+			}
+
+			_, err := client("token-a").GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			_, err = client("token-b").GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requests).To(HaveLen(2))
+
+			_, err = client("token-a").GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requests).To(HaveLen(2))
+		})
+
+		It("caches base layer resolutions per request", func() {
+			respond = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "200 OK",
+					StatusCode: 200,
+					Body:       io.NopCloser(strings.NewReader(`{"os": "ubuntu 22.04", "tag": "1.0", "arch": "x86_64"}`)),
+				}, nil
+			}
+
+			result, err := c.ResolveBaseLayer(api.ResolveBaseLayerConfig{Os: "ubuntu 22.04", Arch: "x86_64"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Tag).To(Equal("1.0"))
+
+			_, err = c.ResolveBaseLayer(api.ResolveBaseLayerConfig{Os: "ubuntu 22.04", Arch: "x86_64"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = c.ResolveBaseLayer(api.ResolveBaseLayerConfig{Os: "ubuntu 24.04", Arch: "x86_64"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requests).To(HaveLen(2))
+		})
+
+		It("falls back to stale responses when the API can't be reached", func() {
+			_, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			expireCache()
+
+			respond = func(req *http.Request) (*http.Response, error) {
+				return nil, api.ErrOffline
+			}
+
+			result, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.LatestMajor).To(HaveKeyWithValue("mint/install-node", "1.2.3"))
+		})
+
+		It("warns when falling back to stale responses on network errors", func() {
+			stderr := &strings.Builder{}
+			c = c.WithStderr(stderr)
+
+			_, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			expireCache()
+
+			respond = func(req *http.Request) (*http.Response, error) {
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			}
+
+			result, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.LatestMajor).To(HaveKeyWithValue("mint/install-node", "1.2.3"))
+			Expect(stderr.String()).To(Equal("Warning: the Mint API can't be reached, using a response cached 2h0m0s ago\n"))
+		})
+
+		It("doesn't fall back to stale responses when the request fails otherwise", func() {
+			_, err := c.GetLeafVersions()
+			Expect(err).NotTo(HaveOccurred())
+			expireCache()
+
+			respond = func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("unable to retrieve access token")
+			}
+			_, err = c.GetLeafVersions()
+			Expect(err).To(MatchError("HTTP request failed: unable to retrieve access token"))
+
+			respond = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					Status:     "401 Unauthorized",
+					StatusCode: 401,
+					Body:       io.NopCloser(strings.NewReader(`{"error": "unauthorized"}`)),
+				}, nil
+			}
+			_, err = c.GetLeafVersions()
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("only reads the access token once a response is cached", func() {
+			backend := &countingBackend{}
+			client, err := api.NewClient(api.Config{Host: "cloud.rwx.com", AccessTokenBackend: backend, Cache: cache, Offline: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backend.gets).To(Equal(0))
+
+			_, err = client.GetLeafVersions()
+			Expect(err).To(HaveOccurred())
+			_, err = client.GetLeafVersions()
+			Expect(err).To(HaveOccurred())
+			Expect(backend.gets).To(Equal(1))
+		})
+
+		It("errors when offline without a cached response", func() {
+			respond = func(req *http.Request) (*http.Response, error) {
+				return nil, api.ErrOffline
+			}
+
+			_, err := c.GetLeafVersions()
+			Expect(err).To(MatchError("no cached response is available: unable to call the Mint API in offline mode"))
+		})
+	})
 })
+
+type countingBackend struct {
+	gets int
+}
+
+func (b *countingBackend) Get() (string, error) {
+	b.gets++
+	return "token", nil
+}
+
+func (b *countingBackend) Set(token string) error {
+	return nil
+}
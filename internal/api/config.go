@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/rwx-research/mint-cli/internal/accesstoken"
+	"github.com/rwx-research/mint-cli/internal/apicache"
 	"github.com/rwx-research/mint-cli/internal/errors"
 	"github.com/rwx-research/mint-cli/internal/messages"
 )
@@ -14,6 +16,12 @@ type Config struct {
 	Host               string
 	AccessToken        string
 	AccessTokenBackend accesstoken.Backend
+	// When set, leaf versions and base layer resolutions are cached on disk
+	Cache *apicache.Cache
+	// When set, no requests are made and only cached responses are used
+	Offline bool
+	// When set, warnings such as the use of stale cached responses are written to it
+	Stderr io.Writer
 }
 
 func (c Config) Validate() error {
@@ -33,6 +41,37 @@ type InitiateRunConfig struct {
 	UseCache                 bool                      `json:"use_cache"`
 }
 
+// FindMissingBlobsConfig lists the digests of the files of a run, eg. sha256:abc.
+type FindMissingBlobsConfig struct {
+	Digests []string `json:"digests"`
+}
+
+func (c FindMissingBlobsConfig) Validate() error {
+	if len(c.Digests) == 0 {
+		return errors.New("no digests")
+	}
+
+	return nil
+}
+
+// FindMissingBlobsResult lists the digests of the blobs which need to be uploaded.
+type FindMissingBlobsResult struct {
+	Missing []string `json:"missing"`
+}
+
+type UploadBlobConfig struct {
+	Digest   string
+	Contents []byte
+}
+
+func (c UploadBlobConfig) Validate() error {
+	if c.Digest == "" {
+		return errors.New("no digest")
+	}
+
+	return nil
+}
+
 type InitializationParameter struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
@@ -203,8 +242,160 @@ type SetSecretsInVaultResult struct {
 }
 
 type LeafVersionsResult struct {
-	LatestMajor map[string]string            `json:"latest_major"`
-	LatestMinor map[string]map[string]string `json:"latest_minor"`
+	LatestMajor map[string]string               `json:"latest_major"`
+	LatestMinor map[string]map[string]string    `json:"latest_minor"`
+	Versions    map[string][]LeafVersionDetails `json:"versions,omitempty"`
+}
+
+type LeafVersionDetails struct {
+	Version     string    `json:"version"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type ResolveLeafVersionsConfig struct {
+	// Leaves that aren't in the public registry, eg. leaves of the authenticated organization
+	Leaves []string `json:"leaves"`
+}
+
+func (c ResolveLeafVersionsConfig) Validate() error {
+	if len(c.Leaves) == 0 {
+		return errors.New("at least one leaf must be provided")
+	}
+
+	return nil
+}
+
+type ResolveLeafGitRefConfig struct {
+	Repository string `json:"repository"`
+	// When empty, the default branch of the repository is resolved
+	Ref string `json:"ref,omitempty"`
+}
+
+func (c ResolveLeafGitRefConfig) Validate() error {
+	if c.Repository == "" {
+		return errors.New("a repository must be provided")
+	}
+
+	return nil
+}
+
+type ResolveLeafGitRefResult struct {
+	Sha string `json:"sha"`
+}
+
+type GetLeafReleasesConfig struct {
+	Leaf        string
+	FromVersion string
+	ToVersion   string
+}
+
+func (c GetLeafReleasesConfig) Validate() error {
+	if c.Leaf == "" {
+		return errors.New("a leaf must be provided")
+	}
+
+	if c.ToVersion == "" {
+		return errors.New("a version to compare to must be provided")
+	}
+
+	return nil
+}
+
+type LeafRelease struct {
+	Version           string   `json:"version"`
+	Notes             string   `json:"notes"`
+	Deprecations      []string `json:"deprecations"`
+	AddedParameters   []string `json:"added_parameters"`
+	RemovedParameters []string `json:"removed_parameters"`
+	ChangedParameters []string `json:"changed_parameters"`
+}
+
+func (r LeafRelease) ChangesParameters() bool {
+	return len(r.AddedParameters) > 0 || len(r.RemovedParameters) > 0 || len(r.ChangedParameters) > 0
+}
+
+type LeafReleasesResult struct {
+	// Releases after FromVersion up to and including ToVersion, newest first
+	Releases []LeafRelease `json:"releases"`
+}
+
+type SearchLeavesConfig struct {
+	Query string
+}
+
+type LeafSummary struct {
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	LatestVersion string `json:"latest_version"`
+}
+
+type SearchLeavesResult struct {
+	Leaves []LeafSummary `json:"leaves"`
+}
+
+type GetLeafConfig struct {
+	Name string
+	// When empty, the latest version of the leaf is returned
+	Version string
+}
+
+func (c GetLeafConfig) Validate() error {
+	if c.Name == "" {
+		return errors.New("a leaf must be provided")
+	}
+
+	return nil
+}
+
+type LeafParameter struct {
+	Name        string  `json:"name"`
+	Required    bool    `json:"required"`
+	Default     *string `json:"default,omitempty"`
+	Description string  `json:"description"`
+}
+
+type LeafOutput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type LeafDetails struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Version     string          `json:"version"`
+	Versions    []string        `json:"versions"`
+	Parameters  []LeafParameter `json:"parameters"`
+	Outputs     []LeafOutput    `json:"outputs"`
+	Example     string          `json:"example"`
+	// The architectures the leaf supports. Leaves without any support every architecture.
+	Arches []string `json:"arches,omitempty"`
+}
+
+type LeafReference struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type ResolveLeafDigestsConfig struct {
+	Leaves []LeafReference `json:"leaves"`
+}
+
+func (c ResolveLeafDigestsConfig) Validate() error {
+	if len(c.Leaves) == 0 {
+		return errors.New("no leaves")
+	}
+
+	return nil
+}
+
+type LeafDigest struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Digest  string `json:"digest"`
+}
+
+type ResolveLeafDigestsResult struct {
+	Leaves []LeafDigest `json:"leaves"`
 }
 
 type resolveBaseLayerSpec struct {
@@ -215,3 +406,44 @@ type resolveBaseLayerSpec struct {
 
 type ResolveBaseLayerConfig = resolveBaseLayerSpec
 type ResolveBaseLayerResult = resolveBaseLayerSpec
+
+// BaseLayer is an operating system image and tag that runs can use as their base. Dates are
+// formatted as YYYY-MM-DD and empty when the base layer isn't deprecated.
+type BaseLayer struct {
+	Os           string   `json:"os"`
+	Tag          string   `json:"tag"`
+	Arches       []string `json:"arches"`
+	Default      bool     `json:"default"`
+	DeprecatedOn string   `json:"deprecated_on,omitempty"`
+	EndOfLifeOn  string   `json:"end_of_life_on,omitempty"`
+}
+
+type ListBaseLayersResult struct {
+	BaseLayers []BaseLayer `json:"base_layers"`
+}
+
+type GetBaseLayerMigrationConfig struct {
+	FromOs string
+	ToOs   string
+}
+
+func (c GetBaseLayerMigrationConfig) Validate() error {
+	if c.FromOs == "" || c.ToOs == "" {
+		return errors.New("the operating systems to migrate between must be provided")
+	}
+
+	return nil
+}
+
+// BaseLayerIncompatibility describes a leaf or a command that is known not to work after migrating
+// between two operating systems. Command is a regular expression matched against the `run` of tasks.
+type BaseLayerIncompatibility struct {
+	Leaf         string `json:"leaf,omitempty"`
+	LeafVersions string `json:"leaf_versions,omitempty"`
+	Command      string `json:"command,omitempty"`
+	Message      string `json:"message"`
+}
+
+type BaseLayerMigrationResult struct {
+	Incompatibilities []BaseLayerIncompatibility `json:"incompatibilities"`
+}
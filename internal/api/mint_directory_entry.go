@@ -1,11 +1,16 @@
 package api
 
+import "encoding/json"
+
 type MintDirectoryEntry struct {
 	OriginalPath string `json:"-"`
 	Path         string `json:"path"`
 	Type         string `json:"type"`
 	Permissions  uint32 `json:"permissions"`
 	FileContents string `json:"file_contents"`
+	// Digest references the contents of a file uploaded as a blob, eg. sha256:abc. Entries with a
+	// digest are sent without their contents.
+	Digest string `json:"digest,omitempty"`
 }
 
 func (e MintDirectoryEntry) IsDir() bool {
@@ -15,3 +20,17 @@ func (e MintDirectoryEntry) IsDir() bool {
 func (e MintDirectoryEntry) IsFile() bool {
 	return e.Type == "file"
 }
+
+func (e MintDirectoryEntry) MarshalJSON() ([]byte, error) {
+	type entry MintDirectoryEntry
+	if e.Digest == "" {
+		return json.Marshal(entry(e))
+	}
+
+	return json.Marshal(struct {
+		Path        string `json:"path"`
+		Type        string `json:"type"`
+		Permissions uint32 `json:"permissions"`
+		Digest      string `json:"digest"`
+	}{e.Path, e.Type, e.Permissions, e.Digest})
+}
@@ -0,0 +1,13 @@
+package apicache_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestAPICache(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "API Cache Suite")
+}
@@ -0,0 +1,97 @@
+package apicache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rwx-research/mint-cli/internal/errors"
+	"github.com/rwx-research/mint-cli/internal/fs"
+)
+
+// Cache stores API responses on disk so they can be reused across invocations, and while offline.
+type Cache struct {
+	Dir string
+}
+
+// Entry is a cached API response.
+type Entry struct {
+	ETag     string          `json:"etag,omitempty"`
+	StoredAt time.Time       `json:"stored_at"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// FreshFor reports whether the entry was stored less than ttl ago.
+func (e Entry) FreshFor(ttl time.Duration) bool {
+	return time.Since(e.StoredAt) < ttl
+}
+
+func New(dir string) (*Cache, error) {
+	dir, err := fs.ExpandTilde(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cache{Dir: dir}, nil
+}
+
+// Get returns the entry stored for the given key. Missing and unreadable entries are
+// reported as nil, as the cache can always be rebuilt.
+func (c *Cache) Get(key string) *Entry {
+	contents, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil
+	}
+
+	entry := Entry{}
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		return nil
+	}
+
+	return &entry
+}
+
+func (c *Cache) Set(key string, entry Entry) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return errors.Wrapf(err, "unable to create %q", c.Dir)
+	}
+
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "unable to encode cache entry")
+	}
+
+	// Write to a temporary file first so concurrent invocations never read a partial entry
+	tmp, err := os.CreateTemp(c.Dir, ".entry-*")
+	if err != nil {
+		return errors.Wrapf(err, "unable to create a file in %q", c.Dir)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(encoded); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, "unable to write %q", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to write %q", tmp.Name())
+	}
+
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// Clear removes every cached entry.
+func (c *Cache) Clear() error {
+	if err := os.RemoveAll(c.Dir); err != nil {
+		return errors.Wrapf(err, "unable to remove %q", c.Dir)
+	}
+
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,73 @@
+package apicache_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rwx-research/mint-cli/internal/apicache"
+)
+
+var _ = Describe("Cache", func() {
+	var tmp string
+	var cache *apicache.Cache
+
+	BeforeEach(func() {
+		var err error
+		tmp, err = os.MkdirTemp(os.TempDir(), "apicache")
+		Expect(err).NotTo(HaveOccurred())
+
+		cache, err = apicache.New(filepath.Join(tmp, "cache"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(tmp)).To(Succeed())
+	})
+
+	It("returns nil for missing entries", func() {
+		Expect(cache.Get("GET /mint/api/leaves")).To(BeNil())
+	})
+
+	It("stores and returns entries", func() {
+		storedAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		entry := apicache.Entry{ETag: `"abc"`, StoredAt: storedAt, Body: json.RawMessage(`{"latest_major":{}}`)}
+
+		Expect(cache.Set("GET /mint/api/leaves", entry)).To(Succeed())
+
+		Expect(cache.Get("GET /mint/api/leaves")).To(Equal(&entry))
+		Expect(cache.Get("GET /mint/api/other")).To(BeNil())
+	})
+
+	It("ignores corrupt entries", func() {
+		Expect(cache.Set("key", apicache.Entry{StoredAt: time.Now(), Body: json.RawMessage(`{}`)})).To(Succeed())
+
+		files, err := filepath.Glob(filepath.Join(cache.Dir, "*.json"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+		Expect(os.WriteFile(files[0], []byte("{"), 0o644)).To(Succeed())
+
+		Expect(cache.Get("key")).To(BeNil())
+	})
+
+	It("clears all entries", func() {
+		Expect(cache.Set("key", apicache.Entry{StoredAt: time.Now(), Body: json.RawMessage(`{}`)})).To(Succeed())
+		Expect(cache.Clear()).To(Succeed())
+
+		Expect(cache.Get("key")).To(BeNil())
+		_, err := os.Stat(cache.Dir)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	Describe("Entry", func() {
+		It("is fresh until its TTL passes", func() {
+			entry := apicache.Entry{StoredAt: time.Now().Add(-30 * time.Minute)}
+			Expect(entry.FreshFor(time.Hour)).To(BeTrue())
+			Expect(entry.FreshFor(10 * time.Minute)).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rwx-research/mint-cli/internal/api"
+)
+
+// baseDeprecationWarnings remembers which deprecated bases a service has warned about, so each
+// run definition is only warned about once per process.
+type baseDeprecationWarnings struct {
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+func newBaseDeprecationWarnings() *baseDeprecationWarnings {
+	return &baseDeprecationWarnings{warned: make(map[string]bool)}
+}
+
+// warnAboutDeprecatedBases prints a warning for every run definition whose base is deprecated or
+// has reached its end of life. Deprecation metadata is best-effort: when it can't be fetched,
+// nothing is printed.
+func (s Service) warnAboutDeprecatedBases(mintFiles []*MintYAMLFile) {
+	if s.baseDeprecations == nil {
+		return
+	}
+
+	type fileBase struct {
+		path string
+		os   string
+		tag  string
+	}
+
+	bases := make([]fileBase, 0)
+	for _, file := range mintFiles {
+		if !file.Doc.IsRunDefinition() {
+			continue
+		}
+
+		base := fileBase{
+			path: file.Entry.OriginalPath,
+			os:   file.Doc.TryReadStringAtPath("$.base.os"),
+			tag:  file.Doc.TryReadStringAtPath("$.base.tag"),
+		}
+		if base.os != "" && base.tag != "" {
+			bases = append(bases, base)
+		}
+	}
+
+	if len(bases) == 0 {
+		return
+	}
+
+	s.baseDeprecations.mu.Lock()
+	defer s.baseDeprecations.mu.Unlock()
+
+	bases = slices.DeleteFunc(bases, func(base fileBase) bool {
+		return s.baseDeprecations.warned[fmt.Sprintf("%s %s %s", base.path, base.os, base.tag)]
+	})
+	if len(bases) == 0 {
+		return
+	}
+
+	result, err := s.APIClient.ListBaseLayers()
+	if err != nil {
+		return
+	}
+
+	for _, base := range bases {
+		s.baseDeprecations.warned[fmt.Sprintf("%s %s %s", base.path, base.os, base.tag)] = true
+
+		index := slices.IndexFunc(result.BaseLayers, func(baseLayer api.BaseLayer) bool {
+			return baseLayer.Os == base.os && baseLayer.Tag == base.tag
+		})
+		if index == -1 {
+			continue
+		}
+
+		baseLayer := result.BaseLayers[index]
+		if baseLayer.DeprecatedOn == "" && baseLayer.EndOfLifeOn == "" {
+			continue
+		}
+
+		s.outputBaseDeprecation(base.path, baseLayer, suggestBaseUpdate(base.path, baseLayer, result.BaseLayers))
+	}
+}
+
+func (s Service) outputBaseDeprecation(path string, baseLayer api.BaseLayer, suggestion string) {
+	w := s.Stderr
+	fmt.Fprintln(w, "========================================")
+
+	description := fmt.Sprintf("%s, tag %s", baseLayer.Os, baseLayer.Tag)
+	switch {
+	case baseLayer.EndOfLifeOn != "" && baseLayer.EndOfLifeOn <= time.Now().Format(time.DateOnly):
+		fmt.Fprintf(w, "The base layer of %s (%s) reached its end of life on %s.\n", relativePathFromWd(path), description, baseLayer.EndOfLifeOn)
+	case baseLayer.EndOfLifeOn != "":
+		fmt.Fprintf(w, "The base layer of %s (%s) is deprecated and reaches its end of life on %s.\n", relativePathFromWd(path), description, baseLayer.EndOfLifeOn)
+	default:
+		fmt.Fprintf(w, "The base layer of %s (%s) is deprecated.\n", relativePathFromWd(path), description)
+	}
+
+	fmt.Fprintln(w, "\nYou can update it with:")
+	fmt.Fprintf(w, "    %s\n", suggestion)
+	fmt.Fprintln(w, "========================================")
+	fmt.Fprintln(w)
+}
+
+// suggestBaseUpdate returns the command updating a run definition off a deprecated base layer.
+// When every tag of its operating system is deprecated, it migrates to the default base layer.
+func suggestBaseUpdate(path string, deprecated api.BaseLayer, baseLayers []api.BaseLayer) string {
+	command := fmt.Sprintf("mint update base %s", relativePathFromWd(path))
+
+	supportedOs := slices.ContainsFunc(baseLayers, func(baseLayer api.BaseLayer) bool {
+		return baseLayer.Os == deprecated.Os && baseLayer.DeprecatedOn == "" && baseLayer.EndOfLifeOn == ""
+	})
+	if supportedOs {
+		return command
+	}
+
+	index := slices.IndexFunc(baseLayers, func(baseLayer api.BaseLayer) bool {
+		return baseLayer.Default
+	})
+	if index == -1 {
+		return command
+	}
+
+	return fmt.Sprintf("mint update base --os %q %s", baseLayers[index].Os, relativePathFromWd(path))
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rwx-research/mint-cli/internal/api"
+)
+
+func outputBaseLayers(w io.Writer, baseLayers []api.BaseLayer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OS\tTAG\tARCH\tSTATUS")
+	for _, baseLayer := range baseLayers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", baseLayer.Os, baseLayer.Tag, strings.Join(baseLayer.Arches, ", "), baseLayerStatus(baseLayer))
+	}
+	tw.Flush()
+}
+
+func baseLayerStatus(baseLayer api.BaseLayer) string {
+	var status []string
+	if baseLayer.Default {
+		status = append(status, "default")
+	}
+	if baseLayer.DeprecatedOn != "" {
+		status = append(status, fmt.Sprintf("deprecated %s", baseLayer.DeprecatedOn))
+	}
+	if baseLayer.EndOfLifeOn != "" {
+		status = append(status, fmt.Sprintf("end of life %s", baseLayer.EndOfLifeOn))
+	}
+	return strings.Join(status, ", ")
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/rwx-research/mint-cli/internal/api"
+)
+
+// warnAboutBaseMigrations adds a warning to every run file migrated to another operating system
+// for each of its tasks calling a leaf or running a command known to be incompatible with it.
+func (s Service) warnAboutBaseMigrations(runFiles []BaseLayerRunFile, plan *UpdatePlan) []BaseLayerRunFile {
+	migrations := make(map[[2]string][]api.BaseLayerIncompatibility)
+
+	for i, runFile := range runFiles {
+		from, to := runFile.OriginalBase.Os, runFile.ResolvedBase.Os
+		if from == "" || from == to {
+			continue
+		}
+
+		incompatibilities, ok := migrations[[2]string{from, to}]
+		if !ok {
+			result, err := s.APIClient.GetBaseLayerMigration(api.GetBaseLayerMigrationConfig{FromOs: from, ToOs: to})
+			if err != nil {
+				fmt.Fprintf(s.Stderr, "Unable to check compatibility of run definitions with %s: %s\n", to, err)
+			} else {
+				incompatibilities = result.Incompatibilities
+			}
+			migrations[[2]string{from, to}] = incompatibilities
+		}
+
+		if len(incompatibilities) == 0 {
+			continue
+		}
+
+		doc, err := readRunFileDoc(runFile, plan)
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "Unable to check compatibility of %s with %s: %s\n", relativePathFromWd(runFile.OriginalPath), to, err)
+			continue
+		}
+
+		runFiles[i].Warnings = s.findBaseIncompatibilities(doc, incompatibilities)
+	}
+
+	return runFiles
+}
+
+func (s Service) findBaseIncompatibilities(doc *YAMLDoc, incompatibilities []api.BaseLayerIncompatibility) []string {
+	warnings := make([]string, 0)
+
+	nodePath := tasksPath(doc)
+	if nodePath == "" {
+		return warnings
+	}
+
+	_ = doc.ForEachNode(nodePath, func(task ast.Node) error {
+		var key, call, run string
+		for _, value := range mappingValues(task) {
+			switch value.Key.GetToken().Value {
+			case "key":
+				key = scalarText(value.Value)
+			case "call":
+				call = scalarText(value.Value)
+			case "run":
+				run = scalarText(value.Value)
+			}
+		}
+
+		for _, incompatibility := range incompatibilities {
+			if incompatibility.Leaf != "" && call != "" && s.leafMatchesIncompatibility(call, incompatibility) {
+				warnings = append(warnings, fmt.Sprintf("task %q calls %s: %s", key, call, incompatibility.Message))
+			}
+
+			if incompatibility.Command != "" && run != "" {
+				// Patterns come from the API, so invalid ones are ignored rather than failing the update
+				pattern, err := regexp.Compile(incompatibility.Command)
+				if err == nil && pattern.MatchString(run) {
+					warnings = append(warnings, fmt.Sprintf("task %q runs %q: %s", key, pattern.FindString(run), incompatibility.Message))
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return warnings
+}
+
+// leafMatchesIncompatibility reports whether a call refers to an incompatible leaf. Calls without
+// a version are considered incompatible, as they'll run the latest version.
+func (s Service) leafMatchesIncompatibility(call string, incompatibility api.BaseLayerIncompatibility) bool {
+	leafVersion := s.parseLeafVersion(call)
+	if leafVersion.Name != incompatibility.Leaf {
+		return false
+	}
+
+	if incompatibility.LeafVersions == "" || leafVersion.Version == "" {
+		return true
+	}
+
+	constraint, err := semver.NewConstraint(incompatibility.LeafVersions)
+	if err != nil {
+		return true
+	}
+
+	version, err := semver.NewVersion(leafVersion.Version)
+	if err != nil {
+		return true
+	}
+
+	return constraint.Check(version)
+}
+
+func scalarText(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.StringNode:
+		return n.Value
+	case *ast.LiteralNode:
+		return n.Value.Value
+	default:
+		return node.String()
+	}
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// maxInlineMintDirectorySize is the total size of files which can be sent in a run along with
+// their contents, when Mint doesn't support uploading them as blobs.
+const maxInlineMintDirectorySize = 5 * 1024 * 1024
+
+// blobDigest returns the address of the contents of a file, eg. sha256:abc.
+func blobDigest(contents string) string {
+	sum := sha256.Sum256([]byte(contents))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// uploadMintDirectoryBlobs references the files of the .mint directory by the digest of their
+// contents, uploading only the ones Mint doesn't have yet. When Mint doesn't support blobs, the
+// entries are sent with their contents as long as they're within the size limit of a run.
+func (s Service) uploadMintDirectoryBlobs(entries []MintDirectoryEntry, listFiles io.Writer) ([]MintDirectoryEntry, error) {
+	blobs := make(map[string]string)
+	digests := make([]string, 0)
+	referenced := make([]MintDirectoryEntry, len(entries))
+	for i, entry := range entries {
+		referenced[i] = entry
+		if !entry.IsFile() {
+			continue
+		}
+
+		digest := blobDigest(entry.FileContents)
+		if _, ok := blobs[digest]; !ok {
+			blobs[digest] = entry.FileContents
+			digests = append(digests, digest)
+		}
+		referenced[i].Digest = digest
+	}
+
+	if len(digests) == 0 {
+		return entries, nil
+	}
+
+	missing, err := s.APIClient.FindMissingBlobs(api.FindMissingBlobsConfig{Digests: digests})
+	if err != nil {
+		if !errors.Is(err, api.ErrNotFound) {
+			return nil, errors.Wrap(err, "unable to determine which files of the .mint directory to upload")
+		}
+
+		if err := mintDirectorySizeError(".mint", entries, maxInlineMintDirectorySize); err != nil {
+			return nil, fmt.Errorf("%w\n\nFiles can be excluded by listing them in .mint/%s", err, mintIgnoreFile)
+		}
+		return entries, nil
+	}
+
+	if listFiles != nil {
+		fmt.Fprintf(listFiles, "Uploading %d of %d files of the .mint directory\n", len(missing.Missing), len(digests))
+	}
+
+	for _, digest := range missing.Missing {
+		if _, ok := blobs[digest]; !ok {
+			return nil, fmt.Errorf("Mint requested an unknown blob %q", digest)
+		}
+	}
+
+	errs := new(errgroup.Group)
+	errs.SetLimit(8)
+	for _, digest := range missing.Missing {
+		errs.Go(func() error {
+			if err := s.APIClient.UploadBlob(api.UploadBlobConfig{Digest: digest, Contents: []byte(blobs[digest])}); err != nil {
+				return errors.Wrapf(err, "unable to upload blob %q", digest)
+			}
+			return nil
+		})
+	}
+	if err := errs.Wait(); err != nil {
+		return nil, err
+	}
+
+	return referenced, nil
+}
@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"fmt"
 	"io"
+	"slices"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/rwx-research/mint-cli/internal/accesstoken"
 	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/apicache"
 	"github.com/rwx-research/mint-cli/internal/errors"
 	"github.com/rwx-research/mint-cli/internal/versions"
 )
@@ -50,6 +54,7 @@ func (c DebugTaskConfig) Validate() error {
 }
 
 type InitiateRunConfig struct {
+	Gitignore      bool
 	InitParameters map[string]string
 	Json           bool
 	MintDirectory  string
@@ -57,6 +62,8 @@ type InitiateRunConfig struct {
 	NoCache        bool
 	TargetedTasks  []string
 	Title          string
+	// Lists the files of the .mint directory included in and excluded from the run, along with their sizes
+	ListFiles bool
 }
 
 func (c InitiateRunConfig) Validate() error {
@@ -153,6 +160,18 @@ func (c WhoamiConfig) Validate() error {
 	return nil
 }
 
+type ClearCacheConfig struct {
+	Cache *apicache.Cache
+}
+
+func (c ClearCacheConfig) Validate() error {
+	if c.Cache == nil {
+		return errors.New("a cache must be provided")
+	}
+
+	return nil
+}
+
 type SetSecretsInVaultConfig struct {
 	Secrets []string
 	Vault   string
@@ -174,6 +193,12 @@ func (c SetSecretsInVaultConfig) Validate() error {
 type UpdateBaseConfig struct {
 	MintDirectory string
 	Files         []string
+	// Migrates run definitions to the given operating system, eg. "ubuntu 24.04"
+	Os string
+	// Moves run definitions to the given architecture, eg. "arm64"
+	Arch string
+	// When set, changes are recorded in the plan instead of being written.
+	Plan *UpdatePlan
 }
 
 func (c UpdateBaseConfig) Validate() error {
@@ -183,7 +208,11 @@ func (c UpdateBaseConfig) Validate() error {
 type UpdateLeavesConfig struct {
 	MintDirectory            string
 	Files                    []string
-	ReplacementVersionPicker func(versions api.LeafVersionsResult, leaf string, major string) (string, error)
+	ReplacementVersionPicker func(versions api.LeafVersionsResult, leaf string, current string) (string, error)
+	// Pins every leaf to the content digest of its version. Leaves that are already pinned stay pinned.
+	PinDigests bool
+	// When set, changes are recorded in the plan instead of being written.
+	Plan *UpdatePlan
 }
 
 func (c UpdateLeavesConfig) Validate() error {
@@ -194,6 +223,78 @@ func (c UpdateLeavesConfig) Validate() error {
 	return nil
 }
 
+type LockLeavesConfig struct {
+	MintDirectory string
+	Files         []string
+}
+
+func (c LockLeavesConfig) Validate() error {
+	return nil
+}
+
+type LockLeavesResult struct {
+	LockedLeaves map[string]string
+}
+
+type AddLeafConfig struct {
+	MintDirectory string
+	File          string
+	Leaf          string
+	Version       string
+	Key           string
+	Params        []string
+}
+
+func (c AddLeafConfig) Validate() error {
+	if c.Leaf == "" {
+		return errors.New("a leaf must be provided")
+	}
+
+	return nil
+}
+
+type AddLeafResult struct {
+	File string
+	Key  string
+	Call string
+}
+
+type SearchLeavesConfig struct {
+	Query string
+	Json  bool
+}
+
+func (c SearchLeavesConfig) Validate() error {
+	return nil
+}
+
+type ShowLeafConfig struct {
+	Leaf    string
+	Version string
+	Json    bool
+}
+
+func (c ShowLeafConfig) Validate() error {
+	if c.Leaf == "" {
+		return errors.New("a leaf must be provided")
+	}
+
+	return nil
+}
+
+type ReportUpdatePlanConfig struct {
+	Plan *UpdatePlan
+	Json bool
+}
+
+func (c ReportUpdatePlanConfig) Validate() error {
+	if c.Plan == nil {
+		return errors.New("a plan must be provided")
+	}
+
+	return nil
+}
+
 type ResolveBaseConfig struct {
 	MintDirectory string
 	Files         []string
@@ -206,6 +307,151 @@ func (c ResolveBaseConfig) Validate() error {
 	return nil
 }
 
+type ListBaseLayersConfig struct {
+	Json bool
+}
+
+func (c ListBaseLayersConfig) Validate() error {
+	return nil
+}
+
+type FormatConfig struct {
+	MintDirectory string
+	Files         []string
+	// Prints a diff of the files that aren't formatted instead of formatting them.
+	Check bool
+}
+
+func (c FormatConfig) Validate() error {
+	return nil
+}
+
+type FormatResult struct {
+	// The files that were formatted, or that need to be formatted when checking.
+	ChangedFiles []string
+}
+
+type EvaluateConfig struct {
+	MintDirectory string
+	// An expression such as `init.branch || 'main'`, or a string with `${{ }}` expressions.
+	Expression     string
+	InitParameters map[string]string
+}
+
+func (c EvaluateConfig) Validate() error {
+	if c.Expression == "" {
+		return errors.New("an expression must be provided")
+	}
+
+	return nil
+}
+
+type EvaluateResult struct {
+	Value any
+}
+
+type ListTasksConfig struct {
+	MintDirectory  string
+	Files          []string
+	InitParameters map[string]string
+}
+
+func (c ListTasksConfig) Validate() error {
+	return nil
+}
+
+type ListTasksResult struct {
+	Tasks []TaskSummary
+}
+
+type TaskSummary struct {
+	File string
+	Key  string
+	// One of TaskKindRun, TaskKindLeaf or TaskKindEmbeddedRun, or empty for other tasks.
+	Kind string
+	// The called leaf or embedded run, with expressions evaluated when possible.
+	Call string
+}
+
+type RenderConfig struct {
+	MintDirectory  string
+	File           string
+	InitParameters map[string]string
+	Json           bool
+}
+
+func (c RenderConfig) Validate() error {
+	if c.File == "" {
+		return errors.New("the path to a run definition must be provided using the --file flag")
+	}
+
+	return nil
+}
+
+type RenderResult struct {
+	// The flattened run definition, as YAML or JSON.
+	Rendered string
+}
+
+type ImportConfig struct {
+	MintDirectory string
+	// One of ImportSources.
+	Source string
+	// The files to import; the CI configuration of the repository when empty.
+	Files []string
+	// The repository cloned by the imported run definitions, eg. rwx-research/mint-cli.
+	Repository string
+	// The vault secrets are read from.
+	Vault string
+	// Overwrites run definitions that already exist.
+	Force bool
+}
+
+func (c ImportConfig) Validate() error {
+	if !slices.Contains(ImportSources, c.Source) {
+		return fmt.Errorf("unknown source %q, expected one of %s", c.Source, strings.Join(ImportSources, ", "))
+	}
+
+	return nil
+}
+
+type ImportResult struct {
+	ImportedFiles []ImportedFile
+}
+
+type ImportedFile struct {
+	Source string
+	Path   string
+	// What couldn't be translated, marked with TODO comments in the run definition.
+	Problems []ImportProblem
+}
+
+type ImportProblem struct {
+	// The line of the imported file, or 0 when it's about the file as a whole.
+	Line    int
+	Message string
+}
+
+type InitConfig struct {
+	MintDirectory string
+	// The repository cloned by the run definition, eg. rwx-research/mint-cli; the GitHub
+	// repository of the origin remote when empty.
+	Repository string
+	// Overwrites the run definition if it already exists.
+	Force bool
+}
+
+func (c InitConfig) Validate() error {
+	return nil
+}
+
+type InitResult struct {
+	// The run definition written.
+	Path string
+	// The languages detected in the project, eg. Go.
+	Languages []string
+}
+
 type BaseLayerSpec struct {
 	Os   string `yaml:"os"`
 	Tag  string `yaml:"tag"`
@@ -229,19 +475,30 @@ func (b BaseLayerSpec) Equal(other BaseLayerSpec) bool {
 		return false
 	}
 
-	arch1 := b.Arch
-	if arch1 == "" {
-		arch1 = DefaultArch
-	}
-	arch2 := other.Arch
-	if arch2 == "" {
-		arch2 = DefaultArch
-	}
-	if arch1 != arch2 {
-		return false
+	return b.SameArch(other)
+}
+
+// SameArch reports whether both specs target the same architecture, treating an empty arch as
+// the default one.
+func (b BaseLayerSpec) SameArch(other BaseLayerSpec) bool {
+	return b.arch() == other.arch()
+}
+
+func (b BaseLayerSpec) arch() string {
+	if b.Arch == "" {
+		return DefaultArch
 	}
+	return b.Arch
+}
 
-	return true
+// Describe returns a human readable description of the spec, eg. "ubuntu 24.04, tag 1.0, arch arm64".
+// The default arch is omitted.
+func (b BaseLayerSpec) Describe() string {
+	description := fmt.Sprintf("%s, tag %s", b.Os, b.Tag)
+	if b.arch() != DefaultArch {
+		description = fmt.Sprintf("%s, arch %s", description, b.Arch)
+	}
+	return description
 }
 
 func (b BaseLayerSpec) Merge(other BaseLayerSpec) BaseLayerSpec {
@@ -272,7 +529,11 @@ type BaseLayerRunFile struct {
 	OriginalBase BaseLayerSpec
 	ResolvedBase BaseLayerSpec
 	OriginalPath string
-	Error        error
+	// The index of the run definition in a multi-document file
+	DocumentIndex int
+	Error         error
+	// Leaves and commands known to be incompatible with the operating system the run file was migrated to
+	Warnings []string
 }
 
 func (rf BaseLayerRunFile) HasChanges() bool {
@@ -292,6 +553,8 @@ type ResolveLeavesConfig struct {
 	MintDirectory       string
 	Files               []string
 	LatestVersionPicker func(versions api.LeafVersionsResult, leaf string, _ string) (string, error)
+	// Pins every leaf to the content digest of its version, eg. "mint/install-node 1.2.3@sha256:..."
+	PinDigests bool
 }
 
 func (c ResolveLeavesConfig) PickLatestVersion(versions api.LeafVersionsResult, leaf string) (string, error) {
@@ -306,6 +569,20 @@ func (c ResolveLeavesConfig) Validate() error {
 	return nil
 }
 
+// resolveOrUpdateLeavesConfig configures how the leaves called from a set of files are resolved
+// or updated.
+type resolveOrUpdateLeavesConfig struct {
+	// Picks a new version for leaves that already have one, rather than only for leaves without one
+	Update bool
+	// Pins every leaf to the content digest of its version. Leaves that are already pinned stay pinned.
+	PinDigests bool
+	// Pins git leaves to the commit their ref points to
+	PinGitLeaves  bool
+	VersionPicker func(versions api.LeafVersionsResult, leaf string, current string) (string, error)
+	// When set, changes are recorded in the plan instead of being written.
+	Plan *UpdatePlan
+}
+
 type ResolveLeavesResult struct {
 	ResolvedLeaves map[string]string
 }
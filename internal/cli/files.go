@@ -1,23 +1,38 @@
 package cli
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	iofs "io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/rwx-research/mint-cli/internal/api"
 	"github.com/rwx-research/mint-cli/internal/errors"
 	"github.com/rwx-research/mint-cli/internal/fs"
+	"github.com/rwx-research/mint-cli/internal/ignore"
 )
 
+// maxMintDirectorySize is the total size of files which can be read from the .mint directory. Their
+// contents are uploaded as blobs, which is why it's larger than the size limit of a run.
+const maxMintDirectorySize = 100 * 1024 * 1024
+
+// mintIgnoreFile is the file listing, in the syntax of .gitignore, the files of the .mint
+// directory which aren't sent in a run.
+const mintIgnoreFile = ".mintignore"
+
 type MintDirectoryEntry = api.MintDirectoryEntry
 type TaskDefinition = api.TaskDefinition
 
 type MintYAMLFile struct {
 	Entry MintDirectoryEntry
 	Doc   *YAMLDoc
+	// The index of Doc in a multi-document file
+	DocumentIndex int
 }
 
 // findMintDirectoryPath returns a configured directory, if it exists, or walks up
@@ -89,32 +104,92 @@ func getFileOrDirectoryEntries(files []string, mintDir string) ([]MintDirectoryE
 	if len(files) != 0 {
 		return mintDirectoryEntriesFromPaths(files)
 	} else if mintDir != "" {
-		return mintDirectoryEntries(mintDir)
+		return mintDirectoryEntries(mintDir, mintDirectoryOptions{})
 	}
 	return make([]MintDirectoryEntry, 0), nil
 }
 
+// mintDirectoryOptions configures how the .mint directory is read.
+type mintDirectoryOptions struct {
+	// gitignore excludes the files ignored by the .gitignore files of the project and of the .mint
+	// directory, along with those listed in .mint/.mintignore
+	gitignore bool
+	// listFiles lists the included and excluded files along with their sizes, when set
+	listFiles io.Writer
+}
+
 // mintDirectoryEntriesFromPaths loads all the files in paths relative to the current working directory.
 func mintDirectoryEntriesFromPaths(paths []string) ([]MintDirectoryEntry, error) {
-	return readMintDirectoryEntries(paths, "")
+	return readMintDirectoryEntries(paths, "", nil, nil)
 }
 
-// mintDirectoryEntries loads all the files in the given dotMintPath relative to the parent of dotMintPath.
-func mintDirectoryEntries(dotMintPath string) ([]MintDirectoryEntry, error) {
-	return readMintDirectoryEntries([]string{dotMintPath}, dotMintPath)
+// mintDirectoryEntries loads all the files in the given dotMintPath relative to the parent of dotMintPath,
+// except for those excluded by .mint/.mintignore.
+func mintDirectoryEntries(dotMintPath string, opts mintDirectoryOptions) ([]MintDirectoryEntry, error) {
+	matcher, err := mintIgnoreMatcher(dotMintPath, opts.gitignore)
+	if err != nil {
+		return nil, err
+	}
+
+	return readMintDirectoryEntries([]string{dotMintPath}, dotMintPath, matcher, opts.listFiles)
 }
 
-func readMintDirectoryEntries(paths []string, relativeTo string) ([]MintDirectoryEntry, error) {
+// mintIgnoreMatcher returns a matcher of the paths under the parent of dotMintPath which are
+// excluded from the .mint directory. Patterns of .mint/.mintignore take precedence over those of
+// .gitignore files.
+func mintIgnoreMatcher(dotMintPath string, gitignore bool) (*ignore.Matcher, error) {
+	dotMintName := filepath.Base(dotMintPath)
+	matcher := new(ignore.Matcher)
+
+	if gitignore {
+		if err := matcher.AddFile(filepath.Join(filepath.Dir(dotMintPath), ".gitignore"), ".gitignore", ""); err != nil {
+			return nil, err
+		}
+		if err := matcher.AddFile(filepath.Join(dotMintPath, ".gitignore"), ".mint/.gitignore", dotMintName); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := matcher.AddFile(filepath.Join(dotMintPath, mintIgnoreFile), ".mint/"+mintIgnoreFile, dotMintName); err != nil {
+		return nil, err
+	}
+
+	return matcher, nil
+}
+
+func readMintDirectoryEntries(paths []string, relativeTo string, matcher *ignore.Matcher, listFiles io.Writer) ([]MintDirectoryEntry, error) {
 	entries := make([]MintDirectoryEntry, 0)
 	var totalSize int
 
 	for _, path := range paths {
 		err := filepath.WalkDir(path, func(subpath string, de os.DirEntry, err error) error {
+			if de != nil && subpath != relativeTo && relativeTo != "" {
+				rel, relErr := filepath.Rel(filepath.Dir(relativeTo), subpath)
+				if relErr != nil {
+					return relErr
+				}
+
+				if match, ok := matcher.Match(filepath.ToSlash(rel), de.IsDir()); ok && match.Ignored {
+					if listFiles != nil {
+						fmt.Fprintf(listFiles, "Excluded %s (%s, by %q in %s)\n", mintDirectoryDisplayPath(subpath, relativeTo), formatSize(pathSize(subpath)), match.Pattern, match.Source)
+					}
+
+					if de.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+
 			entry, entrySize, suberr := mintDirectoryEntry(subpath, de, relativeTo)
 			if suberr != nil {
 				return suberr
 			}
 
+			if entry.IsFile() && listFiles != nil {
+				fmt.Fprintf(listFiles, "Included %s (%s)\n", entry.Path, formatSize(entrySize))
+			}
+
 			totalSize += entrySize
 			entries = append(entries, entry)
 			return nil
@@ -124,13 +199,82 @@ func readMintDirectoryEntries(paths []string, relativeTo string) ([]MintDirector
 		}
 	}
 
-	if totalSize > 5*1024*1024 {
-		return nil, fmt.Errorf("the size of the these files exceed 5MiB: %s", strings.Join(paths, ", "))
+	if totalSize > maxMintDirectorySize {
+		if relativeTo == "" {
+			return nil, mintDirectorySizeError(strings.Join(paths, ", "), entries, maxMintDirectorySize)
+		}
+
+		err := mintDirectorySizeError(relativeTo, entries, maxMintDirectorySize)
+		return nil, fmt.Errorf("%w\n\nFiles can be excluded by listing them in .mint/%s", err, mintIgnoreFile)
 	}
 
 	return entries, nil
 }
 
+// mintDirectorySizeError returns an error naming the largest files when the size of the files of
+// entries exceeds limit.
+func mintDirectorySizeError(location string, entries []MintDirectoryEntry, limit int) error {
+	files := filterFiles(entries)
+
+	var totalSize int
+	for _, file := range files {
+		totalSize += len(file.FileContents)
+	}
+	if totalSize <= limit {
+		return nil
+	}
+
+	slices.SortStableFunc(files, func(a, b MintDirectoryEntry) int { return len(b.FileContents) - len(a.FileContents) })
+
+	var largest strings.Builder
+	for _, file := range files[:min(len(files), 5)] {
+		fmt.Fprintf(&largest, "\n  %s (%s)", file.Path, formatSize(len(file.FileContents)))
+	}
+
+	return fmt.Errorf("the size of the files in %s is %s, which exceeds %s. The largest files are:%s", location, formatSize(totalSize), formatSize(limit), largest.String())
+}
+
+// mintDirectoryDisplayPath returns the path as it's sent in a run, eg. .mint/ci.yml.
+func mintDirectoryDisplayPath(path string, relativeTo string) string {
+	rel, err := filepath.Rel(relativeTo, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(filepath.Join(".mint", rel))
+}
+
+// pathSize returns the size of the file at path, or of the files under it for a directory.
+func pathSize(path string) int {
+	var size int
+	_ = filepath.WalkDir(path, func(_ string, de os.DirEntry, err error) error {
+		if err != nil || !de.Type().IsRegular() {
+			return nil
+		}
+		if info, err := de.Info(); err == nil {
+			size += int(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+// formatSize formats a number of bytes, eg. 1.5 KiB.
+func formatSize(size int) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size) / unit
+	for _, suffix := range []string{"KiB", "MiB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f GiB", value)
+}
+
 // mintDirectoryEntry finds the file at path and converts it to a MintDirectoryEntry.
 func mintDirectoryEntry(path string, de os.DirEntry, makePathRelativeTo string) (MintDirectoryEntry, int, error) {
 	if de == nil {
@@ -199,7 +343,8 @@ func mintDirectoryEntry(path string, de os.DirEntry, makePathRelativeTo string)
 	}, contentLength, nil
 }
 
-// filterYAMLFiles finds any *.yml and *.yaml files in the given entries.
+// filterYAMLFiles finds any *.yml and *.yaml files in the given entries, along with *.json files
+// containing a JSON object.
 func filterYAMLFiles(entries []MintDirectoryEntry) []MintDirectoryEntry {
 	yamlFiles := make([]MintDirectoryEntry, 0)
 
@@ -229,63 +374,77 @@ func filterFiles(entries []MintDirectoryEntry) []MintDirectoryEntry {
 	return files
 }
 
-// filterYAMLFilesForModification finds any *.yml and *.yaml files in the given entries
-// and reads and parses them. Entries that cannot be modified, such as JSON files
-// masquerading as YAML, will not be included.
+// filterYAMLFilesForModification finds any *.yml, *.yaml and *.json files in the given entries
+// and reads and parses them. Entries that cannot be parsed will not be included. Every document
+// of a multi-document file is filtered and returned separately, sharing the same entry.
 func filterYAMLFilesForModification(entries []MintDirectoryEntry, filter func(doc *YAMLDoc) bool) []*MintYAMLFile {
 	yamlFiles := make([]*MintYAMLFile, 0)
 
 	for _, entry := range entries {
-		yamlFile := validateYAMLFileForModification(entry, filter)
-		if yamlFile == nil {
-			continue
-		}
-
-		yamlFiles = append(yamlFiles, yamlFile)
+		yamlFiles = append(yamlFiles, validateYAMLFileForModification(entry, filter)...)
 	}
 
 	return yamlFiles
 }
 
-// validateYAMLFileForModification reads and parses the given file entry. If it cannot
-// be modified, this method will return nil.
-func validateYAMLFileForModification(entry MintDirectoryEntry, filter func(doc *YAMLDoc) bool) *MintYAMLFile {
+// validateYAMLFileForModification reads and parses the given file entry, returning each of
+// its documents that passes the filter. If it cannot be modified, this method will return nil.
+func validateYAMLFileForModification(entry MintDirectoryEntry, filter func(doc *YAMLDoc) bool) []*MintYAMLFile {
 	if !isYAMLFile(entry) {
 		return nil
 	}
 
-	content, err := os.ReadFile(entry.OriginalPath)
+	// JSON is valid YAML, and is modified as text to preserve its formatting
+	doc, err := ParseYAMLDoc(entry.FileContents)
 	if err != nil {
 		return nil
 	}
 
-	// JSON is valid YAML, but we don't support modifying it
-	if isJSON(content) {
-		return nil
-	}
-
-	doc, err := ParseYAMLDoc(string(content))
-	if err != nil {
-		return nil
-	}
+	var yamlFiles []*MintYAMLFile
+	for i, document := range doc.Documents() {
+		if !filter(document) {
+			continue
+		}
 
-	if !filter(doc) {
-		return nil
+		yamlFiles = append(yamlFiles, &MintYAMLFile{
+			Entry:         entry,
+			Doc:           document,
+			DocumentIndex: i,
+		})
 	}
 
-	return &MintYAMLFile{
-		Entry: entry,
-		Doc:   doc,
-	}
+	return yamlFiles
 }
 
+// isJSON reports whether content is a JSON object.
 func isJSON(content []byte) bool {
+	content = bytes.TrimSpace(content)
+
 	var jsonContent any
 	return len(content) > 0 && content[0] == '{' && json.Unmarshal(content, &jsonContent) == nil
 }
 
 func isYAMLFile(entry MintDirectoryEntry) bool {
-	return entry.IsFile() && (strings.HasSuffix(entry.OriginalPath, ".yml") || strings.HasSuffix(entry.OriginalPath, ".yaml"))
+	if !entry.IsFile() {
+		return false
+	}
+
+	if strings.HasSuffix(entry.OriginalPath, ".json") {
+		return isJSON([]byte(entry.FileContents))
+	}
+
+	return strings.HasSuffix(entry.OriginalPath, ".yml") || strings.HasSuffix(entry.OriginalPath, ".yaml")
+}
+
+// writeFile writes contents to the file at path, inheriting the permissions of the existing file
+// if it exists.
+func writeFile(path string, contents []byte) error {
+	mode := iofs.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode()
+	}
+
+	return os.WriteFile(path, contents, mode)
 }
 
 func resolveWd() (string, error) {
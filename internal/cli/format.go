@@ -0,0 +1,606 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/lexer"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/goccy/go-yaml/token"
+)
+
+// taskKeyOrder is the canonical order of the keys of a task. Other keys follow these, in the
+// order they were written in.
+var taskKeyOrder = []string{"key", "use", "after", "if", "call", "with", "run", "env", "filter"}
+
+// reNumberLike matches scalars that some YAML parsers read as numbers or timestamps, such as
+// 1.0, 0755 or 1:30, which need to stay quoted to remain strings.
+var reNumberLike = regexp.MustCompile(`^[-+.0-9:_]+$|^[-+]?\.?(inf|Inf|INF|nan|NaN|NAN)$`)
+
+// reQuotedOnKey matches the on key of run definitions once encoded, which is quoted since it's a
+// boolean in YAML 1.1, which Mint doesn't read it as.
+var reQuotedOnKey = regexp.MustCompile(`(?m)^"on":`)
+
+// yamlReservedWords are read as booleans or null by YAML 1.1 parsers when they're not quoted.
+var yamlReservedWords = []string{"y", "n", "yes", "no", "on", "off", "true", "false", "null", "~"}
+
+type yamlComment struct {
+	text   string
+	inline bool
+}
+
+// yamlFormatter writes the canonical formatting of a YAML file. The structure of the file is
+// read from its AST, while comments are read from its tokens and written back at the lines they
+// were found on: inline comments after the value they followed, and other comments before the
+// node following them.
+type yamlFormatter struct {
+	lines    []string
+	comments map[int]yamlComment
+	emitted  map[int]bool
+	out      strings.Builder
+}
+
+// formatYAML returns the canonical formatting of a YAML document: two spaces of indentation,
+// task keys in their canonical order, quotes only where they're needed and run scripts spanning
+// multiple lines as literal block scalars. Comments and single blank lines are preserved.
+func formatYAML(contents string) (string, error) {
+	file, err := parser.ParseBytes([]byte(contents), parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	f := &yamlFormatter{
+		lines:    strings.Split(contents, "\n"),
+		comments: make(map[int]yamlComment),
+		emitted:  make(map[int]bool),
+	}
+
+	var previous *token.Token
+	for _, tok := range lexer.Tokenize(contents) {
+		if tok.Type != token.CommentType {
+			previous = tok
+			continue
+		}
+
+		f.comments[tok.Position.Line] = yamlComment{
+			text:   strings.TrimRight("#"+tok.Value, " \t\r"),
+			inline: previous != nil && tokenEndLine(previous) == tok.Position.Line,
+		}
+	}
+
+	docs := file.Docs
+	if len(docs) == 0 {
+		f.writeComments(0, len(f.lines)+1, "", false)
+	}
+
+	for i, document := range docs {
+		start := 0
+		if document.Start != nil {
+			start = document.Start.Position.Line
+		}
+		if i > 0 || document.Start != nil {
+			f.out.WriteString("---\n")
+		}
+
+		if err := f.writeBody(document.Body, start); err != nil {
+			return "", err
+		}
+
+		end := len(f.lines) + 1
+		if i+1 < len(docs) && docs[i+1].Start != nil {
+			end = docs[i+1].Start.Position.Line
+		}
+		// Blank lines at the end of a document are dropped, unlike blank lines before a node
+		f.writeComments(start, end, "", document.Body != nil)
+	}
+
+	formatted := strings.TrimRight(f.out.String(), "\n")
+	if formatted == "" {
+		return "", nil
+	}
+	return formatted + "\n", nil
+}
+
+// runDefinitionDoc encodes a run definition in its canonical formatting, with header comments at
+// the top of the file and comments above the nodes at the given paths, eg. $.tasks[0].
+func runDefinitionDoc(definition yaml.MapSlice, header []string, comments map[string][]string) (*YAMLDoc, error) {
+	commentMap := make(yaml.CommentMap, len(comments))
+	for yamlPath, lines := range comments {
+		commentMap[yamlPath] = []*yaml.Comment{yaml.HeadComment(prefixComments(lines)...)}
+	}
+
+	encoded, err := yaml.MarshalWithOptions(definition, yaml.UseLiteralStyleIfMultiline(true), yaml.WithComment(commentMap))
+	if err != nil {
+		return nil, err
+	}
+
+	var contents strings.Builder
+	for _, line := range prefixComments(header) {
+		fmt.Fprintf(&contents, "#%s\n", line)
+	}
+	if len(header) > 0 {
+		contents.WriteString("\n")
+	}
+	contents.Write(encoded)
+
+	formatted, err := formatYAML(contents.String())
+	if err != nil {
+		return nil, err
+	}
+	return ParseYAMLDoc(reQuotedOnKey.ReplaceAllString(formatted, "on:"))
+}
+
+func prefixComments(lines []string) []string {
+	prefixed := make([]string, len(lines))
+	for i, line := range lines {
+		prefixed[i] = " " + line
+	}
+	return prefixed
+}
+
+func (f *yamlFormatter) writeBody(body ast.Node, start int) error {
+	switch body.(type) {
+	case nil, *ast.CommentNode, *ast.CommentGroupNode:
+		return nil
+	}
+
+	if mapping, ok := blockMapping(body); ok {
+		return f.writeMapping(mapping, "", "", start, false, true)
+	}
+
+	if sequence, ok := body.(*ast.SequenceNode); ok && !sequence.IsFlowStyle {
+		// Documents that are sequences are lists of tasks
+		return f.writeSequence(sequence, "", "", start, true)
+	}
+
+	f.writeHead(start, nodeStartLine(body), "", false)
+	return f.writeValue("", nodeStartLine(body), body, "", false)
+}
+
+// writeMapping writes the entries of a block mapping. The first entry is written after
+// firstPrefix, eg. the dash of a sequence item, and the others are indented by indent.
+func (f *yamlFormatter) writeMapping(mapping *ast.MappingNode, firstPrefix string, indent string, start int, isTask bool, isRoot bool) error {
+	type entry struct {
+		value     *ast.MappingValueNode
+		headStart int
+	}
+
+	entries := make([]entry, 0, len(mapping.Values))
+	headStart := start
+	for _, value := range mapping.Values {
+		entries = append(entries, entry{value: value, headStart: headStart})
+		headStart = nodeEndLine(value)
+	}
+
+	if isTask {
+		// Merge keys stay where they are, as the keys they merge override the keys before them and
+		// are overridden by the keys after them. Only the keys between them are ordered.
+		for segmentStart := 0; segmentStart < len(entries); {
+			segmentEnd := segmentStart
+			for segmentEnd < len(entries) && !isMergeKey(entries[segmentEnd].value.Key) {
+				segmentEnd++
+			}
+			slices.SortStableFunc(entries[segmentStart:segmentEnd], func(a entry, b entry) int {
+				return taskKeyRank(a.value.Key) - taskKeyRank(b.value.Key)
+			})
+			segmentStart = segmentEnd + 1
+		}
+	}
+
+	for i, entry := range entries {
+		// Comments of the first entry, which may have been moved there, go above the line it
+		// shares with firstPrefix, so they're indented like it
+		headIndent := indent
+		prefix := indent
+		if i == 0 {
+			headIndent = firstPrefix[:len(firstPrefix)-len(strings.TrimLeft(firstPrefix, " "))]
+			prefix = firstPrefix
+		}
+		f.writeHead(entry.headStart, nodeStartLine(entry.value), headIndent, i > 0)
+
+		key, err := formatYAMLScalar(entry.value.Key)
+		if err != nil {
+			return err
+		}
+
+		value := entry.value.Value
+		if sequence, ok := value.(*ast.SequenceNode); ok && !sequence.IsFlowStyle {
+			f.writeLine(prefix+key+":", nodeStartLine(entry.value.Key))
+			if err := f.writeSequence(sequence, indent+"  ", indent+"  ", nodeStartLine(entry.value.Key), isRoot && key == "tasks"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := f.writeValue(prefix+key+":", nodeStartLine(entry.value.Key), value, indent, isTask && key == "run"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSequence writes the items of a block sequence. Like mappings, the first item is written
+// after firstPrefix, eg. the dash of a sequence it's nested in, and the others are indented by
+// indent.
+func (f *yamlFormatter) writeSequence(sequence *ast.SequenceNode, firstPrefix string, indent string, start int, isTasks bool) error {
+	headStart := start
+	for i, item := range sequence.Values {
+		switch item.(type) {
+		case nil, *ast.CommentNode, *ast.CommentGroupNode:
+			continue
+		}
+
+		itemStart := nodeStartLine(item)
+		f.writeHead(headStart, itemStart, indent, i > 0)
+		headStart = nodeEndLine(item)
+
+		prefix := indent
+		if i == 0 {
+			prefix = firstPrefix
+		}
+
+		if mapping, ok := blockMapping(item); ok {
+			if err := f.writeMapping(mapping, prefix+"- ", indent+"  ", itemStart, isTasks, false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if nested, ok := item.(*ast.SequenceNode); ok && !nested.IsFlowStyle {
+			if err := f.writeSequence(nested, prefix+"- ", indent+"  ", itemStart, false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := f.writeValue(prefix+"-", itemStart, item, indent, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeValue writes a value after lead, eg. the key of a mapping entry found on leadLine. Nested
+// blocks are indented relative to indent.
+func (f *yamlFormatter) writeValue(lead string, leadLine int, value ast.Node, indent string, isRunScript bool) error {
+	for {
+		switch v := value.(type) {
+		case *ast.AnchorNode:
+			lead += " &" + v.Name.String()
+			value = v.Value
+			continue
+		case *ast.TagNode:
+			lead += " " + v.Start.Value
+			value = v.Value
+			continue
+		}
+		break
+	}
+
+	if mapping, ok := blockMapping(value); ok {
+		f.writeLine(lead, leadLine)
+		return f.writeMapping(mapping, indent+"  ", indent+"  ", leadLine, false, false)
+	}
+
+	if sequence, ok := value.(*ast.SequenceNode); ok && !sequence.IsFlowStyle {
+		f.writeLine(lead, leadLine)
+		return f.writeSequence(sequence, indent+"  ", indent+"  ", leadLine, false)
+	}
+
+	if literal, ok := value.(*ast.LiteralNode); ok {
+		if strings.HasPrefix(literal.Start.Value, ">") && !isRunScript {
+			f.writeFoldedScalar(lead, literal, indent)
+			return nil
+		}
+
+		f.writeLiteral(lead, leadLine, literal.Value.Value, indent)
+		return nil
+	}
+
+	if str, ok := value.(*ast.StringNode); ok && isRunScript && strings.Contains(strings.TrimRight(str.Value, "\n"), "\n") {
+		f.writeLiteral(lead, leadLine, str.Value, indent)
+		return nil
+	}
+
+	if f.isImplicitNull(value) {
+		f.writeLine(lead, leadLine)
+		return nil
+	}
+
+	text, err := formatYAMLScalar(value)
+	if err != nil {
+		return err
+	}
+
+	f.writeLine(lead+" "+text, nodeEndLine(value))
+	return nil
+}
+
+// isImplicitNull reports whether a value was left empty, eg. "pull_request:", rather than written
+// as null. The parser places the null it creates for these after the end of their line.
+func (f *yamlFormatter) isImplicitNull(node ast.Node) bool {
+	null, ok := node.(*ast.NullNode)
+	if !ok {
+		return false
+	}
+
+	position := null.GetToken().Position
+	if position.Line < 1 || position.Line > len(f.lines) {
+		return true
+	}
+
+	line := []rune(f.lines[position.Line-1])
+	return position.Column-1 >= len(line) || !strings.HasPrefix(string(line[position.Column-1:]), null.GetToken().Value)
+}
+
+// writeLiteral writes value as a literal block scalar, choosing the chomping indicator that
+// preserves its trailing newlines.
+func (f *yamlFormatter) writeLiteral(lead string, leadLine int, value string, indent string) {
+	content := strings.TrimRight(value, "\n")
+	trailingNewlines := len(value) - len(content)
+
+	header := "|"
+	if strings.HasPrefix(strings.TrimLeft(content, "\n"), " ") {
+		header += "2"
+	}
+	switch {
+	case trailingNewlines == 0:
+		header += "-"
+	case trailingNewlines > 1:
+		header += "+"
+	}
+
+	f.writeLine(lead+" "+header, leadLine)
+	for _, line := range strings.Split(content, "\n") {
+		f.writeContentLine(indent+"  ", line)
+	}
+	for i := 1; i < trailingNewlines; i++ {
+		f.out.WriteString("\n")
+	}
+}
+
+// writeFoldedScalar writes a folded block scalar as it was written, only changing its indentation,
+// as its lines can't be recovered from its value.
+func (f *yamlFormatter) writeFoldedScalar(lead string, literal *ast.LiteralNode, indent string) {
+	header := literal.Start.Value
+	if strings.ContainsAny(header, "123456789") {
+		// Indentation indicators refer to the original indentation, so the value is quoted instead
+		f.writeLine(lead+" "+formatYAMLString(literal.Value.Value, false), literal.GetToken().Position.Line)
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(strings.TrimLeft(literal.Value.GetToken().Origin, "\n"), " \t\n"), "\n")
+	commonIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+		if commonIndent == -1 || lineIndent < commonIndent {
+			commonIndent = lineIndent
+		}
+	}
+
+	f.writeLine(lead+" "+header, literal.GetToken().Position.Line)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			f.out.WriteString("\n")
+			continue
+		}
+		f.writeContentLine(indent+"  ", line[commonIndent:])
+	}
+}
+
+// writeLine writes a line, followed by the inline comment of the given source line, if any.
+func (f *yamlFormatter) writeLine(text string, sourceLine int) {
+	if comment, ok := f.comments[sourceLine]; ok && comment.inline && !f.emitted[sourceLine] {
+		text += " " + comment.text
+		f.emitted[sourceLine] = true
+	}
+	f.out.WriteString(text + "\n")
+}
+
+func (f *yamlFormatter) writeContentLine(indent string, line string) {
+	if line == "" {
+		f.out.WriteString("\n")
+		return
+	}
+	f.out.WriteString(indent + line + "\n")
+}
+
+// writeHead writes the comments found between two lines, exclusive, at the given indentation,
+// followed by a blank line when the node on the second line is preceded by one. Blank lines
+// before and between comments are collapsed into one, unless they lead a container.
+func (f *yamlFormatter) writeHead(from int, to int, indent string, allowBlank bool) {
+	if f.writeComments(from, to, indent, allowBlank) {
+		f.out.WriteString("\n")
+	}
+}
+
+// writeComments writes the comments found between two lines, exclusive, and reports whether
+// they're followed by a blank line that hasn't been written.
+func (f *yamlFormatter) writeComments(from int, to int, indent string, allowBlank bool) bool {
+	pendingBlank := false
+	for line := from + 1; line < to && line <= len(f.lines); line++ {
+		if strings.TrimSpace(f.lines[line-1]) == "" {
+			pendingBlank = true
+			continue
+		}
+
+		comment, ok := f.comments[line]
+		if !ok || f.emitted[line] {
+			pendingBlank = false
+			continue
+		}
+
+		if pendingBlank && allowBlank {
+			f.out.WriteString("\n")
+		}
+		f.out.WriteString(indent + comment.text + "\n")
+		f.emitted[line] = true
+		pendingBlank = false
+		allowBlank = true
+	}
+
+	return pendingBlank && allowBlank
+}
+
+// formatYAMLScalar formats keys, scalars, aliases and flow collections.
+func formatYAMLScalar(node ast.Node) (string, error) {
+	switch n := node.(type) {
+	case *ast.StringNode:
+		return formatYAMLString(n.Value, n.Token.Type == token.StringType), nil
+	case *ast.MergeKeyNode:
+		return "<<", nil
+	case *ast.AliasNode:
+		return "*" + n.Value.String(), nil
+	case *ast.MappingNode, *ast.SequenceNode, *ast.MappingValueNode:
+		return strings.TrimSpace(node.String()), nil
+	case *ast.NullNode, *ast.BoolNode, *ast.IntegerNode, *ast.FloatNode, *ast.InfinityNode, *ast.NanNode:
+		return strings.TrimSpace(n.GetToken().Origin), nil
+	default:
+		return "", fmt.Errorf("unsupported YAML node %s at line %d", node.Type(), nodeStartLine(node))
+	}
+}
+
+// formatYAMLString writes strings without quotes when they'd be read back as the same string,
+// and quotes them otherwise, preferring single quotes to escaping double quotes.
+func formatYAMLString(value string, plain bool) string {
+	if plain && !strings.Contains(value, "\n") {
+		return value
+	}
+
+	if isPlainYAMLSafe(value) {
+		return value
+	}
+
+	if strings.ContainsAny(value, "\"\\") && !strings.ContainsAny(value, "'\n\t\r") {
+		return "'" + value + "'"
+	}
+
+	// JSON strings are valid double-quoted YAML scalars
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprintf("%q", value)
+	}
+	return strings.TrimSuffix(encoded.String(), "\n")
+}
+
+func isPlainYAMLSafe(value string) bool {
+	if value == "" || strings.TrimSpace(value) != value || strings.ContainsAny(value, "\n\r\t") {
+		return false
+	}
+
+	if slices.Contains(yamlReservedWords, strings.ToLower(value)) || reNumberLike.MatchString(value) {
+		return false
+	}
+
+	var parsed map[string]any
+	if err := yaml.Unmarshal([]byte("value: "+value), &parsed); err != nil {
+		return false
+	}
+
+	str, ok := parsed["value"].(string)
+	return ok && str == value && len(parsed) == 1
+}
+
+func isMergeKey(key ast.Node) bool {
+	_, ok := key.(*ast.MergeKeyNode)
+	return ok
+}
+
+func taskKeyRank(key ast.Node) int {
+	str, ok := key.(*ast.StringNode)
+	if !ok {
+		return len(taskKeyOrder)
+	}
+
+	if rank := slices.Index(taskKeyOrder, str.Value); rank != -1 {
+		return rank
+	}
+	return len(taskKeyOrder)
+}
+
+// blockMapping returns the given node as a block mapping, wrapping mapping values of a single
+// entry.
+func blockMapping(node ast.Node) (*ast.MappingNode, bool) {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n, !n.IsFlowStyle
+	case *ast.MappingValueNode:
+		return &ast.MappingNode{BaseNode: &ast.BaseNode{}, Values: []*ast.MappingValueNode{n}}, true
+	default:
+		return nil, false
+	}
+}
+
+func nodeStartLine(node ast.Node) int {
+	switch n := node.(type) {
+	case nil:
+		return 0
+	case *ast.MappingNode:
+		if !n.IsFlowStyle && len(n.Values) > 0 {
+			return nodeStartLine(n.Values[0])
+		}
+	case *ast.MappingValueNode:
+		return nodeStartLine(n.Key)
+	}
+
+	if node.GetToken() == nil {
+		return 0
+	}
+	return node.GetToken().Position.Line
+}
+
+// nodeEndLine returns the last line of a node, including values spanning multiple lines.
+func nodeEndLine(node ast.Node) int {
+	switch n := node.(type) {
+	case nil:
+		return 0
+	case *ast.MappingNode:
+		end := tokenEndLine(n.GetToken())
+		if n.End != nil {
+			end = max(end, tokenEndLine(n.End))
+		}
+		for _, value := range n.Values {
+			end = max(end, nodeEndLine(value))
+		}
+		return end
+	case *ast.MappingValueNode:
+		return max(nodeEndLine(n.Key), nodeEndLine(n.Value))
+	case *ast.SequenceNode:
+		end := tokenEndLine(n.GetToken())
+		if n.End != nil {
+			end = max(end, tokenEndLine(n.End))
+		}
+		for _, value := range n.Values {
+			end = max(end, nodeEndLine(value))
+		}
+		return end
+	case *ast.AnchorNode:
+		return max(tokenEndLine(n.GetToken()), nodeEndLine(n.Value))
+	case *ast.TagNode:
+		return max(tokenEndLine(n.GetToken()), nodeEndLine(n.Value))
+	case *ast.LiteralNode:
+		return max(tokenEndLine(n.GetToken()), tokenEndLine(n.Value.GetToken()))
+	default:
+		return tokenEndLine(node.GetToken())
+	}
+}
+
+func tokenEndLine(tok *token.Token) int {
+	if tok == nil {
+		return 0
+	}
+	return tok.Position.Line + strings.Count(strings.TrimSpace(tok.Origin), "\n")
+}
@@ -0,0 +1,435 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rwx-research/mint-cli/internal/api"
+)
+
+const (
+	ImportSourceCircleCI      = "circleci"
+	ImportSourceGitHubActions = "github-actions"
+	ImportSourceGitLab        = "gitlab"
+
+	// The repository cloned by imported run definitions when none is given
+	importRepositoryPlaceholder = "YOUR_ORG/YOUR_REPO"
+
+	// Why caches and artifacts of other CI systems are left out
+	importCacheLeftOut    = "Mint caches the results of tasks"
+	importArtifactLeftOut = "tasks have the files of the tasks they use"
+)
+
+// ImportSources are the CI systems run definitions can be imported from.
+var ImportSources = []string{ImportSourceGitHubActions, ImportSourceGitLab, ImportSourceCircleCI}
+
+// importImageLeaf is the leaf installing what a container image provides, along with the leaf
+// parameter its tag translates to.
+type importImageLeaf struct {
+	leaf  string
+	param string
+}
+
+var importImageLeaves = map[string]importImageLeaf{
+	"go":     {leaf: "mint/install-go", param: "go-version"},
+	"golang": {leaf: "mint/install-go", param: "go-version"},
+	"node":   {leaf: "mint/install-node", param: "node-version"},
+	"python": {leaf: "mint/install-python", param: "python-version"},
+	"ruby":   {leaf: "mint/install-ruby", param: "ruby-version"},
+}
+
+// importBaseImages are images providing no more than the base of a run definition.
+var importBaseImages = []string{"base", "buildpack-deps", "ubuntu"}
+
+var (
+	reImportKeyInvalidChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+	reImportImageVersion    = regexp.MustCompile(`^\d+(\.\d+)*$`)
+)
+
+// importer translates the configuration files of a CI system to run definitions.
+type importer interface {
+	// defaultFiles returns the configuration files of the repository at root.
+	defaultFiles(root string) ([]string, error)
+	// fileName returns the name of the run definition imported from a file.
+	fileName(path string) string
+	importFile(path string) (*importedRun, error)
+}
+
+func newImporter(cfg ImportConfig, leafVersions *api.LeafVersionsResult) importer {
+	repository := cmp.Or(cfg.Repository, importRepositoryPlaceholder)
+	vault := cmp.Or(cfg.Vault, "default")
+
+	switch cfg.Source {
+	case ImportSourceCircleCI:
+		return &circleCIImporter{repository: repository, vault: vault, leafVersions: leafVersions}
+	case ImportSourceGitLab:
+		return &gitlabImporter{repository: repository, vault: vault, leafVersions: leafVersions}
+	default:
+		return &githubActionsImporter{repository: repository, vault: vault, leafVersions: leafVersions}
+	}
+}
+
+// importedFileName returns the name of the run definition imported from a file, eg. ci.yml for
+// .github/workflows/ci.yaml.
+func importedFileName(path string) string {
+	name := strings.TrimPrefix(filepath.Base(path), ".")
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".yml"
+}
+
+// importedRun is a run definition translated from the configuration of another CI system. Parts
+// that can't be translated are marked with TODO comments and reported.
+type importedRun struct {
+	definition yaml.MapSlice
+	header     []string
+	comments   map[string][]string
+	problems   []ImportProblem
+	tasks      []any
+	keys       map[string]bool
+	images     map[string]string
+
+	// The init parameters the run definition refers to, besides commit-sha
+	init map[string]bool
+
+	// The imported file, to find the lines of what couldn't be translated
+	source *YAMLDoc
+}
+
+func newImportedRun(path string, source *YAMLDoc) *importedRun {
+	return &importedRun{
+		header:   []string{fmt.Sprintf("Imported from %s", relativePathFromWd(path))},
+		comments: make(map[string][]string),
+		problems: make([]ImportProblem, 0),
+		tasks:    make([]any, 0),
+		keys:     make(map[string]bool),
+		images:   make(map[string]string),
+		init:     make(map[string]bool),
+		source:   source,
+	}
+}
+
+// taskPath returns the path of the next task added to the run definition.
+func (run *importedRun) taskPath() string {
+	return fmt.Sprintf("$.tasks[%d]", len(run.tasks))
+}
+
+// taskKey returns a key for a task named eg. "Run tests", unique within the run definition.
+func (run *importedRun) taskKey(names ...string) string {
+	keys := make([]string, 0, len(names))
+	for _, name := range names {
+		if key := importKey(name); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return uniqueImportKey(strings.Join(keys, "-"), run.keys)
+}
+
+func (run *importedRun) addTask(task yaml.MapSlice, comments []importComment) {
+	path := run.taskPath()
+	run.tasks = append(run.tasks, task)
+	for _, comment := range comments {
+		run.comment(path, comment)
+	}
+}
+
+// imageTask returns the key of the task installing what a container image provides, adding it
+// the first time the image is seen. Images of the base need no task, and images without an
+// equivalent leaf aren't translated.
+func (run *importedRun) imageTask(versions *api.LeafVersionsResult, image string, line int) (string, bool) {
+	if key, ok := run.images[image]; ok {
+		return key, true
+	}
+
+	leaf, params, ok := importImage(image)
+	if !ok || leaf == "" {
+		return "", ok
+	}
+
+	task := yaml.MapSlice{
+		{Key: "key", Value: run.taskKey(strings.TrimPrefix(leaf, "mint/"))},
+		{Key: "call", Value: importLeafCall(versions, leaf)},
+	}
+	if len(params) > 0 {
+		task = append(task, yaml.MapItem{Key: "with", Value: params})
+	}
+	run.addTask(task, importLeafVersionTodo(versions, leaf, line))
+
+	run.images[image] = fmt.Sprint(task[0].Value)
+	return run.images[image], true
+}
+
+// lastTaskPath returns the path of the last task added since the given number of tasks, or an
+// empty path when there is none.
+func (run *importedRun) lastTaskPath(since int) string {
+	if len(run.tasks) <= since {
+		return ""
+	}
+	return fmt.Sprintf("$.tasks[%d]", len(run.tasks)-1)
+}
+
+// triggerInit returns the init parameters passed by triggers: the commit to run on, along with
+// the other parameters the run definition refers to.
+func (run *importedRun) triggerInit() yaml.MapSlice {
+	init := yaml.MapSlice{{Key: "commit-sha", Value: "${{ event.git.sha }}"}}
+	for _, param := range []string{"branch", "ref"} {
+		if run.init[param] {
+			init = append(init, yaml.MapItem{Key: param, Value: fmt.Sprintf("${{ event.git.%s }}", param)})
+		}
+	}
+	return init
+}
+
+// translateVariables replaces the variables a CI system sets with the commit a pipeline runs on,
+// eg. $CI_COMMIT_SHA, with the init parameters they translate to. Other variables of the CI
+// system, whose names start with prefix, are left as they are with a TODO.
+func (run *importedRun) translateVariables(str string, prefix string, variables map[string]string, yamlPath string, line int) string {
+	reVariable := regexp.MustCompile(`\$(?:\{(` + prefix + `[A-Z0-9_]*)\}|(` + prefix + `[A-Z0-9_]*))`)
+	return reVariable.ReplaceAllStringFunc(str, func(match string) string {
+		submatches := reVariable.FindStringSubmatch(match)
+		name := cmp.Or(submatches[1], submatches[2])
+
+		param, ok := variables[name]
+		if !ok {
+			run.comment(yamlPath, importTodo(line, "translate $%s", name))
+			return match
+		}
+		if param != "commit-sha" {
+			run.init[param] = true
+		}
+		return fmt.Sprintf("${{ init.%s }}", param)
+	})
+}
+
+// importComment is a comment above a node of an imported run definition. TODOs mark what
+// couldn't be translated, along with its line in the imported file.
+type importComment struct {
+	text string
+	todo bool
+	line int
+}
+
+func importTodo(line int, format string, args ...any) importComment {
+	return importComment{text: fmt.Sprintf(format, args...), todo: true, line: line}
+}
+
+func importNote(format string, args ...any) importComment {
+	return importComment{text: fmt.Sprintf(format, args...)}
+}
+
+// comment adds a comment above the node at yamlPath, or at the top of the file when the path is
+// empty. TODOs are reported as well.
+func (run *importedRun) comment(yamlPath string, comment importComment) {
+	text := comment.text
+	if comment.todo {
+		text = "TODO: " + text
+	}
+
+	if yamlPath == "" {
+		if slices.Contains(run.header, text) {
+			return
+		}
+		run.header = append(run.header, text)
+	} else {
+		if slices.Contains(run.comments[yamlPath], text) {
+			return
+		}
+		run.comments[yamlPath] = append(run.comments[yamlPath], text)
+	}
+
+	if comment.todo {
+		run.problems = append(run.problems, ImportProblem{Line: comment.line, Message: comment.text})
+	}
+}
+
+// line returns the line of the imported file a key or item is on, given the keys and indexes
+// leading to it, or 0 when it can't be found.
+func (run *importedRun) line(path ...any) int {
+	segments := make([]yamlPathSegment, len(path))
+	for i, segment := range path {
+		if index, ok := segment.(int); ok {
+			segments[i] = yamlPathSegment{index: index, isIndex: true}
+		} else {
+			segments[i] = yamlPathSegment{key: fmt.Sprint(segment)}
+		}
+	}
+	if len(segments) == 0 {
+		return 0
+	}
+
+	// Keys are found in their mapping, to return the line of the key rather than its value
+	if last := segments[len(segments)-1]; !last.isIndex {
+		if parent, err := run.source.getNodeAtPath(formatYAMLPath(segments[:len(segments)-1])); err == nil {
+			for _, value := range mappingValues(parent) {
+				if scalarText(value.Key) == last.key {
+					return nodeStartLine(value)
+				}
+			}
+		}
+	}
+
+	node, err := run.source.getNodeAtPath(formatYAMLPath(segments))
+	if err != nil {
+		return 0
+	}
+	return nodeStartLine(node)
+}
+
+// doc returns the run definition in its canonical formatting, to be written like other run
+// definitions.
+func (run *importedRun) doc() (*YAMLDoc, error) {
+	return runDefinitionDoc(run.definition, run.header, run.comments)
+}
+
+// outputImportReport lists what couldn't be translated, by line of the imported files. Problems
+// with the whole file come first.
+func outputImportReport(w io.Writer, files []ImportedFile) {
+	reported := false
+	for _, file := range files {
+		problems := slices.Clone(file.Problems)
+		slices.SortStableFunc(problems, func(a, b ImportProblem) int {
+			return a.Line - b.Line
+		})
+
+		for _, problem := range problems {
+			if !reported {
+				fmt.Fprintln(w, "\nMarked with TODO comments, as they couldn't be translated:")
+				reported = true
+			}
+
+			location := relativePathFromWd(file.Source)
+			if problem.Line > 0 {
+				location = fmt.Sprintf("%s:%d", location, problem.Line)
+			}
+			fmt.Fprintf(w, "  %s: %s\n", location, problem.Message)
+		}
+	}
+}
+
+// importKey turns a name such as "Run tests" into a task key such as "run-tests".
+func importKey(name string) string {
+	key := strings.Trim(reImportKeyInvalidChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if len(key) > 40 {
+		key = strings.TrimRight(key[:40], "-")
+	}
+	return key
+}
+
+// uniqueImportKey returns key, or key followed by a number when it's already taken.
+func uniqueImportKey(key string, taken map[string]bool) string {
+	unique := key
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", key, i)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// importJobOrder returns jobs in the order they're given, except that jobs come after the jobs
+// they need. Jobs needing each other are left in order.
+func importJobOrder(jobs []string, needs map[string][]string) []string {
+	order := make([]string, 0, len(jobs))
+	done := make(map[string]bool)
+	for len(order) < len(jobs) {
+		progressed := false
+		for _, job := range jobs {
+			if done[job] {
+				continue
+			}
+
+			ready := true
+			for _, need := range needs[job] {
+				ready = ready && (done[need] || !slices.Contains(jobs, need))
+			}
+			if ready {
+				order = append(order, job)
+				done[job] = true
+				progressed = true
+			}
+		}
+
+		if !progressed {
+			for _, job := range jobs {
+				if !done[job] {
+					order = append(order, job)
+					done[job] = true
+				}
+			}
+		}
+	}
+	return order
+}
+
+// importImage returns the leaf replacing a container image, along with its parameters, eg.
+// mint/install-node with a node-version of 20.11 for cimg/node:20.11-browsers. Images of the
+// base are replaced by no leaf.
+func importImage(image string) (string, yaml.MapSlice, bool) {
+	image, _, _ = strings.Cut(image, "@")
+	name, tag, _ := strings.Cut(image[strings.LastIndex(image, "/")+1:], ":")
+	if slices.Contains(importBaseImages, name) {
+		return "", nil, true
+	}
+
+	leaf, ok := importImageLeaves[name]
+	if !ok {
+		return "", nil, false
+	}
+
+	version, _, _ := strings.Cut(tag, "-")
+	if !reImportImageVersion.MatchString(version) {
+		return leaf.leaf, nil, true
+	}
+	return leaf.leaf, yaml.MapSlice{{Key: leaf.param, Value: version}}, true
+}
+
+// importLeafCall returns the call of the latest major version of a leaf, or the leaf alone when
+// its versions are unknown, to be resolved by `mint resolve leaves`.
+func importLeafCall(versions *api.LeafVersionsResult, leaf string) string {
+	if versions != nil {
+		if version := versions.LatestMajor[leaf]; version != "" {
+			return fmt.Sprintf("%s %s", leaf, version)
+		}
+	}
+	return leaf
+}
+
+// importLeafVersionTodo returns a TODO to pick the version of a leaf when its versions are unknown.
+func importLeafVersionTodo(versions *api.LeafVersionsResult, leaf string, line int) []importComment {
+	if importLeafCall(versions, leaf) == leaf {
+		return []importComment{importTodo(line, "pick a version of %s, eg. with `mint resolve leaves`", leaf)}
+	}
+	return nil
+}
+
+// stringValue returns the text of a scalar value, eg. "20" for `node-version: 20`.
+func stringValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// stringsValue returns the items of a value that is either a single string or a list of them,
+// such as `needs: build` or `needs: [build, lint]`. Nested lists are flattened.
+func stringsValue(value any) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			strs = append(strs, stringsValue(item)...)
+		}
+		return strs
+	default:
+		return []string{stringValue(v)}
+	}
+}
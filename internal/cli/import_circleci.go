@@ -0,0 +1,561 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// circleCIVariables are the built-in variables translating to init parameters.
+var circleCIVariables = map[string]string{
+	"CIRCLE_SHA1":   "commit-sha",
+	"CIRCLE_BRANCH": "branch",
+}
+
+// circleCIPipelineValues are the pipeline values translating to init parameters.
+var circleCIPipelineValues = map[string]string{
+	"pipeline.git.revision": "commit-sha",
+	"pipeline.git.branch":   "branch",
+}
+
+// circleCIStepsLeftOut are the steps Mint has no need for, along with why.
+var circleCIStepsLeftOut = map[string]string{
+	"restore_cache":        importCacheLeftOut,
+	"save_cache":           importCacheLeftOut,
+	"attach_workspace":     importArtifactLeftOut,
+	"persist_to_workspace": importArtifactLeftOut,
+}
+
+// circleCIWorkflowJobKeys are the keys of jobs in workflows which are translated.
+var circleCIWorkflowJobKeys = []string{"requires", "name", "context", "filters"}
+
+const circleCIDefaultWorkingDirectory = "~/project"
+
+var reCircleCIParameter = regexp.MustCompile(`<<\s*([A-Za-z0-9_.-]+)\s*>>`)
+
+// circleCIStep is a step of a job, along with where it's defined, since steps of commands are
+// defined apart from the jobs running them.
+type circleCIStep struct {
+	value any
+	path  []any
+}
+
+// circleCIImporter translates CircleCI configurations to run definitions: the steps of jobs
+// become tasks using one another in order, and jobs use the jobs they require in workflows.
+type circleCIImporter struct {
+	repository   string
+	vault        string
+	leafVersions *api.LeafVersionsResult
+
+	// The state of the configuration being imported
+	run    *importedRun
+	config yaml.MapSlice
+}
+
+func (i *circleCIImporter) defaultFiles(root string) ([]string, error) {
+	for _, name := range []string{"config.yml", "config.yaml"} {
+		path := filepath.Join(root, ".circleci", name)
+		if _, err := os.Stat(path); err == nil {
+			return []string{path}, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// fileName returns circleci.yml, since CircleCI configurations are all named config.yml.
+func (i *circleCIImporter) fileName(path string) string {
+	if name := importedFileName(path); name != "config.yml" {
+		return name
+	}
+	return "circleci.yml"
+}
+
+func (i *circleCIImporter) importFile(path string) (*importedRun, error) {
+	doc, err := ParseYAMLFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %q", path)
+	}
+
+	config, ok := doc.value().(yaml.MapSlice)
+	jobs, hasJobs := mapSliceValue(config, "jobs").(yaml.MapSlice)
+	if !ok || !hasJobs {
+		return nil, errors.Errorf("%s is not a CircleCI configuration", relativePathFromWd(path))
+	}
+
+	i.run = newImportedRun(path, doc)
+	i.config = config
+
+	if mapSliceValue(config, "setup") != nil {
+		i.run.comment("", importTodo(i.run.line("setup"), "translate the dynamic configuration"))
+	}
+
+	workflows := make(yaml.MapSlice, 0)
+	definedWorkflows, _ := mapSliceValue(config, "workflows").(yaml.MapSlice)
+	for _, item := range definedWorkflows {
+		if _, ok := item.Value.(yaml.MapSlice); ok {
+			workflows = append(workflows, item)
+		}
+	}
+	// Configurations without workflows run the build job
+	if len(workflows) == 0 && mapSliceValue(jobs, "build") != nil {
+		workflows = append(workflows, yaml.MapItem{Key: "", Value: yaml.MapSlice{{Key: "jobs", Value: []any{"build"}}}})
+	}
+
+	cron := make([]any, 0)
+	for _, item := range workflows {
+		name := fmt.Sprint(item.Key)
+		prefix := ""
+		if len(workflows) > 1 {
+			prefix = name
+		}
+
+		workflow := item.Value.(yaml.MapSlice)
+		i.importWorkflow(name, prefix, workflow)
+		cron = append(cron, i.schedules(name, workflow, len(workflows) > 1)...)
+	}
+
+	triggers := yaml.MapSlice{{Key: "github", Value: yaml.MapSlice{{Key: "push", Value: yaml.MapSlice{{Key: "init", Value: i.run.triggerInit()}}}}}}
+	if len(cron) > 0 {
+		triggers = append(triggers, yaml.MapItem{Key: "cron", Value: cron})
+	}
+
+	i.run.definition = yaml.MapSlice{
+		{Key: "on", Value: triggers},
+		{Key: "tasks", Value: i.run.tasks},
+	}
+	return i.run, nil
+}
+
+func (i *circleCIImporter) importWorkflow(workflowName string, prefix string, workflow yaml.MapSlice) {
+	for _, condition := range []string{"when", "unless"} {
+		if mapSliceValue(workflow, condition) != nil {
+			i.run.comment("", importTodo(i.run.line("workflows", workflowName, condition), "translate the %s condition of the workflow %q", condition, workflowName))
+		}
+	}
+
+	// Jobs are referred to by their name in the workflow, which is the job they run by default
+	items, _ := mapSliceValue(workflow, "jobs").([]any)
+	names := make([]string, 0, len(items))
+	jobs := make(map[string]string, len(items))
+	configs := make(map[string]yaml.MapSlice, len(items))
+	indexes := make(map[string]int, len(items))
+	requires := make(map[string][]string, len(items))
+	for index, item := range items {
+		job := stringValue(item)
+		var config yaml.MapSlice
+		if mapping, ok := item.(yaml.MapSlice); ok && len(mapping) > 0 {
+			job = fmt.Sprint(mapping[0].Key)
+			config, _ = mapping[0].Value.(yaml.MapSlice)
+		}
+
+		name := job
+		if alias := stringValue(mapSliceValue(config, "name")); alias != "" {
+			name = alias
+		}
+		names = append(names, name)
+		jobs[name] = job
+		configs[name] = config
+		indexes[name] = index
+		requires[name] = stringsValue(mapSliceValue(config, "requires"))
+	}
+
+	lastTasks := make(map[string][]string, len(names))
+	for _, name := range importJobOrder(names, requires) {
+		job, config := jobs[name], configs[name]
+		line := func(keys ...any) int {
+			if len(keys) == 0 {
+				return i.run.line("workflows", workflowName, "jobs", indexes[name])
+			}
+			return i.run.line(append([]any{"workflows", workflowName, "jobs", indexes[name], job}, keys...)...)
+		}
+
+		use := make([]string, 0)
+		for _, required := range requires[name] {
+			use = append(use, lastTasks[required]...)
+		}
+
+		if stringValue(mapSliceValue(config, "type")) == "approval" {
+			i.run.comment("", importTodo(line("type"), "translate the approval job %q", name))
+			lastTasks[name] = use
+			continue
+		}
+
+		comments := make([]importComment, 0)
+		todo := func(line int, format string, args ...any) {
+			comments = append(comments, importTodo(line, format, args...))
+		}
+		for _, item := range config {
+			if key := fmt.Sprint(item.Key); key != "type" && !slices.Contains(circleCIWorkflowJobKeys, key) {
+				todo(line(key), "translate the %s of the job %q", key, name)
+			}
+		}
+		for _, context := range stringsValue(mapSliceValue(config, "context")) {
+			todo(line("context"), "add the secrets of the context %s to the vault %s, and set them in env", context, i.vault)
+		}
+		condition := i.filterCondition(name, config, line, todo)
+
+		lastTasks[name] = i.importJob(prefix, name, job, line(), use, condition, comments)
+	}
+}
+
+// filterCondition translates the branches a job runs on to a condition of its tasks.
+func (i *circleCIImporter) filterCondition(name string, config yaml.MapSlice, line func(keys ...any) int, todo func(line int, format string, args ...any)) string {
+	filters, _ := mapSliceValue(config, "filters").(yaml.MapSlice)
+	branches, _ := mapSliceValue(filters, "branches").(yaml.MapSlice)
+
+	conditions := make([]string, 0)
+	for _, branch := range stringsValue(mapSliceValue(branches, "only")) {
+		if strings.HasPrefix(branch, "/") {
+			todo(line("filters", "branches", "only"), "translate the branch filter %s of the job %q", branch, name)
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("init.branch == '%s'", branch))
+	}
+	if mapSliceValue(branches, "ignore") != nil {
+		todo(line("filters", "branches", "ignore"), "translate the ignored branches of the job %q", name)
+	}
+	if mapSliceValue(filters, "tags") != nil {
+		todo(line("filters", "tags"), "translate the tag filter of the job %q", name)
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+	i.run.init["branch"] = true
+	return "${{ " + strings.Join(conditions, " || ") + " }}"
+}
+
+// importJob translates the steps of a job to tasks, returning the key of its last task.
+func (i *circleCIImporter) importJob(prefix string, name string, jobName string, line int, use []string, condition string, comments []importComment) []string {
+	jobs, _ := mapSliceValue(i.config, "jobs").(yaml.MapSlice)
+	job, ok := mapSliceValue(jobs, jobName).(yaml.MapSlice)
+	jobLine := func(keys ...any) int {
+		return i.run.line(append([]any{"jobs", jobName}, keys...)...)
+	}
+
+	// Jobs of orbs, or which aren't defined, become a task to translate
+	if !ok {
+		task := yaml.MapSlice{{Key: "key", Value: i.run.taskKey(prefix, name)}}
+		if len(use) > 0 {
+			task = append(task, yaml.MapItem{Key: "use", Value: importUse(use)})
+		}
+		task = append(task, yaml.MapItem{Key: "run", Value: fmt.Sprintf("echo 'Translate the job %s' && exit 1", jobName)})
+		i.run.addTask(task, append(comments, importTodo(line, "translate the job %s", jobName)))
+		return []string{fmt.Sprint(task[0].Value)}
+	}
+
+	executor := i.executor(jobName, job, func(line int, format string, args ...any) {
+		comments = append(comments, importTodo(line, format, args...))
+	})
+
+	for _, unsupported := range []string{"parallelism", "resource_class", "parameters", "circleci_ip_ranges"} {
+		if mapSliceValue(job, unsupported) != nil {
+			comments = append(comments, importTodo(jobLine(unsupported), "translate the %s of the job %q", unsupported, jobName))
+		}
+	}
+
+	if image := executor.image; image != "" {
+		if task, ok := i.run.imageTask(i.leafVersions, image, executor.imageLine); !ok {
+			comments = append(comments, importTodo(executor.imageLine, "the job %q runs in the image %s, which isn't translated", jobName, image))
+		} else if task != "" {
+			use = append(use, task)
+		}
+	}
+
+	env := make(yaml.MapSlice, 0)
+	for _, scope := range []yaml.MapSlice{executor.config, job} {
+		scopeEnv, _ := mapSliceValue(scope, "environment").(yaml.MapSlice)
+		for _, item := range scopeEnv {
+			env = setMapSliceValue(env, fmt.Sprint(item.Key), item.Value)
+		}
+	}
+
+	dir := stringValue(mapSliceValue(job, "working_directory"))
+	if dir == "" {
+		dir = stringValue(mapSliceValue(executor.config, "working_directory"))
+	}
+
+	steps := make([]circleCIStep, 0)
+	rawSteps, _ := mapSliceValue(job, "steps").([]any)
+	for index, step := range rawSteps {
+		steps = append(steps, i.expandStep(circleCIStep{value: step, path: []any{"jobs", jobName, "steps", index}}, nil)...)
+	}
+
+	firstTask := len(i.run.tasks)
+	for index, step := range steps {
+		task, notes := i.importStep(prefix, name, index, step, use, env, dir, condition)
+		comments = append(comments, notes...)
+		if task == nil {
+			continue
+		}
+
+		i.run.addTask(task, comments)
+		comments = nil
+		use = []string{fmt.Sprint(task[0].Value)}
+	}
+
+	// Notes about steps left out at the end of a job go with its last task
+	for _, comment := range comments {
+		i.run.comment(i.run.lastTaskPath(firstTask), comment)
+	}
+
+	return use
+}
+
+// circleCIExecutor is where a job runs: the first image is the one steps run in.
+type circleCIExecutor struct {
+	config    yaml.MapSlice
+	image     string
+	imageLine int
+}
+
+// executor returns the executor of a job, which is either defined by the job or refers to one of
+// the executors of the configuration.
+func (i *circleCIImporter) executor(jobName string, job yaml.MapSlice, todo func(line int, format string, args ...any)) circleCIExecutor {
+	config := job
+	path := []any{"jobs", jobName}
+
+	if ref := mapSliceValue(job, "executor"); ref != nil {
+		name := stringValue(ref)
+		if mapping, ok := ref.(yaml.MapSlice); ok {
+			name = stringValue(mapSliceValue(mapping, "name"))
+		}
+
+		executors, _ := mapSliceValue(i.config, "executors").(yaml.MapSlice)
+		executor, ok := mapSliceValue(executors, name).(yaml.MapSlice)
+		if !ok {
+			todo(i.run.line("jobs", jobName, "executor"), "translate the executor %s", name)
+			return circleCIExecutor{}
+		}
+		config = executor
+		path = []any{"executors", name}
+	}
+
+	for _, kind := range []string{"machine", "macos", "windows"} {
+		if mapSliceValue(config, kind) != nil {
+			todo(i.run.line(append(path, kind)...), "the job %q runs on a %s executor, which isn't translated", jobName, kind)
+		}
+	}
+
+	images, _ := mapSliceValue(config, "docker").([]any)
+	executor := circleCIExecutor{config: config}
+	for index, item := range images {
+		image, _ := item.(yaml.MapSlice)
+		name := stringValue(mapSliceValue(image, "image"))
+		line := i.run.line(append(path, "docker", index, "image")...)
+		if index == 0 {
+			executor.image = name
+			executor.imageLine = line
+		} else {
+			todo(line, "translate the service container %s of the job %q", name, jobName)
+		}
+	}
+	return executor
+}
+
+// expandStep returns a step, or the steps of the command it runs when it's one of the commands
+// of the configuration.
+func (i *circleCIImporter) expandStep(step circleCIStep, expanding []string) []circleCIStep {
+	name := stringValue(step.value)
+	var params yaml.MapSlice
+	if mapping, ok := step.value.(yaml.MapSlice); ok && len(mapping) == 1 {
+		name = fmt.Sprint(mapping[0].Key)
+		params, _ = mapping[0].Value.(yaml.MapSlice)
+	}
+
+	commands, _ := mapSliceValue(i.config, "commands").(yaml.MapSlice)
+	command, ok := mapSliceValue(commands, name).(yaml.MapSlice)
+	if !ok || slices.Contains(expanding, name) {
+		return []circleCIStep{step}
+	}
+
+	if len(params) > 0 {
+		i.run.comment("", importTodo(i.run.line(step.path...), "translate the parameters passed to the command %s", name))
+	}
+
+	steps := make([]circleCIStep, 0)
+	commandSteps, _ := mapSliceValue(command, "steps").([]any)
+	for index, commandStep := range commandSteps {
+		steps = append(steps, i.expandStep(circleCIStep{value: commandStep, path: []any{"commands", name, "steps", index}}, append(expanding, name))...)
+	}
+	return steps
+}
+
+// importStep translates a step to a task, or returns a nil task when the step isn't needed in
+// Mint. Notes about the step are returned to be written above its task.
+func (i *circleCIImporter) importStep(prefix string, jobName string, index int, step circleCIStep, use []string, jobEnv yaml.MapSlice, dir string, condition string) (yaml.MapSlice, []importComment) {
+	path := i.run.taskPath()
+	line := func(keys ...any) int {
+		return i.run.line(append(slices.Clone(step.path), keys...)...)
+	}
+	notes := make([]importComment, 0)
+	todo := func(line int, format string, args ...any) {
+		notes = append(notes, importTodo(line, format, args...))
+	}
+
+	kind := stringValue(step.value)
+	var config yaml.MapSlice
+	if mapping, ok := step.value.(yaml.MapSlice); ok && len(mapping) > 0 {
+		kind = fmt.Sprint(mapping[0].Key)
+		config, _ = mapping[0].Value.(yaml.MapSlice)
+		if command, ok := mapping[0].Value.(string); ok && (kind == "run" || kind == "deploy") {
+			config = yaml.MapSlice{{Key: "command", Value: command}}
+		}
+	}
+	stepLine := func(keys ...any) int {
+		if len(config) > 0 {
+			return line(append([]any{kind}, keys...)...)
+		}
+		return line()
+	}
+
+	if reason, ok := circleCIStepsLeftOut[kind]; ok {
+		return nil, []importComment{importNote("The %s step was left out: %s", kind, reason)}
+	}
+
+	name := stringValue(mapSliceValue(config, "name"))
+	if name == "" && kind == "run" {
+		command, _, _ := strings.Cut(strings.TrimSpace(stringValue(mapSliceValue(config, "command"))), "\n")
+		name = command
+	}
+	if name == "" {
+		name = kind
+	}
+
+	task := yaml.MapSlice{{Key: "key", Value: i.run.taskKey(prefix, jobName, name)}}
+	if len(use) > 0 {
+		task = append(task, yaml.MapItem{Key: "use", Value: importUse(use)})
+	}
+
+	env := slices.Clone(jobEnv)
+	switch kind {
+	case "checkout":
+		if mapSliceValue(config, "path") != nil {
+			todo(stepLine("path"), "translate the path the repository is checked out to")
+		}
+		if i.repository == importRepositoryPlaceholder {
+			todo(line(), "replace %s with the repository to clone", importRepositoryPlaceholder)
+		}
+		task = append(task, yaml.MapItem{Key: "call", Value: importLeafCall(i.leafVersions, "mint/git-clone")}, yaml.MapItem{Key: "with", Value: githubCloneParams(i.repository)})
+		notes = append(notes, importLeafVersionTodo(i.leafVersions, "mint/git-clone", line())...)
+		env = nil
+	case "run", "deploy":
+		script := i.translate(stringValue(mapSliceValue(config, "command")), path, stepLine("command"))
+		stepDir := stringValue(mapSliceValue(config, "working_directory"))
+		if stepDir == "" {
+			stepDir = dir
+		}
+		if relative, ok := circleCIRelativeDirectory(stepDir); !ok {
+			todo(stepLine("working_directory"), "the step runs in %s, which isn't translated", stepDir)
+		} else if relative != "" {
+			script = fmt.Sprintf("cd %s\n%s", relative, script)
+		}
+		task = append(task, yaml.MapItem{Key: "run", Value: script})
+
+		stepEnv, _ := mapSliceValue(config, "environment").(yaml.MapSlice)
+		for _, item := range stepEnv {
+			env = setMapSliceValue(env, fmt.Sprint(item.Key), item.Value)
+		}
+
+		if when := stringValue(mapSliceValue(config, "when")); when != "" && when != "on_success" {
+			todo(stepLine("when"), "the step runs %s, which isn't translated", when)
+		}
+		for _, unsupported := range []string{"background", "shell", "no_output_timeout"} {
+			if mapSliceValue(config, unsupported) != nil {
+				todo(stepLine(unsupported), "translate the %s of the step", unsupported)
+			}
+		}
+	default:
+		task = append(task, yaml.MapItem{Key: "run", Value: fmt.Sprintf("echo 'Translate the step %s' && exit 1", kind)})
+		todo(line(), "translate the step %s", kind)
+	}
+
+	if len(env) > 0 {
+		translated := make(yaml.MapSlice, 0, len(env))
+		for _, item := range env {
+			value := item.Value
+			if str, ok := value.(string); ok {
+				value = i.translate(str, path, line())
+			}
+			translated = append(translated, yaml.MapItem{Key: item.Key, Value: value})
+		}
+		task = append(task, yaml.MapItem{Key: "env", Value: translated})
+	}
+	if condition != "" {
+		task = append(task, yaml.MapItem{Key: "if", Value: condition})
+	}
+
+	return task, notes
+}
+
+// translate translates the pipeline values and built-in variables a string refers to.
+func (i *circleCIImporter) translate(str string, yamlPath string, line int) string {
+	str = reCircleCIParameter.ReplaceAllStringFunc(str, func(match string) string {
+		value := reCircleCIParameter.FindStringSubmatch(match)[1]
+		param, ok := circleCIPipelineValues[value]
+		if !ok {
+			i.run.comment(yamlPath, importTodo(line, "translate %s", match))
+			return match
+		}
+		if param != "commit-sha" {
+			i.run.init[param] = true
+		}
+		return fmt.Sprintf("${{ init.%s }}", param)
+	})
+	return i.run.translateVariables(str, "CIRCLE_", circleCIVariables, yamlPath, line)
+}
+
+// schedules translates the scheduled triggers of a workflow to cron triggers.
+func (i *circleCIImporter) schedules(workflowName string, workflow yaml.MapSlice, shared bool) []any {
+	cron := make([]any, 0)
+	triggers, _ := mapSliceValue(workflow, "triggers").([]any)
+	for index, item := range triggers {
+		trigger, _ := item.(yaml.MapSlice)
+		schedule, ok := mapSliceValue(trigger, "schedule").(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+
+		key := importKey(workflowName)
+		if len(triggers) > 1 {
+			key = fmt.Sprintf("%s-%d", key, index+1)
+		}
+		cron = append(cron, yaml.MapSlice{
+			{Key: "key", Value: key},
+			{Key: "schedule", Value: stringValue(mapSliceValue(schedule, "cron"))},
+			{Key: "init", Value: i.run.triggerInit()},
+		})
+
+		if mapSliceValue(schedule, "filters") != nil {
+			i.run.comment("$.on.cron", importTodo(i.run.line("workflows", workflowName, "triggers", index, "schedule", "filters"), "translate the filters of the schedule of the workflow %q", workflowName))
+		}
+		if shared {
+			i.run.comment("$.on.cron", importTodo(i.run.line("workflows", workflowName, "triggers"), "the workflow %q runs on a schedule, but every task runs on every trigger", workflowName))
+		}
+	}
+	return cron
+}
+
+// circleCIRelativeDirectory returns a working directory relative to the checked out repository,
+// which is an empty path for the repository itself.
+func circleCIRelativeDirectory(dir string) (string, bool) {
+	if dir == "" || dir == "." || dir == circleCIDefaultWorkingDirectory {
+		return "", true
+	}
+	if relative, ok := strings.CutPrefix(dir, circleCIDefaultWorkingDirectory+"/"); ok {
+		return relative, true
+	}
+	if strings.HasPrefix(dir, "/") || strings.HasPrefix(dir, "~") || strings.HasPrefix(dir, "$") {
+		return "", false
+	}
+	return dir, true
+}
@@ -0,0 +1,525 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// githubActionLeaf is the leaf replacing a GitHub action, along with the leaf parameters its
+// inputs translate to.
+type githubActionLeaf struct {
+	leaf   string
+	params map[string]string
+}
+
+var githubActionLeaves = map[string]githubActionLeaf{
+	"actions/setup-go":     {leaf: "mint/install-go", params: map[string]string{"go-version": "go-version"}},
+	"actions/setup-node":   {leaf: "mint/install-node", params: map[string]string{"node-version": "node-version"}},
+	"actions/setup-python": {leaf: "mint/install-python", params: map[string]string{"python-version": "python-version"}},
+	"ruby/setup-ruby":      {leaf: "mint/install-ruby", params: map[string]string{"ruby-version": "ruby-version"}},
+}
+
+// githubActionsLeftOut are the actions Mint has no need for, along with why.
+var githubActionsLeftOut = map[string]string{
+	"actions/cache":             importCacheLeftOut,
+	"actions/download-artifact": importArtifactLeftOut,
+	"actions/upload-artifact":   importArtifactLeftOut,
+}
+
+var (
+	reGitHubExpression = regexp.MustCompile(`\$\{\{(.*?)\}\}`)
+	reGitHubEnv        = regexp.MustCompile(`^env\.([A-Za-z_][A-Za-z0-9_]*)$`)
+	reGitHubToken      = regexp.MustCompile(`\b(?:secrets\.GITHUB_TOKEN|github\.token)\b`)
+	reGitHubSecret     = regexp.MustCompile(`\bsecrets\.([A-Za-z_][A-Za-z0-9_]*)`)
+	reGitHubVar        = regexp.MustCompile(`\bvars\.([A-Za-z_][A-Za-z0-9_]*)`)
+	reGitHubInput      = regexp.MustCompile(`\b(?:github\.event\.)?inputs\.([A-Za-z_][A-Za-z0-9_-]*)`)
+	reGitHubSha        = regexp.MustCompile(`\bgithub\.sha\b`)
+	reGitHubRef        = regexp.MustCompile(`\bgithub\.ref\b`)
+	reGitHubRunsOn     = regexp.MustCompile(`^ubuntu-(\d+\.\d+)$`)
+
+	// Contexts and functions without an equivalent in Mint
+	reGitHubOnly = regexp.MustCompile(`\b(github|matrix|steps|needs|runner|job|jobs|strategy|env|hashFiles|success|failure|always|cancelled|format)\b`)
+
+	githubFunctions = strings.NewReplacer("startsWith(", "starts-with(", "endsWith(", "ends-with(", "toJSON(", "to-json(", "fromJSON(", "from-json(")
+)
+
+// githubActionsImporter translates GitHub Actions workflows to run definitions: jobs and their
+// steps become tasks using one another in order, and `needs` become `use`.
+type githubActionsImporter struct {
+	repository   string
+	vault        string
+	leafVersions *api.LeafVersionsResult
+
+	// The state of the workflow being imported
+	run       *importedRun
+	runsOn    []string
+	workflow  yaml.MapSlice
+	lastTasks map[string][]string
+}
+
+func (i *githubActionsImporter) defaultFiles(root string) ([]string, error) {
+	files := make([]string, 0)
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(root, ".github", "workflows", pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+func (i *githubActionsImporter) fileName(path string) string {
+	return importedFileName(path)
+}
+
+func (i *githubActionsImporter) importFile(path string) (*importedRun, error) {
+	doc, err := ParseYAMLFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %q", path)
+	}
+
+	workflow, ok := doc.value().(yaml.MapSlice)
+	jobs, hasJobs := mapSliceValue(workflow, "jobs").(yaml.MapSlice)
+	if !ok || !hasJobs {
+		return nil, errors.Errorf("%s is not a GitHub Actions workflow", relativePathFromWd(path))
+	}
+
+	i.run = newImportedRun(path, doc)
+	i.runsOn = nil
+	i.workflow = workflow
+	i.lastTasks = make(map[string][]string)
+
+	if concurrency := mapSliceValue(workflow, "concurrency"); concurrency != nil {
+		i.run.comment("", importTodo(i.run.line("concurrency"), "translate the concurrency of the workflow, eg. with concurrency-pools"))
+	}
+
+	for _, jobID := range githubJobOrder(jobs) {
+		job, _ := mapSliceValue(jobs, jobID).(yaml.MapSlice)
+		i.importJob(jobID, job)
+	}
+
+	definition := make(yaml.MapSlice, 0, 3)
+	if triggers := i.triggers(path); len(triggers) > 0 {
+		definition = append(definition, yaml.MapItem{Key: "on", Value: triggers})
+	}
+	if base := i.base(); base != nil {
+		definition = append(definition, yaml.MapItem{Key: "base", Value: base})
+	}
+	definition = append(definition, yaml.MapItem{Key: "tasks", Value: i.run.tasks})
+	i.run.definition = definition
+
+	return i.run, nil
+}
+
+// githubJobOrder returns the IDs of jobs in the order they're defined, except that jobs come
+// after the jobs they need.
+func githubJobOrder(jobs yaml.MapSlice) []string {
+	ids := make([]string, 0, len(jobs))
+	needs := make(map[string][]string, len(jobs))
+	for _, item := range jobs {
+		jobID := fmt.Sprint(item.Key)
+		job, _ := item.Value.(yaml.MapSlice)
+		ids = append(ids, jobID)
+		needs[jobID] = stringsValue(mapSliceValue(job, "needs"))
+	}
+	return importJobOrder(ids, needs)
+}
+
+func (i *githubActionsImporter) importJob(jobID string, job yaml.MapSlice) {
+	use := make([]string, 0)
+	for _, need := range stringsValue(mapSliceValue(job, "needs")) {
+		use = append(use, i.lastTasks[need]...)
+	}
+
+	var todos []importComment
+	for _, unsupported := range []string{"strategy", "services", "container", "outputs", "environment", "concurrency", "continue-on-error"} {
+		if mapSliceValue(job, unsupported) != nil {
+			todos = append(todos, importTodo(i.run.line("jobs", jobID, unsupported), "translate the %s of the job %q", unsupported, jobID))
+		}
+	}
+
+	if runsOn := stringsValue(mapSliceValue(job, "runs-on")); len(runsOn) > 0 {
+		i.runsOn = append(i.runsOn, runsOn[0])
+		if runsOn[0] != "ubuntu-latest" && !reGitHubRunsOn.MatchString(runsOn[0]) {
+			todos = append(todos, importTodo(i.run.line("jobs", jobID, "runs-on"), "the job %q runs on %s, which isn't translated", jobID, strings.Join(runsOn, ", ")))
+		}
+	}
+
+	// Jobs calling a workflow of the repository embed its run definition
+	if workflow := stringValue(mapSliceValue(job, "uses")); workflow != "" {
+		path := i.run.taskPath()
+		task := yaml.MapSlice{{Key: "key", Value: i.run.taskKey(jobID)}}
+		if len(use) > 0 {
+			task = append(task, yaml.MapItem{Key: "use", Value: importUse(use)})
+		}
+
+		if strings.HasPrefix(workflow, "./.github/workflows/") {
+			base := filepath.Base(workflow)
+			task = append(task, yaml.MapItem{Key: "call", Value: "${{ run.mint-dir }}/" + strings.TrimSuffix(base, filepath.Ext(base)) + ".yml"})
+			if with, ok := mapSliceValue(job, "with").(yaml.MapSlice); ok {
+				task = append(task, yaml.MapItem{Key: "init", Value: i.translateMapping(with, path, i.run.line("jobs", jobID, "with"))})
+			}
+		} else {
+			task = append(task, yaml.MapItem{Key: "run", Value: fmt.Sprintf("echo 'Translate the workflow %s' && exit 1", workflow)})
+			todos = append(todos, importTodo(i.run.line("jobs", jobID, "uses"), "translate the workflow %s", workflow))
+		}
+
+		i.run.addTask(task, todos)
+		i.lastTasks[jobID] = []string{fmt.Sprint(task[0].Value)}
+		return
+	}
+
+	steps, _ := mapSliceValue(job, "steps").([]any)
+	firstTask := len(i.run.tasks)
+	for index, item := range steps {
+		step, ok := item.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+
+		task, notes := i.importStep(jobID, job, index, step, use)
+		todos = append(todos, notes...)
+		if task == nil {
+			continue
+		}
+
+		i.run.addTask(task, todos)
+		todos = nil
+		use = []string{fmt.Sprint(task[0].Value)}
+	}
+
+	// Notes about steps left out at the end of a job go with its last task
+	for _, todo := range todos {
+		i.run.comment(i.run.lastTaskPath(firstTask), todo)
+	}
+
+	i.lastTasks[jobID] = use
+}
+
+// importStep translates a step to a task, or returns a nil task when the step isn't needed in
+// Mint. Notes about the step are returned to be written above its task.
+func (i *githubActionsImporter) importStep(jobID string, job yaml.MapSlice, index int, step yaml.MapSlice, use []string) (yaml.MapSlice, []importComment) {
+	path := i.run.taskPath()
+	line := func(keys ...any) int {
+		return i.run.line(append([]any{"jobs", jobID, "steps", index}, keys...)...)
+	}
+	notes := make([]importComment, 0)
+	todo := func(line int, format string, args ...any) {
+		notes = append(notes, importTodo(line, format, args...))
+	}
+
+	uses := stringValue(mapSliceValue(step, "uses"))
+	action, _, _ := strings.Cut(uses, "@")
+	if reason, ok := githubActionsLeftOut[action]; ok {
+		return nil, []importComment{importNote("The step using %s was left out: %s", uses, reason)}
+	}
+
+	name := stringValue(mapSliceValue(step, "id"))
+	if name == "" {
+		name = stringValue(mapSliceValue(step, "name"))
+	}
+	if name == "" && action != "" {
+		name = action[strings.LastIndex(action, "/")+1:]
+	}
+	if name == "" {
+		name = fmt.Sprintf("step-%d", index+1)
+	}
+
+	task := yaml.MapSlice{{Key: "key", Value: i.run.taskKey(jobID, name)}}
+	if len(use) > 0 {
+		task = append(task, yaml.MapItem{Key: "use", Value: importUse(use)})
+	}
+
+	with, _ := mapSliceValue(step, "with").(yaml.MapSlice)
+	switch leaf, isLeaf := githubActionLeaves[action]; {
+	case action == "actions/checkout":
+		params := githubCloneParams(i.repository)
+		for _, input := range with {
+			switch key := fmt.Sprint(input.Key); key {
+			case "repository":
+				params = setMapSliceValue(params, "repository", fmt.Sprintf("https://github.com/%s.git", i.translate(stringValue(input.Value), path, line("with", key), false)))
+			case "ref":
+				params = setMapSliceValue(params, "ref", i.translate(stringValue(input.Value), path, line("with", key), false))
+			case "token":
+				params = setMapSliceValue(params, "github-access-token", i.translate(stringValue(input.Value), path, line("with", key), false))
+			default:
+				todo(line("with", key), "translate the %s input of %s", key, uses)
+			}
+		}
+		if i.repository == importRepositoryPlaceholder {
+			todo(line("uses"), "replace %s with the repository to clone", importRepositoryPlaceholder)
+		}
+
+		task = append(task, yaml.MapItem{Key: "call", Value: importLeafCall(i.leafVersions, "mint/git-clone")}, yaml.MapItem{Key: "with", Value: params})
+		notes = append(notes, importLeafVersionTodo(i.leafVersions, "mint/git-clone", line("uses"))...)
+	case isLeaf:
+		params := make(yaml.MapSlice, 0, len(with))
+		for _, input := range with {
+			key := fmt.Sprint(input.Key)
+			param, ok := leaf.params[key]
+			if !ok {
+				todo(line("with", key), "translate the %s input of %s", key, uses)
+				continue
+			}
+			params = append(params, yaml.MapItem{Key: param, Value: i.translate(stringValue(input.Value), path, line("with", key), false)})
+		}
+
+		task = append(task, yaml.MapItem{Key: "call", Value: importLeafCall(i.leafVersions, leaf.leaf)})
+		if len(params) > 0 {
+			task = append(task, yaml.MapItem{Key: "with", Value: params})
+		}
+		notes = append(notes, importLeafVersionTodo(i.leafVersions, leaf.leaf, line("uses"))...)
+	case uses != "":
+		task = append(task, yaml.MapItem{Key: "run", Value: fmt.Sprintf("echo 'Translate the step using %s' && exit 1", uses)})
+		todo(line("uses"), "translate the step using %s", uses)
+	default:
+		script := i.translate(stringValue(mapSliceValue(step, "run")), path, line("run"), true)
+		if dir := githubWorkingDirectory(i.workflow, job, step); dir != "" {
+			script = fmt.Sprintf("cd %s\n%s", dir, script)
+		}
+		if shell := stringValue(mapSliceValue(step, "shell")); shell != "" && shell != "bash" && shell != "sh" {
+			todo(line("shell"), "the step runs with %s, which isn't translated", shell)
+		}
+		task = append(task, yaml.MapItem{Key: "run", Value: script})
+	}
+
+	env := make(yaml.MapSlice, 0)
+	for _, scope := range []yaml.MapSlice{i.workflow, job, step} {
+		scopeEnv, _ := mapSliceValue(scope, "env").(yaml.MapSlice)
+		for _, item := range scopeEnv {
+			env = setMapSliceValue(env, fmt.Sprint(item.Key), item.Value)
+		}
+	}
+	if len(env) > 0 {
+		task = append(task, yaml.MapItem{Key: "env", Value: i.translateMapping(env, path, line())})
+	}
+
+	jobCondition := stringValue(mapSliceValue(job, "if"))
+	stepCondition := stringValue(mapSliceValue(step, "if"))
+	if condition := cmp.Or(stepCondition, jobCondition); condition != "" {
+		if !strings.Contains(condition, "${{") {
+			condition = "${{ " + condition + " }}"
+		}
+		task = append(task, yaml.MapItem{Key: "if", Value: i.translate(condition, path, line("if"), false)})
+		if stepCondition != "" && jobCondition != "" {
+			todo(line("if"), "combine the condition of the step with the condition of its job, %s", jobCondition)
+		}
+	}
+
+	if timeout := cmp.Or(mapSliceValue(step, "timeout-minutes"), mapSliceValue(job, "timeout-minutes")); timeout != nil {
+		task = append(task, yaml.MapItem{Key: "timeout-minutes", Value: timeout})
+	}
+	if mapSliceValue(step, "continue-on-error") != nil {
+		todo(line("continue-on-error"), "translate continue-on-error")
+	}
+
+	return task, notes
+}
+
+// translate translates the expressions of a string to Mint's. Expressions that can't be
+// translated are left as they are, with a TODO above the node at yamlPath.
+func (i *githubActionsImporter) translate(str string, yamlPath string, line int, script bool) string {
+	return reGitHubExpression.ReplaceAllStringFunc(str, func(match string) string {
+		expression := strings.TrimSpace(match[3 : len(match)-2])
+
+		// Environment variables are set on tasks, so scripts read them from the shell
+		if envVar := reGitHubEnv.FindStringSubmatch(expression); envVar != nil && script {
+			return "${" + envVar[1] + "}"
+		}
+
+		translated := githubFunctions.Replace(expression)
+		translated = reGitHubToken.ReplaceAllString(translated, "\x00")
+		translated = reGitHubSecret.ReplaceAllString(translated, "vaults."+i.vault+".secrets.${1}")
+		translated = reGitHubVar.ReplaceAllString(translated, "vaults."+i.vault+".vars.${1}")
+		translated = reGitHubInput.ReplaceAllString(translated, "init.${1}")
+		translated = reGitHubSha.ReplaceAllString(translated, "init.commit-sha")
+		if reGitHubRef.MatchString(translated) {
+			translated = reGitHubRef.ReplaceAllString(translated, "init.ref")
+			i.run.init["ref"] = true
+		}
+
+		if reGitHubOnly.MatchString(translated) {
+			i.run.comment(yamlPath, importTodo(line, "translate %s", match))
+			return match
+		}
+
+		translated = strings.ReplaceAll(translated, "\x00", fmt.Sprintf("github['%s'].token", i.repository))
+		return "${{ " + translated + " }}"
+	})
+}
+
+func (i *githubActionsImporter) translateMapping(mapping yaml.MapSlice, yamlPath string, line int) yaml.MapSlice {
+	translated := make(yaml.MapSlice, 0, len(mapping))
+	for _, item := range mapping {
+		value := item.Value
+		if str, ok := value.(string); ok {
+			value = i.translate(str, yamlPath, line, false)
+		}
+		translated = append(translated, yaml.MapItem{Key: item.Key, Value: value})
+	}
+	return translated
+}
+
+// triggers translates the events triggering the workflow. Every trigger passes the commit to
+// run on as the commit-sha init parameter.
+func (i *githubActionsImporter) triggers(path string) yaml.MapSlice {
+	init := i.run.triggerInit()
+
+	var events yaml.MapSlice
+	switch on := mapSliceValue(i.workflow, "on").(type) {
+	case yaml.MapSlice:
+		events = on
+	default:
+		for _, event := range stringsValue(on) {
+			events = append(events, yaml.MapItem{Key: event})
+		}
+	}
+
+	github := make(yaml.MapSlice, 0)
+	cron := make([]any, 0)
+	dispatch := make([]any, 0)
+	for _, item := range events {
+		event := fmt.Sprint(item.Key)
+		config, _ := item.Value.(yaml.MapSlice)
+
+		switch event {
+		case "push":
+			push := make(yaml.MapSlice, 0, 2)
+			conditions := make([]string, 0)
+			for _, branch := range stringsValue(mapSliceValue(config, "branches")) {
+				if strings.ContainsAny(branch, "*?[!") {
+					i.run.comment("$.on.github.push", importTodo(i.run.line("on", event, "branches"), "translate the branch filter %q", branch))
+					continue
+				}
+				conditions = append(conditions, fmt.Sprintf("event.git.branch == '%s'", branch))
+			}
+			if len(conditions) > 0 {
+				push = append(push, yaml.MapItem{Key: "if", Value: "${{ " + strings.Join(conditions, " || ") + " }}"})
+			}
+			push = append(push, yaml.MapItem{Key: "init", Value: init})
+			github = setMapSliceValue(github, "push", push)
+			i.filterTodos("$.on.github.push", event, config, "branches-ignore", "tags", "tags-ignore", "paths", "paths-ignore")
+		case "pull_request", "pull_request_target":
+			github = setMapSliceValue(github, "pull_request", yaml.MapSlice{{Key: "init", Value: init}})
+			i.filterTodos("$.on.github.pull_request", event, config, "branches", "branches-ignore", "types", "paths", "paths-ignore")
+		case "schedule":
+			schedules, _ := item.Value.([]any)
+			for index, schedule := range schedules {
+				key := "schedule"
+				if len(schedules) > 1 {
+					key = fmt.Sprintf("schedule-%d", index+1)
+				}
+				cron = append(cron, yaml.MapSlice{
+					{Key: "key", Value: key},
+					{Key: "schedule", Value: stringValue(mapSliceValue(schedule.(yaml.MapSlice), "cron"))},
+					{Key: "init", Value: init},
+				})
+			}
+		case "workflow_dispatch":
+			params := make([]any, 0)
+			dispatchInit := slices.Clone(init)
+			inputs, _ := mapSliceValue(config, "inputs").(yaml.MapSlice)
+			for _, input := range inputs {
+				key := fmt.Sprint(input.Key)
+				param := yaml.MapSlice{{Key: "key", Value: key}}
+				details, _ := input.Value.(yaml.MapSlice)
+				for _, field := range []string{"description", "default", "required"} {
+					if value := mapSliceValue(details, field); value != nil {
+						param = append(param, yaml.MapItem{Key: field, Value: value})
+					}
+				}
+				params = append(params, param)
+				dispatchInit = append(dispatchInit, yaml.MapItem{Key: key, Value: fmt.Sprintf("${{ event.dispatch.params.%s }}", key)})
+			}
+
+			trigger := yaml.MapSlice{{Key: "key", Value: importKey(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))}}
+			if len(params) > 0 {
+				trigger = append(trigger, yaml.MapItem{Key: "params", Value: params})
+			}
+			dispatch = append(dispatch, append(trigger, yaml.MapItem{Key: "init", Value: dispatchInit}))
+		case "workflow_call":
+			// Reusable workflows are embedded by the run definitions calling them
+		default:
+			i.run.comment("", importTodo(i.run.line("on", event), "translate the %s trigger", event))
+		}
+	}
+
+	triggers := make(yaml.MapSlice, 0)
+	if len(github) > 0 {
+		triggers = append(triggers, yaml.MapItem{Key: "github", Value: github})
+	}
+	if len(cron) > 0 {
+		triggers = append(triggers, yaml.MapItem{Key: "cron", Value: cron})
+	}
+	if len(dispatch) > 0 {
+		triggers = append(triggers, yaml.MapItem{Key: "dispatch", Value: dispatch})
+	}
+	return triggers
+}
+
+func (i *githubActionsImporter) filterTodos(yamlPath string, event string, config yaml.MapSlice, filters ...string) {
+	for _, filter := range filters {
+		if mapSliceValue(config, filter) != nil {
+			i.run.comment(yamlPath, importTodo(i.run.line("on", event, filter), "translate the %s filter of the %s trigger", filter, event))
+		}
+	}
+}
+
+// base returns the base of the run definition when its jobs run on a version of Ubuntu, to be
+// completed by resolving it. Runs on the latest Ubuntu get the default base.
+func (i *githubActionsImporter) base() yaml.MapSlice {
+	for _, runsOn := range i.runsOn {
+		if match := reGitHubRunsOn.FindStringSubmatch(runsOn); match != nil {
+			return yaml.MapSlice{{Key: "os", Value: "ubuntu " + match[1]}}
+		}
+	}
+	return nil
+}
+
+// githubCloneParams returns the parameters of mint/git-clone cloning a GitHub repository at the
+// commit a run is for.
+func githubCloneParams(repository string) yaml.MapSlice {
+	return yaml.MapSlice{
+		{Key: "repository", Value: fmt.Sprintf("https://github.com/%s.git", repository)},
+		{Key: "ref", Value: "${{ init.commit-sha }}"},
+		{Key: "github-access-token", Value: fmt.Sprintf("${{ github['%s'].token }}", repository)},
+	}
+}
+
+// githubWorkingDirectory returns the directory a step runs in, if it isn't the root of the
+// repository.
+func githubWorkingDirectory(workflow yaml.MapSlice, job yaml.MapSlice, step yaml.MapSlice) string {
+	if dir := stringValue(mapSliceValue(step, "working-directory")); dir != "" {
+		return dir
+	}
+	for _, scope := range []yaml.MapSlice{job, workflow} {
+		defaults, _ := mapSliceValue(scope, "defaults").(yaml.MapSlice)
+		run, _ := mapSliceValue(defaults, "run").(yaml.MapSlice)
+		if dir := stringValue(mapSliceValue(run, "working-directory")); dir != "" {
+			return dir
+		}
+	}
+	return ""
+}
+
+// importUse returns the value of `use` for the given keys: a single key, or a list of them.
+func importUse(keys []string) any {
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	use := make([]any, len(keys))
+	for i, key := range keys {
+		use[i] = key
+	}
+	return use
+}
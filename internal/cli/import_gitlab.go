@@ -0,0 +1,356 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// gitlabKeywords are the top-level keys of a GitLab CI/CD configuration which aren't jobs.
+var gitlabKeywords = []string{"default", "include", "stages", "variables", "workflow", "image", "services", "cache", "before_script", "after_script", "types"}
+
+// gitlabDefaultStages are the stages of pipelines which don't list their own.
+var gitlabDefaultStages = []string{".pre", "build", "test", "deploy", ".post"}
+
+// gitlabVariables are the predefined variables translating to init parameters.
+var gitlabVariables = map[string]string{
+	"CI_COMMIT_SHA":      "commit-sha",
+	"CI_COMMIT_BRANCH":   "branch",
+	"CI_COMMIT_REF_NAME": "branch",
+}
+
+// gitlabJobTodos are the keys of jobs without an equivalent in Mint.
+var gitlabJobTodos = []string{"services", "rules", "only", "except", "parallel", "retry", "allow_failure", "environment", "resource_group", "release", "secrets", "inherit"}
+
+var reGitLabTimeout = regexp.MustCompile(`^\s*(?:(\d+)\s*h(?:ours?)?)?\s*(?:(\d+)\s*m(?:in(?:utes?)?)?)?\s*$`)
+
+// gitlabImporter translates GitLab CI/CD configurations to run definitions: each job becomes a
+// task using the jobs of the previous stage, or the jobs it needs, and images of languages
+// become the matching Mint leaves.
+type gitlabImporter struct {
+	repository   string
+	vault        string
+	leafVersions *api.LeafVersionsResult
+
+	// The state of the configuration being imported
+	run       *importedRun
+	config    yaml.MapSlice
+	cloneTask string
+	jobTasks  map[string]string
+}
+
+func (i *gitlabImporter) defaultFiles(root string) ([]string, error) {
+	for _, name := range []string{".gitlab-ci.yml", ".gitlab-ci.yaml"} {
+		path := filepath.Join(root, name)
+		if _, err := os.Stat(path); err == nil {
+			return []string{path}, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (i *gitlabImporter) fileName(path string) string {
+	return importedFileName(path)
+}
+
+func (i *gitlabImporter) importFile(path string) (*importedRun, error) {
+	doc, err := ParseYAMLFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %q", path)
+	}
+
+	config, _ := doc.value().(yaml.MapSlice)
+	i.run = newImportedRun(path, doc)
+	i.config = config
+	i.jobTasks = make(map[string]string)
+
+	stages := gitlabDefaultStages
+	if listed := stringsValue(mapSliceValue(config, "stages")); len(listed) > 0 {
+		stages = append([]string{".pre"}, slices.DeleteFunc(listed, func(stage string) bool { return stage == ".pre" || stage == ".post" })...)
+		stages = append(stages, ".post")
+	}
+
+	// Jobs are imported by stage, each stage using the jobs of the previous one
+	jobsByStage := make(map[string][]string)
+	jobs := make(map[string]yaml.MapSlice)
+	for _, item := range config {
+		name := fmt.Sprint(item.Key)
+		job, ok := item.Value.(yaml.MapSlice)
+		if !ok || strings.HasPrefix(name, ".") || slices.Contains(gitlabKeywords, name) {
+			continue
+		}
+
+		jobs[name] = i.resolveJob(name, job, nil)
+		stage := stringValue(mapSliceValue(jobs[name], "stage"))
+		if stage == "" {
+			stage = "test"
+		}
+		if !slices.Contains(stages, stage) {
+			i.run.comment("", importTodo(i.run.line(name, "stage"), "the stage %s of the job %q isn't listed in the stages", stage, name))
+			stage = "test"
+		}
+		jobsByStage[stage] = append(jobsByStage[stage], name)
+	}
+	if len(jobs) == 0 {
+		return nil, errors.Errorf("%s is not a GitLab CI/CD configuration", relativePathFromWd(path))
+	}
+
+	for _, keyword := range []string{"include", "workflow"} {
+		if mapSliceValue(config, keyword) != nil {
+			i.run.comment("", importTodo(i.run.line(keyword), "translate the %s of the pipeline", keyword))
+		}
+	}
+
+	i.addCloneTask()
+
+	var previousStage []string
+	for _, stage := range stages {
+		names := jobsByStage[stage]
+		needs := make(map[string][]string, len(names))
+		for _, name := range names {
+			needs[name] = gitlabNeeds(jobs[name])
+		}
+
+		for _, name := range importJobOrder(names, needs) {
+			i.importJob(name, jobs[name], previousStage)
+		}
+
+		if len(names) > 0 {
+			previousStage = names
+		}
+	}
+
+	i.run.comment("", importTodo(0, "trigger the run definition on the events which ran the pipeline, passing init parameters such as %s", initParamList(i.run)))
+
+	i.run.definition = yaml.MapSlice{{Key: "tasks", Value: i.run.tasks}}
+	return i.run, nil
+}
+
+// resolveJob returns a job along with the keys of the jobs it extends, merged the way GitLab
+// does: mappings are merged, other values are replaced.
+func (i *gitlabImporter) resolveJob(name string, job yaml.MapSlice, extending []string) yaml.MapSlice {
+	resolved := make(yaml.MapSlice, 0)
+	for _, parent := range stringsValue(mapSliceValue(job, "extends")) {
+		parentJob, ok := mapSliceValue(i.config, parent).(yaml.MapSlice)
+		if !ok || slices.Contains(extending, parent) {
+			i.run.comment("", importTodo(i.run.line(name, "extends"), "the job %q extends %s, which isn't defined in the file", name, parent))
+			continue
+		}
+		resolved = gitlabMerge(resolved, i.resolveJob(parent, parentJob, append(extending, name)))
+	}
+
+	own := slices.DeleteFunc(slices.Clone(job), func(item yaml.MapItem) bool { return fmt.Sprint(item.Key) == "extends" })
+	return gitlabMerge(resolved, own)
+}
+
+func gitlabMerge(base yaml.MapSlice, override yaml.MapSlice) yaml.MapSlice {
+	merged := slices.Clone(base)
+	for _, item := range override {
+		key := fmt.Sprint(item.Key)
+		baseMapping, baseOk := mapSliceValue(merged, key).(yaml.MapSlice)
+		mapping, ok := item.Value.(yaml.MapSlice)
+		if baseOk && ok {
+			merged = setMapSliceValue(merged, key, gitlabMerge(baseMapping, mapping))
+		} else {
+			merged = setMapSliceValue(merged, key, item.Value)
+		}
+	}
+	return merged
+}
+
+// gitlabNeeds returns the names of the jobs a job needs, or nil when it doesn't list them.
+func gitlabNeeds(job yaml.MapSlice) []string {
+	needs, ok := mapSliceValue(job, "needs").([]any)
+	if !ok {
+		return nil
+	}
+
+	names := make([]string, 0, len(needs))
+	for _, need := range needs {
+		if mapping, ok := need.(yaml.MapSlice); ok {
+			need = mapSliceValue(mapping, "job")
+		}
+		if name := stringValue(need); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// addCloneTask adds the task cloning the repository, which jobs use unless they use other jobs.
+func (i *gitlabImporter) addCloneTask() {
+	repository := i.repository
+	comments := importLeafVersionTodo(i.leafVersions, "mint/git-clone", 0)
+	if repository == importRepositoryPlaceholder {
+		comments = append(comments, importTodo(0, "replace %s with the repository to clone", importRepositoryPlaceholder))
+	}
+	comments = append(comments, importNote("Add a deploy key of the repository to the vault %s as the secret GITLAB_SSH_KEY", i.vault))
+
+	i.cloneTask = i.run.taskKey("code")
+	i.run.addTask(yaml.MapSlice{
+		{Key: "key", Value: i.cloneTask},
+		{Key: "call", Value: importLeafCall(i.leafVersions, "mint/git-clone")},
+		{Key: "with", Value: yaml.MapSlice{
+			{Key: "repository", Value: fmt.Sprintf("git@gitlab.com:%s.git", repository)},
+			{Key: "ref", Value: "${{ init.commit-sha }}"},
+			{Key: "ssh-key", Value: fmt.Sprintf("${{ vaults.%s.secrets.GITLAB_SSH_KEY }}", i.vault)},
+		}},
+	}, comments)
+}
+
+func (i *gitlabImporter) importJob(name string, job yaml.MapSlice, previousStage []string) {
+	path := i.run.taskPath()
+	line := func(keys ...any) int {
+		if line := i.run.line(append([]any{name}, keys...)...); line > 0 {
+			return line
+		}
+		return i.run.line(name)
+	}
+	comments := make([]importComment, 0)
+	todo := func(line int, format string, args ...any) {
+		comments = append(comments, importTodo(line, format, args...))
+	}
+
+	use := make([]string, 0)
+	needs := gitlabNeeds(job)
+	if mapSliceValue(job, "needs") == nil {
+		needs = previousStage
+	}
+	for _, need := range needs {
+		if task, ok := i.jobTasks[need]; ok {
+			use = append(use, task)
+		}
+	}
+	if len(use) == 0 {
+		use = append(use, i.cloneTask)
+	}
+
+	image := i.inherited(job, "image")
+	if mapping, ok := image.(yaml.MapSlice); ok {
+		image = mapSliceValue(mapping, "name")
+	}
+	if image := stringValue(image); image != "" {
+		if task, ok := i.run.imageTask(i.leafVersions, image, line("image")); !ok {
+			todo(line("image"), "the job %q runs in the image %s, which isn't translated", name, image)
+		} else if task != "" {
+			use = append(use, task)
+		}
+	}
+
+	task := yaml.MapSlice{
+		{Key: "key", Value: i.run.taskKey(name)},
+		{Key: "use", Value: importUse(use)},
+	}
+
+	if trigger := mapSliceValue(job, "trigger"); trigger != nil {
+		task = append(task, yaml.MapItem{Key: "run", Value: fmt.Sprintf("echo 'Translate the pipeline triggered by %s' && exit 1", name)})
+		todo(line("trigger"), "translate the pipeline triggered by the job %q", name)
+		i.run.addTask(task, comments)
+		i.jobTasks[name] = fmt.Sprint(task[0].Value)
+		return
+	}
+
+	lines := stringsValue(i.inherited(job, "before_script"))
+	lines = append(lines, stringsValue(mapSliceValue(job, "script"))...)
+	script := i.run.translateVariables(strings.Join(lines, "\n"), "CI_", gitlabVariables, path, line("script"))
+	task = append(task, yaml.MapItem{Key: "run", Value: script})
+	if afterScript := i.inherited(job, "after_script"); afterScript != nil {
+		todo(line("after_script"), "translate the after_script of the job %q, which runs whether its script succeeds or not", name)
+	}
+
+	env := make(yaml.MapSlice, 0)
+	for _, scope := range []yaml.MapSlice{i.config, job} {
+		variables, _ := mapSliceValue(scope, "variables").(yaml.MapSlice)
+		for _, variable := range variables {
+			value := variable.Value
+			if mapping, ok := value.(yaml.MapSlice); ok {
+				value = mapSliceValue(mapping, "value")
+			}
+			if str, ok := value.(string); ok {
+				value = i.run.translateVariables(str, "CI_", gitlabVariables, path, line("variables", fmt.Sprint(variable.Key)))
+			}
+			env = setMapSliceValue(env, fmt.Sprint(variable.Key), value)
+		}
+	}
+	if len(env) > 0 {
+		task = append(task, yaml.MapItem{Key: "env", Value: env})
+	}
+
+	if timeout := stringValue(mapSliceValue(job, "timeout")); timeout != "" {
+		if minutes, ok := gitlabTimeoutMinutes(timeout); ok {
+			task = append(task, yaml.MapItem{Key: "timeout-minutes", Value: minutes})
+		} else {
+			todo(line("timeout"), "translate the timeout %s", timeout)
+		}
+	}
+
+	if when := stringValue(mapSliceValue(job, "when")); when != "" && when != "on_success" {
+		todo(line("when"), "the job %q runs when %s, which isn't translated", name, when)
+	}
+	for _, unsupported := range gitlabJobTodos {
+		if mapSliceValue(job, unsupported) != nil {
+			todo(line(unsupported), "translate the %s of the job %q", unsupported, name)
+		}
+	}
+
+	if i.inherited(job, "cache") != nil {
+		comments = append(comments, importNote("The cache of the job was left out: %s", importCacheLeftOut))
+	}
+	if artifacts, ok := mapSliceValue(job, "artifacts").(yaml.MapSlice); ok {
+		if mapSliceValue(artifacts, "paths") != nil || mapSliceValue(artifacts, "untracked") != nil {
+			comments = append(comments, importNote("The artifacts of the job were left out: %s", importArtifactLeftOut))
+		}
+		if mapSliceValue(artifacts, "reports") != nil {
+			todo(line("artifacts", "reports"), "translate the reports of the job %q", name)
+		}
+	}
+
+	i.run.addTask(task, comments)
+	i.jobTasks[name] = fmt.Sprint(task[0].Value)
+}
+
+// inherited returns a key of a job, or its default for every job.
+func (i *gitlabImporter) inherited(job yaml.MapSlice, key string) any {
+	if value := mapSliceValue(job, key); value != nil {
+		return value
+	}
+	defaults, _ := mapSliceValue(i.config, "default").(yaml.MapSlice)
+	if value := mapSliceValue(defaults, key); value != nil {
+		return value
+	}
+	return mapSliceValue(i.config, key)
+}
+
+// gitlabTimeoutMinutes converts a timeout such as "1h 30m" to minutes.
+func gitlabTimeoutMinutes(timeout string) (int, bool) {
+	match := reGitLabTimeout.FindStringSubmatch(timeout)
+	if match == nil || (match[1] == "" && match[2] == "") {
+		return 0, false
+	}
+
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	return hours*60 + minutes, true
+}
+
+// initParamList lists the init parameters a run definition refers to, eg. "commit-sha and branch".
+func initParamList(run *importedRun) string {
+	params := make([]string, 0)
+	for _, item := range run.triggerInit() {
+		params = append(params, fmt.Sprint(item.Key))
+	}
+	if len(params) == 1 {
+		return params[0]
+	}
+	return strings.Join(params[:len(params)-1], ", ") + " and " + params[len(params)-1]
+}
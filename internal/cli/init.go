@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// initRunDefinitionName is the name of the run definition written by `mint init`.
+const initRunDefinitionName = "ci.yml"
+
+var (
+	reInitVersion      = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)$`)
+	reInitGoVersion    = regexp.MustCompile(`(?m)^go (\d+\.\d+(?:\.\d+)?)\s*$`)
+	reInitRustChannel  = regexp.MustCompile(`(?m)^\s*channel\s*=\s*"(\d+\.\d+(?:\.\d+)?)"`)
+	reInitGitHubRemote = regexp.MustCompile(`github\.com[:/]([\w.-]+/[\w.-]+?)(?:\.git)?/?$`)
+)
+
+// initToolchain is how a project of a language is built: the leaf installing the language, the
+// command installing dependencies, which only depends on some of the files of the project so
+// that it's cached, and the command running the tests.
+type initToolchain struct {
+	leaf       string
+	params     yaml.MapSlice
+	deps       string
+	depsFilter []string
+	test       string
+}
+
+// initLanguage is a language detected by the files projects of it have, eg. go.mod for Go.
+type initLanguage struct {
+	name      string
+	key       string
+	markers   []string
+	toolchain func(root string) initToolchain
+}
+
+var initLanguages = []initLanguage{
+	{name: "Go", key: "go", markers: []string{"go.mod"}, toolchain: initGoToolchain},
+	{name: "Node.js", key: "node", markers: []string{"package.json"}, toolchain: initNodeToolchain},
+	{name: "Python", key: "python", markers: []string{"pyproject.toml", "requirements.txt"}, toolchain: initPythonToolchain},
+	{name: "Ruby", key: "ruby", markers: []string{"Gemfile"}, toolchain: initRubyToolchain},
+	{name: "Rust", key: "rust", markers: []string{"Cargo.toml"}, toolchain: initRustToolchain},
+}
+
+// detectLanguages returns the languages of the project at root.
+func detectLanguages(root string) []initLanguage {
+	detected := make([]initLanguage, 0)
+	for _, language := range initLanguages {
+		if slices.ContainsFunc(language.markers, func(marker string) bool { return initFileExists(root, marker) }) {
+			detected = append(detected, language)
+		}
+	}
+	return detected
+}
+
+// initRunDefinition returns a starter run definition for the project at root, which clones the
+// repository and installs, caches the dependencies of and tests each of its languages. Leaves
+// are called without a version, to be resolved.
+func initRunDefinition(root string, repository string, languages []initLanguage) (yaml.MapSlice, []string, map[string][]string) {
+	header := make([]string, 0)
+	comments := make(map[string][]string)
+	if repository == importRepositoryPlaceholder {
+		header = append(header, fmt.Sprintf("TODO: replace %s with the repository to clone", importRepositoryPlaceholder))
+	}
+
+	tasks := []any{yaml.MapSlice{
+		{Key: "key", Value: "code"},
+		{Key: "call", Value: "mint/git-clone"},
+		{Key: "with", Value: githubCloneParams(repository)},
+	}}
+
+	for _, language := range languages {
+		toolchain := language.toolchain(root)
+
+		install := yaml.MapSlice{{Key: "key", Value: language.key}, {Key: "call", Value: toolchain.leaf}}
+		if len(toolchain.params) > 0 {
+			install = append(install, yaml.MapItem{Key: "with", Value: toolchain.params})
+		}
+		tasks = append(tasks, install)
+
+		use := []string{"code", language.key}
+		if toolchain.deps != "" {
+			deps := yaml.MapSlice{
+				{Key: "key", Value: language.key + "-deps"},
+				{Key: "use", Value: importUse(use)},
+				{Key: "run", Value: toolchain.deps},
+			}
+
+			filter := slices.DeleteFunc(slices.Clone(toolchain.depsFilter), func(file string) bool { return !initFileExists(root, file) })
+			if len(filter) > 0 {
+				deps = append(deps, yaml.MapItem{Key: "filter", Value: filter})
+				comments[fmt.Sprintf("$.tasks[%d]", len(tasks))] = []string{"Dependencies are installed from these files alone, so that they're cached until the files change"}
+			}
+			tasks = append(tasks, deps)
+			use = []string{"code", language.key + "-deps"}
+		}
+
+		if toolchain.test != "" {
+			tasks = append(tasks, yaml.MapSlice{
+				{Key: "key", Value: language.key + "-test"},
+				{Key: "use", Value: importUse(use)},
+				{Key: "run", Value: toolchain.test},
+			})
+		}
+	}
+
+	if len(languages) == 0 {
+		comments[fmt.Sprintf("$.tasks[%d]", len(tasks))] = []string{"TODO: build and test the project"}
+		tasks = append(tasks, yaml.MapSlice{
+			{Key: "key", Value: "test"},
+			{Key: "use", Value: "code"},
+			{Key: "run", Value: "echo 'Test the project'"},
+		})
+	}
+
+	definition := yaml.MapSlice{
+		{Key: "on", Value: yaml.MapSlice{
+			{Key: "github", Value: yaml.MapSlice{
+				{Key: "push", Value: yaml.MapSlice{
+					{Key: "init", Value: yaml.MapSlice{{Key: "commit-sha", Value: "${{ event.git.sha }}"}}},
+				}},
+			}},
+		}},
+		{Key: "tasks", Value: tasks},
+	}
+	return definition, header, comments
+}
+
+func initGoToolchain(root string) initToolchain {
+	toolchain := initToolchain{
+		leaf:       "mint/install-go",
+		deps:       "go mod download",
+		depsFilter: []string{"go.mod", "go.sum"},
+		test:       "go test ./...",
+	}
+	if match := reInitGoVersion.FindStringSubmatch(initReadFile(root, "go.mod")); match != nil {
+		toolchain.params = yaml.MapSlice{{Key: "go-version", Value: match[1]}}
+	}
+	return toolchain
+}
+
+func initNodeToolchain(root string) initToolchain {
+	toolchain := initToolchain{leaf: "mint/install-node"}
+
+	var pkg struct {
+		Engines struct {
+			Node string `json:"node"`
+		} `json:"engines"`
+		Scripts map[string]string `json:"scripts"`
+	}
+	_ = json.Unmarshal([]byte(initReadFile(root, "package.json")), &pkg)
+
+	for _, version := range []string{initReadFile(root, ".nvmrc"), initReadFile(root, ".node-version"), pkg.Engines.Node} {
+		if match := reInitVersion.FindStringSubmatch(strings.TrimSpace(version)); match != nil {
+			toolchain.params = yaml.MapSlice{{Key: "node-version", Value: match[1]}}
+			break
+		}
+	}
+
+	packageManager := "npm"
+	switch {
+	case initFileExists(root, "pnpm-lock.yaml"):
+		packageManager = "pnpm"
+		toolchain.deps = "corepack enable\npnpm install --frozen-lockfile"
+		toolchain.depsFilter = []string{"package.json", "pnpm-lock.yaml"}
+	case initFileExists(root, "yarn.lock"):
+		packageManager = "yarn"
+		toolchain.deps = "corepack enable\nyarn install"
+		toolchain.depsFilter = []string{"package.json", "yarn.lock", ".yarnrc.yml"}
+	case initFileExists(root, "package-lock.json"):
+		toolchain.deps = "npm ci"
+		toolchain.depsFilter = []string{"package.json", "package-lock.json"}
+	default:
+		toolchain.deps = "npm install"
+		toolchain.depsFilter = []string{"package.json"}
+	}
+
+	if pkg.Scripts["test"] != "" {
+		toolchain.test = packageManager + " test"
+	}
+	return toolchain
+}
+
+func initPythonToolchain(root string) initToolchain {
+	toolchain := initToolchain{leaf: "mint/install-python"}
+	if match := reInitVersion.FindStringSubmatch(strings.TrimSpace(initReadFile(root, ".python-version"))); match != nil {
+		toolchain.params = yaml.MapSlice{{Key: "python-version", Value: match[1]}}
+	}
+
+	// Projects configured by pyproject.toml need their sources to be installed
+	if initFileExists(root, "requirements.txt") {
+		toolchain.deps = "pip install -r requirements.txt"
+		toolchain.depsFilter = []string{"requirements.txt"}
+	} else {
+		toolchain.deps = "pip install ."
+	}
+
+	if initFileExists(root, "tests") || initFileExists(root, "pytest.ini") || initFileExists(root, "conftest.py") {
+		toolchain.test = "python -m pytest"
+	}
+	return toolchain
+}
+
+func initRubyToolchain(root string) initToolchain {
+	toolchain := initToolchain{
+		leaf:       "mint/install-ruby",
+		deps:       "bundle install",
+		depsFilter: []string{"Gemfile", "Gemfile.lock", ".ruby-version"},
+	}
+	if match := reInitVersion.FindStringSubmatch(strings.TrimSpace(initReadFile(root, ".ruby-version"))); match != nil {
+		toolchain.params = yaml.MapSlice{{Key: "ruby-version", Value: match[1]}}
+	}
+
+	switch {
+	case initFileExists(root, "spec"):
+		toolchain.test = "bundle exec rspec"
+	case initFileExists(root, "test"):
+		toolchain.test = "bundle exec rake test"
+	}
+	return toolchain
+}
+
+func initRustToolchain(root string) initToolchain {
+	// Dependencies aren't fetched apart from the sources, since cargo needs the targets of the
+	// crates to fetch them
+	toolchain := initToolchain{leaf: "mint/install-rust", test: "cargo test"}
+
+	channel := initReadFile(root, "rust-toolchain.toml")
+	if match := reInitRustChannel.FindStringSubmatch(channel); match != nil {
+		toolchain.params = yaml.MapSlice{{Key: "rust-version", Value: match[1]}}
+	} else if match := reInitVersion.FindStringSubmatch(strings.TrimSpace(initReadFile(root, "rust-toolchain"))); match != nil {
+		toolchain.params = yaml.MapSlice{{Key: "rust-version", Value: match[1]}}
+	}
+	return toolchain
+}
+
+// initGitHubRepository returns the GitHub repository the origin remote of the project at root
+// refers to, eg. rwx-research/mint-cli, or an empty string when there's none.
+func initGitHubRepository(root string) string {
+	file, err := os.Open(filepath.Join(root, ".git", "config"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	inOrigin := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !inOrigin || !ok || strings.TrimSpace(key) != "url" {
+			continue
+		}
+		if match := reInitGitHubRemote.FindStringSubmatch(strings.TrimSpace(value)); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+func initFileExists(root string, name string) bool {
+	_, err := os.Stat(filepath.Join(root, name))
+	return err == nil
+}
+
+func initReadFile(root string, name string) string {
+	contents, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return ""
+	}
+	return string(contents)
+}
@@ -10,6 +10,8 @@ type APIClient interface {
 	GetDebugConnectionInfo(debugKey string) (api.DebugConnectionInfo, error)
 	GetDispatch(api.GetDispatchConfig) (*api.GetDispatchResult, error)
 	InitiateRun(api.InitiateRunConfig) (*api.InitiateRunResult, error)
+	FindMissingBlobs(api.FindMissingBlobsConfig) (*api.FindMissingBlobsResult, error)
+	UploadBlob(api.UploadBlobConfig) error
 	InitiateDispatch(api.InitiateDispatchConfig) (*api.InitiateDispatchResult, error)
 	ObtainAuthCode(api.ObtainAuthCodeConfig) (*api.ObtainAuthCodeResult, error)
 	AcquireToken(tokenUrl string) (*api.AcquireTokenResult, error)
@@ -18,6 +20,14 @@ type APIClient interface {
 	SetSecretsInVault(api.SetSecretsInVaultConfig) (*api.SetSecretsInVaultResult, error)
 	GetLeafVersions() (*api.LeafVersionsResult, error)
 	ResolveBaseLayer(api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error)
+	ListBaseLayers() (*api.ListBaseLayersResult, error)
+	GetBaseLayerMigration(api.GetBaseLayerMigrationConfig) (*api.BaseLayerMigrationResult, error)
+	ResolveLeafDigests(api.ResolveLeafDigestsConfig) (*api.ResolveLeafDigestsResult, error)
+	GetLeafReleases(api.GetLeafReleasesConfig) (*api.LeafReleasesResult, error)
+	SearchLeaves(api.SearchLeavesConfig) (*api.SearchLeavesResult, error)
+	GetLeaf(api.GetLeafConfig) (*api.LeafDetails, error)
+	ResolveLeafVersions(api.ResolveLeafVersionsConfig) (*api.LeafVersionsResult, error)
+	ResolveLeafGitRef(api.ResolveLeafGitRefConfig) (*api.ResolveLeafGitRefResult, error)
 }
 
 type SSHClient interface {
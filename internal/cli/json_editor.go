@@ -0,0 +1,638 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// jsonEditor modifies JSON documents as text. Only the edited values are rewritten, so the key
+// order, indentation and formatting of the rest of the document are preserved. New values follow
+// the style of their surroundings: compact containers get compact values and multi-line
+// containers get values indented like their siblings.
+type jsonEditor struct {
+	src  string
+	root *jsonValue
+}
+
+// jsonValue is a value of a JSON document along with its location in the document's text.
+type jsonValue struct {
+	start   int
+	end     int
+	kind    byte // '{' for objects, '[' for arrays and 0 for anything else
+	members []jsonMember
+	items   []*jsonValue
+	parent  *jsonValue
+}
+
+type jsonMember struct {
+	key   string
+	start int
+	value *jsonValue
+}
+
+// jsonField is an object member of a value being encoded, keeping the order of its keys.
+type jsonField struct {
+	key   string
+	value any
+}
+
+func newJSONEditor(src string) (*jsonEditor, error) {
+	editor := &jsonEditor{src: src}
+	if err := editor.parse(); err != nil {
+		return nil, err
+	}
+	return editor, nil
+}
+
+// ReplaceAtPath replaces the value at the given path.
+func (e *jsonEditor) ReplaceAtPath(jsonPath string, replacement any) error {
+	segments, err := parseYAMLPath(jsonPath)
+	if err != nil {
+		return err
+	}
+
+	value, err := e.lookup(segments)
+	if err != nil {
+		return err
+	}
+
+	normalized, err := normalizeJSON(replacement)
+	if err != nil {
+		return err
+	}
+
+	return e.replaceValue(value, normalized)
+}
+
+// MergeAtPath merges the given object into the object at the given path. Existing keys keep
+// their position and nested objects are merged recursively; new keys are appended.
+func (e *jsonEditor) MergeAtPath(jsonPath string, value any) error {
+	segments, err := parseYAMLPath(jsonPath)
+	if err != nil {
+		return err
+	}
+
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return err
+	}
+
+	fields, ok := normalized.([]jsonField)
+	if !ok {
+		return fmt.Errorf("unable to merge %T into an object", value)
+	}
+
+	return e.merge(segments, fields)
+}
+
+// SetAtPath sets the value of the member at the given path, adding it when it doesn't exist.
+func (e *jsonEditor) SetAtPath(jsonPath string, value any) error {
+	segments, err := parseYAMLPath(jsonPath)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 || segments[len(segments)-1].isIndex {
+		return fmt.Errorf("expected %q to refer to an object member", jsonPath)
+	}
+
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return err
+	}
+
+	parent, err := e.lookup(segments[:len(segments)-1])
+	if err != nil {
+		return err
+	}
+	if parent.kind != '{' {
+		return fmt.Errorf("expected an object at %q", jsonPath)
+	}
+
+	key := segments[len(segments)-1].key
+	if index := parent.memberIndex(key); index != -1 {
+		return e.replaceValue(parent.members[index].value, normalized)
+	}
+	return e.insertMember(parent, len(parent.members), jsonField{key: key, value: normalized})
+}
+
+// InsertBefore inserts the members of the given object before the root member at the given path.
+func (e *jsonEditor) InsertBefore(jsonPath string, value any) error {
+	segments, err := parseYAMLPath(jsonPath)
+	if err != nil {
+		return err
+	}
+	if len(segments) != 1 || segments[0].isIndex || e.root.kind != '{' {
+		return fmt.Errorf("expected %q to refer to a member of the root object", jsonPath)
+	}
+
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return err
+	}
+
+	fields, ok := normalized.([]jsonField)
+	if !ok {
+		return fmt.Errorf("unable to insert %T into an object", value)
+	}
+
+	index := e.root.memberIndex(segments[0].key)
+	if index == -1 {
+		return fmt.Errorf("unable to find %q", jsonPath)
+	}
+
+	for i, field := range fields {
+		if err := e.insertMember(e.root, index+i, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppendToSequence adds value as the last item of the array at the given path.
+func (e *jsonEditor) AppendToSequence(jsonPath string, value any) error {
+	segments, err := parseYAMLPath(jsonPath)
+	if err != nil {
+		return err
+	}
+
+	array, err := e.lookup(segments)
+	if err != nil {
+		return err
+	}
+	if array.kind != '[' {
+		return fmt.Errorf("expected an array at %q", jsonPath)
+	}
+
+	normalized, err := normalizeJSON(value)
+	if err != nil {
+		return err
+	}
+
+	return e.insertChild(array, len(array.items), func(prefix string, indent string) string {
+		return formatJSON(normalized, prefix, indent)
+	})
+}
+
+func (e *jsonEditor) merge(segments []yamlPathSegment, fields []jsonField) error {
+	for _, field := range fields {
+		// Every edit moves the values after it, so they're looked up again for each field
+		object, err := e.lookup(segments)
+		if err != nil {
+			return err
+		}
+		if object.kind != '{' {
+			return errors.New("unable to merge into a value that isn't an object")
+		}
+
+		index := object.memberIndex(field.key)
+		if index == -1 {
+			if err := e.insertMember(object, len(object.members), field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		nestedFields, isObject := field.value.([]jsonField)
+		if isObject && object.members[index].value.kind == '{' {
+			if err := e.merge(append(slices.Clone(segments), yamlPathSegment{key: field.key}), nestedFields); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := e.replaceValue(object.members[index].value, field.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *jsonEditor) replaceValue(value *jsonValue, normalized any) error {
+	indent := e.indentOf(value)
+	if value.kind == 0 && value.parent != nil {
+		indent = e.indentOf(value.parent)
+	}
+
+	return e.splice(value.start, value.end, formatJSON(normalized, lineIndent(e.src, value.start), indent))
+}
+
+func (e *jsonEditor) insertMember(object *jsonValue, index int, field jsonField) error {
+	return e.insertChild(object, index, func(prefix string, indent string) string {
+		return quoteJSON(field.key) + ": " + formatJSON(field.value, prefix, indent)
+	})
+}
+
+// insertChild inserts a member or item into a container at the given index. The child is
+// formatted with the indentation prefix of its line and the indentation unit of its siblings.
+func (e *jsonEditor) insertChild(container *jsonValue, index int, child func(prefix string, indent string) string) error {
+	starts, ends, siblings := container.children()
+	indent := e.indentOf(container)
+	multiline := indent != ""
+
+	prefix := lineIndent(e.src, container.start) + indent
+	if len(starts) > 0 {
+		prefix = lineIndent(e.src, starts[0])
+	}
+
+	childIndent := indent
+	if len(siblings) > 0 && siblings[len(siblings)-1].kind != 0 {
+		childIndent = e.indentOf(siblings[len(siblings)-1])
+	}
+	text := child(prefix, childIndent)
+
+	switch {
+	case len(starts) == 0 && multiline:
+		return e.splice(container.start+1, container.end-1, "\n"+prefix+text+"\n"+lineIndent(e.src, container.start))
+	case len(starts) == 0:
+		return e.splice(container.start+1, container.end-1, text)
+	case index < len(starts) && multiline:
+		return e.splice(starts[index], starts[index], text+",\n"+prefix)
+	case index < len(starts):
+		return e.splice(starts[index], starts[index], text+", ")
+	case multiline:
+		return e.splice(ends[len(ends)-1], ends[len(ends)-1], ",\n"+prefix+text)
+	default:
+		return e.splice(ends[len(ends)-1], ends[len(ends)-1], ", "+text)
+	}
+}
+
+// indentOf returns the unit of indentation of the children of a container, or an empty string
+// when it's written on a single line. Empty containers follow the style of their parent.
+func (e *jsonEditor) indentOf(container *jsonValue) string {
+	starts, _, _ := container.children()
+	if len(starts) == 0 {
+		if container.parent != nil {
+			return e.indentOf(container.parent)
+		}
+		return "  "
+	}
+
+	if !strings.Contains(e.src[container.start:starts[0]], "\n") {
+		return ""
+	}
+
+	indent, ok := strings.CutPrefix(lineIndent(e.src, starts[0]), lineIndent(e.src, container.start))
+	if !ok || indent == "" {
+		return "  "
+	}
+	return indent
+}
+
+func (e *jsonEditor) splice(start int, end int, text string) error {
+	e.src = e.src[:start] + text + e.src[end:]
+	return e.parse()
+}
+
+func (e *jsonEditor) lookup(segments []yamlPathSegment) (*jsonValue, error) {
+	value := e.root
+	for _, segment := range segments {
+		switch {
+		case segment.isIndex && value.kind == '[' && segment.index < len(value.items):
+			value = value.items[segment.index]
+		case !segment.isIndex && !segment.isWildcard && value.kind == '{' && value.memberIndex(segment.key) != -1:
+			value = value.members[value.memberIndex(segment.key)].value
+		default:
+			return nil, errors.Wrapf(yaml.ErrNotFoundNode, "unable to find %s", formatYAMLPath(segments))
+		}
+	}
+	return value, nil
+}
+
+func (e *jsonEditor) parse() error {
+	scanner := &jsonScanner{src: e.src}
+	scanner.skipSpace()
+
+	root, err := scanner.value(nil)
+	if err != nil {
+		return err
+	}
+
+	scanner.skipSpace()
+	if scanner.pos != len(e.src) {
+		return fmt.Errorf("unexpected %q at offset %d", e.src[scanner.pos], scanner.pos)
+	}
+
+	e.root = root
+	return nil
+}
+
+func (v *jsonValue) memberIndex(key string) int {
+	// Like encoding/json, the last of duplicate keys wins
+	for i := len(v.members) - 1; i >= 0; i-- {
+		if v.members[i].key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// children returns where each member or item of a container starts and ends, along with their
+// values.
+func (v *jsonValue) children() ([]int, []int, []*jsonValue) {
+	starts := make([]int, 0, len(v.members)+len(v.items))
+	ends := make([]int, 0, len(v.members)+len(v.items))
+	values := make([]*jsonValue, 0, len(v.members)+len(v.items))
+
+	for _, member := range v.members {
+		starts = append(starts, member.start)
+		ends = append(ends, member.value.end)
+		values = append(values, member.value)
+	}
+	for _, item := range v.items {
+		starts = append(starts, item.start)
+		ends = append(ends, item.end)
+		values = append(values, item)
+	}
+
+	return starts, ends, values
+}
+
+type jsonScanner struct {
+	src string
+	pos int
+}
+
+func (s *jsonScanner) value(parent *jsonValue) (*jsonValue, error) {
+	if s.pos >= len(s.src) {
+		return nil, errors.New("unexpected end of JSON")
+	}
+
+	value := &jsonValue{start: s.pos, parent: parent}
+
+	switch s.src[s.pos] {
+	case '{':
+		value.kind = '{'
+		s.pos++
+		s.skipSpace()
+		if s.peek() == '}' {
+			s.pos++
+			value.end = s.pos
+			return value, nil
+		}
+
+		for {
+			s.skipSpace()
+			keyStart := s.pos
+			key, err := s.string()
+			if err != nil {
+				return nil, err
+			}
+
+			s.skipSpace()
+			if err := s.expect(':'); err != nil {
+				return nil, err
+			}
+			s.skipSpace()
+
+			memberValue, err := s.value(value)
+			if err != nil {
+				return nil, err
+			}
+			value.members = append(value.members, jsonMember{key: key, start: keyStart, value: memberValue})
+
+			s.skipSpace()
+			if s.peek() == ',' {
+				s.pos++
+				continue
+			}
+			if err := s.expect('}'); err != nil {
+				return nil, err
+			}
+			value.end = s.pos
+			return value, nil
+		}
+	case '[':
+		value.kind = '['
+		s.pos++
+		s.skipSpace()
+		if s.peek() == ']' {
+			s.pos++
+			value.end = s.pos
+			return value, nil
+		}
+
+		for {
+			s.skipSpace()
+			item, err := s.value(value)
+			if err != nil {
+				return nil, err
+			}
+			value.items = append(value.items, item)
+
+			s.skipSpace()
+			if s.peek() == ',' {
+				s.pos++
+				continue
+			}
+			if err := s.expect(']'); err != nil {
+				return nil, err
+			}
+			value.end = s.pos
+			return value, nil
+		}
+	case '"':
+		if _, err := s.string(); err != nil {
+			return nil, err
+		}
+	default:
+		for s.pos < len(s.src) && !strings.ContainsRune(",:]} \t\r\n", rune(s.src[s.pos])) {
+			s.pos++
+		}
+		if s.pos == value.start {
+			return nil, fmt.Errorf("unexpected %q at offset %d", s.src[s.pos], s.pos)
+		}
+	}
+
+	value.end = s.pos
+	return value, nil
+}
+
+func (s *jsonScanner) string() (string, error) {
+	if s.peek() != '"' {
+		return "", fmt.Errorf("expected a string at offset %d", s.pos)
+	}
+
+	start := s.pos
+	for s.pos++; s.pos < len(s.src); s.pos++ {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			s.pos++
+			var str string
+			err := json.Unmarshal([]byte(s.src[start:s.pos]), &str)
+			return str, err
+		}
+	}
+
+	return "", errors.New("unterminated string in JSON")
+}
+
+func (s *jsonScanner) expect(char byte) error {
+	if s.peek() != char {
+		return fmt.Errorf("expected %q at offset %d", char, s.pos)
+	}
+	s.pos++
+	return nil
+}
+
+func (s *jsonScanner) peek() byte {
+	if s.pos >= len(s.src) {
+		return 0
+	}
+	return s.src[s.pos]
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.src) && strings.ContainsRune(" \t\r\n", rune(s.src[s.pos])) {
+		s.pos++
+	}
+}
+
+// normalizeJSON converts a value to []jsonField for objects, []any for arrays and json.RawMessage
+// for anything else. Keys of ordered maps keep their order; keys of other maps are sorted like
+// encoding/json does.
+func normalizeJSON(value any) (any, error) {
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		fields := make([]jsonField, 0, len(v))
+		for _, item := range v {
+			normalized, err := normalizeJSON(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, jsonField{key: fmt.Sprint(item.Key), value: normalized})
+		}
+		return fields, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		fields := make([]jsonField, 0, len(v))
+		for _, key := range keys {
+			normalized, err := normalizeJSON(v[key])
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, jsonField{key: key, value: normalized})
+		}
+		return fields, nil
+	case []any:
+		items := make([]any, 0, len(v))
+		for _, item := range v {
+			normalized, err := normalizeJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, normalized)
+		}
+		return items, nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	encoded := bytes.TrimSpace(buf.Bytes())
+
+	// Other maps and slices are decoded into their generic form to be formatted consistently
+	if len(encoded) > 0 && (encoded[0] == '{' || encoded[0] == '[') {
+		decoder := json.NewDecoder(bytes.NewReader(encoded))
+		decoder.UseNumber()
+
+		var generic any
+		if err := decoder.Decode(&generic); err != nil {
+			return nil, err
+		}
+		return normalizeJSON(generic)
+	}
+
+	return json.RawMessage(encoded), nil
+}
+
+// formatJSON formats a normalized value. Nested lines start with prefix and are indented by
+// indent; an empty indent formats the value on a single line.
+func formatJSON(value any, prefix string, indent string) string {
+	var b strings.Builder
+	writeJSON(&b, value, prefix, indent)
+	return b.String()
+}
+
+func writeJSON(b *strings.Builder, value any, prefix string, indent string) {
+	switch v := value.(type) {
+	case []jsonField:
+		if len(v) == 0 {
+			b.WriteString("{}")
+			return
+		}
+
+		b.WriteString("{")
+		for i, field := range v {
+			writeJSONSeparator(b, i, prefix+indent, indent)
+			b.WriteString(quoteJSON(field.key) + ": ")
+			writeJSON(b, field.value, prefix+indent, indent)
+		}
+		writeJSONSeparator(b, -1, prefix, indent)
+		b.WriteString("}")
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return
+		}
+
+		b.WriteString("[")
+		for i, item := range v {
+			writeJSONSeparator(b, i, prefix+indent, indent)
+			writeJSON(b, item, prefix+indent, indent)
+		}
+		writeJSONSeparator(b, -1, prefix, indent)
+		b.WriteString("]")
+	case json.RawMessage:
+		b.Write(v)
+	}
+}
+
+// writeJSONSeparator writes what precedes the child at the given index of a container, or what
+// precedes its closing bracket for index -1.
+func writeJSONSeparator(b *strings.Builder, index int, prefix string, indent string) {
+	if index > 0 {
+		b.WriteString(",")
+	}
+
+	switch {
+	case indent != "":
+		b.WriteString("\n" + prefix)
+	case index > 0:
+		b.WriteString(" ")
+	}
+}
+
+func quoteJSON(str string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(str)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// lineIndent returns the whitespace the line containing the given offset starts with.
+func lineIndent(src string, offset int) string {
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	lineEnd := lineStart
+	for lineEnd < len(src) && (src[lineEnd] == ' ' || src[lineEnd] == '\t') {
+		lineEnd++
+	}
+	return src[lineStart:lineEnd]
+}
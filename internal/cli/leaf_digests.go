@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// leafDigestResolver fetches the registry digest of leaf versions on demand, so that pinning
+// many calls to the same version only requests it once.
+type leafDigestResolver struct {
+	service Service
+	digests map[string]string
+}
+
+func newLeafDigestResolver(s Service) *leafDigestResolver {
+	return &leafDigestResolver{service: s, digests: make(map[string]string)}
+}
+
+func (r *leafDigestResolver) resolve(name string, version string) (string, error) {
+	key := fmt.Sprintf("%s %s", name, version)
+	if digest, ok := r.digests[key]; ok {
+		return digest, nil
+	}
+
+	digests, err := r.service.resolveLeafDigests([]leafCall{{Leaf: LeafVersion{Name: name, Version: version}}})
+	if err != nil {
+		return "", fmt.Errorf("Unable to pin leaf %s to a digest: %s; skipping it.", key, err)
+	}
+
+	digest, ok := digests[key]
+	if !ok {
+		return "", fmt.Errorf("Unable to pin leaf %s to a digest: it could not be found in the registry; skipping it.", key)
+	}
+
+	r.digests[key] = digest
+	return digest, nil
+}
+
+// verifyPinnedLeafDigests checks every leaf pinned to a digest against the digest the registry
+// currently holds for its version, reporting any drift.
+func (s Service) verifyPinnedLeafDigests(mintFiles []*MintYAMLFile) ([]api.LintProblem, error) {
+	calls, err := s.findLeafCalls(mintFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	pinnedCalls := make([]leafCall, 0, len(calls))
+	for _, call := range calls {
+		if call.Leaf.Digest != "" {
+			pinnedCalls = append(pinnedCalls, call)
+		}
+	}
+
+	problems := make([]api.LintProblem, 0)
+	if len(pinnedCalls) == 0 {
+		return problems, nil
+	}
+
+	digests, err := s.resolveLeafDigests(pinnedCalls)
+	if errors.Is(err, api.ErrOffline) {
+		fmt.Fprintln(s.Stderr, "Warning: the digests of pinned leaves can't be verified while offline; skipping it.")
+		return problems, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, call := range pinnedCalls {
+		digest, ok := digests[call.Key()]
+		if !ok {
+			problems = append(problems, call.lintProblem(
+				"error",
+				fmt.Sprintf("Leaf %s could not be found in the registry", call.Key()),
+				"",
+			))
+		} else if digest != call.Leaf.Digest {
+			problems = append(problems, call.lintProblem(
+				"error",
+				fmt.Sprintf("Leaf %s has digest %s in the registry, but is pinned to %s", call.Key(), digest, call.Leaf.Digest),
+				"The leaf's contents changed in the registry since it was pinned. Verify the change and run `mint resolve leaves --pin-digests` to accept it.",
+			))
+		}
+	}
+
+	return problems, nil
+}
+
+func formatLeafProblems(header string, problems []api.LintProblem) string {
+	var message strings.Builder
+	message.WriteString(header)
+	for _, problem := range problems {
+		fmt.Fprintf(&message, "\n\t%s - %s", problem.FileLocation(), problem.Message)
+	}
+	return message.String()
+}
@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// leafSchemaCache remembers the details of every leaf version fetched by a service, so each
+// version is only requested once per process.
+type leafSchemaCache struct {
+	mu      sync.Mutex
+	entries map[string]leafSchemaCacheEntry
+}
+
+type leafSchemaCacheEntry struct {
+	leaf *api.LeafDetails
+	err  error
+}
+
+func newLeafSchemaCache() *leafSchemaCache {
+	return &leafSchemaCache{entries: make(map[string]leafSchemaCacheEntry)}
+}
+
+// getLeafSchema returns the details of the given leaf version, including its parameters.
+func (s Service) getLeafSchema(name string, version string) (*api.LeafDetails, error) {
+	if s.leafSchemas == nil {
+		return s.APIClient.GetLeaf(api.GetLeafConfig{Name: name, Version: version})
+	}
+
+	key := fmt.Sprintf("%s %s", name, version)
+
+	s.leafSchemas.mu.Lock()
+	defer s.leafSchemas.mu.Unlock()
+
+	if entry, ok := s.leafSchemas.entries[key]; ok {
+		return entry.leaf, entry.err
+	}
+
+	leaf, err := s.APIClient.GetLeaf(api.GetLeafConfig{Name: name, Version: version})
+	s.leafSchemas.entries[key] = leafSchemaCacheEntry{leaf: leaf, err: err}
+	return leaf, err
+}
+
+// validateLeafCalls checks the `with` of every task calling a versioned leaf against the
+// parameters of that leaf version, and that the leaf supports the architecture of the run.
+// Leaves that cannot be fetched are skipped with a warning, as the problem will surface when
+// the run starts.
+func (s Service) validateLeafCalls(mintFiles []*MintYAMLFile) ([]api.LintProblem, error) {
+	problems := make([]api.LintProblem, 0)
+	warned := make(map[string]bool)
+
+	for _, file := range mintFiles {
+		nodePath := tasksPath(file.Doc)
+		if nodePath == "" {
+			continue
+		}
+
+		// Only run definitions have a base; lists of tasks may be embedded in runs of any architecture
+		arch := ""
+		if file.Doc.IsRunDefinition() {
+			arch = file.Doc.TryReadStringAtPath("$.base.arch")
+			if arch == "" {
+				arch = DefaultArch
+			}
+		}
+
+		err := file.Doc.ForEachNode(nodePath, func(node ast.Node) error {
+			callNode, withNode := findCallAndWith(node)
+			if callNode == nil {
+				return nil
+			}
+
+			leafVersion := s.parseLeafVersion(callNode.String())
+			if leafVersion.Name == "" || leafVersion.Version == "" {
+				return nil
+			}
+
+			leaf, err := s.getLeafSchema(leafVersion.Name, leafVersion.Version)
+			if err != nil {
+				key := fmt.Sprintf("%s %s", leafVersion.Name, leafVersion.Version)
+				if !errors.Is(err, errors.ErrNotFound) && !warned[key] {
+					fmt.Fprintf(s.Stderr, "Unable to validate the parameters of leaf %s: %s\n", key, err)
+					warned[key] = true
+				}
+				return nil
+			}
+
+			problems = append(problems, leafParamProblems(file, *leaf, callNode, withNode)...)
+			if arch != "" && len(leaf.Arches) > 0 && !slices.Contains(leaf.Arches, arch) {
+				problems = append(problems, lintProblemAt(
+					file,
+					callNode,
+					"error",
+					fmt.Sprintf("Leaf %s %s does not support the %s architecture", leaf.Name, leaf.Version, arch),
+					fmt.Sprintf("Supported architectures: %s.", strings.Join(leaf.Arches, ", ")),
+				))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find leaf references in %q", file.Entry.OriginalPath)
+		}
+	}
+
+	return problems, nil
+}
+
+// findCallAndWith returns the values of the `call` and `with` keys of a task. Either is nil
+// when the task does not have it.
+func findCallAndWith(task ast.Node) (call ast.Node, with ast.Node) {
+	for _, value := range mappingValues(task) {
+		switch value.Key.GetToken().Value {
+		case "call":
+			call = value.Value
+		case "with":
+			with = value.Value
+		}
+	}
+	return call, with
+}
+
+func mappingValues(node ast.Node) []*ast.MappingValueNode {
+	switch n := node.(type) {
+	case *ast.MappingNode:
+		return n.Values
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{n}
+	default:
+		return nil
+	}
+}
+
+func leafParamProblems(file *MintYAMLFile, leaf api.LeafDetails, callNode ast.Node, withNode ast.Node) []api.LintProblem {
+	problems := make([]api.LintProblem, 0)
+
+	available := Map(leaf.Parameters, func(parameter api.LeafParameter) string {
+		return parameter.Name
+	})
+
+	// Parameters given through an expression can't be checked
+	if withNode != nil && mappingValues(withNode) == nil && withNode.Type() != ast.NullType {
+		return problems
+	}
+
+	given := make(map[string]bool)
+	for _, value := range mappingValues(withNode) {
+		name := value.Key.GetToken().Value
+		given[name] = true
+
+		if slices.Contains(available, name) {
+			continue
+		}
+
+		advice := fmt.Sprintf("Available parameters: %s.", strings.Join(available, ", "))
+		if len(available) == 0 {
+			advice = fmt.Sprintf("%s %s does not accept any parameters.", leaf.Name, leaf.Version)
+		}
+		problems = append(problems, lintProblemAt(
+			file,
+			value.Key,
+			"error",
+			fmt.Sprintf("Leaf %s %s does not have a parameter %q", leaf.Name, leaf.Version, name),
+			advice,
+		))
+	}
+
+	for _, parameter := range leaf.Parameters {
+		if !parameter.Required || parameter.Default != nil || given[parameter.Name] {
+			continue
+		}
+
+		problems = append(problems, lintProblemAt(
+			file,
+			callNode,
+			"error",
+			fmt.Sprintf("Leaf %s %s is missing the required parameter %q", leaf.Name, leaf.Version, parameter.Name),
+			"Add it to the `with` of this task.",
+		))
+	}
+
+	return problems
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+func outputLeafSummaries(w io.Writer, leaves []api.LeafSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tLATEST\tDESCRIPTION")
+	for _, leaf := range leaves {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", leaf.Name, leaf.LatestVersion, leaf.Description)
+	}
+	tw.Flush()
+}
+
+func outputLeafDetails(w io.Writer, leaf api.LeafDetails, snippetVersion string) {
+	fmt.Fprintf(w, "%s %s\n", leaf.Name, leaf.Version)
+	if description := strings.TrimSpace(leaf.Description); description != "" {
+		fmt.Fprintln(w, description)
+	}
+
+	if len(leaf.Versions) > 0 {
+		fmt.Fprintf(w, "\nVersions: %s\n", strings.Join(leaf.Versions, ", "))
+	}
+
+	if len(leaf.Parameters) > 0 {
+		fmt.Fprintln(w, "\nParameters:")
+		for _, parameter := range leaf.Parameters {
+			fmt.Fprintf(w, "  %s", parameter.Name)
+			if parameter.Required {
+				fmt.Fprint(w, " (required)")
+			} else if parameter.Default != nil {
+				fmt.Fprintf(w, " (default: %s)", strconv.Quote(*parameter.Default))
+			}
+			fmt.Fprintln(w)
+			writeIndented(w, parameter.Description, "    ")
+		}
+	}
+
+	if len(leaf.Outputs) > 0 {
+		fmt.Fprintln(w, "\nOutputs:")
+		for _, output := range leaf.Outputs {
+			fmt.Fprintf(w, "  %s\n", output.Name)
+			writeIndented(w, output.Description, "    ")
+		}
+	}
+
+	if example := strings.TrimSpace(leaf.Example); example != "" {
+		fmt.Fprintln(w, "\nExample:")
+		writeIndented(w, example, "  ")
+	}
+
+	fmt.Fprintln(w, "\nUsage:")
+	fmt.Fprint(w, leafTaskSnippet(leaf, snippetVersion))
+}
+
+// leafTaskSnippet returns a task calling the given leaf version, ready to be pasted into a
+// list of tasks. Required parameters are included with an empty value.
+func leafTaskSnippet(leaf api.LeafDetails, version string) string {
+	key := leafTaskKey(leaf.Name)
+
+	var snippet strings.Builder
+	fmt.Fprintf(&snippet, "- key: %s\n", key)
+	fmt.Fprintf(&snippet, "  call: %s %s\n", leaf.Name, version)
+
+	printedWith := false
+	for _, parameter := range leaf.Parameters {
+		if !parameter.Required {
+			continue
+		}
+
+		if !printedWith {
+			snippet.WriteString("  with:\n")
+			printedWith = true
+		}
+		fmt.Fprintf(&snippet, "    %s: \"\"\n", parameter.Name)
+	}
+
+	return snippet.String()
+}
+
+// leafTaskKey returns the default key of a task calling the given leaf, eg. "install-node"
+// for "mint/install-node".
+func leafTaskKey(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+type leafParam struct {
+	Key   string
+	Value string
+}
+
+func parseLeafParams(params []string) ([]leafParam, error) {
+	parsed := make([]leafParam, 0, len(params))
+	for _, param := range params {
+		key, value, found := strings.Cut(param, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("Invalid parameter '%s'. Parameters must be specified in the form 'name=value'.", param)
+		}
+		parsed = append(parsed, leafParam{Key: key, Value: value})
+	}
+	return parsed, nil
+}
+
+// validateLeafParams checks that the given parameters are accepted by the leaf and that
+// every required parameter without a default is present.
+func validateLeafParams(leaf api.LeafDetails, params []leafParam) error {
+	known := make(map[string]bool, len(leaf.Parameters))
+	available := make([]string, 0, len(leaf.Parameters))
+	for _, parameter := range leaf.Parameters {
+		known[parameter.Name] = true
+		available = append(available, parameter.Name)
+	}
+
+	given := make(map[string]bool, len(params))
+	for _, param := range params {
+		if !known[param.Key] {
+			if len(available) == 0 {
+				return fmt.Errorf("Leaf %s %s does not accept any parameters, but %q was given.", leaf.Name, leaf.Version, param.Key)
+			}
+			return fmt.Errorf("Leaf %s %s does not have a parameter %q. Available parameters: %s.", leaf.Name, leaf.Version, param.Key, strings.Join(available, ", "))
+		}
+		given[param.Key] = true
+	}
+
+	missing := make([]string, 0)
+	for _, parameter := range leaf.Parameters {
+		if parameter.Required && parameter.Default == nil && !given[parameter.Name] {
+			missing = append(missing, parameter.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("Missing required parameters for leaf %s %s: %s. Pass them with --param name=value.", leaf.Name, leaf.Version, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// findRunDefinitionForLeaf returns the given file, or the only run definition in the .mint
+// directory when no file is given.
+func findRunDefinitionForLeaf(file string, mintDirectoryPath string) (string, error) {
+	if file != "" {
+		return file, nil
+	}
+
+	if mintDirectoryPath == "" {
+		return "", errors.New("unable to find a .mint directory; specify a file with --file")
+	}
+
+	entries, err := getFileOrDirectoryYAMLEntries(nil, mintDirectoryPath)
+	if err != nil {
+		return "", err
+	}
+
+	runDefinitions := filterYAMLFilesForModification(entries, func(doc *YAMLDoc) bool {
+		return doc.IsRunDefinition()
+	})
+
+	switch len(runDefinitions) {
+	case 0:
+		return "", fmt.Errorf("no run definitions found in %s; specify a file with --file", relativePathFromWd(mintDirectoryPath))
+	case 1:
+		return runDefinitions[0].Entry.OriginalPath, nil
+	default:
+		return "", fmt.Errorf("found multiple run definitions in %s; specify one with --file", relativePathFromWd(mintDirectoryPath))
+	}
+}
+
+func writeIndented(w io.Writer, text string, indent string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(w, "%s%s\n", indent, strings.TrimRight(line, " \t\r"))
+	}
+}
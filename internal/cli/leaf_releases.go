@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/rwx-research/mint-cli/internal/api"
+)
+
+// outputLeafReleaseNotes summarizes the releases of every updated leaf between its original
+// and its new version, highlighting major version changes and changed parameters.
+func (s Service) outputLeafReleaseNotes(replacements map[string]string) {
+	printedHeader := false
+
+	for _, original := range slices.Sorted(maps.Keys(replacements)) {
+		leaf := s.parseLeafVersion(original)
+		// Digests of pinned leaves aren't part of their version
+		target, _, _ := strings.Cut(replacements[original], "@")
+
+		// Leaves without a version didn't skip any releases
+		if leaf.Version == "" || leaf.Version == target {
+			continue
+		}
+
+		result, err := s.APIClient.GetLeafReleases(api.GetLeafReleasesConfig{
+			Leaf:        leaf.Name,
+			FromVersion: leaf.Version,
+			ToVersion:   target,
+		})
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "Unable to fetch release notes for %s: %s\n", leaf.Name, err)
+			continue
+		}
+
+		if len(result.Releases) == 0 {
+			continue
+		}
+
+		if !printedHeader {
+			fmt.Fprintln(s.Stdout)
+			fmt.Fprintln(s.Stdout, "Release notes:")
+			printedHeader = true
+		}
+
+		fmt.Fprintln(s.Stdout)
+		fmt.Fprintf(s.Stdout, "%s %s → %s", leaf.Name, leaf.Version, target)
+		if extractMajorVersion(leaf.Version) != extractMajorVersion(target) {
+			fmt.Fprint(s.Stdout, " (major version change, review for breaking changes)")
+		}
+		fmt.Fprintln(s.Stdout)
+
+		for _, release := range result.Releases {
+			s.outputLeafRelease(leaf.MajorVersion, release)
+		}
+	}
+}
+
+func (s Service) outputLeafRelease(originalMajor string, release api.LeafRelease) {
+	var labels []string
+	if extractMajorVersion(release.Version) != originalMajor && strings.HasSuffix(release.Version, ".0.0") {
+		labels = append(labels, "major")
+	}
+	if release.ChangesParameters() {
+		labels = append(labels, "parameters changed")
+	}
+	if len(release.Deprecations) > 0 {
+		labels = append(labels, "deprecations")
+	}
+
+	fmt.Fprintf(s.Stdout, "  %s", release.Version)
+	if len(labels) > 0 {
+		fmt.Fprintf(s.Stdout, " [%s]", strings.Join(labels, ", "))
+	}
+	fmt.Fprintln(s.Stdout)
+
+	if notes := strings.TrimSpace(release.Notes); notes != "" {
+		for _, line := range strings.Split(notes, "\n") {
+			fmt.Fprintf(s.Stdout, "    %s\n", strings.TrimRight(line, " \t\r"))
+		}
+	}
+
+	if len(release.AddedParameters) > 0 {
+		fmt.Fprintf(s.Stdout, "    Added parameters: %s\n", strings.Join(release.AddedParameters, ", "))
+	}
+	if len(release.RemovedParameters) > 0 {
+		fmt.Fprintf(s.Stdout, "    Removed parameters: %s\n", strings.Join(release.RemovedParameters, ", "))
+	}
+	if len(release.ChangedParameters) > 0 {
+		fmt.Fprintf(s.Stdout, "    Changed parameters: %s\n", strings.Join(release.ChangedParameters, ", "))
+	}
+	for _, deprecation := range release.Deprecations {
+		fmt.Fprintf(s.Stdout, "    Deprecated: %s\n", deprecation)
+	}
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rwx-research/mint-cli/internal/api"
+)
+
+// reGitLeaf matches leaves referenced by git repository, optionally followed by a ref, eg.
+// "git+https://github.com/my-org/my-leaf.git@main" or "git+ssh://git@github.com/my-org/my-leaf.git".
+// Leaves pinned to a commit are followed by a comment with the ref they were pinned from, eg.
+// "git+https://github.com/my-org/my-leaf.git@<sha> # ref: main".
+var reGitLeaf = regexp.MustCompile(`^\s*["']?(git\+(\S+?\.git)(?:@(\S+?))?)["']?\s*(?:#\s*(?:ref:\s*(\S+))?.*)?$`)
+
+var reCommitSha = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// gitLeafDefaultRef is the ref recorded for leaves pinned from the default branch of their
+// repository.
+const gitLeafDefaultRef = "HEAD"
+
+type GitLeaf struct {
+	Original   string
+	Repository string
+	Ref        string
+	// PinnedFrom is the ref a leaf pinned to a commit follows when it's updated
+	PinnedFrom string
+}
+
+func parseGitLeaf(str string) (GitLeaf, bool) {
+	match := reGitLeaf.FindStringSubmatch(str)
+	if len(match) == 0 {
+		return GitLeaf{}, false
+	}
+
+	return GitLeaf{
+		Original:   match[1],
+		Repository: match[2],
+		Ref:        match[3],
+		PinnedFrom: match[4],
+	}, true
+}
+
+// PinnedTo returns the call of this leaf at the given commit.
+func (l GitLeaf) PinnedTo(sha string) string {
+	return fmt.Sprintf("git+%s@%s", l.Repository, sha)
+}
+
+// TrackedRef returns the ref this leaf is resolved from: its own ref, or the ref it was pinned
+// from when it's pinned to a commit. Pinned leaves without a recorded ref don't track any.
+func (l GitLeaf) TrackedRef() (string, bool) {
+	if !isCommitSha(l.Ref) {
+		if l.Ref == "" {
+			return gitLeafDefaultRef, true
+		}
+		return l.Ref, true
+	}
+
+	return l.PinnedFrom, l.PinnedFrom != ""
+}
+
+func isCommitSha(ref string) bool {
+	return reCommitSha.MatchString(ref)
+}
+
+// resolveGitLeafRef returns the commit a ref of a git leaf points to. Refs are resolved by Mint
+// so that private repositories of the organization can be used.
+func (s Service) resolveGitLeafRef(leaf GitLeaf, ref string) (string, error) {
+	if ref == gitLeafDefaultRef {
+		ref = ""
+	}
+
+	result, err := s.APIClient.ResolveLeafGitRef(api.ResolveLeafGitRefConfig{
+		Repository: leaf.Repository,
+		Ref:        ref,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Unable to resolve the leaf %q: %s; skipping it.", leaf.Original, err)
+	}
+
+	if !isCommitSha(result.Sha) {
+		return "", fmt.Errorf("Unable to resolve the leaf %q: %q is not a commit; skipping it.", leaf.Original, result.Sha)
+	}
+
+	return result.Sha, nil
+}
+
+// addOrganizationLeafVersions adds the versions of called leaves that aren't in the public
+// registry, such as the leaves of the authenticated organization.
+func (s Service) addOrganizationLeafVersions(versions *api.LeafVersionsResult, calls []leafCall) {
+	missing := make([]string, 0)
+	for _, call := range calls {
+		if _, ok := versions.LatestMajor[call.Leaf.Name]; ok {
+			continue
+		}
+		if !slices.Contains(missing, call.Leaf.Name) {
+			missing = append(missing, call.Leaf.Name)
+		}
+	}
+
+	if len(missing) == 0 {
+		return
+	}
+
+	result, err := s.APIClient.ResolveLeafVersions(api.ResolveLeafVersionsConfig{Leaves: missing})
+	if err != nil {
+		fmt.Fprintf(s.Stderr, "Unable to fetch the versions of %s: %s\n", strings.Join(missing, ", "), err)
+		return
+	}
+
+	if versions.LatestMajor == nil {
+		versions.LatestMajor = make(map[string]string)
+	}
+	if versions.LatestMinor == nil {
+		versions.LatestMinor = make(map[string]map[string]string)
+	}
+	if versions.Versions == nil && len(result.Versions) > 0 {
+		versions.Versions = make(map[string][]api.LeafVersionDetails)
+	}
+
+	maps.Copy(versions.LatestMajor, result.LatestMajor)
+	maps.Copy(versions.LatestMinor, result.LatestMinor)
+	maps.Copy(versions.Versions, result.Versions)
+}
@@ -0,0 +1,344 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+const LeafLockfileName = "leaves.lock"
+
+const leafLockfileVersion = 1
+
+const leafLockfileHeader = "# This file is generated by `mint leaves lock`. Do not edit it manually.\n"
+
+// LeafLockfile records the content digest of every leaf version referenced by a set of
+// run definitions. Leaves are keyed the same way they are called, eg. "mint/install-node 1.2.3".
+type LeafLockfile struct {
+	Version int               `yaml:"version"`
+	Leaves  map[string]string `yaml:"leaves"`
+}
+
+func NewLeafLockfile() LeafLockfile {
+	return LeafLockfile{
+		Version: leafLockfileVersion,
+		Leaves:  make(map[string]string),
+	}
+}
+
+func leafLockfilePath(mintDirectoryPath string) string {
+	return filepath.Join(mintDirectoryPath, LeafLockfileName)
+}
+
+// readLeafLockfile reads the lockfile in the given .mint directory. When no lockfile exists,
+// it returns nil without an error.
+func readLeafLockfile(mintDirectoryPath string) (*LeafLockfile, error) {
+	if mintDirectoryPath == "" {
+		return nil, nil
+	}
+
+	path := leafLockfilePath(mintDirectoryPath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to read %q", path)
+	}
+
+	lockfile := NewLeafLockfile()
+	if err := yaml.Unmarshal(content, &lockfile); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %q", path)
+	}
+
+	if lockfile.Version != leafLockfileVersion {
+		return nil, fmt.Errorf("unsupported version %d in %q", lockfile.Version, path)
+	}
+
+	if lockfile.Leaves == nil {
+		lockfile.Leaves = make(map[string]string)
+	}
+
+	return &lockfile, nil
+}
+
+func (l LeafLockfile) Bytes() ([]byte, error) {
+	encoded, err := yaml.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(leafLockfileHeader), encoded...), nil
+}
+
+func (l LeafLockfile) WriteFile(path string) error {
+	content, err := l.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "unable to encode the leaf lockfile")
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
+
+// leafCall is a reference to a leaf from the `call` of a task.
+type leafCall struct {
+	File *MintYAMLFile
+	Node ast.Node
+	Leaf LeafVersion
+}
+
+func (c leafCall) Key() string {
+	return fmt.Sprintf("%s %s", c.Leaf.Name, c.Leaf.Version)
+}
+
+// lintProblem builds a problem located at the `call` of this leaf.
+func (c leafCall) lintProblem(severity string, message string, advice string) api.LintProblem {
+	return lintProblemAt(c.File, c.Node, severity, message, advice)
+}
+
+// lintProblemAt builds a problem located at the given node of a file.
+func lintProblemAt(file *MintYAMLFile, node ast.Node, severity string, message string, advice string) api.LintProblem {
+	problem := api.LintProblem{
+		Severity: severity,
+		Message:  message,
+		FileName: file.Entry.Path,
+		Line:     api.NullInt{IsNull: true},
+		Column:   api.NullInt{IsNull: true},
+		Advice:   advice,
+	}
+
+	if token := node.GetToken(); token != nil && token.Position != nil {
+		problem.Line = api.NewNullInt(token.Position.Line)
+		problem.Column = api.NewNullInt(token.Position.Column)
+	}
+
+	return problem
+}
+
+// tasksPath returns the path of the list of tasks in the given document, or an empty
+// string when the document does not contain any tasks.
+func tasksPath(doc *YAMLDoc) string {
+	if doc.IsRunDefinition() {
+		return "$.tasks"
+	} else if doc.IsListOfTasks() {
+		return "$"
+	}
+	return ""
+}
+
+// leafCallsPath returns the path of every task's `call` in the given document, or an
+// empty string when the document does not contain any tasks.
+func leafCallsPath(doc *YAMLDoc) string {
+	if path := tasksPath(doc); path != "" {
+		return path + "[*].call"
+	}
+	return ""
+}
+
+// findLeafCalls finds every task calling a leaf in the given files. Calls that do not
+// reference a leaf, such as embedded runs, are omitted.
+func (s Service) findLeafCalls(mintFiles []*MintYAMLFile) ([]leafCall, error) {
+	calls := make([]leafCall, 0)
+
+	for _, file := range mintFiles {
+		nodePath := leafCallsPath(file.Doc)
+		if nodePath == "" {
+			continue
+		}
+
+		err := file.Doc.ForEachNode(nodePath, func(node ast.Node) error {
+			leafVersion := s.parseLeafVersion(node.String())
+			if leafVersion.Name == "" {
+				return nil
+			}
+
+			calls = append(calls, leafCall{File: file, Node: node, Leaf: leafVersion})
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find leaf references in %q", file.Entry.OriginalPath)
+		}
+	}
+
+	return calls, nil
+}
+
+// resolveLeafDigests fetches the registry digest of every versioned leaf call, keyed the
+// same way as LeafLockfile.Leaves.
+func (s Service) resolveLeafDigests(calls []leafCall) (map[string]string, error) {
+	references := make([]api.LeafReference, 0, len(calls))
+	for _, call := range calls {
+		if call.Leaf.Version == "" {
+			continue
+		}
+
+		reference := api.LeafReference{Name: call.Leaf.Name, Version: call.Leaf.Version}
+		if !slices.Contains(references, reference) {
+			references = append(references, reference)
+		}
+	}
+
+	digests := make(map[string]string, len(references))
+	if len(references) == 0 {
+		return digests, nil
+	}
+
+	result, err := s.APIClient.ResolveLeafDigests(api.ResolveLeafDigestsConfig{Leaves: references})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch leaf digests")
+	}
+
+	for _, leaf := range result.Leaves {
+		digests[fmt.Sprintf("%s %s", leaf.Name, leaf.Version)] = leaf.Digest
+	}
+
+	return digests, nil
+}
+
+// writeLeafLockfile locks every leaf called from the YAML files in the .mint directory
+// and from any additional files.
+func (s Service) writeLeafLockfile(mintDirectoryPath string, files []string) (LeafLockfile, error) {
+	entries, err := getFileOrDirectoryYAMLEntries(nil, mintDirectoryPath)
+	if err != nil {
+		return LeafLockfile{}, err
+	}
+
+	if len(files) > 0 {
+		fileEntries, err := getFileOrDirectoryYAMLEntries(files, "")
+		if err != nil {
+			return LeafLockfile{}, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+
+	entries = removeDuplicates(entries, func(entry MintDirectoryEntry) string {
+		return entry.OriginalPath
+	})
+
+	mintFiles := filterYAMLFilesForModification(entries, func(doc *YAMLDoc) bool {
+		return true
+	})
+
+	calls, err := s.findLeafCalls(mintFiles)
+	if err != nil {
+		return LeafLockfile{}, err
+	}
+
+	for _, call := range calls {
+		if call.Leaf.Version == "" {
+			fmt.Fprintf(s.Stderr, "Leaf %s in %s does not specify a version; skipping it. Run `mint resolve leaves` to add one.\n", call.Leaf.Name, relativePathFromWd(call.File.Entry.OriginalPath))
+		}
+	}
+
+	digests, err := s.resolveLeafDigests(calls)
+	if err != nil {
+		return LeafLockfile{}, err
+	}
+
+	lockfile := NewLeafLockfile()
+	for _, call := range calls {
+		if call.Leaf.Version == "" {
+			continue
+		}
+
+		digest, ok := digests[call.Key()]
+		if !ok {
+			return LeafLockfile{}, fmt.Errorf("unable to find a digest for leaf %s", call.Key())
+		}
+		lockfile.Leaves[call.Key()] = digest
+	}
+
+	if err := lockfile.WriteFile(leafLockfilePath(mintDirectoryPath)); err != nil {
+		return LeafLockfile{}, err
+	}
+
+	return lockfile, nil
+}
+
+// refreshLeafLockfile rewrites the lockfile when the .mint directory already has one, reporting
+// the update to out.
+func (s Service) refreshLeafLockfile(mintDirectoryPath string, files []string, out io.Writer) error {
+	lockfile, err := readLeafLockfile(mintDirectoryPath)
+	if err != nil || lockfile == nil {
+		return err
+	}
+
+	if _, err := s.writeLeafLockfile(mintDirectoryPath, files); err != nil {
+		return errors.Wrap(err, "unable to update the leaf lockfile")
+	}
+
+	fmt.Fprintf(out, "Updated %s\n", relativePathFromWd(leafLockfilePath(mintDirectoryPath)))
+	return nil
+}
+
+// verifyLeafLockfile checks every leaf called from the given files against the lockfile
+// in the .mint directory. When there is no lockfile, nothing is verified.
+func (s Service) verifyLeafLockfile(mintDirectoryPath string, mintFiles []*MintYAMLFile) ([]api.LintProblem, error) {
+	lockfile, err := readLeafLockfile(mintDirectoryPath)
+	if err != nil || lockfile == nil {
+		return nil, err
+	}
+
+	calls, err := s.findLeafCalls(mintFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	lockedCalls := make([]leafCall, 0, len(calls))
+	problems := make([]api.LintProblem, 0)
+	for _, call := range calls {
+		if call.Leaf.Version == "" {
+			continue
+		}
+
+		if _, ok := lockfile.Leaves[call.Key()]; !ok {
+			problems = append(problems, call.lintProblem(
+				"error",
+				fmt.Sprintf("Leaf %s is not in %s", call.Key(), LeafLockfileName),
+				"Run `mint leaves lock` to update the lockfile.",
+			))
+			continue
+		}
+
+		lockedCalls = append(lockedCalls, call)
+	}
+
+	digests, err := s.resolveLeafDigests(lockedCalls)
+	if errors.Is(err, api.ErrOffline) {
+		fmt.Fprintf(s.Stderr, "Warning: the digests of leaves can't be verified against %s while offline; skipping it.\n", LeafLockfileName)
+		return problems, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, call := range lockedCalls {
+		lockedDigest := lockfile.Leaves[call.Key()]
+		digest, ok := digests[call.Key()]
+		if !ok {
+			problems = append(problems, call.lintProblem(
+				"error",
+				fmt.Sprintf("Leaf %s could not be found in the registry", call.Key()),
+				"",
+			))
+		} else if digest != lockedDigest {
+			problems = append(problems, call.lintProblem(
+				"error",
+				fmt.Sprintf("Leaf %s has digest %s, but %s expects %s", call.Key(), digest, LeafLockfileName, lockedDigest),
+				"The leaf's contents changed in the registry since it was locked. Verify the change and run `mint leaves lock` to accept it.",
+			))
+		}
+	}
+
+	return problems, nil
+}
+
+func formatLeafLockfileProblems(problems []api.LintProblem) string {
+	return formatLeafProblems(fmt.Sprintf("unable to verify leaves against %s:", LeafLockfileName), problems)
+}
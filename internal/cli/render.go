@@ -0,0 +1,623 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/rwx-research/mint-cli/internal/errors"
+	"github.com/rwx-research/mint-cli/internal/expressions"
+)
+
+var (
+	reRenderExpression    = regexp.MustCompile(`\$\{\{.*?\}\}`)
+	reRenderTaskReference = regexp.MustCompile(`(^|[^\w.'-])tasks(?:\.([A-Za-z_][\w-]*)|\[\s*'([^']*)'\s*\])`)
+
+	// The fields of tasks embedding runs which are carried to the tasks of the runs they embed
+	renderCarriedTaskKeys = []string{"key", "call", "init", "use", "after", "if", "env", "filter"}
+)
+
+// renderedNumber is a number as it's written, so that eg. a tag of 1.10 isn't rendered as 1.1.
+type renderedNumber string
+
+func (n renderedNumber) MarshalYAML() ([]byte, error) {
+	return []byte(n), nil
+}
+
+func (n renderedNumber) MarshalJSON() ([]byte, error) {
+	if json.Valid([]byte(n)) {
+		return []byte(n), nil
+	}
+	// Numbers such as 0x1F or 1_000 aren't valid JSON
+	return json.Marshal(string(n))
+}
+
+// renderedTask is a task of a flattened run definition, along with where it's defined.
+type renderedTask struct {
+	task   yaml.MapSlice
+	source string
+}
+
+// renderer flattens run definitions by inlining the tasks of the runs they embed.
+type renderer struct {
+	stderr io.Writer
+
+	// The files being rendered, outermost first, to detect runs embedding themselves
+	rendering []string
+}
+
+// renderFile returns the definition of a run along with its tasks, flattened. Tasks of embedded
+// runs are keyed by the key of the task embedding them followed by their own, eg. `ci.build`.
+func (r *renderer) renderFile(path string, ctx expressions.Context) (yaml.MapSlice, []renderedTask, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if slices.Contains(r.rendering, absPath) {
+		return nil, nil, errors.Errorf("%s embeds itself", relativePathFromWd(absPath))
+	}
+	r.rendering = append(r.rendering, absPath)
+	defer func() { r.rendering = r.rendering[:len(r.rendering)-1] }()
+
+	doc, err := ParseYAMLFile(path)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to parse %q", path)
+	}
+
+	var document *YAMLDoc
+	for _, candidate := range doc.Documents() {
+		if candidate.IsRunDefinition() {
+			document = candidate
+			break
+		}
+	}
+	if document == nil {
+		return nil, nil, errors.Errorf("%s is not a run definition", relativePathFromWd(path))
+	}
+
+	definition, ok := document.value().(yaml.MapSlice)
+	if !ok {
+		return nil, nil, errors.Errorf("%s is not a run definition", relativePathFromWd(path))
+	}
+
+	tasksNode, err := document.getNodeAtPath("$.tasks")
+	if err != nil {
+		return nil, nil, err
+	}
+	seqNode, ok := tasksNode.(*ast.SequenceNode)
+	if !ok {
+		return nil, nil, errors.Errorf("expected the tasks of %s to be a sequence", relativePathFromWd(path))
+	}
+	tasks, _ := mapSliceValue(definition, "tasks").([]any)
+
+	rendered := make([]renderedTask, 0, len(tasks))
+	embedded := make(map[string][]string)
+	for i, item := range tasks {
+		task, ok := item.(yaml.MapSlice)
+		if !ok {
+			continue
+		}
+		source := fmt.Sprintf("%s:%d", relativePathFromWd(path), nodeStartLine(seqNode.Values[i]))
+
+		key, _ := mapSliceValue(task, "key").(string)
+		call, _ := mapSliceValue(task, "call").(string)
+		if call == "" || !isEmbeddedRunCall(call) {
+			substituted, err := substituteExpressions(task, ctx)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "unable to render task %q in %s", key, source)
+			}
+			rendered = append(rendered, renderedTask{task: substituted.(yaml.MapSlice), source: source})
+			continue
+		}
+
+		embeddedPath, err := expressions.Interpolate(call, ctx)
+		if err != nil {
+			fmt.Fprintf(r.stderr, "Unable to expand task %q in %s: %s\n", key, source, err)
+			rendered = append(rendered, renderedTask{task: task, source: source})
+			continue
+		}
+
+		for _, item := range task {
+			if field := fmt.Sprint(item.Key); !slices.Contains(renderCarriedTaskKeys, field) {
+				return nil, nil, errors.Errorf("unable to expand task %q in %s: its %s can't be carried to the tasks of %s", key, source, field, embeddedPath)
+			}
+		}
+
+		embeddedCtx := r.embeddedContext(task, ctx)
+		embeddedDefinition, children, err := r.renderFile(embeddedPath, embeddedCtx)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to expand task %q in %s", key, source)
+		}
+
+		carried, err := substituteExpressions(yaml.MapSlice{
+			{Key: "if", Value: mapSliceValue(task, "if")},
+			{Key: "env", Value: mapSliceValue(task, "env")},
+		}, ctx)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to render task %q in %s", key, source)
+		}
+		embeddedEnv, err := substituteExpressions(mapSliceValue(embeddedDefinition, "env"), embeddedCtx)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to render the env of %s", embeddedPath)
+		}
+
+		// Tasks of the embedded run keep its base when it differs from the one of this run
+		var base any
+		if embeddedBase := mapSliceValue(embeddedDefinition, "base"); !reflect.DeepEqual(embeddedBase, mapSliceValue(definition, "base")) {
+			base = embeddedBase
+		}
+
+		childKeys := make([]string, 0, len(children))
+		for _, child := range children {
+			childKey, _ := mapSliceValue(child.task, "key").(string)
+			childKeys = append(childKeys, childKey)
+		}
+		rewriteChildKeys := func(ref string) []string {
+			if slices.Contains(childKeys, ref) {
+				return []string{key + "." + ref}
+			}
+			return []string{ref}
+		}
+
+		use := mapSliceValue(task, "use")
+		for _, child := range children {
+			childKey, _ := mapSliceValue(child.task, "key").(string)
+			child.task = setMapSliceValue(child.task, "key", key+"."+childKey)
+
+			if childUse := mapSliceValue(child.task, "use"); childUse != nil {
+				child.task = setMapSliceValue(child.task, "use", rewriteUse(childUse, rewriteChildKeys))
+			} else if use != nil {
+				// Tasks of the embedded run wait for the tasks the embedding task uses
+				child.task = setMapSliceValue(child.task, "use", use)
+			}
+			if childAfter := mapSliceValue(child.task, "after"); childAfter != nil {
+				child.task = setMapSliceValue(child.task, "after", rewriteUse(childAfter, rewriteChildKeys))
+			}
+
+			child.task = rewriteTaskReferences(child.task, func(ref string) (string, bool) {
+				if slices.Contains(childKeys, ref) {
+					return key + "." + ref, true
+				}
+				return "", false
+			}).(yaml.MapSlice)
+
+			child.task, err = carryEmbeddingTask(child.task, carried.(yaml.MapSlice), mapSliceValue(task, "after"), mapSliceValue(task, "filter"), embeddedEnv, base)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "unable to expand task %q in %s into task %q", key, source, childKey)
+			}
+
+			child.source = fmt.Sprintf("%s, embedded by %s", child.source, source)
+			rendered = append(rendered, child)
+			embedded[key] = append(embedded[key], key+"."+childKey)
+		}
+	}
+
+	// Tasks using or running after an embedded run do so for every task of it instead
+	for i := range rendered {
+		for _, field := range []string{"use", "after"} {
+			if refs := mapSliceValue(rendered[i].task, field); refs != nil {
+				rendered[i].task = setMapSliceValue(rendered[i].task, field, rewriteUse(refs, func(ref string) []string {
+					if keys, ok := embedded[ref]; ok {
+						return keys
+					}
+					return []string{ref}
+				}))
+			}
+		}
+	}
+
+	return definition, rendered, nil
+}
+
+// carryEmbeddingTask applies the fields of a task embedding a run to one of the tasks of the run:
+// the task only runs when both conditions hold, runs after the tasks of both, and inherits the
+// env of the embedding task and then of the embedded run, its own variables taking precedence.
+func carryEmbeddingTask(task yaml.MapSlice, carried yaml.MapSlice, after any, filter any, embeddedEnv any, base any) (yaml.MapSlice, error) {
+	condition, err := combineConditions(mapSliceValue(carried, "if"), mapSliceValue(task, "if"))
+	if err != nil {
+		return nil, err
+	}
+	if condition != nil {
+		task = setMapSliceValue(task, "if", condition)
+	}
+
+	after, err = combineAfter(after, mapSliceValue(task, "after"))
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		task = setMapSliceValue(task, "after", after)
+	}
+
+	env := mapSliceValue(task, "env")
+	for _, defaults := range []any{mapSliceValue(carried, "env"), embeddedEnv} {
+		if env, err = mergeEnv(env, defaults); err != nil {
+			return nil, err
+		}
+	}
+	if env != nil {
+		task = setMapSliceValue(task, "env", env)
+	}
+
+	if filter != nil {
+		if mapSliceValue(task, "filter") != nil {
+			return nil, errors.New("the filter of the embedding task can't be combined with the filter of the task")
+		}
+		task = setMapSliceValue(task, "filter", filter)
+	}
+
+	if base != nil && mapSliceValue(task, "base") == nil {
+		task = setMapSliceValue(task, "base", base)
+	}
+	return task, nil
+}
+
+// combineConditions returns a condition which holds when both conditions hold. Literal
+// conditions are simplified, eg. a task embedded by a task which never runs never runs either.
+func combineConditions(outer any, inner any) (any, error) {
+	if outer == nil {
+		return inner, nil
+	}
+	outerExpression, err := conditionExpression(outer)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case outerExpression == "false":
+		return false, nil
+	case outerExpression == "true":
+		return inner, nil
+	case inner == nil:
+		return outer, nil
+	}
+
+	innerExpression, err := conditionExpression(inner)
+	if err != nil {
+		return nil, err
+	}
+	switch innerExpression {
+	case "false":
+		return false, nil
+	case "true":
+		return outer, nil
+	}
+	return fmt.Sprintf("${{ (%s) && (%s) }}", outerExpression, innerExpression), nil
+}
+
+// conditionExpression returns the expression of a condition, eg. `init.ref == 'main'` for
+// `${{ init.ref == 'main' }}`, or `true` and `false` for literal conditions.
+func conditionExpression(condition any) (string, error) {
+	switch v := condition.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if trimmed == "true" || trimmed == "false" {
+			return trimmed, nil
+		}
+		if inner, ok := strings.CutPrefix(trimmed, "${{"); ok {
+			if inner, ok := strings.CutSuffix(inner, "}}"); ok {
+				if _, err := expressions.Parse(inner); err == nil {
+					return strings.TrimSpace(inner), nil
+				}
+			}
+		}
+	}
+	return "", errors.Errorf("the condition %q can't be combined with another", fmt.Sprint(condition))
+}
+
+// combineAfter returns the tasks to run after of both an embedding task and a task of the run it
+// embeds, the ones of the embedding task first. Conditions can't be combined with keys.
+func combineAfter(outer any, inner any) (any, error) {
+	if outer == nil || inner == nil {
+		if outer != nil {
+			return outer, nil
+		}
+		return inner, nil
+	}
+
+	for _, after := range []any{outer, inner} {
+		if text, ok := after.(string); ok && expressions.ContainsExpression(text) {
+			return nil, errors.Errorf("the after %q can't be combined with another", text)
+		}
+	}
+
+	var refs []any
+	for _, after := range []any{outer, inner} {
+		if items, ok := after.([]any); ok {
+			refs = append(refs, items...)
+		} else {
+			refs = append(refs, after)
+		}
+	}
+	return rewriteUse(refs, func(ref string) []string { return []string{ref} }), nil
+}
+
+// mergeEnv returns the variables of env along with the ones of defaults it doesn't set.
+func mergeEnv(env any, defaults any) (any, error) {
+	if defaults == nil {
+		return env, nil
+	}
+	if env == nil {
+		return defaults, nil
+	}
+
+	envVariables, ok := env.(yaml.MapSlice)
+	if !ok {
+		return nil, errors.Errorf("the env %q can't be combined with another", fmt.Sprint(env))
+	}
+	defaultVariables, ok := defaults.(yaml.MapSlice)
+	if !ok {
+		return nil, errors.Errorf("the env %q can't be combined with another", fmt.Sprint(defaults))
+	}
+
+	merged := slices.Clone(envVariables)
+	for _, variable := range defaultVariables {
+		if mapSliceValue(envVariables, fmt.Sprint(variable.Key)) == nil {
+			merged = append(merged, variable)
+		}
+	}
+	return merged, nil
+}
+
+// embeddedContext returns the contexts of the run embedded by a task: its init parameters are
+// the `init` of the task, which are only available when every one of them is statically known.
+func (r *renderer) embeddedContext(task yaml.MapSlice, ctx expressions.Context) expressions.Context {
+	embeddedCtx := expressions.Context{}
+	if run, ok := ctx["run"]; ok {
+		embeddedCtx["run"] = run
+	}
+
+	initParameters := make(map[string]string)
+	init, _ := mapSliceValue(task, "init").(yaml.MapSlice)
+	for _, item := range init {
+		var value string
+		switch v := item.Value.(type) {
+		case nil:
+		case string:
+			interpolated, err := expressions.Interpolate(v, ctx)
+			if err != nil {
+				return embeddedCtx
+			}
+			value = interpolated
+		default:
+			value = fmt.Sprint(v)
+		}
+		initParameters[fmt.Sprint(item.Key)] = value
+	}
+
+	embeddedCtx["init"] = initParameters
+	return embeddedCtx
+}
+
+// value returns the value of a document, with mappings as yaml.MapSlice and numbers as they're
+// written. Aliases and merge keys are expanded.
+func (doc *YAMLDoc) value() any {
+	astDoc := doc.file().Docs[0]
+	return newYAMLAnchors(astDoc).value(astDoc.Body, make(map[ast.Node]bool))
+}
+
+// value converts a node to the value it represents, following aliases and merge keys. Mappings
+// are converted to yaml.MapSlice to keep their order, and numbers to renderedNumber.
+func (anchors yamlAnchors) value(node ast.Node, visiting map[ast.Node]bool) any {
+	node = anchors.deref(yamlMatch{node: node}).node
+	if visiting[node] {
+		return nil
+	}
+	visiting[node] = true
+	defer delete(visiting, node)
+
+	switch n := node.(type) {
+	case *ast.MappingNode, *ast.MappingValueNode:
+		values := mappingValues(n)
+
+		explicit := make(map[string]bool)
+		for _, value := range values {
+			if _, ok := value.Key.(*ast.MergeKeyNode); !ok {
+				explicit[scalarText(value.Key)] = true
+			}
+		}
+
+		mapping := make(yaml.MapSlice, 0, len(values))
+		merged := make(map[string]bool)
+		for _, value := range values {
+			if _, ok := value.Key.(*ast.MergeKeyNode); !ok {
+				mapping = append(mapping, yaml.MapItem{Key: scalarText(value.Key), Value: anchors.value(value.Value, visiting)})
+				continue
+			}
+
+			// Merged keys are inserted where they're merged, unless they're explicitly set
+			sources := []any{anchors.value(value.Value, visiting)}
+			if items, ok := sources[0].([]any); ok {
+				sources = items
+			}
+			for _, source := range sources {
+				items, _ := source.(yaml.MapSlice)
+				for _, item := range items {
+					key := fmt.Sprint(item.Key)
+					if explicit[key] || merged[key] {
+						continue
+					}
+					merged[key] = true
+					mapping = append(mapping, item)
+				}
+			}
+		}
+		return mapping
+	case *ast.SequenceNode:
+		items := make([]any, 0, len(n.Values))
+		for _, item := range n.Values {
+			items = append(items, anchors.value(item, visiting))
+		}
+		return items
+	case *ast.StringNode:
+		return n.Value
+	case *ast.LiteralNode:
+		return n.Value.Value
+	case *ast.IntegerNode, *ast.FloatNode:
+		return renderedNumber(n.GetToken().Value)
+	case *ast.BoolNode:
+		return n.Value
+	case *ast.NullNode, nil:
+		return nil
+	default:
+		return scalarText(node)
+	}
+}
+
+// substituteExpressions evaluates the expressions of every string of a value which refer to
+// available contexts. Keys are left as they are.
+func substituteExpressions(value any, ctx expressions.Context) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return expressions.InterpolateAvailable(v, ctx)
+	case yaml.MapSlice:
+		substituted := make(yaml.MapSlice, 0, len(v))
+		for _, item := range v {
+			itemValue, err := substituteExpressions(item.Value, ctx)
+			if err != nil {
+				return nil, err
+			}
+			substituted = append(substituted, yaml.MapItem{Key: item.Key, Value: itemValue})
+		}
+		return substituted, nil
+	case []any:
+		substituted := make([]any, 0, len(v))
+		for _, item := range v {
+			substitutedItem, err := substituteExpressions(item, ctx)
+			if err != nil {
+				return nil, err
+			}
+			substituted = append(substituted, substitutedItem)
+		}
+		return substituted, nil
+	default:
+		return value, nil
+	}
+}
+
+// rewriteUse replaces each task key of a `use`, which is either a single key or a list of them.
+func rewriteUse(use any, replace func(ref string) []string) any {
+	var refs []any
+	switch v := use.(type) {
+	case string:
+		refs = []any{v}
+	case []any:
+		refs = v
+	default:
+		return use
+	}
+
+	rewritten := make([]any, 0, len(refs))
+	for _, ref := range refs {
+		key, ok := ref.(string)
+		if !ok {
+			rewritten = append(rewritten, ref)
+			continue
+		}
+		for _, replacement := range replace(key) {
+			if !slices.Contains(rewritten, any(replacement)) {
+				rewritten = append(rewritten, replacement)
+			}
+		}
+	}
+
+	if _, ok := use.(string); ok && len(rewritten) == 1 {
+		return rewritten[0]
+	}
+	return rewritten
+}
+
+// rewriteTaskReferences replaces the keys of tasks referenced in the expressions of a value, eg.
+// `${{ tasks.build.values.x }}`. Renamed keys are indexed, eg. `tasks['ci.build']`, as they
+// contain dots.
+func rewriteTaskReferences(value any, replace func(ref string) (string, bool)) any {
+	switch v := value.(type) {
+	case string:
+		return reRenderExpression.ReplaceAllStringFunc(v, func(expression string) string {
+			return reRenderTaskReference.ReplaceAllStringFunc(expression, func(reference string) string {
+				match := reRenderTaskReference.FindStringSubmatch(reference)
+				ref := match[2] + match[3]
+				replacement, ok := replace(ref)
+				if !ok {
+					return reference
+				}
+				return fmt.Sprintf("%stasks['%s']", match[1], replacement)
+			})
+		})
+	case yaml.MapSlice:
+		rewritten := make(yaml.MapSlice, 0, len(v))
+		for _, item := range v {
+			rewritten = append(rewritten, yaml.MapItem{Key: item.Key, Value: rewriteTaskReferences(item.Value, replace)})
+		}
+		return rewritten
+	case []any:
+		rewritten := make([]any, 0, len(v))
+		for _, item := range v {
+			rewritten = append(rewritten, rewriteTaskReferences(item, replace))
+		}
+		return rewritten
+	default:
+		return value
+	}
+}
+
+func mapSliceValue(mapping yaml.MapSlice, key string) any {
+	for _, item := range mapping {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// setMapSliceValue returns a copy of mapping with key set to value, appended if it isn't set.
+func setMapSliceValue(mapping yaml.MapSlice, key string, value any) yaml.MapSlice {
+	updated := slices.Clone(mapping)
+	for i, item := range updated {
+		if fmt.Sprint(item.Key) == key {
+			updated[i].Value = value
+			return updated
+		}
+	}
+	return append(updated, yaml.MapItem{Key: key, Value: value})
+}
+
+// renderDefinition formats a flattened run definition as YAML, with a comment above each task
+// pointing to where it's defined, or as JSON.
+func renderDefinition(definition yaml.MapSlice, tasks []renderedTask, asJSON bool) (string, error) {
+	taskValues := make([]any, len(tasks))
+	comments := make(yaml.CommentMap)
+	for i, task := range tasks {
+		taskValues[i] = task.task
+		comments[fmt.Sprintf("$.tasks[%d]", i)] = []*yaml.Comment{yaml.HeadComment(" source: " + task.source)}
+	}
+	definition = setMapSliceValue(definition, "tasks", taskValues)
+
+	if asJSON {
+		normalized, err := normalizeJSON(definition)
+		if err != nil {
+			return "", err
+		}
+		return formatJSON(normalized, "", "  ") + "\n", nil
+	}
+
+	encoded, err := yaml.MarshalWithOptions(definition, yaml.UseLiteralStyleIfMultiline(true), yaml.WithComment(comments))
+	if err != nil {
+		return "", err
+	}
+
+	formatted, err := formatYAML(string(encoded))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(formatted, "\n") + "\n", nil
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"maps"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"slices"
 	"strconv"
@@ -18,12 +20,15 @@ import (
 
 	"github.com/rwx-research/mint-cli/internal/accesstoken"
 	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/diff"
 	"github.com/rwx-research/mint-cli/internal/dotenv"
 	"github.com/rwx-research/mint-cli/internal/errors"
+	"github.com/rwx-research/mint-cli/internal/expressions"
 	"github.com/rwx-research/mint-cli/internal/messages"
 	"github.com/rwx-research/mint-cli/internal/versions"
 
 	"github.com/briandowns/spinner"
+	"github.com/goccy/go-yaml"
 	"github.com/goccy/go-yaml/ast"
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/sync/errgroup"
@@ -37,6 +42,9 @@ var hasOutputVersionMessage atomic.Bool
 // Service holds the main business logic of the CLI.
 type Service struct {
 	Config
+
+	leafSchemas      *leafSchemaCache
+	baseDeprecations *baseDeprecationWarnings
 }
 
 func NewService(cfg Config) (Service, error) {
@@ -44,7 +52,11 @@ func NewService(cfg Config) (Service, error) {
 		return Service{}, errors.Wrap(err, "validation failed")
 	}
 
-	return Service{cfg}, nil
+	return Service{
+		Config:           cfg,
+		leafSchemas:      newLeafSchemaCache(),
+		baseDeprecations: newBaseDeprecationWarnings(),
+	}, nil
 }
 
 // DebugRunConfig will connect to a running task over SSH. Key exchange is facilitated over the Cloud API.
@@ -124,9 +136,14 @@ func (s Service) InitiateRun(cfg InitiateRunConfig) (*api.InitiateRunResult, err
 		return nil, errors.Wrap(err, "unable to find .mint directory")
 	}
 
+	var listFiles io.Writer
+	if cfg.ListFiles {
+		listFiles = s.Stderr
+	}
+
 	// It's possible (when no directory is specified) that there is no .mint directory found during traversal
 	if mintDirectoryPath != "" {
-		mintDirectoryEntries, err := mintDirectoryEntries(mintDirectoryPath)
+		mintDirectoryEntries, err := mintDirectoryEntries(mintDirectoryPath, mintDirectoryOptions{gitignore: cfg.Gitignore, listFiles: listFiles})
 		if err != nil {
 			if errors.Is(err, errors.ErrFileNotExists) {
 				return nil, fmt.Errorf("You specified --dir %q, but %q could not be found", cfg.MintDirectory, cfg.MintDirectory)
@@ -154,7 +171,7 @@ func (s Service) InitiateRun(cfg InitiateRunConfig) (*api.InitiateRunResult, err
 			return errors.Wrapf(err, "unable to reload %q", runDefinitionPath)
 		}
 		if mintDirectoryPath != "" {
-			mintDirectoryEntries, err := mintDirectoryEntries(mintDirectoryPath)
+			mintDirectoryEntries, err := mintDirectoryEntries(mintDirectoryPath, mintDirectoryOptions{gitignore: cfg.Gitignore})
 			if err != nil && !errors.Is(err, errors.ErrFileNotExists) {
 				return errors.Wrapf(err, "unable to reload mint directory %q", mintDirectoryPath)
 			}
@@ -164,7 +181,7 @@ func (s Service) InitiateRun(cfg InitiateRunConfig) (*api.InitiateRunResult, err
 		return nil
 	}
 
-	addBaseIfNeeded, err := s.resolveOrUpdateBaseForFiles(runDefinition, BaseLayerSpec{}, false)
+	addBaseIfNeeded, err := s.resolveOrUpdateBaseForFiles(runDefinition, BaseLayerSpec{}, false, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to resolve base")
 	}
@@ -185,7 +202,9 @@ func (s Service) InitiateRun(cfg InitiateRunConfig) (*api.InitiateRunResult, err
 	mintFiles := filterYAMLFilesForModification(runDefinition, func(doc *YAMLDoc) bool {
 		return true
 	})
-	resolvedLeaves, err := s.resolveOrUpdateLeavesForFiles(mintFiles, false, PickLatestMajorVersion)
+	s.warnAboutDeprecatedBases(mintFiles)
+
+	resolvedLeaves, err := s.resolveOrUpdateLeavesForFiles(mintFiles, resolveOrUpdateLeavesConfig{VersionPicker: PickLatestMajorVersion})
 	if err != nil {
 		return nil, err
 	}
@@ -195,11 +214,46 @@ func (s Service) InitiateRun(cfg InitiateRunConfig) (*api.InitiateRunResult, err
 		}
 		fmt.Fprintln(s.Stderr, "")
 
+		// Leaves resolved for this run are locked like any other
+		if err := s.refreshLeafLockfile(mintDirectoryPath, []string{runDefinitionPath}, s.Stderr); err != nil {
+			return nil, err
+		}
+
 		if err = reloadRunDefinitions(); err != nil {
 			return nil, err
 		}
 	}
 
+	// Every YAML file in the .mint directory is sent along with the run, so all of them are verified
+	verifiedEntries := slices.Clone(runDefinition)
+	for _, entry := range filterYAMLFiles(mintDirectory) {
+		if isUpdatePolicyEntry(entry) || slices.ContainsFunc(runDefinition, func(rd MintDirectoryEntry) bool {
+			return rd.Path == entry.Path
+		}) {
+			continue
+		}
+		verifiedEntries = append(verifiedEntries, entry)
+	}
+	verifiedFiles := filterYAMLFilesForModification(verifiedEntries, func(doc *YAMLDoc) bool {
+		return true
+	})
+
+	lockProblems, err := s.verifyLeafLockfile(mintDirectoryPath, verifiedFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(lockProblems) > 0 {
+		return nil, errors.New(formatLeafLockfileProblems(lockProblems))
+	}
+
+	digestProblems, err := s.verifyPinnedLeafDigests(verifiedFiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(digestProblems) > 0 {
+		return nil, errors.New(formatLeafProblems("unable to verify the digests of pinned leaves:", digestProblems))
+	}
+
 	i := 0
 	initializationParameters := make([]api.InitializationParameter, len(cfg.InitParameters))
 	for key, value := range cfg.InitParameters {
@@ -210,6 +264,11 @@ func (s Service) InitiateRun(cfg InitiateRunConfig) (*api.InitiateRunResult, err
 		i++
 	}
 
+	mintDirectory, err = s.uploadMintDirectoryBlobs(mintDirectory, listFiles)
+	if err != nil {
+		return nil, err
+	}
+
 	runResult, err := s.APIClient.InitiateRun(api.InitiateRunConfig{
 		InitializationParameters: initializationParameters,
 		TaskDefinitions:          runDefinition,
@@ -300,13 +359,14 @@ func (s Service) Lint(cfg LintConfig) (*api.LintResult, error) {
 
 	var mintDirEntries []MintDirectoryEntry
 	if mintDirectoryPath != "" {
-		mdEntries, err := mintDirectoryEntries(mintDirectoryPath)
+		mdEntries, err := mintDirectoryEntries(mintDirectoryPath, mintDirectoryOptions{})
 		if err != nil {
 			return nil, err
 		}
 
 		// Ensure both the provided paths and everything in the MintDirectory is loaded.
 		mdEntries = filterYAMLFiles(mdEntries)
+		mdEntries = slices.DeleteFunc(mdEntries, isUpdatePolicyEntry)
 		mdEntries = removeDuplicates(mdEntries, func(entry MintDirectoryEntry) string {
 			return entry.Path
 		})
@@ -358,6 +418,33 @@ func (s Service) Lint(cfg LintConfig) (*api.LintResult, error) {
 		return nil, errors.Wrap(err, "unable to lint files")
 	}
 
+	targetedFiles := filterYAMLFilesForModification(targetedEntries, func(doc *YAMLDoc) bool {
+		return true
+	})
+	targetedFiles = slices.DeleteFunc(targetedFiles, func(file *MintYAMLFile) bool {
+		return !slices.Contains(targetedPaths, file.Entry.Path)
+	})
+	s.warnAboutDeprecatedBases(targetedFiles)
+
+	lockProblems, err := s.verifyLeafLockfile(mintDirectoryPath, targetedFiles)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lint files")
+	}
+	lintResult.Problems = append(lintResult.Problems, lockProblems...)
+
+	digestProblems, err := s.verifyPinnedLeafDigests(targetedFiles)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lint files")
+	}
+	lintResult.Problems = append(lintResult.Problems, digestProblems...)
+
+	leafProblems, err := s.validateLeafCalls(targetedFiles)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lint files")
+	}
+	lintResult.Problems = append(lintResult.Problems, leafProblems...)
+	lintResult.Problems = append(lintResult.Problems, s.validateEmbeddedRunCalls(mintDirectoryPath, targetedFiles)...)
+
 	switch cfg.OutputFormat {
 	case LintOutputOneLine:
 		err = outputLintOneLine(s.Stdout, lintResult.Problems)
@@ -521,6 +608,21 @@ func (s Service) Whoami(cfg WhoamiConfig) error {
 	return nil
 }
 
+// ClearCache removes every cached API response.
+func (s Service) ClearCache(cfg ClearCacheConfig) error {
+	err := cfg.Validate()
+	if err != nil {
+		return errors.Wrap(err, "validation failed")
+	}
+
+	if err := cfg.Cache.Clear(); err != nil {
+		return errors.Wrap(err, "unable to clear the cache")
+	}
+
+	fmt.Fprintf(s.Stdout, "Cleared the cache in %s\n", cfg.Cache.Dir)
+	return nil
+}
+
 func (s Service) SetSecretsInVault(cfg SetSecretsInVaultConfig) error {
 	defer s.outputLatestVersionMessage()
 	err := cfg.Validate()
@@ -607,7 +709,11 @@ func (s Service) ResolveLeaves(cfg ResolveLeavesConfig) (ResolveLeavesResult, er
 		return true
 	})
 
-	replacements, err := s.resolveOrUpdateLeavesForFiles(mintFiles, false, cfg.LatestVersionPicker)
+	replacements, err := s.resolveOrUpdateLeavesForFiles(mintFiles, resolveOrUpdateLeavesConfig{
+		PinDigests:    cfg.PinDigests,
+		PinGitLeaves:  true,
+		VersionPicker: cfg.LatestVersionPicker,
+	})
 	if err != nil {
 		return ResolveLeavesResult{}, err
 	}
@@ -619,6 +725,10 @@ func (s Service) ResolveLeaves(cfg ResolveLeavesConfig) (ResolveLeavesResult, er
 		for leaf, version := range replacements {
 			fmt.Fprintf(s.Stdout, "\t%s → %s\n", leaf, version)
 		}
+
+		if err := s.refreshLeafLockfile(mintDirectoryPath, cfg.Files, s.Stdout); err != nil {
+			return ResolveLeavesResult{}, err
+		}
 	}
 
 	return ResolveLeavesResult{ResolvedLeaves: replacements}, nil
@@ -645,15 +755,38 @@ func (s Service) UpdateLeaves(cfg UpdateLeavesConfig) error {
 		return errors.New(fmt.Sprintf("no files provided, and no yaml files found in directory %s", mintDirectoryPath))
 	}
 
+	if cfg.Plan != nil {
+		yamlFiles = cfg.Plan.applyTo(yamlFiles)
+	}
+
 	mintFiles := filterYAMLFilesForModification(yamlFiles, func(doc *YAMLDoc) bool {
 		return true
 	})
 
-	replacements, err := s.resolveOrUpdateLeavesForFiles(mintFiles, true, cfg.ReplacementVersionPicker)
+	versionPicker := cfg.ReplacementVersionPicker
+	updatePolicy, err := readUpdatePolicy(mintDirectoryPath)
+	if err != nil {
+		return err
+	}
+	if updatePolicy != nil {
+		versionPicker = updatePolicy.Picker(versionPicker)
+	}
+
+	replacements, err := s.resolveOrUpdateLeavesForFiles(mintFiles, resolveOrUpdateLeavesConfig{
+		Update:        true,
+		PinDigests:    cfg.PinDigests,
+		PinGitLeaves:  true,
+		VersionPicker: versionPicker,
+		Plan:          cfg.Plan,
+	})
 	if err != nil {
 		return err
 	}
 
+	if cfg.Plan != nil {
+		return nil
+	}
+
 	if len(replacements) == 0 {
 		fmt.Fprintln(s.Stdout, "No leaves to update.")
 	} else {
@@ -666,18 +799,251 @@ func (s Service) UpdateLeaves(cfg UpdateLeavesConfig) error {
 				fmt.Fprintf(s.Stdout, "\t%s → %s\n", original, replacement)
 			}
 		}
+
+		s.outputLeafReleaseNotes(replacements)
+
+		if err := s.refreshLeafLockfile(mintDirectoryPath, cfg.Files, s.Stdout); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-var reLeafVersion = regexp.MustCompile(`([a-z0-9-]+\/[a-z0-9-]+)(?:\s+(([0-9]+)\.[0-9]+\.[0-9]+))?`)
+// LockLeaves records the digest of every leaf version called from the .mint directory in its lockfile.
+func (s Service) LockLeaves(cfg LockLeavesConfig) (LockLeavesResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return LockLeavesResult{}, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return LockLeavesResult{}, errors.Wrap(err, "unable to find .mint directory")
+	}
+
+	if mintDirectoryPath == "" {
+		return LockLeavesResult{}, fmt.Errorf("unable to find a .mint directory to write %s to", LeafLockfileName)
+	}
+
+	lockfile, err := s.writeLeafLockfile(mintDirectoryPath, cfg.Files)
+	if err != nil {
+		return LockLeavesResult{}, err
+	}
+
+	if len(lockfile.Leaves) == 0 {
+		fmt.Fprintf(s.Stdout, "No leaves to lock. Wrote an empty %s.\n", relativePathFromWd(leafLockfilePath(mintDirectoryPath)))
+	} else {
+		fmt.Fprintf(s.Stdout, "Locked the following leaves in %s:\n", relativePathFromWd(leafLockfilePath(mintDirectoryPath)))
+		for _, leaf := range slices.Sorted(maps.Keys(lockfile.Leaves)) {
+			fmt.Fprintf(s.Stdout, "\t%s → %s\n", leaf, lockfile.Leaves[leaf])
+		}
+	}
+
+	return LockLeavesResult{LockedLeaves: lockfile.Leaves}, nil
+}
+
+// AddLeaf appends a task calling a leaf to a run definition. The leaf is pinned to its latest
+// version unless a version is given, and the parameters are validated against its schema.
+func (s Service) AddLeaf(cfg AddLeafConfig) (AddLeafResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return AddLeafResult{}, errors.Wrap(err, "validation failed")
+	}
+
+	params, err := parseLeafParams(cfg.Params)
+	if err != nil {
+		return AddLeafResult{}, err
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return AddLeafResult{}, errors.Wrap(err, "unable to find .mint directory")
+	}
+
+	runFilePath, err := findRunDefinitionForLeaf(cfg.File, mintDirectoryPath)
+	if err != nil {
+		return AddLeafResult{}, err
+	}
+
+	file, err := ParseYAMLFile(runFilePath)
+	if err != nil {
+		return AddLeafResult{}, errors.Wrapf(err, "unable to parse %q", runFilePath)
+	}
+
+	// In multi-document files, the task is added to the first document with tasks
+	var doc *YAMLDoc
+	var yamlPath string
+	for _, document := range file.Documents() {
+		if yamlPath = tasksPath(document); yamlPath != "" {
+			doc = document
+			break
+		}
+	}
+	if yamlPath == "" {
+		return AddLeafResult{}, fmt.Errorf("%q is not a run definition or a list of tasks", runFilePath)
+	}
+
+	version := cfg.Version
+	if version == "" {
+		leafVersions, err := s.APIClient.GetLeafVersions()
+		if err != nil {
+			return AddLeafResult{}, errors.Wrap(err, "unable to fetch leaf versions")
+		}
+
+		version = leafVersions.LatestMajor[cfg.Leaf]
+		if version == "" {
+			return AddLeafResult{}, fmt.Errorf("Unable to find the leaf %q.", cfg.Leaf)
+		}
+	}
+
+	leaf, err := s.APIClient.GetLeaf(api.GetLeafConfig{Name: cfg.Leaf, Version: version})
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			return AddLeafResult{}, fmt.Errorf("Unable to find version %q of the leaf %q.", version, cfg.Leaf)
+		}
+		return AddLeafResult{}, errors.Wrapf(err, "unable to fetch leaf %q", cfg.Leaf)
+	}
+
+	if err := validateLeafParams(*leaf, params); err != nil {
+		return AddLeafResult{}, err
+	}
+
+	key := cfg.Key
+	if key == "" {
+		key = leafTaskKey(cfg.Leaf)
+	}
+
+	keyExists := false
+	err = doc.ForEachNode(yamlPath+"[*].key", func(node ast.Node) error {
+		keyExists = keyExists || scalarText(node) == key
+		return nil
+	})
+	if err != nil {
+		return AddLeafResult{}, errors.Wrapf(err, "unable to read the task keys in %q", runFilePath)
+	}
+	if keyExists {
+		return AddLeafResult{}, fmt.Errorf("A task with the key %q already exists in %s. Choose another key with --key.", key, relativePathFromWd(runFilePath))
+	}
+
+	call := fmt.Sprintf("%s %s", cfg.Leaf, version)
+	task := yaml.MapSlice{{Key: "key", Value: key}, {Key: "call", Value: call}}
+	if len(params) > 0 {
+		with := make(yaml.MapSlice, 0, len(params))
+		for _, param := range params {
+			with = append(with, yaml.MapItem{Key: param.Key, Value: param.Value})
+		}
+		task = append(task, yaml.MapItem{Key: "with", Value: with})
+	}
+
+	if err := doc.AppendToSequence(yamlPath, task); err != nil {
+		return AddLeafResult{}, errors.Wrapf(err, "unable to add the task to %q", runFilePath)
+	}
+
+	if err := doc.WriteFile(runFilePath); err != nil {
+		return AddLeafResult{}, err
+	}
+
+	fmt.Fprintf(s.Stdout, "Added task %q calling %s to %s\n", key, call, relativePathFromWd(runFilePath))
+
+	if err := s.refreshLeafLockfile(mintDirectoryPath, []string{runFilePath}, s.Stdout); err != nil {
+		return AddLeafResult{}, err
+	}
+
+	return AddLeafResult{File: runFilePath, Key: key, Call: call}, nil
+}
+
+// SearchLeaves lists the leaves in the registry matching a query.
+func (s Service) SearchLeaves(cfg SearchLeavesConfig) (*api.SearchLeavesResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	result, err := s.APIClient.SearchLeaves(api.SearchLeavesConfig{Query: cfg.Query})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to search leaves")
+	}
+
+	if cfg.Json {
+		encoded, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to JSON encode the result")
+		}
+
+		fmt.Fprintln(s.Stdout, string(encoded))
+		return result, nil
+	}
+
+	if len(result.Leaves) == 0 {
+		if cfg.Query == "" {
+			fmt.Fprintln(s.Stdout, "No leaves found.")
+		} else {
+			fmt.Fprintf(s.Stdout, "No leaves match %q.\n", cfg.Query)
+		}
+		return result, nil
+	}
+
+	outputLeafSummaries(s.Stdout, result.Leaves)
+	return result, nil
+}
+
+// ShowLeaf outputs the details of a leaf along with a task snippet calling it.
+func (s Service) ShowLeaf(cfg ShowLeafConfig) (*api.LeafDetails, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	leaf, err := s.APIClient.GetLeaf(api.GetLeafConfig{Name: cfg.Leaf, Version: cfg.Version})
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			return nil, fmt.Errorf("Unable to find the leaf %q.", cfg.Leaf)
+		}
+		return nil, errors.Wrapf(err, "unable to fetch leaf %q", cfg.Leaf)
+	}
+
+	if cfg.Json {
+		encoded, err := json.MarshalIndent(leaf, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to JSON encode the result")
+		}
+
+		fmt.Fprintln(s.Stdout, string(encoded))
+		return leaf, nil
+	}
+
+	snippetVersion := cfg.Version
+	if snippetVersion == "" {
+		leafVersions, err := s.APIClient.GetLeafVersions()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to fetch leaf versions")
+		}
+
+		snippetVersion = leafVersions.LatestMajor[leaf.Name]
+		if snippetVersion == "" {
+			snippetVersion = leaf.Version
+		}
+	}
+
+	outputLeafDetails(s.Stdout, *leaf, snippetVersion)
+	return leaf, nil
+}
+
+// reLeafVersion matches leaves of the public registry and of organizations, eg. "mint/install-node 1.2.3"
+// or "My-Org/deploy_app", optionally pinned to a digest, quoted and followed by a comment.
+var reLeafVersion = regexp.MustCompile(`^\s*["']?(([A-Za-z0-9][A-Za-z0-9_-]*\/[A-Za-z0-9][A-Za-z0-9_-]*)(?:\s+(([0-9]+)\.[0-9]+\.[0-9]+(?:-[0-9A-Za-z.-]+)?)(?:@(sha256:[0-9a-f]{64}))?)?)["']?\s*(?:#.*)?$`)
 
 type LeafVersion struct {
 	Original     string
 	Name         string
 	Version      string
 	MajorVersion string
+	Digest       string
 }
 
 func (s Service) parseLeafVersion(str string) LeafVersion {
@@ -687,61 +1053,126 @@ func (s Service) parseLeafVersion(str string) LeafVersion {
 	}
 
 	return LeafVersion{
-		Original:     match[0],
-		Name:         tryGetSliceAtIndex(match, 1, ""),
-		Version:      tryGetSliceAtIndex(match, 2, ""),
-		MajorVersion: tryGetSliceAtIndex(match, 3, ""),
+		Original:     tryGetSliceAtIndex(match, 1, ""),
+		Name:         tryGetSliceAtIndex(match, 2, ""),
+		Version:      tryGetSliceAtIndex(match, 3, ""),
+		MajorVersion: tryGetSliceAtIndex(match, 4, ""),
+		Digest:       tryGetSliceAtIndex(match, 5, ""),
 	}
 }
 
-func (s Service) resolveOrUpdateLeavesForFiles(mintFiles []*MintYAMLFile, update bool, versionPicker func(versions api.LeafVersionsResult, leaf string, major string) (string, error)) (map[string]string, error) {
+// resolveOrUpdateLeavesForFiles adds or updates the version of every leaf called from the given files.
+func (s Service) resolveOrUpdateLeavesForFiles(mintFiles []*MintYAMLFile, cfg resolveOrUpdateLeavesConfig) (map[string]string, error) {
 	leafVersions, err := s.APIClient.GetLeafVersions()
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to fetch leaf versions")
 	}
 
+	calls, err := s.findLeafCalls(mintFiles)
+	if err != nil {
+		return nil, err
+	}
+	s.addOrganizationLeafVersions(leafVersions, calls)
+
+	digests := newLeafDigestResolver(s)
+
 	docs := make(map[string]*YAMLDoc)
 	replacements := make(map[string]string)
 
 	for _, file := range mintFiles {
 		hasChange := false
 
-		var nodePath string
-		if file.Doc.IsRunDefinition() {
-			nodePath = "$.tasks[*].call"
-		} else if file.Doc.IsListOfTasks() {
-			nodePath = "$[*].call"
-		} else {
+		nodePath := leafCallsPath(file.Doc)
+		if nodePath == "" {
 			continue
 		}
 
+		// replace replaces the call of a leaf, setting the comment following it when one is given
+		replace := func(node ast.Node, original string, newLeaf string, comment string, target string) error {
+			if newLeaf == scalarText(node) && comment == "" {
+				return nil
+			}
+
+			if err := file.Doc.ReplaceAtPath(node.GetPath(), newLeaf); err != nil {
+				return err
+			}
+
+			if comment != "" {
+				if err := file.Doc.SetLineCommentAtPath(node.GetPath(), comment); err != nil {
+					return err
+				}
+				newLeaf = fmt.Sprintf("%s # %s", newLeaf, comment)
+			}
+
+			if cfg.Plan != nil {
+				cfg.Plan.addChange(file.Entry.OriginalPath, node.GetPath(), node.String(), newLeaf, PlannedChangeKindLeaf)
+			}
+
+			replacements[original] = target
+			hasChange = true
+			return nil
+		}
+
 		err = file.Doc.ForEachNode(nodePath, func(node ast.Node) error {
+			if gitLeaf, ok := parseGitLeaf(node.String()); ok {
+				// Leaves pinned to a commit are only moved forward when updating them
+				if !cfg.PinGitLeaves || (isCommitSha(gitLeaf.Ref) && !cfg.Update) {
+					return nil
+				}
+
+				ref, ok := gitLeaf.TrackedRef()
+				if !ok {
+					return nil
+				}
+
+				if file.Doc.IsJSON() {
+					fmt.Fprintf(s.Stderr, "Unable to pin the leaf %q, as JSON has no comments to record its ref in; skipping it.\n", gitLeaf.Original)
+					return nil
+				}
+
+				sha, err := s.resolveGitLeafRef(gitLeaf, ref)
+				if err != nil {
+					fmt.Fprintln(s.Stderr, err.Error())
+					return nil
+				}
+
+				if gitLeaf.PinnedTo(sha) == scalarText(node) && gitLeaf.PinnedFrom == ref {
+					return nil
+				}
+
+				// The ref is kept next to the commit, so that updates can follow it
+				return replace(node, gitLeaf.Original, gitLeaf.PinnedTo(sha), fmt.Sprintf("ref: %s", ref), sha)
+			}
+
 			leafVersion := s.parseLeafVersion(node.String())
 			if leafVersion.Name == "" {
 				// Leaves won't be found for eg. embedded runs, call: ${{ run.mint-dir }}/embed.yml
 				return nil
-			} else if !update && leafVersion.MajorVersion != "" {
-				return nil
-			}
-
-			targetLeafVersion, err := versionPicker(*leafVersions, leafVersion.Name, leafVersion.MajorVersion)
-			if err != nil {
-				fmt.Fprintln(s.Stderr, err.Error())
+			} else if !cfg.Update && leafVersion.MajorVersion != "" && !cfg.PinDigests {
 				return nil
 			}
 
-			newLeaf := fmt.Sprintf("%s %s", leafVersion.Name, targetLeafVersion)
-			if newLeaf == node.String() {
-				return nil
+			targetLeafVersion := leafVersion.Version
+			if cfg.Update || targetLeafVersion == "" {
+				picked, err := cfg.VersionPicker(*leafVersions, leafVersion.Name, leafVersion.Version)
+				if err != nil {
+					fmt.Fprintln(s.Stderr, err.Error())
+					return nil
+				}
+				targetLeafVersion = picked
 			}
 
-			if err = file.Doc.ReplaceAtPath(node.GetPath(), newLeaf); err != nil {
-				return err
+			// Once pinned, leaves stay pinned to the digest of their new version
+			if cfg.PinDigests || leafVersion.Digest != "" {
+				digest, err := digests.resolve(leafVersion.Name, targetLeafVersion)
+				if err != nil {
+					fmt.Fprintln(s.Stderr, err.Error())
+					return nil
+				}
+				targetLeafVersion = fmt.Sprintf("%s@%s", targetLeafVersion, digest)
 			}
 
-			replacements[leafVersion.Original] = targetLeafVersion
-			hasChange = true
-			return nil
+			return replace(node, leafVersion.Original, fmt.Sprintf("%s %s", leafVersion.Name, targetLeafVersion), "", targetLeafVersion)
 		})
 		if err != nil {
 			return nil, errors.Wrap(err, "unable to replace leaf references")
@@ -757,7 +1188,11 @@ func (s Service) resolveOrUpdateLeavesForFiles(mintFiles []*MintYAMLFile, update
 			continue
 		}
 
-		err := doc.WriteFile(path)
+		if cfg.Plan != nil {
+			err = cfg.Plan.writeFile(path, doc.String())
+		} else {
+			err = doc.WriteFile(path)
+		}
 		if err != nil {
 			return replacements, err
 		}
@@ -766,34 +1201,442 @@ func (s Service) resolveOrUpdateLeavesForFiles(mintFiles []*MintYAMLFile, update
 	return replacements, nil
 }
 
-func (s Service) ResolveBase(cfg ResolveBaseConfig) (ResolveBaseResult, error) {
+// ListBaseLayers outputs the base layers available to runs, which are the valid values for the
+// os, tag and arch of a run's base.
+func (s Service) ListBaseLayers(cfg ListBaseLayersConfig) (*api.ListBaseLayersResult, error) {
 	defer s.outputLatestVersionMessage()
 	err := cfg.Validate()
 	if err != nil {
-		return ResolveBaseResult{}, errors.Wrap(err, "validation failed")
-	}
-
-	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
-	if err != nil {
-		return ResolveBaseResult{}, errors.Wrap(err, "unable to find .mint directory")
+		return nil, errors.Wrap(err, "validation failed")
 	}
 
-	yamlFiles, err := getFileOrDirectoryYAMLEntries(cfg.Files, mintDirectoryPath)
+	result, err := s.APIClient.ListBaseLayers()
 	if err != nil {
-		return ResolveBaseResult{}, err
+		return nil, errors.Wrap(err, "unable to list base layers")
 	}
 
-	if len(yamlFiles) == 0 {
+	if cfg.Json {
+		encoded, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to JSON encode the result")
+		}
+
+		fmt.Fprintln(s.Stdout, string(encoded))
+		return result, nil
+	}
+
+	if len(result.BaseLayers) == 0 {
+		fmt.Fprintln(s.Stdout, "No base layers found.")
+		return result, nil
+	}
+
+	outputBaseLayers(s.Stdout, result.BaseLayers)
+	return result, nil
+}
+
+// Evaluate evaluates an expression with the contexts known locally: init parameters and the
+// Mint directory. Strings containing `${{ }}` expressions are interpolated instead.
+func (s Service) Evaluate(cfg EvaluateConfig) (*EvaluateResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find .mint directory")
+	}
+
+	initParameters := cfg.InitParameters
+	if initParameters == nil {
+		initParameters = make(map[string]string)
+	}
+	ctx := expressionContext(mintDirectoryPath, initParameters)
+
+	var value any
+	if expressions.ContainsExpression(cfg.Expression) {
+		value, err = expressions.Interpolate(cfg.Expression, ctx)
+	} else {
+		value, err = expressions.Evaluate(cfg.Expression, ctx)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to evaluate the expression")
+	}
+
+	fmt.Fprintln(s.Stdout, expressions.ToString(value))
+	return &EvaluateResult{Value: value}, nil
+}
+
+// ListTasks lists the tasks of the given files, or of the top-level files of the Mint directory,
+// along with the leaf or embedded run they call.
+func (s Service) ListTasks(cfg ListTasksConfig) (*ListTasksResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find .mint directory")
+	}
+
+	yamlFiles, err := getFileOrDirectoryYAMLEntries(cfg.Files, mintDirectoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(yamlFiles) == 0 {
+		return nil, errors.New(fmt.Sprintf("no files provided, and no yaml files found in directory %s", mintDirectoryPath))
+	}
+
+	initParameters := cfg.InitParameters
+	if initParameters == nil {
+		initParameters = make(map[string]string)
+	}
+	ctx := expressionContext(mintDirectoryPath, initParameters)
+
+	result := &ListTasksResult{Tasks: make([]TaskSummary, 0)}
+	for _, entry := range yamlFiles {
+		if isUpdatePolicyEntry(entry) {
+			continue
+		}
+
+		tasks, err := s.listTasks(entry, ctx)
+		if err != nil {
+			return nil, err
+		}
+		result.Tasks = append(result.Tasks, tasks...)
+	}
+
+	if len(result.Tasks) == 0 {
+		fmt.Fprintln(s.Stdout, "No tasks found.")
+		return result, nil
+	}
+
+	outputTasks(s.Stdout, result.Tasks)
+	return result, nil
+}
+
+// Render prints a run definition with the tasks of the runs it embeds inlined, evaluating the
+// expressions that only refer to init parameters and the Mint directory.
+func (s Service) Render(cfg RenderConfig) (*RenderResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find .mint directory")
+	}
+
+	// Without init parameters, they're left to be given when running
+	initParameters := cfg.InitParameters
+	if len(initParameters) == 0 {
+		initParameters = nil
+	}
+
+	r := &renderer{stderr: s.Stderr}
+	definition, tasks, err := r.renderFile(cfg.File, expressionContext(mintDirectoryPath, initParameters))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to render %q", cfg.File)
+	}
+
+	rendered, err := renderDefinition(definition, tasks, cfg.Json)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to render %q", cfg.File)
+	}
+
+	fmt.Fprint(s.Stdout, rendered)
+	return &RenderResult{Rendered: rendered}, nil
+}
+
+// Import translates the configuration of another CI system to run definitions in the Mint
+// directory, which is created if needed. Parts that can't be translated are marked with TODO
+// comments.
+func (s Service) Import(cfg ImportConfig) (*ImportResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find .mint directory")
+	}
+	if mintDirectoryPath == "" {
+		mintDirectoryPath = ".mint"
+	}
+
+	leafVersions, err := s.APIClient.GetLeafVersions()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch leaf versions")
+	}
+	imp := newImporter(cfg, leafVersions)
+
+	files := cfg.Files
+	if len(files) == 0 {
+		absMintDirectoryPath, err := filepath.Abs(mintDirectoryPath)
+		if err != nil {
+			return nil, err
+		}
+
+		files, err = imp.defaultFiles(filepath.Dir(absMintDirectoryPath))
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to find the files to import")
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files provided, and no %s configuration found in %s", cfg.Source, relativePathFromWd(filepath.Dir(absMintDirectoryPath)))
+		}
+	}
+
+	type importedFile struct {
+		ImportedFile
+		doc *YAMLDoc
+	}
+
+	imported := make([]importedFile, 0, len(files))
+	for _, file := range files {
+		run, err := imp.importFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := run.doc()
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to write the run definition imported from %q", file)
+		}
+
+		path := filepath.Join(mintDirectoryPath, imp.fileName(file))
+		if _, err := os.Stat(path); err == nil && !cfg.Force {
+			return nil, fmt.Errorf("%s already exists. Use --force to overwrite it.", relativePathFromWd(path))
+		}
+
+		imported = append(imported, importedFile{
+			ImportedFile: ImportedFile{Source: file, Path: path, Problems: run.problems},
+			doc:          doc,
+		})
+	}
+
+	if err := os.MkdirAll(mintDirectoryPath, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "unable to create %q", mintDirectoryPath)
+	}
+
+	result := &ImportResult{ImportedFiles: make([]ImportedFile, 0, len(imported))}
+	for _, file := range imported {
+		if err := file.doc.WriteFile(file.Path); err != nil {
+			return nil, errors.Wrapf(err, "unable to write %q", file.Path)
+		}
+		result.ImportedFiles = append(result.ImportedFiles, file.ImportedFile)
+
+		fmt.Fprintf(s.Stdout, "Imported %s to %s\n", relativePathFromWd(file.Source), relativePathFromWd(file.Path))
+	}
+	outputImportReport(s.Stdout, result.ImportedFiles)
+
+	paths := Map(result.ImportedFiles, func(file ImportedFile) string {
+		return file.Path
+	})
+	entries, err := mintDirectoryEntriesFromPaths(paths)
+	if err == nil {
+		_, err = s.resolveOrUpdateBaseForFiles(entries, BaseLayerSpec{}, false, nil)
+	}
+	if err != nil {
+		fmt.Fprintf(s.Stderr, "Unable to resolve the base of the imported run definitions: %s\nRun 'mint resolve base' to try again.\n", err)
+	}
+
+	return result, nil
+}
+
+// Init writes a starter run definition for the languages detected in the project, with its base
+// and leaves resolved.
+func (s Service) Init(cfg InitConfig) (*InitResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find .mint directory")
+	}
+	if mintDirectoryPath == "" {
+		mintDirectoryPath = cmp.Or(cfg.MintDirectory, ".mint")
+	}
+
+	absMintDirectoryPath, err := filepath.Abs(mintDirectoryPath)
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Dir(absMintDirectoryPath)
+
+	path := filepath.Join(mintDirectoryPath, initRunDefinitionName)
+	if _, err := os.Stat(path); err == nil && !cfg.Force {
+		return nil, fmt.Errorf("%s already exists. Use --force to overwrite it.", relativePathFromWd(path))
+	}
+
+	repository := cmp.Or(cfg.Repository, initGitHubRepository(root), importRepositoryPlaceholder)
+	languages := detectLanguages(root)
+	result := &InitResult{Path: path, Languages: Map(languages, func(language initLanguage) string {
+		return language.name
+	})}
+
+	doc, err := runDefinitionDoc(initRunDefinition(root, repository, languages))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to write %q", path)
+	}
+	if err := os.MkdirAll(mintDirectoryPath, 0o755); err != nil {
+		return nil, errors.Wrapf(err, "unable to create %q", mintDirectoryPath)
+	}
+	if err := doc.WriteFile(path); err != nil {
+		return nil, errors.Wrapf(err, "unable to write %q", path)
+	}
+
+	if len(result.Languages) > 0 {
+		fmt.Fprintf(s.Stdout, "Detected %s\n", strings.Join(result.Languages, ", "))
+	} else {
+		fmt.Fprintln(s.Stdout, "No language detected")
+	}
+	fmt.Fprintf(s.Stdout, "Created %s\n", relativePathFromWd(path))
+
+	entries, err := mintDirectoryEntriesFromPaths([]string{path})
+	if err != nil {
+		return nil, err
+	}
+	resolvedBase, err := s.resolveOrUpdateBaseForFiles(entries, BaseLayerSpec{}, false, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to resolve base")
+	}
+	for _, runFile := range resolvedBase.UpdatedRunFiles {
+		fmt.Fprintf(s.Stdout, "Configured %s to run on %s\n", relativePathFromWd(path), runFile.ResolvedBase.Os)
+	}
+
+	entries, err = mintDirectoryEntriesFromPaths([]string{path})
+	if err != nil {
+		return nil, err
+	}
+	mintFiles := filterYAMLFilesForModification(entries, func(doc *YAMLDoc) bool {
+		return true
+	})
+	resolvedLeaves, err := s.resolveOrUpdateLeavesForFiles(mintFiles, resolveOrUpdateLeavesConfig{VersionPicker: PickLatestMajorVersion})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to resolve leaves")
+	}
+	for _, leaf := range slices.Sorted(maps.Keys(resolvedLeaves)) {
+		fmt.Fprintf(s.Stdout, "Configured leaf %s to use version %s\n", leaf, resolvedLeaves[leaf])
+	}
+
+	fmt.Fprintf(s.Stdout, "\nRun it with `mint run --file %s`\n", relativePathFromWd(path))
+	return result, nil
+}
+
+// Format rewrites Mint YAML files in their canonical formatting. When checking, files are left
+// as is and a diff of the changes formatting would make is printed instead.
+func (s Service) Format(cfg FormatConfig) (*FormatResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to find .mint directory")
+	}
+
+	yamlFiles, err := getFileOrDirectoryYAMLEntries(cfg.Files, mintDirectoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(yamlFiles) == 0 {
+		return nil, errors.New(fmt.Sprintf("no files provided, and no yaml files found in directory %s", mintDirectoryPath))
+	}
+
+	result := &FormatResult{ChangedFiles: make([]string, 0)}
+	for _, entry := range yamlFiles {
+		// JSON files are written by other tools, so they're left as they are
+		if isJSON([]byte(entry.FileContents)) {
+			continue
+		}
+
+		formatted, err := formatYAML(entry.FileContents)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to format %q", entry.OriginalPath)
+		}
+
+		if formatted == entry.FileContents {
+			continue
+		}
+		result.ChangedFiles = append(result.ChangedFiles, entry.OriginalPath)
+
+		if cfg.Check {
+			name := filepath.ToSlash(relativePathFromWd(entry.OriginalPath))
+			fmt.Fprint(s.Stdout, diff.Unified("a/"+name, "b/"+name, entry.FileContents, formatted))
+			continue
+		}
+
+		if err := writeFile(entry.OriginalPath, []byte(formatted)); err != nil {
+			return nil, errors.Wrapf(err, "unable to write %q", entry.OriginalPath)
+		}
+	}
+
+	switch {
+	case len(result.ChangedFiles) == 0:
+		fmt.Fprintln(s.Stdout, "All files are formatted.")
+	case cfg.Check:
+		pluralizedFiles := "files need"
+		if len(result.ChangedFiles) == 1 {
+			pluralizedFiles = "file needs"
+		}
+		fmt.Fprintf(s.Stdout, "\n%d %s to be formatted. Run `mint fmt` to format them.\n", len(result.ChangedFiles), pluralizedFiles)
+	default:
+		fmt.Fprintln(s.Stdout, "Formatted the following files:")
+		for _, path := range result.ChangedFiles {
+			fmt.Fprintf(s.Stdout, "\t%s\n", relativePathFromWd(path))
+		}
+	}
+
+	return result, nil
+}
+
+func (s Service) ResolveBase(cfg ResolveBaseConfig) (ResolveBaseResult, error) {
+	defer s.outputLatestVersionMessage()
+	err := cfg.Validate()
+	if err != nil {
+		return ResolveBaseResult{}, errors.Wrap(err, "validation failed")
+	}
+
+	mintDirectoryPath, err := findAndValidateMintDirectoryPath(cfg.MintDirectory)
+	if err != nil {
+		return ResolveBaseResult{}, errors.Wrap(err, "unable to find .mint directory")
+	}
+
+	yamlFiles, err := getFileOrDirectoryYAMLEntries(cfg.Files, mintDirectoryPath)
+	if err != nil {
+		return ResolveBaseResult{}, err
+	}
+
+	if len(yamlFiles) == 0 {
 		return ResolveBaseResult{}, fmt.Errorf("no files provided, and no yaml files found in directory %s", mintDirectoryPath)
 	}
 
+	// Run definitions that already have a base aren't resolved, but may be on a deprecated one
+	s.warnAboutDeprecatedBases(filterYAMLFilesForModification(yamlFiles, func(doc *YAMLDoc) bool {
+		return true
+	}))
+
 	requestedSpec := BaseLayerSpec{
 		Os:   cfg.Os,
 		Tag:  cfg.Tag,
 		Arch: cfg.Arch,
 	}
 
-	result, err := s.resolveOrUpdateBaseForFiles(yamlFiles, requestedSpec, false)
+	result, err := s.resolveOrUpdateBaseForFiles(yamlFiles, requestedSpec, false, nil)
 	if err != nil {
 		return ResolveBaseResult{}, err
 	}
@@ -850,18 +1693,37 @@ func (s Service) UpdateBase(cfg UpdateBaseConfig) (ResolveBaseResult, error) {
 		return ResolveBaseResult{}, errors.New(errmsg)
 	}
 
-	result, err := s.resolveOrUpdateBaseForFiles(yamlFiles, BaseLayerSpec{}, true)
+	if cfg.Plan != nil {
+		yamlFiles = cfg.Plan.applyTo(yamlFiles)
+	}
+
+	result, err := s.resolveOrUpdateBaseForFiles(yamlFiles, BaseLayerSpec{Os: cfg.Os, Arch: cfg.Arch}, true, cfg.Plan)
 	if err != nil {
 		return ResolveBaseResult{}, err
 	}
+	result.UpdatedRunFiles = s.warnAboutBaseMigrations(result.UpdatedRunFiles, cfg.Plan)
 
-	if !result.HasChanges() {
+	if cfg.Plan != nil {
+		for _, runFile := range result.ErroredRunFiles {
+			fmt.Fprintf(s.Stderr, "Unable to update base for %s: %s\n", relativePathFromWd(runFile.OriginalPath), runFile.Error)
+		}
+		for _, runFile := range result.UpdatedRunFiles {
+			for _, warning := range runFile.Warnings {
+				fmt.Fprintf(s.Stderr, "Warning: %s may not be compatible with %s: %s\n", relativePathFromWd(runFile.OriginalPath), runFile.ResolvedBase.Os, warning)
+			}
+		}
+	} else if !result.HasChanges() {
 		fmt.Fprintln(s.Stdout, "No run bases to update.")
 	} else {
 		if len(result.UpdatedRunFiles) > 0 {
 			fmt.Fprintln(s.Stdout, "Updated base for the following run definitions:")
 			for _, runFile := range result.UpdatedRunFiles {
-				if runFile.Spec.Tag != "" {
+				if runFile.OriginalBase.Os != "" && (runFile.OriginalBase.Os != runFile.ResolvedBase.Os || !runFile.OriginalBase.SameArch(runFile.ResolvedBase)) {
+					fmt.Fprintf(s.Stdout, "\t%s %s → %s\n", relativePathFromWd(runFile.OriginalPath), runFile.OriginalBase.Describe(), runFile.ResolvedBase.Describe())
+					for _, warning := range runFile.Warnings {
+						fmt.Fprintf(s.Stdout, "\t\twarning: %s\n", warning)
+					}
+				} else if runFile.Spec.Tag != "" {
 					fmt.Fprintf(s.Stdout, "\t%s tag %s → tag %s\n", relativePathFromWd(runFile.OriginalPath), runFile.OriginalBase.Tag, runFile.ResolvedBase.Tag)
 				} else {
 					fmt.Fprintf(s.Stdout, "\t%s → tag %s\n", relativePathFromWd(runFile.OriginalPath), runFile.ResolvedBase.Tag)
@@ -883,7 +1745,9 @@ func (s Service) UpdateBase(cfg UpdateBaseConfig) (ResolveBaseResult, error) {
 	return result, nil
 }
 
-func (s Service) resolveOrUpdateBaseForFiles(mintFiles []MintDirectoryEntry, requestedSpec BaseLayerSpec, update bool) (ResolveBaseResult, error) {
+// resolveOrUpdateBaseForFiles adds or updates the base of every run definition in the given entries. When a
+// plan is given, changes are recorded in it instead of being written.
+func (s Service) resolveOrUpdateBaseForFiles(mintFiles []MintDirectoryEntry, requestedSpec BaseLayerSpec, update bool, plan *UpdatePlan) (ResolveBaseResult, error) {
 	runFiles, err := s.getFilesForBaseResolveOrUpdate(mintFiles, requestedSpec, update)
 	if err != nil {
 		return ResolveBaseResult{}, err
@@ -908,7 +1772,7 @@ func (s Service) resolveOrUpdateBaseForFiles(mintFiles []MintDirectoryEntry, req
 		}
 		runFile.ResolvedBase = resolvedBase
 
-		err := s.writeRunFileWithBase(runFile)
+		err := s.writeRunFileWithBase(runFile, plan)
 		if err != nil {
 			runFile.Error = err
 			erroredRunFiles = append(erroredRunFiles, runFile)
@@ -945,10 +1809,22 @@ func (s Service) getFilesForBaseResolveOrUpdate(entries []MintDirectoryEntry, re
 			Arch: yamlFile.Doc.TryReadStringAtPath("$.base.arch"),
 		}
 
+		runFileSpec := requestedSpec.Merge(spec)
+		if update {
+			// The requested os and arch take precedence over the run definition's when updating
+			runFileSpec = spec.Merge(requestedSpec)
+
+			// Migrating to another operating system starts over from its latest tag
+			if requestedSpec.Os != "" && requestedSpec.Os != spec.Os {
+				runFileSpec.Tag = requestedSpec.Tag
+			}
+		}
+
 		runFiles = append(runFiles, BaseLayerRunFile{
-			OriginalBase: spec,
-			Spec:         requestedSpec.Merge(spec),
-			OriginalPath: yamlFile.Entry.OriginalPath,
+			OriginalBase:  spec,
+			Spec:          runFileSpec,
+			OriginalPath:  yamlFile.Entry.OriginalPath,
+			DocumentIndex: yamlFile.DocumentIndex,
 		})
 	}
 
@@ -1000,6 +1876,10 @@ func (s Service) resolveBaseSpecs(runFiles []BaseLayerRunFile) (map[BaseLayerSpe
 	for _, runFile := range runFiles {
 		normalizedSpec := runFile.Spec
 		normalizedSpec.Tag = extractMajorVersion(normalizedSpec.Tag)
+		// Run definitions without an arch run on the default one, so they resolve together
+		if normalizedSpec.Arch == "" {
+			normalizedSpec.Arch = DefaultArch
+		}
 
 		originalToNormalized[runFile.Spec] = normalizedSpec
 
@@ -1058,8 +1938,8 @@ func (s Service) resolveBaseSpecs(runFiles []BaseLayerRunFile) (map[BaseLayerSpe
 
 			// Check each original base against the resolved version
 			for origBase := range maps.Keys(group.OriginalBases) {
-				// Only compare versions if they're in the same major version group
-				if extractMajorVersion(origBase.Tag) == extractMajorVersion(resolvedSpec.Tag) {
+				// Only compare versions if they're in the same major version group of the same OS
+				if origBase.Os == resolvedSpec.Os && extractMajorVersion(origBase.Tag) == extractMajorVersion(resolvedSpec.Tag) {
 					if origBase.TagVersion().GreaterThan(resolvedSpec.TagVersion()) {
 						// Report the specific tag that wasn't found
 						paths := group.RunFilePaths[origBase.Tag]
@@ -1092,8 +1972,32 @@ func (s Service) resolveBaseSpecs(runFiles []BaseLayerRunFile) (map[BaseLayerSpe
 	return originalToResolved, nil
 }
 
-func (s Service) writeRunFileWithBase(runFile BaseLayerRunFile) error {
-	doc, err := ParseYAMLFile(runFile.OriginalPath)
+// readRunFileDoc parses the run definition of a run file, reading its planned contents when a
+// plan is given.
+func readRunFileDoc(runFile BaseLayerRunFile, plan *UpdatePlan) (*YAMLDoc, error) {
+	var doc *YAMLDoc
+	var err error
+	if plan != nil {
+		var content string
+		if content, err = plan.readFile(runFile.OriginalPath); err == nil {
+			doc, err = ParseYAMLDoc(content)
+		}
+	} else {
+		doc, err = ParseYAMLFile(runFile.OriginalPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	documents := doc.Documents()
+	if runFile.DocumentIndex >= len(documents) {
+		return nil, fmt.Errorf("expected %q to have at least %d documents", runFile.OriginalPath, runFile.DocumentIndex+1)
+	}
+	return documents[runFile.DocumentIndex], nil
+}
+
+func (s Service) writeRunFileWithBase(runFile BaseLayerRunFile, plan *UpdatePlan) error {
+	doc, err := readRunFileDoc(runFile, plan)
 	if err != nil {
 		return err
 	}
@@ -1103,8 +2007,9 @@ func (s Service) writeRunFileWithBase(runFile BaseLayerRunFile) error {
 		"os": resolvedBase.Os,
 	}
 
-	// Prevent unnecessary quoting of float-like tags, eg. 1.2
-	if strings.Count(resolvedBase.Tag, ".") == 1 {
+	// Prevent unnecessary quoting of float-like tags, eg. 1.2. JSON keeps them as strings, as
+	// numbers would lose trailing zeros.
+	if strings.Count(resolvedBase.Tag, ".") == 1 && !doc.IsJSON() {
 		parsedTag, err := strconv.ParseFloat(resolvedBase.Tag, 64)
 		if err != nil {
 			return err
@@ -1114,7 +2019,9 @@ func (s Service) writeRunFileWithBase(runFile BaseLayerRunFile) error {
 		base["tag"] = resolvedBase.Tag
 	}
 
-	if resolvedBase.Arch != "" && resolvedBase.Arch != DefaultArch {
+	// The default arch is only written when the run definition already specifies one, eg. when
+	// switching back from arm64
+	if resolvedBase.Arch != "" && (resolvedBase.Arch != DefaultArch || runFile.OriginalBase.Arch != "") {
 		base["arch"] = resolvedBase.Arch
 	}
 
@@ -1135,9 +2042,54 @@ func (s Service) writeRunFileWithBase(runFile BaseLayerRunFile) error {
 		return nil
 	}
 
+	if plan != nil {
+		original := runFile.OriginalBase
+		if original.Os != resolvedBase.Os {
+			plan.addChange(runFile.OriginalPath, "$.base.os", original.Os, resolvedBase.Os, PlannedChangeKindBase)
+		}
+		if original.Tag != resolvedBase.Tag {
+			plan.addChange(runFile.OriginalPath, "$.base.tag", original.Tag, resolvedBase.Tag, PlannedChangeKindBase)
+		}
+		if arch, ok := base["arch"]; ok && original.Arch != arch {
+			plan.addChange(runFile.OriginalPath, "$.base.arch", original.Arch, resolvedBase.Arch, PlannedChangeKindBase)
+		}
+		return plan.writeFile(runFile.OriginalPath, doc.String())
+	}
+
 	return doc.WriteFile(runFile.OriginalPath)
 }
 
+// ReportUpdatePlan outputs the changes an update would make, either as a unified diff per file or as JSON.
+func (s Service) ReportUpdatePlan(cfg ReportUpdatePlanConfig) error {
+	err := cfg.Validate()
+	if err != nil {
+		return errors.Wrap(err, "validation failed")
+	}
+
+	if cfg.Json {
+		return cfg.Plan.writeJSON(s.Stdout)
+	}
+
+	if !cfg.Plan.HasChanges() {
+		fmt.Fprintln(s.Stdout, "No updates available.")
+		return nil
+	}
+
+	fmt.Fprint(s.Stdout, cfg.Plan.Diff())
+
+	pluralizedChanges := "changes"
+	if len(cfg.Plan.Changes) == 1 {
+		pluralizedChanges = "change"
+	}
+	pluralizedFiles := "files"
+	if len(cfg.Plan.fileOrder) == 1 {
+		pluralizedFiles = "file"
+	}
+	fmt.Fprintf(s.Stdout, "\nWould make %d %s to %d %s.\n", len(cfg.Plan.Changes), pluralizedChanges, len(cfg.Plan.fileOrder), pluralizedFiles)
+
+	return nil
+}
+
 func (s Service) outputLatestVersionMessage() {
 	if !versions.NewVersionAvailable() {
 		return
@@ -1168,6 +2120,7 @@ func (s Service) outputLatestVersionMessage() {
 	fmt.Fprintln(w)
 }
 
+// PickLatestMajorVersion picks the latest version of a leaf, regardless of the current one.
 func PickLatestMajorVersion(versions api.LeafVersionsResult, leaf string, _ string) (string, error) {
 	latestVersion, ok := versions.LatestMajor[leaf]
 	if !ok {
@@ -1177,11 +2130,16 @@ func PickLatestMajorVersion(versions api.LeafVersionsResult, leaf string, _ stri
 	return latestVersion, nil
 }
 
-func PickLatestMinorVersion(versions api.LeafVersionsResult, leaf string, major string) (string, error) {
-	if major == "" {
-		return PickLatestMajorVersion(versions, leaf, major)
+// PickLatestMinorVersion picks the latest version of a leaf with the same major version as the
+// current one. The current version may be a full version, eg. "1.2.3", or only a major version,
+// eg. "1". Leaves without a current version get the latest version.
+func PickLatestMinorVersion(versions api.LeafVersionsResult, leaf string, current string) (string, error) {
+	if current == "" {
+		return PickLatestMajorVersion(versions, leaf, current)
 	}
 
+	major := extractMajorVersion(current)
+
 	majorVersions, ok := versions.LatestMinor[leaf]
 	if !ok {
 		return "", fmt.Errorf("Unable to find the leaf %q; skipping it.", leaf)
@@ -1,9 +1,11 @@
 package cli_test
 
 import (
+	"crypto/sha256"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -11,9 +13,11 @@ import (
 
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/rwx-research/mint-cli/internal/accesstoken"
 	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/apicache"
 	"github.com/rwx-research/mint-cli/internal/cli"
 	"github.com/rwx-research/mint-cli/internal/errors"
 	"github.com/rwx-research/mint-cli/internal/messages"
@@ -86,6 +90,9 @@ var _ = Describe("CLI Service", func() {
 		var majorLeafVersions map[string]string
 		var minorLeafVersions map[string]map[string]string
 		var getLeafVersionsError error
+		var missingBlobs func(digests []string) []string
+		var uploadedBlobs map[string]string
+		var uploadedBlobsMutex sync.Mutex
 
 		BeforeEach(func() {
 			runConfig = cli.InitiateRunConfig{}
@@ -113,6 +120,18 @@ var _ = Describe("CLI Service", func() {
 					LatestMinor: minorLeafVersions,
 				}, getLeafVersionsError
 			}
+
+			missingBlobs = func(digests []string) []string { return digests }
+			uploadedBlobs = make(map[string]string)
+			mockAPI.MockFindMissingBlobs = func(cfg api.FindMissingBlobsConfig) (*api.FindMissingBlobsResult, error) {
+				return &api.FindMissingBlobsResult{Missing: missingBlobs(cfg.Digests)}, nil
+			}
+			mockAPI.MockUploadBlob = func(cfg api.UploadBlobConfig) error {
+				uploadedBlobsMutex.Lock()
+				defer uploadedBlobsMutex.Unlock()
+				uploadedBlobs[cfg.Digest] = string(cfg.Contents)
+				return nil
+			}
 		})
 
 		Context("with a specific mint file and no specific directory", func() {
@@ -424,6 +443,259 @@ var _ = Describe("CLI Service", func() {
 					Expect(mockStderr.String()).To(ContainSubstring("Configured leaf mint/setup-node to use version 1.2.3\n"))
 				})
 			})
+
+			Context("when the directory has ignored files", func() {
+				var receivedMintDir []api.MintDirectoryEntry
+
+				BeforeEach(func() {
+					var err error
+
+					mintDir := filepath.Join(tmp, ".mint")
+					err = os.MkdirAll(filepath.Join(mintDir, "fixtures"), 0o755)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(baseSpec+"tasks:\n  - key: foo\n    run: echo 'bar'\n"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, ".ci.yml.swp"), []byte("swap"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "fixtures", "data.bin"), []byte(strings.Repeat("a", 2048)), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "notes.txt"), []byte("notes"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, ".mintignore"), []byte("# editor files\n*.swp\n/fixtures/\n"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(tmp, ".gitignore"), []byte(".mint/notes.txt\n"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					runConfig.MintFilePath = ".mint/ci.yml"
+					runConfig.MintDirectory = ".mint"
+
+					receivedMintDir = nil
+					mockAPI.MockInitiateRun = func(cfg api.InitiateRunConfig) (*api.InitiateRunResult, error) {
+						receivedMintDir = cfg.MintDirectory
+						return &api.InitiateRunResult{
+							RunId:            "785ce4e8-17b9-4c8b-8869-a55e95adffe7",
+							RunURL:           "https://cloud.rwx.com/mint/rwx/runs/785ce4e8-17b9-4c8b-8869-a55e95adffe7",
+							TargetedTaskKeys: []string{},
+							DefinitionPath:   ".mint/ci.yml",
+						}, nil
+					}
+				})
+
+				JustBeforeEach(func() {
+					_, err := service.InitiateRun(runConfig)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				mintDirPaths := func() []string {
+					paths := make([]string, 0)
+					for _, entry := range receivedMintDir {
+						paths = append(paths, entry.Path)
+					}
+					return paths
+				}
+
+				It("excludes the files listed in .mint/.mintignore", func() {
+					Expect(mintDirPaths()).To(Equal([]string{".mint", ".mint/.mintignore", ".mint/ci.yml", ".mint/notes.txt"}))
+				})
+
+				It("doesn't list the files", func() {
+					Expect(mockStderr.String()).NotTo(ContainSubstring("Included"))
+					Expect(mockStderr.String()).NotTo(ContainSubstring("Excluded"))
+				})
+
+				Context("when honoring .gitignore", func() {
+					BeforeEach(func() {
+						runConfig.Gitignore = true
+					})
+
+					It("also excludes the files ignored by .gitignore", func() {
+						Expect(mintDirPaths()).To(Equal([]string{".mint", ".mint/.mintignore", ".mint/ci.yml"}))
+					})
+				})
+
+				Context("when listing files", func() {
+					BeforeEach(func() {
+						runConfig.ListFiles = true
+					})
+
+					It("lists the included and excluded files with their sizes", func() {
+						Expect(mockStderr.String()).To(ContainSubstring("Excluded .mint/.ci.yml.swp (4 B, by \"*.swp\" in .mint/.mintignore:2)\n"))
+						Expect(mockStderr.String()).To(ContainSubstring("Included .mint/.mintignore (32 B)\n"))
+						Expect(mockStderr.String()).To(ContainSubstring("Included .mint/ci.yml (76 B)\n"))
+						Expect(mockStderr.String()).To(ContainSubstring("Excluded .mint/fixtures (2.0 KiB, by \"/fixtures/\" in .mint/.mintignore:3)\n"))
+						Expect(mockStderr.String()).To(ContainSubstring("Included .mint/notes.txt (5 B)\n"))
+					})
+				})
+			})
+
+			Context("when uploading the files as blobs", func() {
+				var receivedMintDir []api.MintDirectoryEntry
+				var ciDigest string
+				var dataDigest string
+
+				BeforeEach(func() {
+					var err error
+
+					mintDir := filepath.Join(tmp, ".mint")
+					err = os.MkdirAll(filepath.Join(mintDir, "nested"), 0o755)
+					Expect(err).NotTo(HaveOccurred())
+
+					ciContents := baseSpec + "tasks:\n  - key: foo\n    run: echo 'bar'\n"
+					err = os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(ciContents), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "data.txt"), []byte("data"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "nested", "data.txt"), []byte("data"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					ciDigest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(ciContents)))
+					dataDigest = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("data")))
+
+					// Mint already has ci.yml
+					missingBlobs = func(digests []string) []string {
+						Expect(digests).To(Equal([]string{ciDigest, dataDigest}))
+						return []string{dataDigest}
+					}
+
+					runConfig.MintFilePath = ".mint/ci.yml"
+					runConfig.MintDirectory = ".mint"
+
+					receivedMintDir = nil
+					mockAPI.MockInitiateRun = func(cfg api.InitiateRunConfig) (*api.InitiateRunResult, error) {
+						receivedMintDir = cfg.MintDirectory
+						return &api.InitiateRunResult{
+							RunId:            "785ce4e8-17b9-4c8b-8869-a55e95adffe7",
+							RunURL:           "https://cloud.rwx.com/mint/rwx/runs/785ce4e8-17b9-4c8b-8869-a55e95adffe7",
+							TargetedTaskKeys: []string{},
+							DefinitionPath:   ".mint/ci.yml",
+						}, nil
+					}
+				})
+
+				It("only uploads the blobs Mint doesn't have, once", func() {
+					_, err := service.InitiateRun(runConfig)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(uploadedBlobs).To(Equal(map[string]string{dataDigest: "data"}))
+				})
+
+				It("references the files by digest", func() {
+					_, err := service.InitiateRun(runConfig)
+					Expect(err).NotTo(HaveOccurred())
+
+					digests := make(map[string]string)
+					for _, entry := range receivedMintDir {
+						digests[entry.Path] = entry.Digest
+					}
+					Expect(digests).To(Equal(map[string]string{
+						".mint":                 "",
+						".mint/ci.yml":          ciDigest,
+						".mint/data.txt":        dataDigest,
+						".mint/nested":          "",
+						".mint/nested/data.txt": dataDigest,
+					}))
+				})
+
+				Context("when uploading a blob fails", func() {
+					BeforeEach(func() {
+						mockAPI.MockUploadBlob = func(cfg api.UploadBlobConfig) error {
+							return errors.New("connection reset")
+						}
+					})
+
+					It("doesn't start the run", func() {
+						_, err := service.InitiateRun(runConfig)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("unable to upload blob %q: connection reset", dataDigest)))
+						Expect(receivedMintDir).To(BeNil())
+					})
+				})
+
+				Context("when the directory exceeds the size limit", func() {
+					BeforeEach(func() {
+						var err error
+
+						mintDir := filepath.Join(tmp, ".mint")
+						err = os.WriteFile(filepath.Join(mintDir, "large.bin"), []byte(strings.Repeat("a", 64*1024*1024)), 0o644)
+						Expect(err).NotTo(HaveOccurred())
+
+						err = os.WriteFile(filepath.Join(mintDir, "medium.bin"), []byte(strings.Repeat("a", 40*1024*1024)), 0o644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("names the largest files", func() {
+						_, err := service.InitiateRun(runConfig)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("the size of the files in .mint is 104.0 MiB, which exceeds 100.0 MiB. The largest files are:\n  .mint/large.bin (64.0 MiB)\n  .mint/medium.bin (40.0 MiB)\n  .mint/ci.yml (76 B)\n  .mint/data.txt (4 B)\n  .mint/nested/data.txt (4 B)\n\nFiles can be excluded by listing them in .mint/.mintignore"))
+						Expect(receivedMintDir).To(BeNil())
+						Expect(uploadedBlobs).To(BeEmpty())
+					})
+				})
+			})
+
+			Context("when Mint doesn't support blobs", func() {
+				var receivedMintDir []api.MintDirectoryEntry
+
+				BeforeEach(func() {
+					var err error
+
+					mintDir := filepath.Join(tmp, ".mint")
+					err = os.MkdirAll(mintDir, 0o755)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(baseSpec+"tasks:\n  - key: foo\n    run: echo 'bar'\n"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					mockAPI.MockFindMissingBlobs = func(cfg api.FindMissingBlobsConfig) (*api.FindMissingBlobsResult, error) {
+						return nil, errors.Wrap(api.ErrNotFound, "Unable to call Mint API - 404 Not Found")
+					}
+
+					runConfig.MintFilePath = ".mint/ci.yml"
+					runConfig.MintDirectory = ".mint"
+
+					receivedMintDir = nil
+					mockAPI.MockInitiateRun = func(cfg api.InitiateRunConfig) (*api.InitiateRunResult, error) {
+						receivedMintDir = cfg.MintDirectory
+						return &api.InitiateRunResult{}, nil
+					}
+				})
+
+				It("sends the contents of the files", func() {
+					_, err := service.InitiateRun(runConfig)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(receivedMintDir).To(HaveLen(2))
+					Expect(receivedMintDir[1].Digest).To(Equal(""))
+					Expect(receivedMintDir[1].FileContents).To(Equal(baseSpec + "tasks:\n  - key: foo\n    run: echo 'bar'\n"))
+					Expect(uploadedBlobs).To(BeEmpty())
+				})
+
+				Context("when the directory exceeds the size limit", func() {
+					BeforeEach(func() {
+						var err error
+
+						mintDir := filepath.Join(tmp, ".mint")
+						err = os.WriteFile(filepath.Join(mintDir, "large.bin"), []byte(strings.Repeat("a", 4*1024*1024)), 0o644)
+						Expect(err).NotTo(HaveOccurred())
+
+						err = os.WriteFile(filepath.Join(mintDir, "medium.bin"), []byte(strings.Repeat("a", 2*1024*1024)), 0o644)
+						Expect(err).NotTo(HaveOccurred())
+					})
+
+					It("names the largest files", func() {
+						_, err := service.InitiateRun(runConfig)
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("the size of the files in .mint is 6.0 MiB, which exceeds 5.0 MiB. The largest files are:\n  .mint/large.bin (4.0 MiB)\n  .mint/medium.bin (2.0 MiB)\n  .mint/ci.yml (76 B)\n\nFiles can be excluded by listing them in .mint/.mintignore"))
+						Expect(receivedMintDir).To(BeNil())
+					})
+				})
+			})
 		})
 
 		Context("with no specific mint file and no specific directory", func() {
@@ -1306,6 +1578,19 @@ AAAEC6442PQKevgYgeT0SIu9zwlnEMl6MF59ZgM+i0ByMv4eLJPqG3xnZcEQmktHj/GY2i
 		})
 	})
 
+	Describe("clearing the cache", func() {
+		It("removes the cache directory", func() {
+			cache, err := apicache.New(filepath.Join(tmp, "cache"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cache.Set("key", apicache.Entry{StoredAt: time.Now(), Body: []byte(`{}`)})).To(Succeed())
+
+			err = service.ClearCache(cli.ClearCacheConfig{Cache: cache})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cache.Get("key")).To(BeNil())
+			Expect(mockStdout.String()).To(Equal(fmt.Sprintf("Cleared the cache in %s\n", cache.Dir)))
+		})
+	})
+
 	Describe("setting secrets", func() {
 		BeforeEach(func() {
 			var err error
@@ -1925,288 +2210,2084 @@ tasks:
 				})
 			})
 		})
-	})
 
-	Describe("resolving leaves", func() {
-		Context("when no files provided", func() {
-			Context("when no yaml files found in the default directory", func() {
-				var mintDir string
+		Context("with an update policy", func() {
+			var mintDir string
+			var leafVersions []api.LeafVersionDetails
 
-				BeforeEach(func() {
-					var err error
+			BeforeEach(func() {
+				mintDir = filepath.Join(tmp, ".mint")
+				Expect(os.MkdirAll(mintDir, 0o755)).To(Succeed())
+
+				now := time.Now()
+				leafVersions = []api.LeafVersionDetails{
+					{Version: "1.2.3", PublishedAt: now.Add(-90 * 24 * time.Hour)},
+					{Version: "1.2.4", PublishedAt: now.Add(-30 * 24 * time.Hour)},
+					{Version: "1.3.0", PublishedAt: now.Add(-10 * 24 * time.Hour)},
+					{Version: "1.4.0", PublishedAt: now.Add(-1 * 24 * time.Hour)},
+					{Version: "2.0.0", PublishedAt: now.Add(-1 * time.Hour)},
+				}
 
-					mintDir = tmp
+				mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+					return &api.LeafVersionsResult{
+						LatestMajor: map[string]string{"mint/setup-node": "2.0.0", "mint/setup-ruby": "1.0.1"},
+						LatestMinor: map[string]map[string]string{"mint/setup-node": {"1": "1.4.0", "2": "2.0.0"}, "mint/setup-ruby": {"1": "1.0.1"}},
+						Versions:    map[string][]api.LeafVersionDetails{"mint/setup-node": leafVersions},
+					}, nil
+				}
 
-					err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
-					Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(`tasks:
+  - key: node
+    call: mint/setup-node 1.2.3
+  - key: ruby
+    call: mint/setup-ruby 1.0.0
+`), 0o644)).To(Succeed())
+			})
 
-					err = os.WriteFile(filepath.Join(mintDir, "bar.json"), []byte("some json"), 0o644)
-					Expect(err).NotTo(HaveOccurred())
+			updateLeaves := func(picker func(api.LeafVersionsResult, string, string) (string, error)) string {
+				err := service.UpdateLeaves(cli.UpdateLeavesConfig{
+					MintDirectory:            mintDir,
+					ReplacementVersionPicker: picker,
 				})
+				Expect(err).NotTo(HaveOccurred())
 
-				It("returns an error", func() {
-					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
-						MintDirectory:       mintDir,
-						LatestVersionPicker: cli.PickLatestMajorVersion,
-					})
+				contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+				Expect(err).NotTo(HaveOccurred())
+				return string(contents)
+			}
 
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("no files provided, and no yaml files found in directory %s", mintDir)))
-				})
-			})
+			It("skips ignored leaves", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+ignore:
+  - mint/setup-ruby
+`), 0o644)).To(Succeed())
 
-			Context("when yaml files are found in the specified directory", func() {
-				var mintDir string
+				Expect(updateLeaves(cli.PickLatestMinorVersion)).To(Equal(`tasks:
+  - key: node
+    call: mint/setup-node 1.4.0
+  - key: ruby
+    call: mint/setup-ruby 1.0.0
+`))
+				Expect(mockStderr.String()).To(ContainSubstring(`Leaf "mint/setup-ruby" is ignored by update.yml; skipping it.`))
+			})
 
-				BeforeEach(func() {
-					var err error
+			It("picks the latest version satisfying a constraint", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+leaves:
+  mint/setup-node:
+    constraint: "<1.4"
+`), 0o644)).To(Succeed())
 
-					mintDir = tmp
+				Expect(updateLeaves(cli.PickLatestMajorVersion)).To(ContainSubstring("call: mint/setup-node 1.3.0"))
+			})
 
-					err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
-					Expect(err).NotTo(HaveOccurred())
+			It("stays within the major version unless major version changes are allowed", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+leaves:
+  mint/setup-node:
+    constraint: "<3"
+`), 0o644)).To(Succeed())
 
-					err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`
-tasks:
-  - key: foo
-    call: mint/setup-node 1.2.3
-`), 0o644)
-					Expect(err).NotTo(HaveOccurred())
+				Expect(updateLeaves(cli.PickLatestMinorVersion)).To(ContainSubstring("call: mint/setup-node 1.4.0"))
+				Expect(updateLeaves(cli.PickLatestMajorVersion)).To(ContainSubstring("call: mint/setup-node 2.0.0"))
+			})
 
-					err = os.WriteFile(filepath.Join(mintDir, "baz.yaml"), []byte(`
-tasks:
-  - key: foo
-    call: mint/setup-node
-`), 0o644)
-					Expect(err).NotTo(HaveOccurred())
+			It("only picks patch versions in patch-only mode", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+leaves:
+  mint/setup-node:
+    patch-only: true
+`), 0o644)).To(Succeed())
 
-					nestedDir := filepath.Join(mintDir, "some", "nested", "dir")
-					err = os.MkdirAll(nestedDir, 0o755)
-					Expect(err).NotTo(HaveOccurred())
+				Expect(updateLeaves(cli.PickLatestMajorVersion)).To(ContainSubstring("call: mint/setup-node 1.2.4"))
+			})
 
-					err = os.WriteFile(filepath.Join(nestedDir, "tasks.yaml"), []byte(`
-tasks:
-  - key: foo
-    call: mint/setup-node
-`), 0o644)
-					Expect(err).NotTo(HaveOccurred())
-				})
+			It("only picks versions older than the minimum release age", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+minimum-release-age: 7d
+`), 0o644)).To(Succeed())
 
-				BeforeEach(func() {
-					mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
-						return &api.LeafVersionsResult{
-							LatestMajor: map[string]string{"mint/setup-node": "1.3.0"},
-						}, nil
-					}
-				})
+				Expect(updateLeaves(cli.PickLatestMajorVersion)).To(ContainSubstring("call: mint/setup-node 1.3.0"))
+				Expect(mockStderr.String()).To(ContainSubstring(`Unable to find the published versions of leaf "mint/setup-ruby"; skipping it.`))
+			})
 
-				It("uses the default directory", func() {
-					var err error
+			It("lets a leaf override the minimum release age", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+minimum-release-age: 7d
+leaves:
+  mint/setup-node:
+    minimum-release-age: 60d
+`), 0o644)).To(Succeed())
 
-					_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
-						MintDirectory:       mintDir,
-						LatestVersionPicker: cli.PickLatestMajorVersion,
-					})
-					Expect(err).NotTo(HaveOccurred())
+				Expect(updateLeaves(cli.PickLatestMajorVersion)).To(ContainSubstring("call: mint/setup-node 1.2.3"))
+			})
 
-					var contents []byte
+			It("errors on an invalid policy", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+leaves:
+  mint/setup-node:
+    constraint: "not a constraint"
+`), 0o644)).To(Succeed())
 
-					contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(ContainSubstring("mint/setup-node 1.2.3"))
+				err := service.UpdateLeaves(cli.UpdateLeavesConfig{
+					MintDirectory:            mintDir,
+					ReplacementVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(`invalid constraint "not a constraint" for leaf "mint/setup-node"`))
+			})
+			It("picks the latest version satisfying every rule", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+minimum-release-age: 20d
+leaves:
+  mint/setup-node:
+    constraint: "<1.4"
+`), 0o644)).To(Succeed())
+
+				Expect(updateLeaves(cli.PickLatestMajorVersion)).To(ContainSubstring("call: mint/setup-node 1.2.4"))
+			})
 
-					contents, err = os.ReadFile(filepath.Join(mintDir, "baz.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(ContainSubstring("mint/setup-node 1.3.0"))
+			It("skips leaves when the rules don't agree on any version", func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "update.yml"), []byte(`
+leaves:
+  mint/setup-node:
+    constraint: ">=1.3"
+    patch-only: true
+`), 0o644)).To(Succeed())
 
-					contents, err = os.ReadFile(filepath.Join(mintDir, "some", "nested", "dir", "tasks.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(ContainSubstring("mint/setup-node 1.3.0"))
-				})
+				Expect(updateLeaves(cli.PickLatestMajorVersion)).To(ContainSubstring("call: mint/setup-node 1.2.3"))
+				Expect(mockStderr.String()).To(ContainSubstring(`No version of leaf "mint/setup-node" satisfies ">=1.3"; skipping it.`))
 			})
 		})
 
-		Context("with files", func() {
-			var majorLeafVersions map[string]string
-			var minorLeafVersions map[string]map[string]string
-			var leafError error
+		Context("with version pickers", func() {
+			var versions api.LeafVersionsResult
 
 			BeforeEach(func() {
-				majorLeafVersions = make(map[string]string)
-				minorLeafVersions = make(map[string]map[string]string)
-				leafError = nil
+				now := time.Now()
+				versions = api.LeafVersionsResult{
+					LatestMajor: map[string]string{"mint/setup-node": "2.0.0"},
+					Versions: map[string][]api.LeafVersionDetails{"mint/setup-node": {
+						{Version: "1.2.3", PublishedAt: now.Add(-90 * 24 * time.Hour)},
+						{Version: "1.2.4", PublishedAt: now.Add(-30 * 24 * time.Hour)},
+						{Version: "1.3.0", PublishedAt: now.Add(-10 * 24 * time.Hour)},
+						{Version: "2.0.0", PublishedAt: now.Add(-1 * time.Hour)},
+					}},
+				}
+			})
+
+			It("picks the latest version satisfying a constraint", func() {
+				picker, err := cli.PickLatestVersionSatisfying("~1.2")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(picker(versions, "mint/setup-node", "1.2.3")).To(Equal("1.2.4"))
+
+				picker, err = cli.PickLatestVersionSatisfying(">3")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = picker(versions, "mint/setup-node", "1.2.3")
+				Expect(err).To(MatchError(`No version of leaf "mint/setup-node" satisfies ">3"; skipping it.`))
+
+				_, err = cli.PickLatestVersionSatisfying("not a constraint")
+				Expect(err).To(MatchError(ContainSubstring(`invalid constraint "not a constraint"`)))
+			})
+
+			It("picks the latest minor version of the current major version", func() {
+				versions.LatestMinor = map[string]map[string]string{"mint/setup-node": {"1": "1.3.0", "2": "2.0.0"}}
+
+				Expect(cli.PickLatestMinorVersion(versions, "mint/setup-node", "1.2.3")).To(Equal("1.3.0"))
+				Expect(cli.PickLatestMinorVersion(versions, "mint/setup-node", "1")).To(Equal("1.3.0"))
+				Expect(cli.PickLatestMinorVersion(versions, "mint/setup-node", "")).To(Equal("2.0.0"))
+
+				_, err := cli.PickLatestMinorVersion(versions, "mint/setup-node", "3.0.0")
+				Expect(err).To(MatchError(`Unable to find major version "3" for leaf "mint/setup-node"; skipping it.`))
+			})
+
+			It("picks the latest patch version", func() {
+				Expect(cli.PickLatestPatchVersion(versions, "mint/setup-node", "1.2.3")).To(Equal("1.2.4"))
+				Expect(cli.PickLatestPatchVersion(versions, "mint/setup-node", "1.3.0")).To(Equal("1.3.0"))
+				Expect(cli.PickLatestPatchVersion(versions, "mint/setup-node", "")).To(Equal("2.0.0"))
+
+				_, err := cli.PickLatestPatchVersion(versions, "mint/setup-node", "1.5.0")
+				Expect(err).To(MatchError(`Unable to find version "1.5.0" for leaf "mint/setup-node"; skipping it.`))
+			})
+
+			It("picks the latest version older than an age", func() {
+				Expect(cli.PickLatestVersionOlderThan(7*24*time.Hour)(versions, "mint/setup-node", "1.2.3")).To(Equal("1.3.0"))
+				Expect(cli.PickLatestVersionOlderThan(60*24*time.Hour)(versions, "mint/setup-node", "1.2.3")).To(Equal("1.2.3"))
+
+				_, err := cli.PickLatestVersionOlderThan(365*24*time.Hour)(versions, "mint/setup-node", "1.2.3")
+				Expect(err).To(MatchError(`No version of leaf "mint/setup-node" is older than 8760h0m0s; skipping it.`))
+			})
+		})
+
+		Context("with release notes", func() {
+			var requestedReleases []api.GetLeafReleasesConfig
+
+			BeforeEach(func() {
+				requestedReleases = nil
 
 				mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
 					return &api.LeafVersionsResult{
-						LatestMajor: majorLeafVersions,
-						LatestMinor: minorLeafVersions,
-					}, leafError
+						LatestMajor: map[string]string{"mint/install-go": "2.0.1", "mint/setup-node": "1.2.3"},
+					}, nil
+				}
+				mockAPI.MockGetLeafReleases = func(cfg api.GetLeafReleasesConfig) (*api.LeafReleasesResult, error) {
+					requestedReleases = append(requestedReleases, cfg)
+					if cfg.Leaf != "mint/install-go" {
+						return &api.LeafReleasesResult{}, nil
+					}
+					return &api.LeafReleasesResult{
+						Releases: []api.LeafRelease{
+							{Version: "2.0.1", Notes: "Fixes caching of the module cache"},
+							{Version: "2.0.0", Notes: "Installs Go from the official tarballs\nRequires a go.mod file", RemovedParameters: []string{"go-version-file"}, Deprecations: []string{"The `cache` parameter will be removed in 3.0.0"}},
+							{Version: "1.5.0", AddedParameters: []string{"go-version"}},
+						},
+					}, nil
 				}
-			})
 
-			Context("when the leaf versions cannot be retrieved", func() {
-				BeforeEach(func() {
-					leafError = errors.New("cannot get leaf versions")
+				Expect(os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(`
+tasks:
+  - key: go
+    call: mint/install-go 1.4.0
+  - key: node
+    call: mint/setup-node
+`), 0o644)).To(Succeed())
+			})
 
-					err := os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(""), 0o644)
-					Expect(err).NotTo(HaveOccurred())
+			It("summarizes the skipped releases of each updated leaf", func() {
+				err := service.UpdateLeaves(cli.UpdateLeavesConfig{
+					Files:                    []string{filepath.Join(tmp, "foo.yaml")},
+					ReplacementVersionPicker: cli.PickLatestMajorVersion,
 				})
+				Expect(err).NotTo(HaveOccurred())
 
-				It("returns an error", func() {
-					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
-						MintDirectory:       tmp,
-						LatestVersionPicker: cli.PickLatestMajorVersion,
-					})
+				Expect(requestedReleases).To(Equal([]api.GetLeafReleasesConfig{
+					{Leaf: "mint/install-go", FromVersion: "1.4.0", ToVersion: "2.0.1"},
+				}))
+				Expect(mockStdout.String()).To(HaveSuffix(`
+Release notes:
+
+mint/install-go 1.4.0 → 2.0.1 (major version change, review for breaking changes)
+  2.0.1
+    Fixes caching of the module cache
+  2.0.0 [major, parameters changed, deprecations]
+    Installs Go from the official tarballs
+    Requires a go.mod file
+    Removed parameters: go-version-file
+    Deprecated: The ` + "`cache`" + ` parameter will be removed in 3.0.0
+  1.5.0 [parameters changed]
+    Added parameters: go-version
+`))
+			})
 
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring("cannot get leaf versions"))
+			It("still updates leaves when release notes are unavailable", func() {
+				mockAPI.MockGetLeafReleases = nil
+
+				err := service.UpdateLeaves(cli.UpdateLeavesConfig{
+					Files:                    []string{filepath.Join(tmp, "foo.yaml")},
+					ReplacementVersionPicker: cli.PickLatestMajorVersion,
 				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStdout.String()).To(ContainSubstring("mint/install-go 1.4.0 → 2.0.1"))
+				Expect(mockStdout.String()).NotTo(ContainSubstring("Release notes:"))
+				Expect(mockStderr.String()).To(ContainSubstring("Unable to fetch release notes for mint/install-go"))
 			})
+		})
+	})
 
-			Context("when all leaves have a version", func() {
-				BeforeEach(func() {
-					majorLeafVersions["mint/setup-node"] = "1.3.0"
+	Describe("adding a leaf", func() {
+		var mintDir string
+		var requestedLeaf api.GetLeafConfig
 
-					err := os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(`
+		BeforeEach(func() {
+			mintDir = filepath.Join(tmp, ".mint")
+			Expect(os.MkdirAll(mintDir, 0o755)).To(Succeed())
+
+			defaultCache := "true"
+			mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+				return &api.LeafVersionsResult{LatestMajor: map[string]string{"mint/install-node": "1.2.3"}}, nil
+			}
+			mockAPI.MockGetLeaf = func(cfg api.GetLeafConfig) (*api.LeafDetails, error) {
+				requestedLeaf = cfg
+				return &api.LeafDetails{
+					Name:    "mint/install-node",
+					Version: cfg.Version,
+					Parameters: []api.LeafParameter{
+						{Name: "node-version", Required: true},
+						{Name: "cache", Default: &defaultCache},
+					},
+				}, nil
+			}
+
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(`# CI
 tasks:
-  - key: foo
-    call: mint/setup-node 1.2.3
-`), 0o644)
-					Expect(err).NotTo(HaveOccurred())
-				})
+  - key: checkout
+    call: mint/git-clone 1.0.0 # pinned
 
-				It("does not change the file content", func() {
-					var err error
+  - key: test
+    run: make test
 
-					_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
-						MintDirectory:       tmp,
-						LatestVersionPicker: cli.PickLatestMajorVersion,
-					})
-					Expect(err).NotTo(HaveOccurred())
+# Not a task
+concurrency-pools:
+  - id: ci
+`), 0o644)).To(Succeed())
+		})
 
-					contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(Equal(`
+		It("appends a task pinned to the latest version", func() {
+			result, err := service.AddLeaf(cli.AddLeafConfig{
+				MintDirectory: mintDir,
+				Leaf:          "mint/install-node",
+				Key:           "node",
+				Params:        []string{"node-version=20", "cache=false"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Call).To(Equal("mint/install-node 1.2.3"))
+			Expect(requestedLeaf).To(Equal(api.GetLeafConfig{Name: "mint/install-node", Version: "1.2.3"}))
+			Expect(mockStdout.String()).To(ContainSubstring(`Added task "node" calling mint/install-node 1.2.3 to .mint/ci.yml`))
+
+			contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`# CI
 tasks:
-  - key: foo
+  - key: checkout
+    call: mint/git-clone 1.0.0 # pinned
+
+  - key: test
+    run: make test
+  - key: node
+    call: mint/install-node 1.2.3
+    with:
+      node-version: "20"
+      cache: "false"
+
+# Not a task
+concurrency-pools:
+  - id: ci
+`))
+		})
+
+		It("defaults the key to the name of the leaf and uses the given version", func() {
+			_, err := service.AddLeaf(cli.AddLeafConfig{
+				File:    filepath.Join(mintDir, "ci.yml"),
+				Leaf:    "mint/install-node",
+				Version: "1.0.0",
+				Params:  []string{"node-version=20"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requestedLeaf.Version).To(Equal("1.0.0"))
+
+			contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("  - key: install-node\n    call: mint/install-node 1.0.0\n"))
+		})
+
+		It("errors on unknown parameters", func() {
+			_, err := service.AddLeaf(cli.AddLeafConfig{
+				MintDirectory: mintDir,
+				Leaf:          "mint/install-node",
+				Params:        []string{"node-version=20", "version=20"},
+			})
+			Expect(err).To(MatchError(`Leaf mint/install-node 1.2.3 does not have a parameter "version". Available parameters: node-version, cache.`))
+		})
+
+		It("errors on missing required parameters", func() {
+			_, err := service.AddLeaf(cli.AddLeafConfig{
+				MintDirectory: mintDir,
+				Leaf:          "mint/install-node",
+			})
+			Expect(err).To(MatchError("Missing required parameters for leaf mint/install-node 1.2.3: node-version. Pass them with --param name=value."))
+		})
+
+		It("errors when the key is taken", func() {
+			_, err := service.AddLeaf(cli.AddLeafConfig{
+				MintDirectory: mintDir,
+				Leaf:          "mint/install-node",
+				Key:           "test",
+				Params:        []string{"node-version=20"},
+			})
+			Expect(err).To(MatchError(`A task with the key "test" already exists in .mint/ci.yml. Choose another key with --key.`))
+		})
+
+		It("appends to the first document of a multi-document file with unindented tasks", func() {
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(`tasks:
+- key: checkout
+  call: mint/git-clone 1.0.0
+---
+- key: test
+  run: make test
+`), 0o644)).To(Succeed())
+
+			_, err := service.AddLeaf(cli.AddLeafConfig{
+				File:   filepath.Join(mintDir, "ci.yml"),
+				Leaf:   "mint/install-node",
+				Key:    "node",
+				Params: []string{"node-version=20"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`tasks:
+- key: checkout
+  call: mint/git-clone 1.0.0
+- key: node
+  call: mint/install-node 1.2.3
+  with:
+    node-version: "20"
+---
+- key: test
+  run: make test
+`))
+		})
+
+		It("errors when the run definition is ambiguous", func() {
+			Expect(os.WriteFile(filepath.Join(mintDir, "other.yml"), []byte("tasks:\n  - key: a\n    run: echo\n"), 0o644)).To(Succeed())
+
+			_, err := service.AddLeaf(cli.AddLeafConfig{
+				MintDirectory: mintDir,
+				Leaf:          "mint/install-node",
+				Params:        []string{"node-version=20"},
+			})
+			Expect(err).To(MatchError("found multiple run definitions in .mint; specify one with --file"))
+		})
+	})
+
+	Describe("searching leaves", func() {
+		var requestedQuery string
+
+		BeforeEach(func() {
+			mockAPI.MockSearchLeaves = func(cfg api.SearchLeavesConfig) (*api.SearchLeavesResult, error) {
+				requestedQuery = cfg.Query
+				if cfg.Query == "python" {
+					return &api.SearchLeavesResult{}, nil
+				}
+				return &api.SearchLeavesResult{
+					Leaves: []api.LeafSummary{
+						{Name: "mint/install-node", LatestVersion: "1.2.3", Description: "Install Node.js"},
+						{Name: "mint/setup-node-modules", LatestVersion: "10.0.0", Description: "Install node modules"},
+					},
+				}, nil
+			}
+		})
+
+		It("lists the matching leaves", func() {
+			_, err := service.SearchLeaves(cli.SearchLeavesConfig{Query: "node"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requestedQuery).To(Equal("node"))
+			Expect(mockStdout.String()).To(Equal(`NAME                     LATEST  DESCRIPTION
+mint/install-node        1.2.3   Install Node.js
+mint/setup-node-modules  10.0.0  Install node modules
+`))
+		})
+
+		It("outputs JSON", func() {
+			_, err := service.SearchLeaves(cli.SearchLeavesConfig{Query: "node", Json: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(ContainSubstring(`"name": "mint/install-node"`))
+			Expect(mockStdout.String()).To(ContainSubstring(`"latest_version": "1.2.3"`))
+		})
+
+		It("indicates when nothing matches", func() {
+			_, err := service.SearchLeaves(cli.SearchLeavesConfig{Query: "python"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(Equal("No leaves match \"python\".\n"))
+		})
+	})
+
+	Describe("showing a leaf", func() {
+		var requestedLeaf api.GetLeafConfig
+
+		BeforeEach(func() {
+			defaultCache := "true"
+			mockAPI.MockGetLeaf = func(cfg api.GetLeafConfig) (*api.LeafDetails, error) {
+				requestedLeaf = cfg
+				if cfg.Name != "mint/install-node" {
+					return nil, errors.Wrapf(errors.ErrNotFound, "leaf %q", cfg.Name)
+				}
+				return &api.LeafDetails{
+					Name:        "mint/install-node",
+					Description: "Install Node.js",
+					Version:     "1.2.3",
+					Versions:    []string{"1.2.3", "1.2.2", "1.0.0"},
+					Parameters: []api.LeafParameter{
+						{Name: "node-version", Required: true, Description: "The version of Node.js to install"},
+						{Name: "cache", Default: &defaultCache, Description: "Whether to cache downloads"},
+					},
+					Outputs: []api.LeafOutput{
+						{Name: "node-path", Description: "The path Node.js was installed to"},
+					},
+					Example: "- key: node\n  call: mint/install-node 1.2.3\n  with:\n    node-version: 20.11.0",
+				}, nil
+			}
+			mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+				return &api.LeafVersionsResult{LatestMajor: map[string]string{"mint/install-node": "1.2.3"}}, nil
+			}
+		})
+
+		It("outputs the details of the latest version and a task snippet", func() {
+			_, err := service.ShowLeaf(cli.ShowLeafConfig{Leaf: "mint/install-node"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requestedLeaf).To(Equal(api.GetLeafConfig{Name: "mint/install-node"}))
+			Expect(mockStdout.String()).To(Equal(`mint/install-node 1.2.3
+Install Node.js
+
+Versions: 1.2.3, 1.2.2, 1.0.0
+
+Parameters:
+  node-version (required)
+    The version of Node.js to install
+  cache (default: "true")
+    Whether to cache downloads
+
+Outputs:
+  node-path
+    The path Node.js was installed to
+
+Example:
+  - key: node
+    call: mint/install-node 1.2.3
+    with:
+      node-version: 20.11.0
+
+Usage:
+- key: install-node
+  call: mint/install-node 1.2.3
+  with:
+    node-version: ""
+`))
+		})
+
+		It("pins the snippet to the requested version", func() {
+			_, err := service.ShowLeaf(cli.ShowLeafConfig{Leaf: "mint/install-node", Version: "1.0.0"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requestedLeaf).To(Equal(api.GetLeafConfig{Name: "mint/install-node", Version: "1.0.0"}))
+			Expect(mockStdout.String()).To(ContainSubstring("  call: mint/install-node 1.0.0\n"))
+		})
+
+		It("errors when the leaf does not exist", func() {
+			_, err := service.ShowLeaf(cli.ShowLeafConfig{Leaf: "mint/missing"})
+			Expect(err).To(MatchError(`Unable to find the leaf "mint/missing".`))
+		})
+	})
+
+	Describe("locking leaves", func() {
+		var mintDir string
+		var digests map[string]string
+		var requestedLeaves []api.LeafReference
+
+		BeforeEach(func() {
+			mintDir = filepath.Join(tmp, ".mint")
+			Expect(os.MkdirAll(mintDir, 0o755)).To(Succeed())
+
+			digests = map[string]string{
+				"mint/setup-node 1.2.3": "sha256:node",
+				"mint/setup-ruby 1.0.1": "sha256:ruby",
+			}
+			requestedLeaves = nil
+
+			mockAPI.MockResolveLeafDigests = func(cfg api.ResolveLeafDigestsConfig) (*api.ResolveLeafDigestsResult, error) {
+				requestedLeaves = append(requestedLeaves, cfg.Leaves...)
+				result := &api.ResolveLeafDigestsResult{}
+				for _, leaf := range cfg.Leaves {
+					digest, ok := digests[leaf.Name+" "+leaf.Version]
+					if !ok {
+						continue
+					}
+					result.Leaves = append(result.Leaves, api.LeafDigest{Name: leaf.Name, Version: leaf.Version, Digest: digest})
+				}
+				return result, nil
+			}
+
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(`
+tasks:
+  - key: node
     call: mint/setup-node 1.2.3
+  - key: ruby
+    call: mint/setup-ruby 1.0.1
+  - key: go
+    call: mint/setup-go
+  - key: embed
+    call: ${{ run.mint-dir }}/embed.yml
+`), 0o644)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(mintDir, "other.yml"), []byte(`
+tasks:
+  - key: node
+    call: mint/setup-node 1.2.3
+`), 0o644)).To(Succeed())
+
+			Expect(os.WriteFile(filepath.Join(mintDir, "embed.yml"), []byte(`
+tasks:
+  - key: embedded
+    run: echo embedded
+`), 0o644)).To(Succeed())
+		})
+
+		It("writes a lockfile with the digest of every versioned leaf", func() {
+			result, err := service.LockLeaves(cli.LockLeavesConfig{MintDirectory: mintDir})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.LockedLeaves).To(Equal(digests))
+			Expect(requestedLeaves).To(ConsistOf(
+				api.LeafReference{Name: "mint/setup-node", Version: "1.2.3"},
+				api.LeafReference{Name: "mint/setup-ruby", Version: "1.0.1"},
+			))
+
+			contents, err := os.ReadFile(filepath.Join(mintDir, "leaves.lock"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal("# This file is generated by `mint leaves lock`. Do not edit it manually.\n" + `version: 1
+leaves:
+  mint/setup-node 1.2.3: sha256:node
+  mint/setup-ruby 1.0.1: sha256:ruby
 `))
+		})
+
+		It("warns about leaves without a version", func() {
+			_, err := service.LockLeaves(cli.LockLeavesConfig{MintDirectory: mintDir})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStderr.String()).To(ContainSubstring("Leaf mint/setup-go in .mint/ci.yml does not specify a version"))
+		})
+
+		It("errors when the registry does not know a leaf version", func() {
+			delete(digests, "mint/setup-ruby 1.0.1")
+
+			_, err := service.LockLeaves(cli.LockLeavesConfig{MintDirectory: mintDir})
+			Expect(err).To(MatchError("unable to find a digest for leaf mint/setup-ruby 1.0.1"))
+		})
+
+		Context("when a lockfile exists", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "leaves.lock"), []byte(`version: 1
+leaves:
+  mint/setup-node 1.2.3: sha256:node
+  mint/setup-ruby 1.0.1: sha256:ruby
+`), 0o644)).To(Succeed())
+			})
+
+			Context("and leaves are updated", func() {
+				BeforeEach(func() {
+					digests["mint/setup-node 1.3.0"] = "sha256:newnode"
+					mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+						return &api.LeafVersionsResult{
+							LatestMajor: map[string]string{"mint/setup-node": "1.3.0", "mint/setup-ruby": "1.0.1", "mint/setup-go": "1.0.0"},
+						}, nil
+					}
+					digests["mint/setup-go 1.0.0"] = "sha256:go"
 				})
 
-				It("indicates no leaves were resolved", func() {
-					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
-						MintDirectory:       tmp,
-						LatestVersionPicker: cli.PickLatestMajorVersion,
+				It("refreshes the lockfile", func() {
+					err := service.UpdateLeaves(cli.UpdateLeavesConfig{
+						MintDirectory:            mintDir,
+						ReplacementVersionPicker: cli.PickLatestMajorVersion,
 					})
+					Expect(err).NotTo(HaveOccurred())
 
+					contents, err := os.ReadFile(filepath.Join(mintDir, "leaves.lock"))
 					Expect(err).NotTo(HaveOccurred())
-					Expect(mockStdout.String()).To(ContainSubstring("No leaves to resolve."))
+					Expect(string(contents)).To(ContainSubstring(`leaves:
+  mint/setup-go 1.0.0: sha256:go
+  mint/setup-node 1.3.0: sha256:newnode
+  mint/setup-ruby 1.0.1: sha256:ruby
+`))
+					Expect(mockStdout.String()).To(ContainSubstring("Updated .mint/leaves.lock"))
 				})
 			})
 
-			Context("when there are leaves to resolve across multiple files", func() {
-				var originalFooContents string
-				var originalBarContents string
-
+			Context("when linting", func() {
 				BeforeEach(func() {
-					majorLeafVersions["mint/setup-node"] = "1.2.3"
-					majorLeafVersions["mint/setup-ruby"] = "1.0.1"
-					majorLeafVersions["mint/setup-go"] = "1.3.5"
-
-					var err error
+					Expect(os.Chdir(tmp)).To(Succeed())
+					mockAPI.MockLint = func(cfg api.LintConfig) (*api.LintResult, error) {
+						return &api.LintResult{}, nil
+					}
+				})
 
-					originalFooContents = `
-tasks:
-  - key: foo
-    call: mint/setup-node
-  - key: bar
-    call: mint/setup-ruby 0.0.1
-  - key: baz
-    call: mint/setup-go
-`
-					err = os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(originalFooContents), 0o644)
+				It("reports no problems when the digests match", func() {
+					result, err := service.Lint(cli.LintConfig{OutputFormat: cli.LintOutputNone})
 					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Problems).To(BeEmpty())
+				})
 
-					originalBarContents = `
-tasks:
-  - key: foo
-    call: mint/setup-ruby
-`
-					err = os.WriteFile(filepath.Join(tmp, "bar.yaml"), []byte(originalBarContents), 0o644)
+				It("reports leaves whose digest changed in the registry", func() {
+					digests["mint/setup-node 1.2.3"] = "sha256:tampered"
+
+					result, err := service.Lint(cli.LintConfig{OutputFormat: cli.LintOutputOneLine})
 					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Problems).To(HaveLen(2))
+					Expect(mockStdout.String()).To(ContainSubstring("error   .mint/ci.yml:4:11 - Leaf mint/setup-node 1.2.3 has digest sha256:tampered, but leaves.lock expects sha256:node"))
+					Expect(mockStdout.String()).To(ContainSubstring("error   .mint/other.yml:4:11 - Leaf mint/setup-node 1.2.3 has digest sha256:tampered, but leaves.lock expects sha256:node"))
 				})
 
-				It("updates all files", func() {
-					var err error
+				It("skips verifying the digests while offline", func() {
+					mockAPI.MockResolveLeafDigests = func(cfg api.ResolveLeafDigestsConfig) (*api.ResolveLeafDigestsResult, error) {
+						return nil, errors.Wrap(api.ErrOffline, "HTTP request failed")
+					}
 
-					_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
-						MintDirectory:       tmp,
-						LatestVersionPicker: cli.PickLatestMajorVersion,
-					})
+					result, err := service.Lint(cli.LintConfig{OutputFormat: cli.LintOutputNone})
 					Expect(err).NotTo(HaveOccurred())
+					Expect(result.Problems).To(BeEmpty())
+					Expect(mockStderr.String()).To(ContainSubstring("Warning: the digests of leaves can't be verified against leaves.lock while offline; skipping it."))
+				})
 
-					var contents []byte
+				It("reports leaves missing from the lockfile", func() {
+					Expect(os.WriteFile(filepath.Join(mintDir, "other.yml"), []byte(`
+tasks:
+  - key: node
+    call: mint/setup-node 1.2.4
+`), 0o644)).To(Succeed())
 
-					contents, err = os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+					result, err := service.Lint(cli.LintConfig{OutputFormat: cli.LintOutputOneLine})
 					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(Equal(`tasks:
+					Expect(result.Problems).To(HaveLen(1))
+					Expect(mockStdout.String()).To(ContainSubstring("error   .mint/other.yml:4:11 - Leaf mint/setup-node 1.2.4 is not in leaves.lock"))
+				})
+			})
+
+			Context("when initiating a run", func() {
+				var runInitiated bool
+
+				BeforeEach(func() {
+					runInitiated = false
+					mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
+						return api.ResolveBaseLayerResult{Os: "ubuntu 24.04", Tag: "1.0", Arch: "x86_64"}, nil
+					}
+					mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+						return &api.LeafVersionsResult{LatestMajor: map[string]string{}}, nil
+					}
+					mockAPI.MockFindMissingBlobs = func(cfg api.FindMissingBlobsConfig) (*api.FindMissingBlobsResult, error) {
+						return &api.FindMissingBlobsResult{}, nil
+					}
+					mockAPI.MockInitiateRun = func(cfg api.InitiateRunConfig) (*api.InitiateRunResult, error) {
+						runInitiated = true
+						return &api.InitiateRunResult{}, nil
+					}
+				})
+
+				It("starts the run when the digests match", func() {
+					_, err := service.InitiateRun(cli.InitiateRunConfig{
+						MintFilePath:  filepath.Join(mintDir, "other.yml"),
+						MintDirectory: mintDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(runInitiated).To(BeTrue())
+				})
+
+				It("refuses to start the run when a digest changed", func() {
+					digests["mint/setup-node 1.2.3"] = "sha256:tampered"
+
+					_, err := service.InitiateRun(cli.InitiateRunConfig{
+						MintFilePath:  filepath.Join(mintDir, "other.yml"),
+						MintDirectory: mintDir,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("unable to verify leaves against leaves.lock"))
+					Expect(err.Error()).To(ContainSubstring("Leaf mint/setup-node 1.2.3 has digest sha256:tampered"))
+					Expect(runInitiated).To(BeFalse())
+				})
+
+				It("refuses to start the run when a digest changed in another file of the .mint directory", func() {
+					digests["mint/setup-ruby 1.0.1"] = "sha256:tampered"
+
+					_, err := service.InitiateRun(cli.InitiateRunConfig{
+						MintFilePath:  filepath.Join(mintDir, "other.yml"),
+						MintDirectory: mintDir,
+					})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring(".mint/ci.yml:6:11 - Leaf mint/setup-ruby 1.0.1 has digest sha256:tampered"))
+					Expect(runInitiated).To(BeFalse())
+				})
+
+				It("locks the leaves it resolves", func() {
+					digests["mint/setup-go 1.0.0"] = "sha256:go"
+					mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+						return &api.LeafVersionsResult{LatestMajor: map[string]string{"mint/setup-go": "1.0.0"}}, nil
+					}
+					Expect(os.WriteFile(filepath.Join(mintDir, "other.yml"), []byte(`
+tasks:
+  - key: go
+    call: mint/setup-go
+`), 0o644)).To(Succeed())
+
+					_, err := service.InitiateRun(cli.InitiateRunConfig{
+						MintFilePath:  filepath.Join(mintDir, "other.yml"),
+						MintDirectory: mintDir,
+					})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(runInitiated).To(BeTrue())
+					Expect(mockStderr.String()).To(ContainSubstring("Updated .mint/leaves.lock"))
+
+					contents, err := os.ReadFile(filepath.Join(mintDir, "leaves.lock"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(ContainSubstring("mint/setup-go 1.0.0: sha256:go"))
+				})
+			})
+		})
+	})
+
+	Describe("resolving leaves", func() {
+		Context("when no files provided", func() {
+			Context("when no yaml files found in the default directory", func() {
+				var mintDir string
+
+				BeforeEach(func() {
+					var err error
+
+					mintDir = tmp
+
+					err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "bar.json"), []byte("some json"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns an error", func() {
+					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+						MintDirectory:       mintDir,
+						LatestVersionPicker: cli.PickLatestMajorVersion,
+					})
+
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("no files provided, and no yaml files found in directory %s", mintDir)))
+				})
+			})
+
+			Context("when yaml files are found in the specified directory", func() {
+				var mintDir string
+
+				BeforeEach(func() {
+					var err error
+
+					mintDir = tmp
+
+					err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`
+tasks:
+  - key: foo
+    call: mint/setup-node 1.2.3
+`), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(mintDir, "baz.yaml"), []byte(`
+tasks:
+  - key: foo
+    call: mint/setup-node
+`), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					nestedDir := filepath.Join(mintDir, "some", "nested", "dir")
+					err = os.MkdirAll(nestedDir, 0o755)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = os.WriteFile(filepath.Join(nestedDir, "tasks.yaml"), []byte(`
+tasks:
+  - key: foo
+    call: mint/setup-node
+`), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				BeforeEach(func() {
+					mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+						return &api.LeafVersionsResult{
+							LatestMajor: map[string]string{"mint/setup-node": "1.3.0"},
+						}, nil
+					}
+				})
+
+				It("uses the default directory", func() {
+					var err error
+
+					_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
+						MintDirectory:       mintDir,
+						LatestVersionPicker: cli.PickLatestMajorVersion,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					var contents []byte
+
+					contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(ContainSubstring("mint/setup-node 1.2.3"))
+
+					contents, err = os.ReadFile(filepath.Join(mintDir, "baz.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(ContainSubstring("mint/setup-node 1.3.0"))
+
+					contents, err = os.ReadFile(filepath.Join(mintDir, "some", "nested", "dir", "tasks.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(ContainSubstring("mint/setup-node 1.3.0"))
+				})
+			})
+		})
+
+		Context("with files", func() {
+			var majorLeafVersions map[string]string
+			var minorLeafVersions map[string]map[string]string
+			var leafError error
+
+			BeforeEach(func() {
+				majorLeafVersions = make(map[string]string)
+				minorLeafVersions = make(map[string]map[string]string)
+				leafError = nil
+
+				mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+					return &api.LeafVersionsResult{
+						LatestMajor: majorLeafVersions,
+						LatestMinor: minorLeafVersions,
+					}, leafError
+				}
+			})
+
+			Context("when the leaf versions cannot be retrieved", func() {
+				BeforeEach(func() {
+					leafError = errors.New("cannot get leaf versions")
+
+					err := os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(""), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("returns an error", func() {
+					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+						MintDirectory:       tmp,
+						LatestVersionPicker: cli.PickLatestMajorVersion,
+					})
+
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("cannot get leaf versions"))
+				})
+			})
+
+			Context("when all leaves have a version", func() {
+				BeforeEach(func() {
+					majorLeafVersions["mint/setup-node"] = "1.3.0"
+
+					err := os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(`
+tasks:
+  - key: foo
+    call: mint/setup-node 1.2.3
+`), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("does not change the file content", func() {
+					var err error
+
+					_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
+						MintDirectory:       tmp,
+						LatestVersionPicker: cli.PickLatestMajorVersion,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(Equal(`
+tasks:
   - key: foo
     call: mint/setup-node 1.2.3
+`))
+				})
+
+				It("indicates no leaves were resolved", func() {
+					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+						MintDirectory:       tmp,
+						LatestVersionPicker: cli.PickLatestMajorVersion,
+					})
+
+					Expect(err).NotTo(HaveOccurred())
+					Expect(mockStdout.String()).To(ContainSubstring("No leaves to resolve."))
+				})
+			})
+
+			Context("when there are leaves to resolve across multiple files", func() {
+				var originalFooContents string
+				var originalBarContents string
+
+				BeforeEach(func() {
+					majorLeafVersions["mint/setup-node"] = "1.2.3"
+					majorLeafVersions["mint/setup-ruby"] = "1.0.1"
+					majorLeafVersions["mint/setup-go"] = "1.3.5"
+
+					var err error
+
+					originalFooContents = `
+tasks:
+  - key: foo
+    call: mint/setup-node
   - key: bar
     call: mint/setup-ruby 0.0.1
   - key: baz
-    call: mint/setup-go 1.3.5
-`))
+    call: mint/setup-go
+`
+					err = os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(originalFooContents), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+
+					originalBarContents = `
+tasks:
+  - key: foo
+    call: mint/setup-ruby
+`
+					err = os.WriteFile(filepath.Join(tmp, "bar.yaml"), []byte(originalBarContents), 0o644)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("updates all files", func() {
+					var err error
+
+					_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
+						MintDirectory:       tmp,
+						LatestVersionPicker: cli.PickLatestMajorVersion,
+					})
+					Expect(err).NotTo(HaveOccurred())
+
+					var contents []byte
+
+					contents, err = os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(Equal(`tasks:
+  - key: foo
+    call: mint/setup-node 1.2.3
+  - key: bar
+    call: mint/setup-ruby 0.0.1
+  - key: baz
+    call: mint/setup-go 1.3.5
+`))
+
+					contents, err = os.ReadFile(filepath.Join(tmp, "bar.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(Equal(`tasks:
+  - key: foo
+    call: mint/setup-ruby 1.0.1
+`))
+				})
+
+				It("indicates leaves were resolved", func() {
+					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+						MintDirectory:       tmp,
+						LatestVersionPicker: cli.PickLatestMajorVersion,
+					})
+
+					Expect(err).NotTo(HaveOccurred())
+					Expect(mockStdout.String()).To(ContainSubstring("Resolved the following leaves:"))
+					Expect(mockStdout.String()).To(ContainSubstring("mint/setup-go → 1.3.5"))
+					Expect(mockStdout.String()).To(ContainSubstring("mint/setup-node → 1.2.3"))
+					Expect(mockStdout.String()).To(ContainSubstring("mint/setup-ruby → 1.0.1"))
+				})
+
+				Context("when a single file is targeted", func() {
+					It("resolves only the targeted file", func() {
+						var err error
+
+						_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
+							MintDirectory:       tmp,
+							Files:               []string{filepath.Join(tmp, "bar.yaml")},
+							LatestVersionPicker: cli.PickLatestMajorVersion,
+						})
+						Expect(err).NotTo(HaveOccurred())
+
+						contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(contents)).To(Equal(originalFooContents))
+
+						contents, err = os.ReadFile(filepath.Join(tmp, "bar.yaml"))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(contents)).To(Equal(`tasks:
+  - key: foo
+    call: mint/setup-ruby 1.0.1
+`))
+					})
+				})
+			})
+		})
+
+		Context("with organization and git leaves", func() {
+			var requestedVersions []string
+			var requestedRefs []api.ResolveLeafGitRefConfig
+
+			BeforeEach(func() {
+				requestedVersions = nil
+				requestedRefs = nil
+
+				mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+					return &api.LeafVersionsResult{
+						LatestMajor: map[string]string{"mint/setup-node": "1.3.0"},
+					}, nil
+				}
+				mockAPI.MockResolveLeafVersions = func(cfg api.ResolveLeafVersionsConfig) (*api.LeafVersionsResult, error) {
+					requestedVersions = append(requestedVersions, cfg.Leaves...)
+					return &api.LeafVersionsResult{
+						LatestMajor: map[string]string{"My-Org/deploy_app": "2.0.0"},
+					}, nil
+				}
+				mockAPI.MockResolveLeafGitRef = func(cfg api.ResolveLeafGitRefConfig) (*api.ResolveLeafGitRefResult, error) {
+					requestedRefs = append(requestedRefs, cfg)
+					if cfg.Ref == "missing" {
+						return nil, errors.New("ref not found")
+					}
+					return &api.ResolveLeafGitRefResult{Sha: "0123456789abcdef0123456789abcdef01234567"}, nil
+				}
+
+				Expect(os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(`
+tasks:
+  - key: node
+    call: mint/setup-node
+  - key: deploy
+    call: My-Org/deploy_app
+  - key: internal
+    call: git+https://github.com/my-org/internal-leaf.git@main
+  - key: default-branch
+    call: git+ssh://git@github.com/my-org/other-leaf.git
+  - key: pinned
+    call: git+https://github.com/my-org/pinned-leaf.git@89abcdef0123456789abcdef0123456789abcdef
+  - key: missing
+    call: git+https://github.com/my-org/internal-leaf.git@missing
+`), 0o644)).To(Succeed())
+			})
+
+			It("resolves organization leaves and pins git refs to commits", func() {
+				_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+					Files:               []string{filepath.Join(tmp, "foo.yaml")},
+					LatestVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requestedVersions).To(Equal([]string{"My-Org/deploy_app"}))
+				Expect(requestedRefs).To(Equal([]api.ResolveLeafGitRefConfig{
+					{Repository: "https://github.com/my-org/internal-leaf.git", Ref: "main"},
+					{Repository: "ssh://git@github.com/my-org/other-leaf.git"},
+					{Repository: "https://github.com/my-org/internal-leaf.git", Ref: "missing"},
+				}))
+
+				contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`tasks:
+  - key: node
+    call: mint/setup-node 1.3.0
+  - key: deploy
+    call: My-Org/deploy_app 2.0.0
+  - key: internal
+    call: git+https://github.com/my-org/internal-leaf.git@0123456789abcdef0123456789abcdef01234567 # ref: main
+  - key: default-branch
+    call: git+ssh://git@github.com/my-org/other-leaf.git@0123456789abcdef0123456789abcdef01234567 # ref: HEAD
+  - key: pinned
+    call: git+https://github.com/my-org/pinned-leaf.git@89abcdef0123456789abcdef0123456789abcdef
+  - key: missing
+    call: git+https://github.com/my-org/internal-leaf.git@missing
+`))
+				Expect(mockStderr.String()).To(ContainSubstring(`Unable to resolve the leaf "git+https://github.com/my-org/internal-leaf.git@missing": ref not found; skipping it.`))
+			})
+
+			It("updates pinned git leaves from the ref they were pinned from", func() {
+				_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+					Files:               []string{filepath.Join(tmp, "foo.yaml")},
+					LatestVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				requestedRefs = nil
+				mockAPI.MockResolveLeafGitRef = func(cfg api.ResolveLeafGitRefConfig) (*api.ResolveLeafGitRefResult, error) {
+					requestedRefs = append(requestedRefs, cfg)
+					if cfg.Ref == "missing" {
+						return nil, errors.New("ref not found")
+					}
+					return &api.ResolveLeafGitRefResult{Sha: "fedcba9876543210fedcba9876543210fedcba98"}, nil
+				}
+
+				err = service.UpdateLeaves(cli.UpdateLeavesConfig{
+					Files:                    []string{filepath.Join(tmp, "foo.yaml")},
+					ReplacementVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requestedRefs).To(Equal([]api.ResolveLeafGitRefConfig{
+					{Repository: "https://github.com/my-org/internal-leaf.git", Ref: "main"},
+					{Repository: "ssh://git@github.com/my-org/other-leaf.git"},
+					{Repository: "https://github.com/my-org/internal-leaf.git", Ref: "missing"},
+				}))
+
+				contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(ContainSubstring(`
+  - key: internal
+    call: git+https://github.com/my-org/internal-leaf.git@fedcba9876543210fedcba9876543210fedcba98 # ref: main
+  - key: default-branch
+    call: git+ssh://git@github.com/my-org/other-leaf.git@fedcba9876543210fedcba9876543210fedcba98 # ref: HEAD
+  - key: pinned
+    call: git+https://github.com/my-org/pinned-leaf.git@89abcdef0123456789abcdef0123456789abcdef
+`))
+			})
+
+			It("doesn't pin git leaves when initiating a run", func() {
+				mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
+					return api.ResolveBaseLayerResult{Os: "ubuntu 24.04", Tag: "1.0", Arch: "x86_64"}, nil
+				}
+				mockAPI.MockFindMissingBlobs = func(cfg api.FindMissingBlobsConfig) (*api.FindMissingBlobsResult, error) {
+					return &api.FindMissingBlobsResult{}, nil
+				}
+				mockAPI.MockInitiateRun = func(cfg api.InitiateRunConfig) (*api.InitiateRunResult, error) {
+					return &api.InitiateRunResult{}, nil
+				}
+
+				_, err := service.InitiateRun(cli.InitiateRunConfig{
+					MintFilePath:  filepath.Join(tmp, "foo.yaml"),
+					MintDirectory: tmp,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requestedRefs).To(BeEmpty())
+
+				contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(ContainSubstring(`
+  - key: internal
+    call: git+https://github.com/my-org/internal-leaf.git@main
+  - key: default-branch
+    call: git+ssh://git@github.com/my-org/other-leaf.git
+`))
+			})
+
+			It("reports leaves it can't find the versions of", func() {
+				mockAPI.MockResolveLeafVersions = func(cfg api.ResolveLeafVersionsConfig) (*api.LeafVersionsResult, error) {
+					return nil, errors.New("unauthorized")
+				}
+
+				_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+					Files:               []string{filepath.Join(tmp, "foo.yaml")},
+					LatestVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStderr.String()).To(ContainSubstring("Unable to fetch the versions of My-Org/deploy_app: unauthorized\n"))
+				Expect(mockStderr.String()).To(ContainSubstring(`Unable to find the leaf "My-Org/deploy_app"; skipping it.`))
+			})
+		})
+
+		Context("with multi-document files", func() {
+			BeforeEach(func() {
+				mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+					return &api.LeafVersionsResult{
+						LatestMajor: map[string]string{"mint/setup-node": "1.3.0", "mint/setup-ruby": "1.0.1"},
+					}, nil
+				}
+
+				Expect(os.WriteFile(filepath.Join(tmp, "multi.yaml"), []byte(`tasks:
+  - key: node
+    call: mint/setup-node # latest
+---
+- key: ruby
+  call: mint/setup-ruby
+- key: node
+  call: mint/setup-node 1.2.3
+`), 0o644)).To(Succeed())
+			})
+
+			It("resolves the leaves of every document", func() {
+				_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+					Files:               []string{filepath.Join(tmp, "multi.yaml")},
+					LatestVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(tmp, "multi.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`tasks:
+  - key: node
+    call: mint/setup-node 1.3.0
+---
+- key: ruby
+  call: mint/setup-ruby 1.0.1
+- key: node
+  call: mint/setup-node 1.2.3
+`))
+			})
+		})
+
+		Context("with anchors and aliases", func() {
+			BeforeEach(func() {
+				mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+					return &api.LeafVersionsResult{
+						LatestMajor: map[string]string{"mint/setup-node": "1.3.0", "mint/setup-ruby": "1.0.1"},
+					}, nil
+				}
+
+				Expect(os.WriteFile(filepath.Join(tmp, "anchors.yaml"), []byte(`x-node: &node
+  call: mint/setup-node
+tasks:
+  - key: node
+    <<: *node
+  - key: node-again
+    <<: *node
+  - key: ruby
+    call: &ruby mint/setup-ruby # shared
+  - key: ruby-again
+    call: *ruby
+`), 0o644)).To(Succeed())
+			})
+
+			It("resolves each anchor once and keeps the aliases", func() {
+				_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+					Files:               []string{filepath.Join(tmp, "anchors.yaml")},
+					LatestVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(tmp, "anchors.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`x-node: &node
+  call: mint/setup-node 1.3.0
+tasks:
+  - key: node
+    <<: *node
+  - key: node-again
+    <<: *node
+  - key: ruby
+    call: &ruby mint/setup-ruby 1.0.1
+  - key: ruby-again
+    call: *ruby
+`))
+				Expect(mockStdout.String()).To(ContainSubstring("mint/setup-node → 1.3.0"))
+				Expect(mockStdout.String()).To(ContainSubstring("mint/setup-ruby → 1.0.1"))
+			})
+		})
+
+		Context("with digest pinning", func() {
+			var digests map[string]string
+			var requestedLeaves []api.LeafReference
+
+			BeforeEach(func() {
+				digests = map[string]string{
+					"mint/setup-node 1.2.3": "sha256:" + strings.Repeat("a", 64),
+					"mint/setup-node 1.3.0": "sha256:" + strings.Repeat("b", 64),
+					"mint/setup-ruby 1.0.1": "sha256:" + strings.Repeat("c", 64),
+				}
+				requestedLeaves = nil
+
+				mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+					return &api.LeafVersionsResult{
+						LatestMajor: map[string]string{"mint/setup-node": "1.3.0", "mint/setup-ruby": "1.0.1", "mint/setup-go": "1.0.0"},
+						LatestMinor: map[string]map[string]string{
+							"mint/setup-node": {"1": "1.3.0"},
+							"mint/setup-ruby": {"1": "1.0.1"},
+						},
+					}, nil
+				}
+				mockAPI.MockResolveLeafDigests = func(cfg api.ResolveLeafDigestsConfig) (*api.ResolveLeafDigestsResult, error) {
+					requestedLeaves = append(requestedLeaves, cfg.Leaves...)
+					result := &api.ResolveLeafDigestsResult{}
+					for _, leaf := range cfg.Leaves {
+						digest, ok := digests[leaf.Name+" "+leaf.Version]
+						if !ok {
+							continue
+						}
+						result.Leaves = append(result.Leaves, api.LeafDigest{Name: leaf.Name, Version: leaf.Version, Digest: digest})
+					}
+					return result, nil
+				}
+
+				Expect(os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(`
+tasks:
+  - key: node
+    call: mint/setup-node 1.2.3
+  - key: node-again
+    call: mint/setup-node 1.2.3
+  - key: ruby
+    call: mint/setup-ruby
+  - key: go
+    call: mint/setup-go
+  - key: embed
+    call: ${{ run.mint-dir }}/embed.yml
+`), 0o644)).To(Succeed())
+			})
+
+			It("does not pin leaves unless asked to", func() {
+				_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+					Files:               []string{filepath.Join(tmp, "foo.yaml")},
+					LatestVersionPicker: cli.PickLatestMajorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(requestedLeaves).To(BeEmpty())
+			})
+
+			It("pins every leaf to the digest of its version", func() {
+				resolved, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
+					Files:               []string{filepath.Join(tmp, "foo.yaml")},
+					LatestVersionPicker: cli.PickLatestMajorVersion,
+					PinDigests:          true,
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resolved.ResolvedLeaves).To(HaveKeyWithValue("mint/setup-node 1.2.3", "1.2.3@"+digests["mint/setup-node 1.2.3"]))
+				Expect(requestedLeaves).To(ConsistOf(
+					api.LeafReference{Name: "mint/setup-node", Version: "1.2.3"},
+					api.LeafReference{Name: "mint/setup-ruby", Version: "1.0.1"},
+					api.LeafReference{Name: "mint/setup-go", Version: "1.0.0"},
+				))
+
+				contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`tasks:
+  - key: node
+    call: mint/setup-node 1.2.3@sha256:` + strings.Repeat("a", 64) + `
+  - key: node-again
+    call: mint/setup-node 1.2.3@sha256:` + strings.Repeat("a", 64) + `
+  - key: ruby
+    call: mint/setup-ruby 1.0.1@sha256:` + strings.Repeat("c", 64) + `
+  - key: go
+    call: mint/setup-go
+  - key: embed
+    call: ${{ run.mint-dir }}/embed.yml
+`))
+				Expect(mockStderr.String()).To(ContainSubstring("Unable to pin leaf mint/setup-go 1.0.0 to a digest: it could not be found in the registry; skipping it."))
+			})
+
+			It("keeps pinned leaves pinned when updating them", func() {
+				Expect(os.WriteFile(filepath.Join(tmp, "foo.yaml"), []byte(`
+tasks:
+  - key: node
+    call: mint/setup-node 1.2.3@sha256:`+strings.Repeat("a", 64)+`
+  - key: ruby
+    call: mint/setup-ruby 1.0.1
+`), 0o644)).To(Succeed())
+
+				err := service.UpdateLeaves(cli.UpdateLeavesConfig{
+					Files:                    []string{filepath.Join(tmp, "foo.yaml")},
+					ReplacementVersionPicker: cli.PickLatestMinorVersion,
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`tasks:
+  - key: node
+    call: mint/setup-node 1.3.0@sha256:` + strings.Repeat("b", 64) + `
+  - key: ruby
+    call: mint/setup-ruby 1.0.1
+`))
+			})
+		})
+	})
+
+	Describe("listing base layers", func() {
+		BeforeEach(func() {
+			mockAPI.MockListBaseLayers = func() (*api.ListBaseLayersResult, error) {
+				return &api.ListBaseLayersResult{
+					BaseLayers: []api.BaseLayer{
+						{Os: "ubuntu 22.04", Tag: "1.1", Arches: []string{"x86_64", "arm64"}, Default: true},
+						{Os: "ubuntu 20.04", Tag: "1.0", Arches: []string{"x86_64"}, DeprecatedOn: "2025-01-01", EndOfLifeOn: "2025-06-30"},
+					},
+				}, nil
+			}
+		})
+
+		It("lists the base layers with their status", func() {
+			_, err := service.ListBaseLayers(cli.ListBaseLayersConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(Equal(`OS            TAG  ARCH           STATUS
+ubuntu 22.04  1.1  x86_64, arm64  default
+ubuntu 20.04  1.0  x86_64         deprecated 2025-01-01, end of life 2025-06-30
+`))
+		})
+
+		It("outputs JSON", func() {
+			_, err := service.ListBaseLayers(cli.ListBaseLayersConfig{Json: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(ContainSubstring(`"os": "ubuntu 22.04"`))
+			Expect(mockStdout.String()).To(ContainSubstring(`"end_of_life_on": "2025-06-30"`))
+		})
+
+		It("indicates when there are no base layers", func() {
+			mockAPI.MockListBaseLayers = func() (*api.ListBaseLayersResult, error) {
+				return &api.ListBaseLayersResult{}, nil
+			}
+
+			_, err := service.ListBaseLayers(cli.ListBaseLayersConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(Equal("No base layers found.\n"))
+		})
+	})
+
+	Describe("resolving base layers", func() {
+		var (
+			apiOs        string
+			apiTag       string
+			apiArch      string
+			apiCallCount int
+			apiError     func(callCount int) error
+			workingDir   string
+			mintDir      string
+		)
+
+		BeforeEach(func() {
+			workingDir = filepath.Join(tmp, "subdir1/subdir2")
+			err := os.MkdirAll(workingDir, 0o755)
+			Expect(err).NotTo(HaveOccurred())
+
+			mintDir = filepath.Join(tmp, "subdir1/.mint")
+			err = os.MkdirAll(mintDir, 0o755)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = os.Chdir(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			apiOs = "gentoo 99"
+			apiTag = "1.2"
+			apiArch = "x86_64"
+			apiCallCount = 0
+			apiError = func(callCount int) error { return nil }
+
+			mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
+				apiCallCount += 1
+				if err := apiError(apiCallCount); err != nil {
+					return api.ResolveBaseLayerResult{}, err
+				}
+
+				os := cfg.Os
+				if os == "" {
+					os = apiOs
+				}
+				tag := cfg.Tag
+				if tag == "" {
+					tag = apiTag
+				}
+				arch := cfg.Arch
+				if arch == "" {
+					arch = apiArch
+				}
+				return api.ResolveBaseLayerResult{
+					Os:   os,
+					Tag:  tag,
+					Arch: arch,
+				}, nil
+			}
+		})
+
+		Context("when no yaml files found in the default directory", func() {
+			BeforeEach(func() {
+				var err error
+
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(mintDir, "bar.json"), []byte("some json"), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				_, err := service.ResolveBase(cli.ResolveBaseConfig{})
+
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("no files provided, and no yaml files found in directory %s", mintDir)))
+			})
+		})
+
+		Context("when yaml file is actually json", func() {
+			BeforeEach(func() {
+				err := os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`{
+"tasks": [
+  { "key": "a" },
+  { "key": "b" }
+]
+}`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("adds base to the file, preserving its formatting", func() {
+				_, err := service.ResolveBase(cli.ResolveBaseConfig{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStderr.String()).To(Equal(""))
+
+				contents, err := os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`{
+"base": {
+  "os": "gentoo 99",
+  "tag": "1.2"
+},
+"tasks": [
+  { "key": "a" },
+  { "key": "b" }
+]
+}`))
+			})
+		})
+
+		Context("when the run definition is a json file", func() {
+			BeforeEach(func() {
+				err := os.WriteFile(filepath.Join(mintDir, "bar.json"), []byte(`{
+    "on": {"github": {"push": {}}},
+    "tasks": [
+        {
+            "key": "a",
+            "run": "echo a && echo b"
+        }
+    ]
+}
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("adds base to the file, preserving its key order and indentation", func() {
+				_, err := service.ResolveBase(cli.ResolveBaseConfig{Arch: "quantum"})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(mintDir, "bar.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`{
+    "on": {"github": {"push": {}}},
+    "base": {
+        "arch": "quantum",
+        "os": "gentoo 99",
+        "tag": "1.2"
+    },
+    "tasks": [
+        {
+            "key": "a",
+            "run": "echo a && echo b"
+        }
+    ]
+}
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Added base to the following run definitions:\n%s\n",
+					"\t../.mint/bar.json → gentoo 99, tag 1.2",
+				)))
+			})
+
+			It("updates the base in place", func() {
+				err := os.WriteFile(filepath.Join(mintDir, "bar.json"), []byte(`{
+  "base": {"os": "gentoo 99", "tag": "1.1"},
+  "tasks": [{"key": "a"}]
+}
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{Os: "gentoo 100"})
+				Expect(err).NotTo(HaveOccurred())
+
+				contents, err := os.ReadFile(filepath.Join(mintDir, "bar.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`{
+  "base": {"os": "gentoo 100", "tag": "1.2"},
+  "tasks": [{"key": "a"}]
+}
+`))
+			})
+		})
+
+		Context("when yaml file doesn't include base", func() {
+			BeforeEach(func() {
+				var err error
+
+				err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`
+tasks:
+  - key: a
+  - key: b
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(mintDir, "baz.yaml"), []byte(`
+not-my-key:
+  - key: qux
+    call: mint/setup-node 1.2.3
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("adds base to file", func() {
+				var err error
+
+				_, err = service.ResolveBase(cli.ResolveBaseConfig{
+					Arch: "quantum",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`base:
+  arch: quantum
+  os: gentoo 99
+  tag: 1.2
+
+tasks:
+  - key: a
+  - key: b
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Added base to the following run definitions:\n%s\n",
+					"\t../.mint/bar.yaml → gentoo 99, tag 1.2",
+				)))
+
+				// yaml file without tasks key is unaffected
+				contents, err = os.ReadFile(filepath.Join(mintDir, "baz.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`
+not-my-key:
+  - key: qux
+    call: mint/setup-node 1.2.3
+`))
+			})
+
+			It("adds base to only a targeted file", func() {
+				var err error
+
+				originalQuxContents := `
+tasks:
+  - key: a
+  - key: b
+`
+				err = os.WriteFile(filepath.Join(mintDir, "qux.yaml"), []byte(originalQuxContents), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = service.ResolveBase(cli.ResolveBaseConfig{
+					Files: []string{"../.mint/bar.yaml"},
+					Arch:  "quantum",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`base:
+  arch: quantum
+  os: gentoo 99
+  tag: 1.2
+
+tasks:
+  - key: a
+  - key: b
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Added base to the following run definitions:\n%s\n",
+					"\t../.mint/bar.yaml → gentoo 99, tag 1.2",
+				)))
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "qux.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(originalQuxContents))
+			})
+
+			It("errors when given a file that does not exist", func() {
+				var err error
+
+				_, err = service.ResolveBase(cli.ResolveBaseConfig{
+					Files: []string{"does-not-exist.yaml"},
+					Arch:  "quantum",
+				})
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(Equal("reading mint directory entries at does-not-exist.yaml: file does not exist"))
+			})
+		})
+
+		Context("when yaml file has a base with os but no tag or arch", func() {
+			BeforeEach(func() {
+				err := os.WriteFile(filepath.Join(mintDir, "ci.yaml"), []byte(`on:
+  github:
+    push: {}
+
+base:
+  os: gentoo 99
+
+tasks:
+  - key: a
+  - key: b
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("adds tag to base", func() {
+				var err error
+
+				_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "ci.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`on:
+  github:
+    push: {}
+
+base:
+  os: gentoo 99
+  tag: 1.2
+
+tasks:
+  - key: a
+  - key: b
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Added base to the following run definitions:\n%s\n",
+					"\t../.mint/ci.yaml → gentoo 99, tag 1.2",
+				)))
+			})
+		})
+
+		Context("when yaml file has a base with os and arch but no tag", func() {
+			BeforeEach(func() {
+				err := os.WriteFile(filepath.Join(mintDir, "ci.yaml"), []byte(`on:
+  github:
+    push: {}
+
+base:
+  os: gentoo 99
+  arch: quantum
+
+tasks:
+  - key: a
+  - key: b
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("adds tag to base", func() {
+				var err error
+
+				_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "ci.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`on:
+  github:
+    push: {}
+
+base:
+  os: gentoo 99
+  arch: quantum
+  tag: 1.2
+
+tasks:
+  - key: a
+  - key: b
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Added base to the following run definitions:\n%s\n",
+					"\t../.mint/ci.yaml → gentoo 99, tag 1.2",
+				)))
+			})
+		})
+
+		Context("when yaml file has base after tasks with os but no tag", func() {
+			BeforeEach(func() {
+				err := os.WriteFile(filepath.Join(mintDir, "ci.yaml"), []byte(`on:
+  github:
+    push: {}
+
+tasks:
+  - key: a
+  - key: b
+
+base:
+  os: gentoo 99`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("adds tag to base without moving base before tasks", func() {
+				var err error
+
+				_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "ci.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`on:
+  github:
+    push: {}
+
+tasks:
+  - key: a
+  - key: b
+
+base:
+  os: gentoo 99
+  tag: 1.2
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Added base to the following run definitions:\n%s\n",
+					"\t../.mint/ci.yaml → gentoo 99, tag 1.2",
+				)))
+			})
+		})
+
+		Context("with multiple yaml files", func() {
+			BeforeEach(func() {
+				var err error
+
+				err = os.WriteFile(filepath.Join(mintDir, "one.yaml"), []byte(`tasks:
+  - key: a
+  - key: b
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(mintDir, "two.yaml"), []byte(`base:
+  os: gentoo 88
+
+tasks:
+  - key: c
+  - key: d
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(mintDir, "three.yaml"), []byte(`tasks:
+  - key: e
+  - key: f
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("updates all files", func() {
+				var err error
+
+				_, err = service.ResolveBase(cli.ResolveBaseConfig{
+					Os: "gentoo 99",
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "one.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`base:
+  os: gentoo 99
+  tag: 1.2
+
+tasks:
+  - key: a
+  - key: b
+`))
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "two.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`base:
+  os: gentoo 88
+  tag: 1.2
+
+tasks:
+  - key: c
+  - key: d
+`))
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "three.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`base:
+  os: gentoo 99
+  tag: 1.2
+
+tasks:
+  - key: e
+  - key: f
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Added base to the following run definitions:\n%s\n%s\n%s\n",
+					"\t../.mint/one.yaml → gentoo 99, tag 1.2",
+					"\t../.mint/three.yaml → gentoo 99, tag 1.2",
+					"\t../.mint/two.yaml → gentoo 88, tag 1.2",
+				)))
+			})
+
+			Context("when an API request fails", func() {
+				It("doesn't update any files", func() {
+					contentsOne, err := os.ReadFile(filepath.Join(mintDir, "one.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					contentsTwo, err := os.ReadFile(filepath.Join(mintDir, "two.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					contentsThree, err := os.ReadFile(filepath.Join(mintDir, "three.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+
+					apiError = func(callCount int) error {
+						if callCount == 2 {
+							return errors.New("API request failed")
+						}
+						return nil
+					}
+
+					_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("API request failed"))
 
-					contents, err = os.ReadFile(filepath.Join(tmp, "bar.yaml"))
+					var contents []byte
+
+					contents, err = os.ReadFile(filepath.Join(mintDir, "one.yaml"))
 					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(Equal(`tasks:
-  - key: foo
-    call: mint/setup-ruby 1.0.1
-`))
-				})
+					Expect(string(contents)).To(Equal(string(contentsOne)))
 
-				It("indicates leaves were resolved", func() {
-					_, err := service.ResolveLeaves(cli.ResolveLeavesConfig{
-						MintDirectory:       tmp,
-						LatestVersionPicker: cli.PickLatestMajorVersion,
-					})
+					contents, err = os.ReadFile(filepath.Join(mintDir, "two.yaml"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(Equal(string(contentsTwo)))
 
+					contents, err = os.ReadFile(filepath.Join(mintDir, "three.yaml"))
 					Expect(err).NotTo(HaveOccurred())
-					Expect(mockStdout.String()).To(ContainSubstring("Resolved the following leaves:"))
-					Expect(mockStdout.String()).To(ContainSubstring("mint/setup-go → 1.3.5"))
-					Expect(mockStdout.String()).To(ContainSubstring("mint/setup-node → 1.2.3"))
-					Expect(mockStdout.String()).To(ContainSubstring("mint/setup-ruby → 1.0.1"))
+					Expect(string(contents)).To(Equal(string(contentsThree)))
 				})
+			})
+		})
 
-				Context("when a single file is targeted", func() {
-					It("resolves only the targeted file", func() {
-						var err error
+		Context("with multi-document files", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "multi.yaml"), []byte(`# first run
+tasks:
+  - key: a
+    run: echo a
+---
+# shared tasks
+- key: b
+  run: echo b
+---
+base:
+  os: gentoo 99
+  tag: 1.1
 
-						_, err = service.ResolveLeaves(cli.ResolveLeavesConfig{
-							MintDirectory:       tmp,
-							Files:               []string{filepath.Join(tmp, "bar.yaml")},
-							LatestVersionPicker: cli.PickLatestMajorVersion,
-						})
-						Expect(err).NotTo(HaveOccurred())
+tasks:
+  - key: c
+    run: echo c
+`), 0o644)).To(Succeed())
+			})
 
-						contents, err := os.ReadFile(filepath.Join(tmp, "foo.yaml"))
-						Expect(err).NotTo(HaveOccurred())
-						Expect(string(contents)).To(Equal(originalFooContents))
+			It("adds a base to every run definition missing one and keeps the other documents intact", func() {
+				_, err := service.ResolveBase(cli.ResolveBaseConfig{})
+				Expect(err).NotTo(HaveOccurred())
 
-						contents, err = os.ReadFile(filepath.Join(tmp, "bar.yaml"))
-						Expect(err).NotTo(HaveOccurred())
-						Expect(string(contents)).To(Equal(`tasks:
-  - key: foo
-    call: mint/setup-ruby 1.0.1
+				contents, err := os.ReadFile(filepath.Join(mintDir, "multi.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`# first run
+base:
+  os: gentoo 99
+  tag: 1.2
+
+tasks:
+  - key: a
+    run: echo a
+---
+# shared tasks
+- key: b
+  run: echo b
+---
+base:
+  os: gentoo 99
+  tag: 1.1
+
+tasks:
+  - key: c
+    run: echo c
 `))
-					})
-				})
 			})
 		})
 	})
 
-	Describe("resolving base layers", func() {
+	Describe("updating base layers", func() {
 		var (
 			apiOs        string
 			apiTag       string
@@ -2230,7 +4311,7 @@ tasks:
 			Expect(err).NotTo(HaveOccurred())
 
 			apiOs = "gentoo 99"
-			apiTag = "1.2"
+			apiTag = "1.5"
 			apiArch = "x86_64"
 			apiCallCount = 0
 			apiError = func(callCount int) error { return nil }
@@ -2246,8 +4327,10 @@ tasks:
 					os = apiOs
 				}
 				tag := cfg.Tag
-				if tag == "" {
+				if tag == "" || string(tag[0]) == "1" {
 					tag = apiTag
+				} else {
+					return api.ResolveBaseLayerResult{}, errors.Wrap(api.ErrNotFound, "unknown base tag")
 				}
 				arch := cfg.Arch
 				if arch == "" {
@@ -2265,6 +4348,7 @@ tasks:
 			BeforeEach(func() {
 				var err error
 
+				err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
 				Expect(err).NotTo(HaveOccurred())
 
 				err = os.WriteFile(filepath.Join(mintDir, "bar.json"), []byte("some json"), 0o644)
@@ -2272,16 +4356,22 @@ tasks:
 			})
 
 			It("returns an error", func() {
-				_, err := service.ResolveBase(cli.ResolveBaseConfig{})
+				_, err := service.UpdateBase(cli.UpdateBaseConfig{})
 
 				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring(fmt.Sprintf("no files provided, and no yaml files found in directory %s", mintDir)))
+				Expect(err.Error()).To(ContainSubstring("no files provided, and no yaml files found"))
 			})
 		})
 
 		Context("when yaml file is actually json", func() {
+			var mintDir string
+
 			BeforeEach(func() {
-				err := os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`{
+				var err error
+
+				mintDir = tmp
+
+				err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`{
 "tasks": [
   { "key": "a" },
   { "key": "b" }
@@ -2290,16 +4380,15 @@ tasks:
 				Expect(err).NotTo(HaveOccurred())
 			})
 
-			It("ignores the file", func() {
-				_, err := service.ResolveBase(cli.ResolveBaseConfig{})
+			It("returns an error", func() {
+				_, err := service.UpdateBase(cli.UpdateBaseConfig{})
 
-				Expect(err).NotTo(HaveOccurred())
-				Expect(mockStderr.String()).To(Equal(""))
-				Expect(mockStdout.String()).To(ContainSubstring("No run files were missing base"))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("no files provided, and no yaml files found"))
 			})
 		})
 
-		Context("when yaml file doesn't include base", func() {
+		Context("when yaml file doesn't include a base", func() {
 			BeforeEach(func() {
 				var err error
 
@@ -2324,9 +4413,7 @@ not-my-key:
 			It("adds base to file", func() {
 				var err error
 
-				_, err = service.ResolveBase(cli.ResolveBaseConfig{
-					Arch: "quantum",
-				})
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
 				Expect(err).NotTo(HaveOccurred())
 
 				var contents []byte
@@ -2334,9 +4421,8 @@ not-my-key:
 				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(contents)).To(Equal(`base:
-  arch: quantum
   os: gentoo 99
-  tag: 1.2
+  tag: 1.5
 
 tasks:
   - key: a
@@ -2344,8 +4430,8 @@ tasks:
 `))
 
 				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Added base to the following run definitions:\n%s\n",
-					"\t../.mint/bar.yaml → gentoo 99, tag 1.2",
+					"Updated base for the following run definitions:\n%s\n",
+					"\t../.mint/bar.yaml → tag 1.5",
 				)))
 
 				// yaml file without tasks key is unaffected
@@ -2369,9 +4455,8 @@ tasks:
 				err = os.WriteFile(filepath.Join(mintDir, "qux.yaml"), []byte(originalQuxContents), 0o644)
 				Expect(err).NotTo(HaveOccurred())
 
-				_, err = service.ResolveBase(cli.ResolveBaseConfig{
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{
 					Files: []string{"../.mint/bar.yaml"},
-					Arch:  "quantum",
 				})
 				Expect(err).NotTo(HaveOccurred())
 
@@ -2380,9 +4465,8 @@ tasks:
 				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(contents)).To(Equal(`base:
-  arch: quantum
   os: gentoo 99
-  tag: 1.2
+  tag: 1.5
 
 tasks:
   - key: a
@@ -2390,60 +4474,99 @@ tasks:
 `))
 
 				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Added base to the following run definitions:\n%s\n",
-					"\t../.mint/bar.yaml → gentoo 99, tag 1.2",
+					"Updated base for the following run definitions:\n%s\n",
+					"\t../.mint/bar.yaml → tag 1.5",
 				)))
 
 				contents, err = os.ReadFile(filepath.Join(mintDir, "qux.yaml"))
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(contents)).To(Equal(originalQuxContents))
 			})
-
-			It("errors when given a file that does not exist", func() {
-				var err error
-
-				_, err = service.ResolveBase(cli.ResolveBaseConfig{
-					Files: []string{"does-not-exist.yaml"},
-					Arch:  "quantum",
-				})
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(Equal("reading mint directory entries at does-not-exist.yaml: file does not exist"))
-			})
 		})
 
-		Context("when yaml file has a base with os but no tag or arch", func() {
+		Context("when yaml file includes an older base", func() {
 			BeforeEach(func() {
-				err := os.WriteFile(filepath.Join(mintDir, "ci.yaml"), []byte(`on:
-  github:
-    push: {}
+				var err error
+
+				err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
+				Expect(err).NotTo(HaveOccurred())
 
+				err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`
 base:
   os: gentoo 99
+  tag: 1.1
 
 tasks:
   - key: a
   - key: b
+`), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = os.WriteFile(filepath.Join(mintDir, "baz.yaml"), []byte(`
+not-my-key:
+  - key: qux
+    call: mint/setup-node 1.2.3
 `), 0o644)
 				Expect(err).NotTo(HaveOccurred())
 			})
 
-			It("adds tag to base", func() {
+			It("updates base tag", func() {
 				var err error
 
-				_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
 				Expect(err).NotTo(HaveOccurred())
 
 				var contents []byte
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "ci.yaml"))
+				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
 				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`on:
-  github:
-    push: {}
+				Expect(string(contents)).To(Equal(`base:
+  os: gentoo 99
+  tag: 1.5
 
-base:
+tasks:
+  - key: a
+  - key: b
+`))
+
+				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
+					"Updated base for the following run definitions:\n%s\n",
+					"\t../.mint/bar.yaml tag 1.1 → tag 1.5",
+				)))
+
+				// yaml file without tasks key is unaffected
+				contents, err = os.ReadFile(filepath.Join(mintDir, "baz.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`
+not-my-key:
+  - key: qux
+    call: mint/setup-node 1.2.3
+`))
+			})
+
+			It("updates base for only a targeted file", func() {
+				var err error
+
+				originalQuxContents := `
+tasks:
+  - key: a
+  - key: b
+`
+				err = os.WriteFile(filepath.Join(mintDir, "qux.yaml"), []byte(originalQuxContents), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{
+					Files: []string{"../.mint/bar.yaml"},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`base:
   os: gentoo 99
-  tag: 1.2
+  tag: 1.5
 
 tasks:
   - key: a
@@ -2451,9 +4574,52 @@ tasks:
 `))
 
 				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Added base to the following run definitions:\n%s\n",
-					"\t../.mint/ci.yaml → gentoo 99, tag 1.2",
+					"Updated base for the following run definitions:\n%s\n",
+					"\t../.mint/bar.yaml tag 1.1 → tag 1.5",
 				)))
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "qux.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(originalQuxContents))
+			})
+		})
+
+		Context("when yaml file includes base tag newer than the server knows about", func() {
+			var originalContents string
+
+			BeforeEach(func() {
+				var err error
+
+				originalContents = `
+base:
+  os: gentoo 99
+  tag: 2.0
+
+tasks:
+  - key: a
+  - key: b
+`
+
+				err = os.WriteFile(filepath.Join(workingDir, "ci.yaml"), []byte(originalContents), 0o644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("errors without changing file", func() {
+				var err error
+
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{
+					Files: []string{"ci.yaml"},
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				var contents []byte
+
+				contents, err = os.ReadFile(filepath.Join(workingDir, "ci.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(originalContents))
+
+				Expect(mockStderr.String()).To(Equal("Unknown base tag 2 for run definitions: ci.yaml\n"))
+				Expect(mockStdout.String()).To(Equal("No run bases to update.\n"))
 			})
 		})
 
@@ -2477,7 +4643,7 @@ tasks:
 			It("adds tag to base", func() {
 				var err error
 
-				_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
 				Expect(err).NotTo(HaveOccurred())
 
 				var contents []byte
@@ -2491,7 +4657,7 @@ tasks:
 base:
   os: gentoo 99
   arch: quantum
-  tag: 1.2
+  tag: 1.5
 
 tasks:
   - key: a
@@ -2499,8 +4665,8 @@ tasks:
 `))
 
 				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Added base to the following run definitions:\n%s\n",
-					"\t../.mint/ci.yaml → gentoo 99, tag 1.2",
+					"Updated base for the following run definitions:\n%s\n",
+					"\t../.mint/ci.yaml → tag 1.5",
 				)))
 			})
 		})
@@ -2523,7 +4689,7 @@ base:
 			It("adds tag to base without moving base before tasks", func() {
 				var err error
 
-				_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
 				Expect(err).NotTo(HaveOccurred())
 
 				var contents []byte
@@ -2540,12 +4706,12 @@ tasks:
 
 base:
   os: gentoo 99
-  tag: 1.2
+  tag: 1.5
 `))
 
 				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Added base to the following run definitions:\n%s\n",
-					"\t../.mint/ci.yaml → gentoo 99, tag 1.2",
+					"Updated base for the following run definitions:\n%s\n",
+					"\t../.mint/ci.yaml → tag 1.5",
 				)))
 			})
 		})
@@ -2554,7 +4720,11 @@ base:
 			BeforeEach(func() {
 				var err error
 
-				err = os.WriteFile(filepath.Join(mintDir, "one.yaml"), []byte(`tasks:
+				err = os.WriteFile(filepath.Join(mintDir, "one.yaml"), []byte(`base:
+  os: gentoo 99
+  tag: 1.1
+
+tasks:
   - key: a
   - key: b
 `), 0o644)
@@ -2579,9 +4749,7 @@ tasks:
 			It("updates all files", func() {
 				var err error
 
-				_, err = service.ResolveBase(cli.ResolveBaseConfig{
-					Os: "gentoo 99",
-				})
+				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
 				Expect(err).NotTo(HaveOccurred())
 
 				var contents []byte
@@ -2590,7 +4758,7 @@ tasks:
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(contents)).To(Equal(`base:
   os: gentoo 99
-  tag: 1.2
+  tag: 1.5
 
 tasks:
   - key: a
@@ -2601,7 +4769,7 @@ tasks:
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(contents)).To(Equal(`base:
   os: gentoo 88
-  tag: 1.2
+  tag: 1.5
 
 tasks:
   - key: c
@@ -2612,19 +4780,17 @@ tasks:
 				Expect(err).NotTo(HaveOccurred())
 				Expect(string(contents)).To(Equal(`base:
   os: gentoo 99
-  tag: 1.2
+  tag: 1.5
 
 tasks:
   - key: e
   - key: f
 `))
 
-				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Added base to the following run definitions:\n%s\n%s\n%s\n",
-					"\t../.mint/one.yaml → gentoo 99, tag 1.2",
-					"\t../.mint/three.yaml → gentoo 99, tag 1.2",
-					"\t../.mint/two.yaml → gentoo 88, tag 1.2",
-				)))
+				Expect(mockStdout.String()).To(ContainSubstring("Updated base for the following run definitions:"))
+				Expect(mockStdout.String()).To(ContainSubstring("\t../.mint/one.yaml tag 1.1 → tag 1.5"))
+				Expect(mockStdout.String()).To(ContainSubstring("\t../.mint/two.yaml → tag 1.5"))
+				Expect(mockStdout.String()).To(ContainSubstring("\t../.mint/three.yaml → tag 1.5"))
 			})
 
 			Context("when an API request fails", func() {
@@ -2643,7 +4809,7 @@ tasks:
 						return nil
 					}
 
-					_, err = service.ResolveBase(cli.ResolveBaseConfig{})
+					_, err = service.UpdateBase(cli.UpdateBaseConfig{})
 					Expect(err).To(HaveOccurred())
 					Expect(err.Error()).To(ContainSubstring("API request failed"))
 
@@ -2663,549 +4829,1275 @@ tasks:
 				})
 			})
 		})
-	})
-
-	Describe("updating base layers", func() {
-		var (
-			apiOs        string
-			apiTag       string
-			apiArch      string
-			apiCallCount int
-			apiError     func(callCount int) error
-			workingDir   string
-			mintDir      string
-		)
 
-		BeforeEach(func() {
-			workingDir = filepath.Join(tmp, "subdir1/subdir2")
-			err := os.MkdirAll(workingDir, 0o755)
-			Expect(err).NotTo(HaveOccurred())
+		Context("when migrating to another operating system", func() {
+			var requestedMigrations []api.GetBaseLayerMigrationConfig
 
-			mintDir = filepath.Join(tmp, "subdir1/.mint")
-			err = os.MkdirAll(mintDir, 0o755)
-			Expect(err).NotTo(HaveOccurred())
+			BeforeEach(func() {
+				apiTag = "1.0"
+				requestedMigrations = nil
+
+				mockAPI.MockGetBaseLayerMigration = func(cfg api.GetBaseLayerMigrationConfig) (*api.BaseLayerMigrationResult, error) {
+					requestedMigrations = append(requestedMigrations, cfg)
+					return &api.BaseLayerMigrationResult{
+						Incompatibilities: []api.BaseLayerIncompatibility{
+							{Leaf: "mint/install-python", LeafVersions: "< 2.0.0", Message: "Python 2 is not available"},
+							{Command: `apt-get install[^\n]*\blibssl1\.1\b`, Message: "libssl1.1 was replaced by libssl3"},
+						},
+					}, nil
+				}
 
-			err = os.Chdir(workingDir)
-			Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(mintDir, "one.yaml"), []byte(`base:
+  os: ubuntu 22.04
+  tag: 1.2
 
-			apiOs = "gentoo 99"
-			apiTag = "1.5"
-			apiArch = "x86_64"
-			apiCallCount = 0
-			apiError = func(callCount int) error { return nil }
+tasks:
+  - key: python
+    call: mint/install-python 1.3.0
+  - key: new-python
+    call: mint/install-python 2.1.0
+  - key: packages
+    run: |
+      sudo apt-get update
+      sudo apt-get install -y libssl1.1
+`), 0o644)).To(Succeed())
+
+				Expect(os.WriteFile(filepath.Join(mintDir, "two.yaml"), []byte(`base:
+  os: ubuntu 24.04
+  tag: 1.0
 
-			mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
-				apiCallCount += 1
-				if err := apiError(apiCallCount); err != nil {
-					return api.ResolveBaseLayerResult{}, err
-				}
+tasks:
+  - key: a
+    run: echo a
+`), 0o644)).To(Succeed())
+			})
 
-				os := cfg.Os
-				if os == "" {
-					os = apiOs
-				}
-				tag := cfg.Tag
-				if tag == "" || string(tag[0]) == "1" {
-					tag = apiTag
-				} else {
-					return api.ResolveBaseLayerResult{}, errors.Wrap(api.ErrNotFound, "unknown base tag")
-				}
-				arch := cfg.Arch
-				if arch == "" {
-					arch = apiArch
-				}
-				return api.ResolveBaseLayerResult{
-					Os:   os,
-					Tag:  tag,
-					Arch: arch,
-				}, nil
-			}
-		})
+			It("migrates run definitions to the latest tag of the new operating system", func() {
+				result, err := service.UpdateBase(cli.UpdateBaseConfig{Os: "ubuntu 24.04"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.UpdatedRunFiles).To(HaveLen(1))
 
-		Context("when no yaml files found in the default directory", func() {
-			BeforeEach(func() {
-				var err error
+				contents, err := os.ReadFile(filepath.Join(mintDir, "one.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix(`base:
+  os: ubuntu 24.04
+  tag: 1.0
+`))
 
-				err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
+				contents, err = os.ReadFile(filepath.Join(mintDir, "two.yaml"))
 				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix(`base:
+  os: ubuntu 24.04
+  tag: 1.0
+`))
 
-				err = os.WriteFile(filepath.Join(mintDir, "bar.json"), []byte("some json"), 0o644)
+				Expect(requestedMigrations).To(Equal([]api.GetBaseLayerMigrationConfig{{FromOs: "ubuntu 22.04", ToOs: "ubuntu 24.04"}}))
+			})
+
+			It("reports leaves and commands known to be incompatible", func() {
+				_, err := service.UpdateBase(cli.UpdateBaseConfig{Os: "ubuntu 24.04"})
 				Expect(err).NotTo(HaveOccurred())
+
+				Expect(mockStdout.String()).To(ContainSubstring(`Updated base for the following run definitions:
+	../.mint/one.yaml ubuntu 22.04, tag 1.2 → ubuntu 24.04, tag 1.0
+		warning: task "python" calls mint/install-python 1.3.0: Python 2 is not available
+		warning: task "packages" runs "apt-get install -y libssl1.1": libssl1.1 was replaced by libssl3
+`))
+				Expect(mockStdout.String()).NotTo(ContainSubstring("new-python"))
 			})
 
-			It("returns an error", func() {
-				_, err := service.UpdateBase(cli.UpdateBaseConfig{})
+			It("still migrates when the incompatibilities can't be fetched", func() {
+				mockAPI.MockGetBaseLayerMigration = func(cfg api.GetBaseLayerMigrationConfig) (*api.BaseLayerMigrationResult, error) {
+					return nil, errors.New("registry unavailable")
+				}
 
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("no files provided, and no yaml files found"))
+				result, err := service.UpdateBase(cli.UpdateBaseConfig{Os: "ubuntu 24.04"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.UpdatedRunFiles).To(HaveLen(1))
+				Expect(mockStderr.String()).To(ContainSubstring("Unable to check compatibility of run definitions with ubuntu 24.04: registry unavailable\n"))
 			})
 		})
 
-		Context("when yaml file is actually json", func() {
-			var mintDir string
+		Context("with run definitions for multiple architectures", func() {
+			var requestedSpecs []api.ResolveBaseLayerConfig
 
 			BeforeEach(func() {
-				var err error
-
-				mintDir = tmp
+				requestedSpecs = nil
+				resolve := mockAPI.MockResolveBaseLayer
+				mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
+					requestedSpecs = append(requestedSpecs, cfg)
+					return resolve(cfg)
+				}
 
-				err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`{
-"tasks": [
-  { "key": "a" },
-  { "key": "b" }
-]
-}`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
-			})
+				Expect(os.WriteFile(filepath.Join(mintDir, "default.yaml"), []byte(`base:
+  os: gentoo 99
+  tag: 1.2
 
-			It("returns an error", func() {
-				_, err := service.UpdateBase(cli.UpdateBaseConfig{})
+tasks:
+  - key: a
+    run: echo a
+`), 0o644)).To(Succeed())
 
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("no files provided, and no yaml files found"))
-			})
-		})
+				Expect(os.WriteFile(filepath.Join(mintDir, "x86.yaml"), []byte(`base:
+  os: gentoo 99
+  tag: 1.2
+  arch: x86_64
 
-		Context("when yaml file doesn't include a base", func() {
-			BeforeEach(func() {
-				var err error
+tasks:
+  - key: a
+    run: echo a
+`), 0o644)).To(Succeed())
 
-				err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
-				Expect(err).NotTo(HaveOccurred())
+				Expect(os.WriteFile(filepath.Join(mintDir, "arm.yaml"), []byte(`base:
+  os: gentoo 99
+  tag: 1.2
+  arch: arm64
 
-				err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`
 tasks:
   - key: a
-  - key: b
-`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
+    run: echo a
+`), 0o644)).To(Succeed())
+			})
 
-				err = os.WriteFile(filepath.Join(mintDir, "baz.yaml"), []byte(`
-not-my-key:
-  - key: qux
-    call: mint/setup-node 1.2.3
-`), 0o644)
+			It("resolves run definitions without an arch together with those on the default arch", func() {
+				_, err := service.UpdateBase(cli.UpdateBaseConfig{})
 				Expect(err).NotTo(HaveOccurred())
-			})
 
-			It("adds base to file", func() {
-				var err error
+				Expect(requestedSpecs).To(ConsistOf(
+					api.ResolveBaseLayerConfig{Os: "gentoo 99", Tag: "1", Arch: "x86_64"},
+					api.ResolveBaseLayerConfig{Os: "gentoo 99", Tag: "1", Arch: "arm64"},
+				))
 
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
+				contents, err := os.ReadFile(filepath.Join(mintDir, "default.yaml"))
 				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix("base:\n  os: gentoo 99\n  tag: 1.5\n\n"))
 
-				var contents []byte
+				contents, err = os.ReadFile(filepath.Join(mintDir, "arm.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix("base:\n  os: gentoo 99\n  tag: 1.5\n  arch: arm64\n"))
+			})
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
+			It("moves every run definition to the requested arch", func() {
+				_, err := service.UpdateBase(cli.UpdateBaseConfig{Arch: "arm64"})
 				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`base:
-  os: gentoo 99
-  tag: 1.5
 
-tasks:
-  - key: a
-  - key: b
-`))
+				for _, name := range []string{"default.yaml", "x86.yaml", "arm.yaml"} {
+					contents, err := os.ReadFile(filepath.Join(mintDir, name))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(string(contents)).To(HavePrefix("base:\n  os: gentoo 99\n  tag: 1.5\n  arch: arm64\n"), name)
+				}
 
-				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Updated base for the following run definitions:\n%s\n",
-					"\t../.mint/bar.yaml → tag 1.5",
-				)))
+				Expect(mockStdout.String()).To(ContainSubstring("\t../.mint/default.yaml gentoo 99, tag 1.2 → gentoo 99, tag 1.5, arch arm64\n"))
+			})
 
-				// yaml file without tasks key is unaffected
-				contents, err = os.ReadFile(filepath.Join(mintDir, "baz.yaml"))
+			It("writes the default arch when moving back to it", func() {
+				_, err := service.UpdateBase(cli.UpdateBaseConfig{Arch: "x86_64"})
 				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`
-not-my-key:
-  - key: qux
-    call: mint/setup-node 1.2.3
-`))
+
+				contents, err := os.ReadFile(filepath.Join(mintDir, "arm.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix("base:\n  os: gentoo 99\n  tag: 1.5\n  arch: x86_64\n"))
+
+				contents, err = os.ReadFile(filepath.Join(mintDir, "default.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix("base:\n  os: gentoo 99\n  tag: 1.5\n\n"))
 			})
+		})
+	})
 
-			It("adds base to only a targeted file", func() {
-				var err error
+	Describe("planning updates", func() {
+		var mintDir string
+		var plan *cli.UpdatePlan
+		const original = `base:
+  os: ubuntu 24.04
+  tag: 1.0
 
-				originalQuxContents := `
 tasks:
-  - key: a
-  - key: b
+  - key: node
+    call: mint/setup-node 1.0.1
 `
-				err = os.WriteFile(filepath.Join(mintDir, "qux.yaml"), []byte(originalQuxContents), 0o644)
-				Expect(err).NotTo(HaveOccurred())
-
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{
-					Files: []string{"../.mint/bar.yaml"},
-				})
-				Expect(err).NotTo(HaveOccurred())
 
-				var contents []byte
+		BeforeEach(func() {
+			mintDir = filepath.Join(tmp, ".mint")
+			Expect(os.MkdirAll(mintDir, 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(original), 0o644)).To(Succeed())
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`base:
-  os: gentoo 99
-  tag: 1.5
+			plan = cli.NewUpdatePlan()
 
-tasks:
-  - key: a
-  - key: b
-`))
+			mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
+				return api.ResolveBaseLayerResult{Os: "ubuntu 24.04", Tag: "1.1", Arch: "x86_64"}, nil
+			}
+			mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+				return &api.LeafVersionsResult{
+					LatestMajor: map[string]string{"mint/setup-node": "1.2.3"},
+					LatestMinor: map[string]map[string]string{"mint/setup-node": {"1": "1.2.3"}},
+				}, nil
+			}
+		})
 
-				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Updated base for the following run definitions:\n%s\n",
-					"\t../.mint/bar.yaml → tag 1.5",
-				)))
+		JustBeforeEach(func() {
+			_, err := service.UpdateBase(cli.UpdateBaseConfig{MintDirectory: mintDir, Plan: plan})
+			Expect(err).NotTo(HaveOccurred())
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "qux.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(originalQuxContents))
+			err = service.UpdateLeaves(cli.UpdateLeavesConfig{
+				MintDirectory:            mintDir,
+				ReplacementVersionPicker: cli.PickLatestMinorVersion,
+				Plan:                     plan,
 			})
+			Expect(err).NotTo(HaveOccurred())
 		})
 
-		Context("when yaml file includes an older base", func() {
-			BeforeEach(func() {
-				var err error
+		It("does not write any files", func() {
+			contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(original))
+			Expect(mockStdout.String()).To(BeEmpty())
+		})
 
-				err = os.WriteFile(filepath.Join(mintDir, "foo.txt"), []byte("some txt"), 0o644)
-				Expect(err).NotTo(HaveOccurred())
+		It("records base and leaf changes", func() {
+			Expect(plan.HasChanges()).To(BeTrue())
+			Expect(plan.Changes).To(Equal([]cli.PlannedChange{
+				{File: ".mint/ci.yml", Path: "$.base.tag", From: "1.0", To: "1.1", Kind: "base"},
+				{File: ".mint/ci.yml", Path: "$.tasks[0].call", From: "mint/setup-node 1.0.1", To: "mint/setup-node 1.2.3", Kind: "leaf"},
+			}))
+		})
 
-				err = os.WriteFile(filepath.Join(mintDir, "bar.yaml"), []byte(`
-base:
-  os: gentoo 99
+		It("reports a diff of all changes per file", func() {
+			Expect(service.ReportUpdatePlan(cli.ReportUpdatePlanConfig{Plan: plan})).To(Succeed())
+			Expect(mockStdout.String()).To(Equal(`--- a/.mint/ci.yml
++++ b/.mint/ci.yml
+@@ -1,7 +1,7 @@
+ base:
+   os: ubuntu 24.04
+-  tag: 1.0
++  tag: 1.1
+ 
+ tasks:
+   - key: node
+-    call: mint/setup-node 1.0.1
++    call: mint/setup-node 1.2.3
+
+Would make 2 changes to 1 file.
+`))
+		})
+
+		It("reports the plan as JSON", func() {
+			Expect(service.ReportUpdatePlan(cli.ReportUpdatePlanConfig{Plan: plan, Json: true})).To(Succeed())
+			Expect(mockStdout.String()).To(MatchJSON(`{
+  "changes": [
+    {"file": ".mint/ci.yml", "path": "$.base.tag", "from": "1.0", "to": "1.1", "kind": "base"},
+    {"file": ".mint/ci.yml", "path": "$.tasks[0].call", "from": "mint/setup-node 1.0.1", "to": "mint/setup-node 1.2.3", "kind": "leaf"}
+  ]
+}`))
+		})
+
+		Context("when everything is up to date", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(`base:
+  os: ubuntu 24.04
   tag: 1.1
 
 tasks:
-  - key: a
-  - key: b
-`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
-
-				err = os.WriteFile(filepath.Join(mintDir, "baz.yaml"), []byte(`
-not-my-key:
-  - key: qux
+  - key: node
     call: mint/setup-node 1.2.3
-`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
+`), 0o644)).To(Succeed())
 			})
 
-			It("updates base tag", func() {
-				var err error
+			It("reports that no updates are available", func() {
+				Expect(plan.HasChanges()).To(BeFalse())
+				Expect(service.ReportUpdatePlan(cli.ReportUpdatePlanConfig{Plan: plan})).To(Succeed())
+				Expect(mockStdout.String()).To(Equal("No updates available.\n"))
+			})
+		})
+	})
 
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
-				Expect(err).NotTo(HaveOccurred())
+	Describe("formatting", func() {
+		var mintDir string
+		const original = `# Runs on every push
+on:
+    github:
+        push: {}   # all branches
 
-				var contents []byte
+base:
+    os: "ubuntu 24.04"
+    tag: '1.2'
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`base:
-  os: gentoo 99
-  tag: 1.5
+tasks:
+    # Dependencies
+    - run: "npm ci\nnpm run build\n"
+      use: node
+      key: build
+    - call: mint/setup-node 1.3.0 # pinned
+      with:
+        node-version: "20.10.0"
+        cache: "true"
+      key: node
+`
+		const formatted = `# Runs on every push
+on:
+  github:
+    push: {} # all branches
+
+base:
+  os: ubuntu 24.04
+  tag: "1.2"
 
 tasks:
-  - key: a
-  - key: b
-`))
+  # Dependencies
+  - key: build
+    use: node
+    run: |
+      npm ci
+      npm run build
+  - key: node
+    call: mint/setup-node 1.3.0 # pinned
+    with:
+      node-version: "20.10.0"
+      cache: "true"
+`
 
-				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Updated base for the following run definitions:\n%s\n",
-					"\t../.mint/bar.yaml tag 1.1 → tag 1.5",
-				)))
+		BeforeEach(func() {
+			mintDir = filepath.Join(tmp, ".mint")
+			Expect(os.MkdirAll(mintDir, 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(original), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(mintDir, "generated.json"), []byte(`{"tasks":  [{"key": "a", "run": "echo"}]}`), 0o644)).To(Succeed())
+		})
 
-				// yaml file without tasks key is unaffected
-				contents, err = os.ReadFile(filepath.Join(mintDir, "baz.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`
-not-my-key:
-  - key: qux
-    call: mint/setup-node 1.2.3
-`))
-			})
+		It("formats files in place, preserving their comments", func() {
+			result, err := service.Format(cli.FormatConfig{MintDirectory: mintDir})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ChangedFiles).To(Equal([]string{filepath.Join(mintDir, "ci.yml")}))
 
-			It("updates base for only a targeted file", func() {
-				var err error
+			contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(formatted))
+			Expect(mockStdout.String()).To(Equal("Formatted the following files:\n\t.mint/ci.yml\n"))
+		})
 
-				originalQuxContents := `
-tasks:
-  - key: a
-  - key: b
-`
-				err = os.WriteFile(filepath.Join(mintDir, "qux.yaml"), []byte(originalQuxContents), 0o644)
-				Expect(err).NotTo(HaveOccurred())
+		It("leaves JSON files as they are", func() {
+			_, err := service.Format(cli.FormatConfig{MintDirectory: mintDir})
+			Expect(err).NotTo(HaveOccurred())
 
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{
-					Files: []string{"../.mint/bar.yaml"},
-				})
-				Expect(err).NotTo(HaveOccurred())
+			contents, err := os.ReadFile(filepath.Join(mintDir, "generated.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`{"tasks":  [{"key": "a", "run": "echo"}]}`))
+		})
 
-				var contents []byte
+		It("is idempotent", func() {
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(formatted), 0o644)).To(Succeed())
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "bar.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`base:
-  os: gentoo 99
-  tag: 1.5
+			result, err := service.Format(cli.FormatConfig{MintDirectory: mintDir})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ChangedFiles).To(BeEmpty())
+			Expect(mockStdout.String()).To(Equal("All files are formatted.\n"))
+		})
 
-tasks:
-  - key: a
-  - key: b
-`))
+		It("is idempotent with comments on reordered keys", func() {
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(`tasks:
+  # first task
+  - run: echo hi
+    # the key
+    key: build
+  - run: echo bye
+    # the use
+    use: build
+    # the other key
+    key: deploy
+`), 0o644)).To(Succeed())
+
+			_, err := service.Format(cli.FormatConfig{MintDirectory: mintDir})
+			Expect(err).NotTo(HaveOccurred())
 
-				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Updated base for the following run definitions:\n%s\n",
-					"\t../.mint/bar.yaml tag 1.1 → tag 1.5",
-				)))
+			contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`tasks:
+  # first task
+  # the key
+  - key: build
+    run: echo hi
+  # the other key
+  - key: deploy
+    # the use
+    use: build
+    run: echo bye
+`))
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "qux.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(originalQuxContents))
-			})
+			mockStdout.Reset()
+			result, err := service.Format(cli.FormatConfig{MintDirectory: mintDir, Check: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ChangedFiles).To(BeEmpty())
 		})
 
-		Context("when yaml file includes base tag newer than the server knows about", func() {
-			var originalContents string
+		It("keeps the merge keys of tasks in place", func() {
+			Expect(os.WriteFile(filepath.Join(mintDir, "ci.yml"), []byte(`x-defaults: &defaults
+  key: y
+  use: setup
 
-			BeforeEach(func() {
-				var err error
+tasks:
+  - run: echo hi
+    key: z
+    <<: *defaults
+    use: build
+    after: setup
+`), 0o644)).To(Succeed())
+
+			_, err := service.Format(cli.FormatConfig{MintDirectory: mintDir})
+			Expect(err).NotTo(HaveOccurred())
 
-				originalContents = `
-base:
-  os: gentoo 99
-  tag: 2.0
+			contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`x-defaults: &defaults
+  key: y
+  use: setup
 
 tasks:
-  - key: a
-  - key: b
-`
+  - key: z
+    run: echo hi
+    <<: *defaults
+    use: build
+    after: setup
+`))
+		})
 
-				err = os.WriteFile(filepath.Join(workingDir, "ci.yaml"), []byte(originalContents), 0o644)
+		Context("when checking", func() {
+			It("prints a diff without writing the files", func() {
+				result, err := service.Format(cli.FormatConfig{MintDirectory: mintDir, Files: []string{filepath.Join(mintDir, "ci.yml")}, Check: true})
 				Expect(err).NotTo(HaveOccurred())
-			})
+				Expect(result.ChangedFiles).To(HaveLen(1))
 
-			It("errors without changing file", func() {
-				var err error
-
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{
-					Files: []string{"ci.yaml"},
-				})
+				contents, err := os.ReadFile(filepath.Join(mintDir, "ci.yml"))
 				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(original))
 
-				var contents []byte
+				Expect(mockStdout.String()).To(HavePrefix("--- a/.mint/ci.yml\n+++ b/.mint/ci.yml\n"))
+				Expect(mockStdout.String()).To(ContainSubstring("\n-    os: \"ubuntu 24.04\"\n"))
+				Expect(mockStdout.String()).To(ContainSubstring("\n+  os: ubuntu 24.04\n"))
+				Expect(mockStdout.String()).To(HaveSuffix("\n1 file needs to be formatted. Run `mint fmt` to format them.\n"))
+			})
+		})
+	})
 
-				contents, err = os.ReadFile(filepath.Join(workingDir, "ci.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(originalContents))
+	Describe("evaluating expressions", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(tmp, ".mint"), 0o755)).To(Succeed())
+		})
 
-				Expect(mockStderr.String()).To(Equal("Unknown base tag 2 for run definitions: ci.yaml\n"))
-				Expect(mockStdout.String()).To(Equal("No run bases to update.\n"))
+		It("evaluates expressions with the given init parameters", func() {
+			result, err := service.Evaluate(cli.EvaluateConfig{
+				Expression:     "init.branch || 'main'",
+				InitParameters: map[string]string{"branch": "dev"},
 			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Value).To(Equal("dev"))
+			Expect(mockStdout.String()).To(Equal("dev\n"))
 		})
 
-		Context("when yaml file has a base with os and arch but no tag", func() {
-			BeforeEach(func() {
-				err := os.WriteFile(filepath.Join(mintDir, "ci.yaml"), []byte(`on:
-  github:
-    push: {}
+		It("interpolates strings with expressions", func() {
+			result, err := service.Evaluate(cli.EvaluateConfig{Expression: "${{ run.mint-dir }}/${{ init.file || 'ci' }}.yml"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Value).To(Equal(".mint/ci.yml"))
+		})
 
-base:
-  os: gentoo 99
-  arch: quantum
+		It("reports contexts that are only known while running", func() {
+			_, err := service.Evaluate(cli.EvaluateConfig{Expression: "tasks.build.values.version"})
+			Expect(err).To(MatchError("unable to evaluate the expression: the tasks context: not available locally"))
+		})
+	})
 
-tasks:
-  - key: a
-  - key: b
-`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
-			})
+	Describe("listing tasks", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(tmp, ".mint"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "ci.yml"), []byte(`tasks:
+  - key: node
+    call: mint/setup-node 1.3.0
+  - key: deploy
+    call: ${{ run.mint-dir }}/${{ init.target || 'staging' }}.yml
+  - key: build
+    use: node
+    run: npm run build
+`), 0o644)).To(Succeed())
+		})
 
-			It("adds tag to base", func() {
-				var err error
+		It("lists every task along with what it calls", func() {
+			result, err := service.ListTasks(cli.ListTasksConfig{InitParameters: map[string]string{"target": "production"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Tasks).To(Equal([]cli.TaskSummary{
+				{File: ".mint/ci.yml", Key: "node", Kind: cli.TaskKindLeaf, Call: "mint/setup-node 1.3.0"},
+				{File: ".mint/ci.yml", Key: "deploy", Kind: cli.TaskKindEmbeddedRun, Call: ".mint/production.yml"},
+				{File: ".mint/ci.yml", Key: "build", Kind: cli.TaskKindRun},
+			}))
+			Expect(mockStdout.String()).To(Equal(`FILE          KEY     TYPE          CALL
+.mint/ci.yml  node    leaf          mint/setup-node 1.3.0
+.mint/ci.yml  deploy  embedded run  .mint/production.yml
+.mint/ci.yml  build   run           
+`))
+		})
 
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
-				Expect(err).NotTo(HaveOccurred())
+		It("lists embedded runs it can't resolve as written", func() {
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "ci.yml"), []byte(`tasks:
+  - key: deploy
+    call: ${{ run.mint-dir }}/${{ event.git.branch }}.yml
+`), 0o644)).To(Succeed())
 
-				var contents []byte
+			result, err := service.ListTasks(cli.ListTasksConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Tasks[0].Call).To(Equal("${{ run.mint-dir }}/${{ event.git.branch }}.yml"))
+			Expect(mockStderr.String()).To(ContainSubstring(`Unable to resolve the call of task "deploy" in .mint/ci.yml`))
+		})
+	})
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "ci.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`on:
-  github:
-    push: {}
+	Describe("rendering", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(tmp, ".mint"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "ci.yml"), []byte(`base:
+  os: ubuntu 22.04
+  tag: 1.10
 
-base:
-  os: gentoo 99
-  arch: quantum
-  tag: 1.5
+tasks:
+  - key: code
+    call: mint/git-clone 1.2.3
+    with:
+      ref: ${{ init.ref }}
+  - key: deploy
+    use: code
+    call: ${{ run.mint-dir }}/deploy.yml
+    init:
+      target: ${{ init.ref }}-env
+  - key: notify
+    use: deploy
+    run: echo ${{ tasks.deploy.values.url }}
+`), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "deploy.yml"), []byte(`tasks:
+  - key: build
+    run: echo ${{ init.target }}
+  - key: ship
+    use: build
+    run: echo ship
+`), 0o644)).To(Succeed())
+		})
 
+		It("inlines embedded runs with comments pointing to their source", func() {
+			_, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml", InitParameters: map[string]string{"ref": "main"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(Equal(`base:
+  os: ubuntu 22.04
+  tag: 1.10
 tasks:
-  - key: a
-  - key: b
+  # source: .mint/ci.yml:6
+  - key: code
+    call: mint/git-clone 1.2.3
+    with:
+      ref: main
+  # source: .mint/deploy.yml:2, embedded by .mint/ci.yml:10
+  - key: deploy.build
+    use: code
+    run: echo main-env
+  # source: .mint/deploy.yml:4, embedded by .mint/ci.yml:10
+  - key: deploy.ship
+    use: deploy.build
+    run: echo ship
+  # source: .mint/ci.yml:15
+  - key: notify
+    use:
+      - deploy.build
+      - deploy.ship
+    run: echo ${{ tasks.deploy.values.url }}
 `))
+		})
 
-				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Updated base for the following run definitions:\n%s\n",
-					"\t../.mint/ci.yaml → tag 1.5",
-				)))
-			})
+		It("leaves init parameters to be given when running when there are none", func() {
+			_, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(ContainSubstring("ref: ${{ init.ref }}"))
+			Expect(mockStdout.String()).To(ContainSubstring("run: echo ${{ init.target }}"))
 		})
 
-		Context("when yaml file has base after tasks with os but no tag", func() {
+		It("renders JSON", func() {
+			result, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml", InitParameters: map[string]string{"ref": "main"}, Json: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Rendered).To(HavePrefix(`{
+  "base": {
+    "os": "ubuntu 22.04",
+    "tag": 1.10
+  },
+  "tasks": [
+    {
+      "key": "code",`))
+			Expect(result.Rendered).To(ContainSubstring(`"key": "deploy.build"`))
+		})
+
+		It("rewrites references to the tasks of embedded runs", func() {
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "deploy.yml"), []byte(`tasks:
+  - key: build
+    run: echo build
+  - key: ship
+    use: build
+    run: echo ${{ tasks.build.values.version }} ${{ tasks[ 'build' ].status }} ${{ init.target }}
+    env:
+      URL:
+        value: ${{ tasks.other.values.url }}
+`), 0o644)).To(Succeed())
+
+			_, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(ContainSubstring(`  - key: deploy.ship
+    use: deploy.build
+    run: echo ${{ tasks['deploy.build'].values.version }} ${{ tasks['deploy.build'].status }} ${{ init.target }}
+    env:
+      URL:
+        value: ${{ tasks.other.values.url }}
+`))
+			Expect(mockStdout.String()).To(ContainSubstring("run: echo ${{ tasks.deploy.values.url }}"))
+		})
+
+		Context("when the embedding task has a condition and env", func() {
 			BeforeEach(func() {
-				err := os.WriteFile(filepath.Join(mintDir, "ci.yaml"), []byte(`on:
-  github:
-    push: {}
+				Expect(os.WriteFile(filepath.Join(tmp, ".mint", "ci.yml"), []byte(`base:
+  os: ubuntu 24.04
+  tag: 1.0
 
 tasks:
-  - key: a
-  - key: b
+  - key: code
+    run: echo code
+  - key: deploy
+    use: code
+    after: code
+    call: ${{ run.mint-dir }}/deploy.yml
+    if: ${{ init.ref == 'main' }}
+    env:
+      TARGET: production
+      REGION: us-east-1
+`), 0o644)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(tmp, ".mint", "deploy.yml"), []byte(`base:
+  os: ubuntu 22.04
+  tag: 1.0
+
+env:
+  CI: "true"
 
-base:
-  os: gentoo 99`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
+tasks:
+  - key: build
+    run: echo build
+    if: ${{ tasks.code.succeeded }}
+    env:
+      TARGET: staging
+  - key: ship
+    use: build
+    run: echo ship
+`), 0o644)).To(Succeed())
 			})
 
-			It("adds tag to base without moving base before tasks", func() {
-				var err error
+			It("carries them to the tasks of the embedded run, along with its base", func() {
+				_, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml", InitParameters: map[string]string{"ref": "dev"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStdout.String()).To(ContainSubstring(`  # source: .mint/deploy.yml:9, embedded by .mint/ci.yml:8
+  - key: deploy.build
+    use: code
+    after: code
+    if: false
+    run: echo build
+    env:
+      TARGET: staging
+      REGION: us-east-1
+      CI: "true"
+    base:
+      os: ubuntu 22.04
+      tag: 1.0
+  # source: .mint/deploy.yml:14, embedded by .mint/ci.yml:8
+  - key: deploy.ship
+    use: deploy.build
+    after: code
+    if: false
+    run: echo ship
+    env:
+      TARGET: production
+      REGION: us-east-1
+      CI: "true"
+    base:
+      os: ubuntu 22.04
+      tag: 1.0
+`))
+			})
 
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
+			It("combines the conditions which are only known when running", func() {
+				_, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml"})
 				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStdout.String()).To(ContainSubstring(`if: ${{ (init.ref == 'main') && (tasks.code.succeeded) }}`))
+				Expect(mockStdout.String()).To(ContainSubstring(`if: ${{ init.ref == 'main' }}`))
+			})
 
-				var contents []byte
+			It("fails when a field of the embedding task can't be carried", func() {
+				Expect(os.WriteFile(filepath.Join(tmp, ".mint", "ci.yml"), []byte(`tasks:
+  - key: deploy
+    call: ${{ run.mint-dir }}/deploy.yml
+    timeout-minutes: 10
+`), 0o644)).To(Succeed())
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "ci.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`on:
-  github:
-    push: {}
+				_, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml"})
+				Expect(err).To(MatchError(ContainSubstring("its timeout-minutes can't be carried to the tasks of .mint/deploy.yml")))
+			})
+		})
 
-tasks:
-  - key: a
-  - key: b
+		It("fails when a run embeds itself", func() {
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "deploy.yml"), []byte(`tasks:
+  - key: again
+    call: ${{ run.mint-dir }}/ci.yml
+`), 0o644)).To(Succeed())
+
+			_, err := service.Render(cli.RenderConfig{File: ".mint/ci.yml"})
+			Expect(err).To(MatchError(ContainSubstring(".mint/ci.yml embeds itself")))
+		})
+	})
+
+	Describe("importing", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(tmp, ".github", "workflows"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".github", "workflows", "ci.yml"), []byte(`name: CI
+on:
+  push:
+    branches: [main]
+  pull_request:
+  workflow_dispatch:
+    inputs:
+      target:
+        description: Where to deploy
+        default: staging
+
+env:
+  NODE_ENV: test
+
+jobs:
+  test:
+    runs-on: ubuntu-22.04
+    steps:
+      - uses: actions/checkout@v4
+      - uses: actions/setup-node@v4
+        with:
+          node-version: 20.10
+      - uses: actions/cache@v4
+        with:
+          path: node_modules
+      - name: Install dependencies
+        run: npm ci
+      - name: Run tests
+        run: npm test -- --shard ${{ matrix.shard }}
+        env:
+          API_KEY: ${{ secrets.API_KEY }}
+  deploy:
+    needs: test
+    if: github.ref == 'refs/heads/main'
+    runs-on: ubuntu-22.04
+    steps:
+      - uses: actions/checkout@v4
+      - run: ./deploy.sh ${{ inputs.target }}
+        env:
+          TOKEN: ${{ secrets.GITHUB_TOKEN }}
+`), 0o644)).To(Succeed())
+
+			mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+				return &api.LeafVersionsResult{
+					LatestMajor: map[string]string{"mint/git-clone": "1.6.0", "mint/install-node": "1.1.0"},
+				}, nil
+			}
+			mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
+				return api.ResolveBaseLayerResult{Os: cfg.Os, Tag: "1.1", Arch: "x86_64"}, nil
+			}
+		})
+
+		It("translates workflows to run definitions", func() {
+			result, err := service.Import(cli.ImportConfig{Source: cli.ImportSourceGitHubActions, Repository: "acme/app", Vault: "ci"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ImportedFiles).To(HaveLen(1))
+			Expect(mockStdout.String()).To(Equal(`Imported .github/workflows/ci.yml to .mint/ci.yml
+
+Marked with TODO comments, as they couldn't be translated:
+  .github/workflows/ci.yml:29: translate ${{ matrix.shard }}
+`))
+
+			contents, err := os.ReadFile(filepath.Join(tmp, ".mint", "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`# Imported from .github/workflows/ci.yml
 
+on:
+  github:
+    push:
+      if: ${{ event.git.branch == 'main' }}
+      init:
+        commit-sha: ${{ event.git.sha }}
+        ref: ${{ event.git.ref }}
+    pull_request:
+      init:
+        commit-sha: ${{ event.git.sha }}
+        ref: ${{ event.git.ref }}
+  dispatch:
+    - key: ci
+      params:
+        - key: target
+          description: Where to deploy
+          default: staging
+      init:
+        commit-sha: ${{ event.git.sha }}
+        ref: ${{ event.git.ref }}
+        target: ${{ event.dispatch.params.target }}
 base:
-  os: gentoo 99
-  tag: 1.5
+  os: ubuntu 22.04
+  tag: 1.1
+tasks:
+  - key: test-checkout
+    call: mint/git-clone 1.6.0
+    with:
+      repository: https://github.com/acme/app.git
+      ref: ${{ init.commit-sha }}
+      github-access-token: ${{ github['acme/app'].token }}
+    env:
+      NODE_ENV: test
+  - key: test-setup-node
+    use: test-checkout
+    call: mint/install-node 1.1.0
+    with:
+      node-version: "20.10"
+    env:
+      NODE_ENV: test
+  # The step using actions/cache@v4 was left out: Mint caches the results of tasks
+  - key: test-install-dependencies
+    use: test-setup-node
+    run: npm ci
+    env:
+      NODE_ENV: test
+  # TODO: translate ${{ matrix.shard }}
+  - key: test-run-tests
+    use: test-install-dependencies
+    run: npm test -- --shard ${{ matrix.shard }}
+    env:
+      NODE_ENV: test
+      API_KEY: ${{ vaults.ci.secrets.API_KEY }}
+  - key: deploy-checkout
+    use: test-run-tests
+    if: ${{ init.ref == 'refs/heads/main' }}
+    call: mint/git-clone 1.6.0
+    with:
+      repository: https://github.com/acme/app.git
+      ref: ${{ init.commit-sha }}
+      github-access-token: ${{ github['acme/app'].token }}
+    env:
+      NODE_ENV: test
+  - key: deploy-step-2
+    use: deploy-checkout
+    if: ${{ init.ref == 'refs/heads/main' }}
+    run: ./deploy.sh ${{ init.target }}
+    env:
+      NODE_ENV: test
+      TOKEN: ${{ github['acme/app'].token }}
 `))
+		})
+
+		It("marks the repository to clone as a TODO when it isn't given", func() {
+			_, err := service.Import(cli.ImportConfig{Source: cli.ImportSourceGitHubActions})
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := os.ReadFile(filepath.Join(tmp, ".mint", "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("# TODO: replace YOUR_ORG/YOUR_REPO with the repository to clone"))
+			Expect(string(contents)).To(ContainSubstring("API_KEY: ${{ vaults.default.secrets.API_KEY }}"))
+		})
+
+		It("marks leaves as TODOs when their versions are unknown", func() {
+			mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+				return &api.LeafVersionsResult{LatestMajor: map[string]string{}}, nil
+			}
+
+			_, err := service.Import(cli.ImportConfig{Source: cli.ImportSourceGitHubActions, Repository: "acme/app"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mockStdout.String()).To(ContainSubstring("  .github/workflows/ci.yml:19: pick a version of mint/git-clone, eg. with `mint resolve leaves`\n"))
+
+			contents, err := os.ReadFile(filepath.Join(tmp, ".mint", "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("  # TODO: pick a version of mint/install-node, eg. with `mint resolve leaves`\n"))
+		})
 
-				Expect(mockStdout.String()).To(Equal(fmt.Sprintf(
-					"Updated base for the following run definitions:\n%s\n",
-					"\t../.mint/ci.yaml → tag 1.5",
-				)))
-			})
+		It("doesn't overwrite run definitions unless forced to", func() {
+			Expect(os.MkdirAll(filepath.Join(tmp, ".mint"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "ci.yml"), []byte("tasks: []\n"), 0o644)).To(Succeed())
+
+			_, err := service.Import(cli.ImportConfig{Source: cli.ImportSourceGitHubActions})
+			Expect(err).To(MatchError(".mint/ci.yml already exists. Use --force to overwrite it."))
+
+			_, err = service.Import(cli.ImportConfig{Source: cli.ImportSourceGitHubActions, Force: true})
+			Expect(err).NotTo(HaveOccurred())
 		})
 
-		Context("with multiple yaml files", func() {
+		Context("from GitLab", func() {
 			BeforeEach(func() {
-				var err error
+				Expect(os.WriteFile(filepath.Join(tmp, ".gitlab-ci.yml"), []byte(`stages: [build, test]
+
+variables:
+  NODE_ENV: test
+
+.node:
+  image: node:20.10-alpine
+  before_script:
+    - npm ci
+  cache:
+    paths: [node_modules]
+
+build:
+  extends: .node
+  stage: build
+  script: npm run build -- --sha $CI_COMMIT_SHA
+  artifacts:
+    paths: [dist]
+
+lint:
+  extends: .node
+  stage: build
+  script: npm run lint
+
+test:
+  extends: .node
+  script:
+    - npm test
+  timeout: 1h 30m
+  artifacts:
+    reports:
+      junit: junit.xml
+
+deploy:
+  stage: test
+  needs: [build]
+  image: alpine:3.19
+  script: ./deploy.sh $CI_ENVIRONMENT_NAME
+  rules:
+    - if: $CI_COMMIT_BRANCH == "main"
+`), 0o644)).To(Succeed())
+			})
 
-				err = os.WriteFile(filepath.Join(mintDir, "one.yaml"), []byte(`base:
-  os: gentoo 99
-  tag: 1.1
+			It("translates stages and jobs to tasks", func() {
+				_, err := service.Import(cli.ImportConfig{Source: cli.ImportSourceGitLab, Repository: "acme/app", Vault: "ci"})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStdout.String()).To(Equal(`Imported .gitlab-ci.yml to .mint/gitlab-ci.yml
+
+Marked with TODO comments, as they couldn't be translated:
+  .gitlab-ci.yml: trigger the run definition on the events which ran the pipeline, passing init parameters such as commit-sha
+  .gitlab-ci.yml:31: translate the reports of the job "test"
+  .gitlab-ci.yml:37: the job "deploy" runs in the image alpine:3.19, which isn't translated
+  .gitlab-ci.yml:38: translate $CI_ENVIRONMENT_NAME
+  .gitlab-ci.yml:39: translate the rules of the job "deploy"
+`))
 
-tasks:
-  - key: a
-  - key: b
-`), 0o644)
+				contents, err := os.ReadFile(filepath.Join(tmp, ".mint", "gitlab-ci.yml"))
 				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(Equal(`# Imported from .gitlab-ci.yml
+# TODO: trigger the run definition on the events which ran the pipeline, passing init parameters such as commit-sha
 
-				err = os.WriteFile(filepath.Join(mintDir, "two.yaml"), []byte(`base:
-  os: gentoo 88
+base:
+  os: ""
+  tag: 1.1
 
 tasks:
-  - key: c
-  - key: d
-`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
-
-				err = os.WriteFile(filepath.Join(mintDir, "three.yaml"), []byte(`tasks:
-  - key: e
-  - key: f
-`), 0o644)
-				Expect(err).NotTo(HaveOccurred())
+  # Add a deploy key of the repository to the vault ci as the secret GITLAB_SSH_KEY
+  - key: code
+    call: mint/git-clone 1.6.0
+    with:
+      repository: git@gitlab.com:acme/app.git
+      ref: ${{ init.commit-sha }}
+      ssh-key: ${{ vaults.ci.secrets.GITLAB_SSH_KEY }}
+  - key: install-node
+    call: mint/install-node 1.1.0
+    with:
+      node-version: "20.10"
+  # The cache of the job was left out: Mint caches the results of tasks
+  # The artifacts of the job were left out: tasks have the files of the tasks they use
+  - key: build
+    use:
+      - code
+      - install-node
+    run: |-
+      npm ci
+      npm run build -- --sha ${{ init.commit-sha }}
+    env:
+      NODE_ENV: test
+  # The cache of the job was left out: Mint caches the results of tasks
+  - key: lint
+    use:
+      - code
+      - install-node
+    run: |-
+      npm ci
+      npm run lint
+    env:
+      NODE_ENV: test
+  # The cache of the job was left out: Mint caches the results of tasks
+  # TODO: translate the reports of the job "test"
+  - key: test
+    use:
+      - build
+      - lint
+      - install-node
+    run: |-
+      npm ci
+      npm test
+    env:
+      NODE_ENV: test
+    timeout-minutes: 90
+  # TODO: translate $CI_ENVIRONMENT_NAME
+  # TODO: the job "deploy" runs in the image alpine:3.19, which isn't translated
+  # TODO: translate the rules of the job "deploy"
+  - key: deploy
+    use: build
+    run: ./deploy.sh $CI_ENVIRONMENT_NAME
+    env:
+      NODE_ENV: test
+`))
 			})
+		})
 
-			It("updates all files", func() {
-				var err error
+		Context("from CircleCI", func() {
+			BeforeEach(func() {
+				Expect(os.MkdirAll(filepath.Join(tmp, ".circleci"), 0o755)).To(Succeed())
+				Expect(os.WriteFile(filepath.Join(tmp, ".circleci", "config.yml"), []byte(`version: 2.1
+
+orbs:
+  slack: circleci/slack@4.12
+
+executors:
+  node:
+    docker:
+      - image: cimg/node:20.10
+      - image: cimg/postgres:16.1
+    environment:
+      NODE_ENV: test
+
+commands:
+  install:
+    steps:
+      - restore_cache:
+          key: deps-{{ checksum "package-lock.json" }}
+      - run: npm ci
+
+jobs:
+  test:
+    executor: node
+    steps:
+      - checkout
+      - install
+      - run:
+          name: Run tests
+          command: npm test
+          environment:
+            COMMIT: << pipeline.git.revision >>
+      - store_test_results:
+          path: results
+  deploy:
+    docker:
+      - image: cimg/base:2024.01
+    steps:
+      - checkout
+      - run: ./deploy.sh $CIRCLE_BRANCH
+      - slack/notify
+
+workflows:
+  main:
+    jobs:
+      - test
+      - deploy:
+          requires: [test]
+          context: production
+          filters:
+            branches:
+              only: main
+`), 0o644)).To(Succeed())
+			})
 
-				_, err = service.UpdateBase(cli.UpdateBaseConfig{})
+			It("translates workflows and steps to tasks", func() {
+				_, err := service.Import(cli.ImportConfig{Source: cli.ImportSourceCircleCI, Repository: "acme/app", Vault: "ci"})
 				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStdout.String()).To(Equal(`Imported .circleci/config.yml to .mint/circleci.yml
 
-				var contents []byte
+Marked with TODO comments, as they couldn't be translated:
+  .circleci/config.yml:10: translate the service container cimg/postgres:16.1 of the job "test"
+  .circleci/config.yml:32: translate the step store_test_results
+  .circleci/config.yml:40: translate the step slack/notify
+  .circleci/config.yml:48: add the secrets of the context production to the vault ci, and set them in env
+`))
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "one.yaml"))
+				contents, err := os.ReadFile(filepath.Join(tmp, ".mint", "circleci.yml"))
 				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`base:
-  os: gentoo 99
-  tag: 1.5
+				Expect(string(contents)).To(Equal(`# Imported from .circleci/config.yml
+
+on:
+  github:
+    push:
+      init:
+        commit-sha: ${{ event.git.sha }}
+        branch: ${{ event.git.branch }}
+base:
+  os: ""
+  tag: 1.1
 
 tasks:
-  - key: a
-  - key: b
+  - key: install-node
+    call: mint/install-node 1.1.0
+    with:
+      node-version: "20.10"
+  # TODO: translate the service container cimg/postgres:16.1 of the job "test"
+  - key: test-checkout
+    use: install-node
+    call: mint/git-clone 1.6.0
+    with:
+      repository: https://github.com/acme/app.git
+      ref: ${{ init.commit-sha }}
+      github-access-token: ${{ github['acme/app'].token }}
+  # The restore_cache step was left out: Mint caches the results of tasks
+  - key: test-npm-ci
+    use: test-checkout
+    run: npm ci
+    env:
+      NODE_ENV: test
+  - key: test-run-tests
+    use: test-npm-ci
+    run: npm test
+    env:
+      NODE_ENV: test
+      COMMIT: ${{ init.commit-sha }}
+  # TODO: translate the step store_test_results
+  - key: test-store_test_results
+    use: test-run-tests
+    run: echo 'Translate the step store_test_results' && exit 1
+    env:
+      NODE_ENV: test
+  # TODO: add the secrets of the context production to the vault ci, and set them in env
+  - key: deploy-checkout
+    use: test-store_test_results
+    if: ${{ init.branch == 'main' }}
+    call: mint/git-clone 1.6.0
+    with:
+      repository: https://github.com/acme/app.git
+      ref: ${{ init.commit-sha }}
+      github-access-token: ${{ github['acme/app'].token }}
+  - key: deploy-deploy-sh-circle_branch
+    use: deploy-checkout
+    if: ${{ init.branch == 'main' }}
+    run: ./deploy.sh ${{ init.branch }}
+  # TODO: translate the step slack/notify
+  - key: deploy-slack-notify
+    use: deploy-deploy-sh-circle_branch
+    if: ${{ init.branch == 'main' }}
+    run: echo 'Translate the step slack/notify' && exit 1
 `))
+			})
+		})
+	})
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "two.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`base:
-  os: gentoo 88
-  tag: 1.5
+	Describe("initializing", func() {
+		BeforeEach(func() {
+			mockAPI.MockGetLeafVersions = func() (*api.LeafVersionsResult, error) {
+				return &api.LeafVersionsResult{
+					LatestMajor: map[string]string{"mint/git-clone": "1.6.0", "mint/install-go": "1.2.0", "mint/install-node": "1.1.0"},
+				}, nil
+			}
+			mockAPI.MockResolveBaseLayer = func(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error) {
+				return api.ResolveBaseLayerResult{Os: "ubuntu 24.04", Tag: "1.1", Arch: "x86_64"}, nil
+			}
+		})
 
-tasks:
-  - key: c
-  - key: d
+		It("writes a run definition for the languages of the project", func() {
+			Expect(os.MkdirAll(filepath.Join(tmp, ".git"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".git", "config"), []byte("[core]\n\tbare = false\n[remote \"origin\"]\n\turl = git@github.com:acme/app.git\n"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, "go.mod"), []byte("module github.com/acme/app\n\ngo 1.23.1\n"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, "go.sum"), []byte(""), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, "package.json"), []byte(`{"scripts": {"test": "jest"}}`), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, "package-lock.json"), []byte("{}"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".nvmrc"), []byte("v20.10.0\n"), 0o644)).To(Succeed())
+
+			result, err := service.Init(cli.InitConfig{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Languages).To(Equal([]string{"Go", "Node.js"}))
+			Expect(mockStdout.String()).To(Equal(`Detected Go, Node.js
+Created .mint/ci.yml
+Configured .mint/ci.yml to run on ubuntu 24.04
+Configured leaf mint/git-clone to use version 1.6.0
+Configured leaf mint/install-go to use version 1.2.0
+Configured leaf mint/install-node to use version 1.1.0
+
+Run it with ` + "`mint run --file .mint/ci.yml`" + `
 `))
 
-				contents, err = os.ReadFile(filepath.Join(mintDir, "three.yaml"))
-				Expect(err).NotTo(HaveOccurred())
-				Expect(string(contents)).To(Equal(`base:
-  os: gentoo 99
-  tag: 1.5
+			contents, err := os.ReadFile(filepath.Join(tmp, ".mint", "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(Equal(`on:
+  github:
+    push:
+      init:
+        commit-sha: ${{ event.git.sha }}
+base:
+  os: ubuntu 24.04
+  tag: 1.1
 
 tasks:
-  - key: e
-  - key: f
+  - key: code
+    call: mint/git-clone 1.6.0
+    with:
+      repository: https://github.com/acme/app.git
+      ref: ${{ init.commit-sha }}
+      github-access-token: ${{ github['acme/app'].token }}
+  - key: go
+    call: mint/install-go 1.2.0
+    with:
+      go-version: 1.23.1
+  # Dependencies are installed from these files alone, so that they're cached until the files change
+  - key: go-deps
+    use:
+      - code
+      - go
+    run: go mod download
+    filter:
+      - go.mod
+      - go.sum
+  - key: go-test
+    use:
+      - code
+      - go-deps
+    run: go test ./...
+  - key: node
+    call: mint/install-node 1.1.0
+    with:
+      node-version: 20.10.0
+  # Dependencies are installed from these files alone, so that they're cached until the files change
+  - key: node-deps
+    use:
+      - code
+      - node
+    run: npm ci
+    filter:
+      - package.json
+      - package-lock.json
+  - key: node-test
+    use:
+      - code
+      - node-deps
+    run: npm test
 `))
+		})
 
-				Expect(mockStdout.String()).To(ContainSubstring("Updated base for the following run definitions:"))
-				Expect(mockStdout.String()).To(ContainSubstring("\t../.mint/one.yaml tag 1.1 → tag 1.5"))
-				Expect(mockStdout.String()).To(ContainSubstring("\t../.mint/two.yaml → tag 1.5"))
-				Expect(mockStdout.String()).To(ContainSubstring("\t../.mint/three.yaml → tag 1.5"))
-			})
-
-			Context("when an API request fails", func() {
-				It("doesn't update any files", func() {
-					contentsOne, err := os.ReadFile(filepath.Join(mintDir, "one.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					contentsTwo, err := os.ReadFile(filepath.Join(mintDir, "two.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					contentsThree, err := os.ReadFile(filepath.Join(mintDir, "three.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-
-					apiError = func(callCount int) error {
-						if callCount == 2 {
-							return errors.New("API request failed")
-						}
-						return nil
-					}
-
-					_, err = service.UpdateBase(cli.UpdateBaseConfig{})
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(ContainSubstring("API request failed"))
+		It("writes a run definition to complete when no language is detected", func() {
+			_, err := service.Init(cli.InitConfig{})
+			Expect(err).NotTo(HaveOccurred())
 
-					var contents []byte
+			contents, err := os.ReadFile(filepath.Join(tmp, ".mint", "ci.yml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(ContainSubstring("# TODO: replace YOUR_ORG/YOUR_REPO with the repository to clone"))
+			Expect(string(contents)).To(ContainSubstring("# TODO: build and test the project"))
+		})
 
-					contents, err = os.ReadFile(filepath.Join(mintDir, "one.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(Equal(string(contentsOne)))
+		It("doesn't overwrite the run definition unless forced to", func() {
+			Expect(os.MkdirAll(filepath.Join(tmp, ".mint"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(tmp, ".mint", "ci.yml"), []byte("tasks: []\n"), 0o644)).To(Succeed())
 
-					contents, err = os.ReadFile(filepath.Join(mintDir, "two.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(Equal(string(contentsTwo)))
+			_, err := service.Init(cli.InitConfig{})
+			Expect(err).To(MatchError(".mint/ci.yml already exists. Use --force to overwrite it."))
 
-					contents, err = os.ReadFile(filepath.Join(mintDir, "three.yaml"))
-					Expect(err).NotTo(HaveOccurred())
-					Expect(string(contents)).To(Equal(string(contentsThree)))
-				})
-			})
+			_, err = service.Init(cli.InitConfig{Force: true})
+			Expect(err).NotTo(HaveOccurred())
 		})
 	})
 
@@ -3558,6 +6450,353 @@ Checked 2 files and found 4 problems.
 				Expect(lintedDefinitions[0].Path).To(Equal(".mint/base.yml"))
 				Expect(lintedDefinitions[1].Path).To(Equal(".mint/some/nested/dir/tasks.yml"))
 			})
+
+			It("does not lint the update policy", func() {
+				Expect(os.WriteFile(".mint/update.yml", []byte("ignore: [mint/setup-node]"), 0o644)).NotTo(HaveOccurred())
+
+				_, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lintedDefinitions).To(HaveLen(2))
+			})
+		})
+
+		Context("with leaf parameters", func() {
+			var requestedLeaves []api.GetLeafConfig
+
+			BeforeEach(func() {
+				requestedLeaves = nil
+
+				mockAPI.MockLint = func(cfg api.LintConfig) (*api.LintResult, error) {
+					return &api.LintResult{Problems: []api.LintProblem{}}, nil
+				}
+				mockAPI.MockGetLeaf = func(cfg api.GetLeafConfig) (*api.LeafDetails, error) {
+					requestedLeaves = append(requestedLeaves, cfg)
+					switch cfg.Name {
+					case "mint/install-node":
+						return &api.LeafDetails{
+							Name:    cfg.Name,
+							Version: cfg.Version,
+							Parameters: []api.LeafParameter{
+								{Name: "node-version", Required: true},
+								{Name: "cache"},
+							},
+						}, nil
+					case "mint/git-clone":
+						return nil, errors.Wrapf(errors.ErrNotFound, "leaf %q", cfg.Name)
+					default:
+						return nil, errors.New("registry unavailable")
+					}
+				}
+
+				Expect(os.WriteFile(".mint/ci.yml", []byte(`tasks:
+  - key: node
+    call: mint/install-node 1.2.3
+    with:
+      node-verison: 20
+      cache: true
+  - key: node-again
+    call: mint/install-node 1.2.3
+    with:
+      node-version: 20
+  - key: node-expression
+    call: mint/install-node 1.2.3
+    with: ${{ init.params }}
+  - key: clone
+    call: mint/git-clone 1.0.0
+    with:
+      anything: goes
+  - key: unversioned
+    call: mint/install-node
+`), 0o644)).NotTo(HaveOccurred())
+
+				Expect(os.WriteFile(".mint/tasks.yml", []byte(`- key: go
+  call: mint/install-go 1.0.0
+- key: node
+  call: mint/install-node 1.2.3
+`), 0o644)).NotTo(HaveOccurred())
+			})
+
+			It("reports unknown and missing required parameters", func() {
+				lintResult, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(lintResult.Problems).To(Equal([]api.LintProblem{
+					{
+						Severity: "error",
+						Message:  `Leaf mint/install-node 1.2.3 does not have a parameter "node-verison"`,
+						FileName: ".mint/ci.yml",
+						Line:     api.NewNullInt(5),
+						Column:   api.NewNullInt(7),
+						Advice:   "Available parameters: node-version, cache.",
+					},
+					{
+						Severity: "error",
+						Message:  `Leaf mint/install-node 1.2.3 is missing the required parameter "node-version"`,
+						FileName: ".mint/ci.yml",
+						Line:     api.NewNullInt(3),
+						Column:   api.NewNullInt(11),
+						Advice:   "Add it to the `with` of this task.",
+					},
+					{
+						Severity: "error",
+						Message:  `Leaf mint/install-node 1.2.3 is missing the required parameter "node-version"`,
+						FileName: ".mint/tasks.yml",
+						Line:     api.NewNullInt(4),
+						Column:   api.NewNullInt(9),
+						Advice:   "Add it to the `with` of this task.",
+					},
+				}))
+			})
+
+			It("fetches each leaf version once and warns about leaves it can't fetch", func() {
+				_, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(requestedLeaves).To(ConsistOf(
+					api.GetLeafConfig{Name: "mint/install-node", Version: "1.2.3"},
+					api.GetLeafConfig{Name: "mint/git-clone", Version: "1.0.0"},
+					api.GetLeafConfig{Name: "mint/install-go", Version: "1.0.0"},
+				))
+				Expect(mockStderr.String()).To(Equal("Unable to validate the parameters of leaf mint/install-go 1.0.0: registry unavailable\n"))
+			})
+		})
+
+		Context("with embedded runs", func() {
+			BeforeEach(func() {
+				mockAPI.MockLint = func(cfg api.LintConfig) (*api.LintResult, error) {
+					return &api.LintResult{Problems: []api.LintProblem{}}, nil
+				}
+
+				Expect(os.WriteFile(".mint/embed.yml", []byte("tasks:\n  - key: a\n    run: echo a\n"), 0o644)).NotTo(HaveOccurred())
+				Expect(os.WriteFile(".mint/ci.yml", []byte(`tasks:
+  - key: present
+    call: ${{ run.mint-dir }}/embed.yml
+  - key: missing
+    call: ${{ run.mint-dir }}/missing.yml
+  - key: parameterized
+    call: ${{ run.mint-dir }}/${{ init.file }}
+  - key: invalid
+    call: ${{ run.mint-dir ) }}/embed.yml
+`), 0o644)).NotTo(HaveOccurred())
+			})
+
+			It("reports embedded runs that don't exist", func() {
+				lintResult, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(lintResult.Problems).To(Equal([]api.LintProblem{
+					{
+						Severity: "error",
+						Message:  "Embedded run .mint/missing.yml does not exist",
+						FileName: ".mint/ci.yml",
+						Line:     api.NewNullInt(5),
+						Column:   api.NewNullInt(11),
+						Advice:   `"${{ run.mint-dir }}/missing.yml" resolves to .mint/missing.yml.`,
+					},
+					{
+						Severity: "error",
+						Message:  `Invalid expression in call "${{ run.mint-dir ) }}/embed.yml"`,
+						FileName: ".mint/ci.yml",
+						Line:     api.NewNullInt(9),
+						Column:   api.NewNullInt(11),
+						Advice:   `unable to evaluate "run.mint-dir )": unexpected ")" at position 15`,
+					},
+				}))
+			})
+		})
+
+		Context("with leaves that only support some architectures", func() {
+			BeforeEach(func() {
+				mockAPI.MockLint = func(cfg api.LintConfig) (*api.LintResult, error) {
+					return &api.LintResult{Problems: []api.LintProblem{}}, nil
+				}
+				mockAPI.MockGetLeaf = func(cfg api.GetLeafConfig) (*api.LeafDetails, error) {
+					if cfg.Name == "mint/install-x86-tool" {
+						return &api.LeafDetails{Name: cfg.Name, Version: cfg.Version, Arches: []string{"x86_64"}}, nil
+					}
+					return &api.LeafDetails{Name: cfg.Name, Version: cfg.Version}, nil
+				}
+
+				Expect(os.WriteFile(".mint/arm.yml", []byte(`base:
+  os: ubuntu 24.04
+  tag: 1.0
+  arch: arm64
+
+tasks:
+  - key: tool
+    call: mint/install-x86-tool 1.0.0
+  - key: node
+    call: mint/install-node 1.2.3
+`), 0o644)).NotTo(HaveOccurred())
+
+				Expect(os.WriteFile(".mint/x86.yml", []byte(`base:
+  os: ubuntu 24.04
+  tag: 1.0
+
+tasks:
+  - key: tool
+    call: mint/install-x86-tool 1.0.0
+`), 0o644)).NotTo(HaveOccurred())
+			})
+
+			It("reports leaves that do not support the architecture of the run", func() {
+				lintResult, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(lintResult.Problems).To(Equal([]api.LintProblem{
+					{
+						Severity: "error",
+						Message:  "Leaf mint/install-x86-tool 1.0.0 does not support the arm64 architecture",
+						FileName: ".mint/arm.yml",
+						Line:     api.NewNullInt(8),
+						Column:   api.NewNullInt(11),
+						Advice:   "Supported architectures: x86_64.",
+					},
+				}))
+			})
+		})
+
+		Context("with deprecated base layers", func() {
+			var listCalls int
+
+			BeforeEach(func() {
+				listCalls = 0
+
+				mockAPI.MockLint = func(cfg api.LintConfig) (*api.LintResult, error) {
+					return &api.LintResult{Problems: []api.LintProblem{}}, nil
+				}
+				mockAPI.MockListBaseLayers = func() (*api.ListBaseLayersResult, error) {
+					listCalls++
+					return &api.ListBaseLayersResult{
+						BaseLayers: []api.BaseLayer{
+							{Os: "ubuntu 24.04", Tag: "1.0", Default: true},
+							{Os: "ubuntu 22.04", Tag: "1.1"},
+							{Os: "ubuntu 22.04", Tag: "1.0", DeprecatedOn: "2020-01-01", EndOfLifeOn: "2999-06-30"},
+							{Os: "ubuntu 20.04", Tag: "1.0", DeprecatedOn: "2020-01-01", EndOfLifeOn: "2021-06-30"},
+						},
+					}, nil
+				}
+
+				Expect(os.WriteFile(".mint/ci.yml", []byte(`base:
+  os: ubuntu 22.04
+  tag: 1.0
+
+tasks:
+  - key: a
+    run: echo a
+`), 0o644)).NotTo(HaveOccurred())
+
+				Expect(os.WriteFile(".mint/old.yml", []byte(`base:
+  os: ubuntu 20.04
+  tag: 1.0
+
+tasks:
+  - key: a
+    run: echo a
+`), 0o644)).NotTo(HaveOccurred())
+
+				Expect(os.WriteFile(".mint/current.yml", []byte(`base:
+  os: ubuntu 24.04
+  tag: 1.0
+
+tasks:
+  - key: a
+    run: echo a
+`), 0o644)).NotTo(HaveOccurred())
+			})
+
+			It("warns about bases approaching or past their end of life", func() {
+				_, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(mockStderr.String()).To(ContainSubstring(`The base layer of .mint/ci.yml (ubuntu 22.04, tag 1.0) is deprecated and reaches its end of life on 2999-06-30.
+
+You can update it with:
+    mint update base .mint/ci.yml
+`))
+				Expect(mockStderr.String()).To(ContainSubstring(`The base layer of .mint/old.yml (ubuntu 20.04, tag 1.0) reached its end of life on 2021-06-30.
+
+You can update it with:
+    mint update base --os "ubuntu 24.04" .mint/old.yml
+`))
+				Expect(mockStderr.String()).NotTo(ContainSubstring("current.yml"))
+			})
+
+			It("only warns once", func() {
+				_, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(strings.Count(mockStderr.String(), "The base layer of .mint/ci.yml")).To(Equal(1))
+				Expect(listCalls).To(Equal(1))
+			})
+
+			It("doesn't warn when the base layers can't be fetched", func() {
+				mockAPI.MockListBaseLayers = func() (*api.ListBaseLayersResult, error) {
+					return nil, errors.New("offline")
+				}
+
+				_, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mockStderr.String()).To(BeEmpty())
+			})
+		})
+
+		Context("with leaves pinned to digests", func() {
+			var pinnedDigest string
+
+			BeforeEach(func() {
+				pinnedDigest = "sha256:" + strings.Repeat("a", 64)
+
+				mockAPI.MockLint = func(cfg api.LintConfig) (*api.LintResult, error) {
+					return &api.LintResult{Problems: []api.LintProblem{}}, nil
+				}
+				mockAPI.MockGetLeaf = func(cfg api.GetLeafConfig) (*api.LeafDetails, error) {
+					return &api.LeafDetails{Name: cfg.Name, Version: cfg.Version}, nil
+				}
+				mockAPI.MockResolveLeafDigests = func(cfg api.ResolveLeafDigestsConfig) (*api.ResolveLeafDigestsResult, error) {
+					Expect(cfg.Leaves).To(ConsistOf(
+						api.LeafReference{Name: "mint/install-node", Version: "1.2.3"},
+						api.LeafReference{Name: "mint/install-go", Version: "1.0.0"},
+					))
+					return &api.ResolveLeafDigestsResult{Leaves: []api.LeafDigest{
+						{Name: "mint/install-node", Version: "1.2.3", Digest: "sha256:" + strings.Repeat("b", 64)},
+					}}, nil
+				}
+
+				Expect(os.WriteFile(".mint/ci.yml", []byte(`tasks:
+  - key: node
+    call: mint/install-node 1.2.3@`+pinnedDigest+`
+  - key: go
+    call: mint/install-go 1.0.0@`+pinnedDigest+`
+  - key: ruby
+    call: mint/install-ruby 1.0.0
+`), 0o644)).NotTo(HaveOccurred())
+			})
+
+			It("reports leaves whose content changed in the registry", func() {
+				lintResult, err := service.Lint(lintConfig)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(lintResult.Problems).To(Equal([]api.LintProblem{
+					{
+						Severity: "error",
+						Message:  "Leaf mint/install-node 1.2.3 has digest sha256:" + strings.Repeat("b", 64) + " in the registry, but is pinned to " + pinnedDigest,
+						FileName: ".mint/ci.yml",
+						Line:     api.NewNullInt(3),
+						Column:   api.NewNullInt(11),
+						Advice:   "The leaf's contents changed in the registry since it was pinned. Verify the change and run `mint resolve leaves --pin-digests` to accept it.",
+					},
+					{
+						Severity: "error",
+						Message:  "Leaf mint/install-go 1.0.0 could not be found in the registry",
+						FileName: ".mint/ci.yml",
+						Line:     api.NewNullInt(5),
+						Column:   api.NewNullInt(11),
+					},
+				}))
+			})
 		})
 	})
 })
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+	"github.com/rwx-research/mint-cli/internal/expressions"
+)
+
+const (
+	TaskKindRun         = "run"
+	TaskKindLeaf        = "leaf"
+	TaskKindEmbeddedRun = "embedded run"
+)
+
+// expressionContext returns the contexts known locally. The init context is only available
+// when init parameters are given; the run context only knows the Mint directory.
+func expressionContext(mintDirectoryPath string, initParameters map[string]string) expressions.Context {
+	ctx := expressions.Context{}
+	if initParameters != nil {
+		ctx["init"] = initParameters
+	}
+	if mintDirectoryPath != "" {
+		ctx["run"] = map[string]any{"mint-dir": relativePathFromWd(mintDirectoryPath)}
+	}
+	return ctx
+}
+
+// isEmbeddedRunCall reports whether a call refers to a run definition rather than a leaf, eg.
+// `call: ${{ run.mint-dir }}/embed.yml`.
+func isEmbeddedRunCall(call string) bool {
+	return expressions.ContainsExpression(call) || strings.HasSuffix(call, ".yml") || strings.HasSuffix(call, ".yaml")
+}
+
+// listTasks summarizes the tasks of every document of a file, in order. Embedded run calls are
+// evaluated with the given contexts; calls that can't be evaluated are listed as written.
+func (s Service) listTasks(entry MintDirectoryEntry, ctx expressions.Context) ([]TaskSummary, error) {
+	doc, err := ParseYAMLDoc(entry.FileContents)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %q", entry.OriginalPath)
+	}
+
+	tasks := make([]TaskSummary, 0)
+	for _, document := range doc.Documents() {
+		path := tasksPath(document)
+		if path == "" {
+			continue
+		}
+
+		node, err := document.getNodeAtPath(path)
+		if err != nil {
+			continue
+		}
+		seqNode, ok := node.(*ast.SequenceNode)
+		if !ok {
+			continue
+		}
+
+		for i := range seqNode.Values {
+			taskPath := fmt.Sprintf("%s[%d]", path, i)
+			task := TaskSummary{
+				File: relativePathFromWd(entry.OriginalPath),
+				Key:  document.TryReadStringAtPath(taskPath + ".key"),
+			}
+
+			switch call := document.TryReadStringAtPath(taskPath + ".call"); {
+			case call != "" && isEmbeddedRunCall(call):
+				task.Kind = TaskKindEmbeddedRun
+				task.Call = call
+				if resolved, err := expressions.Interpolate(call, ctx); err == nil {
+					task.Call = resolved
+				} else {
+					fmt.Fprintf(s.Stderr, "Unable to resolve the call of task %q in %s: %s\n", task.Key, task.File, err)
+				}
+			case call != "":
+				task.Kind = TaskKindLeaf
+				task.Call = call
+			case document.hasPath(taskPath + ".run"):
+				task.Kind = TaskKindRun
+			}
+
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks, nil
+}
+
+func outputTasks(w io.Writer, tasks []TaskSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tKEY\tTYPE\tCALL")
+	for _, task := range tasks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", task.File, task.Key, task.Kind, task.Call)
+	}
+	tw.Flush()
+}
+
+// validateEmbeddedRunCalls reports calls of embedded runs that don't exist. Calls referring to
+// contexts that are only known while running, such as init parameters, aren't checked.
+func (s Service) validateEmbeddedRunCalls(mintDirectoryPath string, mintFiles []*MintYAMLFile) []api.LintProblem {
+	problems := make([]api.LintProblem, 0)
+	ctx := expressionContext(mintDirectoryPath, nil)
+
+	for _, file := range mintFiles {
+		nodePath := leafCallsPath(file.Doc)
+		if nodePath == "" {
+			continue
+		}
+
+		_ = file.Doc.ForEachNode(nodePath, func(callNode ast.Node) error {
+			call := scalarText(callNode)
+			if !expressions.ContainsExpression(call) {
+				return nil
+			}
+
+			resolved, err := expressions.Interpolate(call, ctx)
+			if errors.Is(err, expressions.ErrUnavailable) {
+				return nil
+			} else if err != nil {
+				problems = append(problems, lintProblemAt(file, callNode, "error", fmt.Sprintf("Invalid expression in call %q", call), err.Error()))
+				return nil
+			}
+
+			if _, err := os.Stat(resolved); errors.Is(err, os.ErrNotExist) {
+				problems = append(problems, lintProblemAt(
+					file,
+					callNode,
+					"error",
+					fmt.Sprintf("Embedded run %s does not exist", resolved),
+					fmt.Sprintf("%q resolves to %s.", call, resolved),
+				))
+			}
+			return nil
+		})
+	}
+
+	return problems
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/rwx-research/mint-cli/internal/diff"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+const (
+	PlannedChangeKindBase = "base"
+	PlannedChangeKindLeaf = "leaf"
+)
+
+// UpdatePlan collects the changes an update would make to run definitions while keeping the
+// modified files in memory instead of writing them.
+type UpdatePlan struct {
+	Changes []PlannedChange
+
+	files     map[string]*plannedFile
+	fileOrder []string
+}
+
+type PlannedChange struct {
+	File string `json:"file"`
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+type plannedFile struct {
+	original string
+	updated  string
+}
+
+func NewUpdatePlan() *UpdatePlan {
+	return &UpdatePlan{
+		Changes: make([]PlannedChange, 0),
+		files:   make(map[string]*plannedFile),
+	}
+}
+
+func (p *UpdatePlan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+func (p *UpdatePlan) addChange(originalPath string, yamlPath string, from string, to string, kind string) {
+	p.Changes = append(p.Changes, PlannedChange{
+		File: relativePathFromWd(originalPath),
+		Path: yamlPath,
+		From: from,
+		To:   to,
+		Kind: kind,
+	})
+}
+
+// readFile returns the planned contents of the file at path, falling back to its contents on disk.
+func (p *UpdatePlan) readFile(path string) (string, error) {
+	if file, ok := p.files[path]; ok {
+		return file.updated, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (p *UpdatePlan) writeFile(path string, content string) error {
+	if file, ok := p.files[path]; ok {
+		file.updated = content
+		return nil
+	}
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to read %q", path)
+	}
+
+	p.files[path] = &plannedFile{original: string(original), updated: content}
+	p.fileOrder = append(p.fileOrder, path)
+	return nil
+}
+
+// applyTo replaces the contents of the given entries with their planned contents, so that
+// subsequent steps of an update build on the changes of previous ones.
+func (p *UpdatePlan) applyTo(entries []MintDirectoryEntry) []MintDirectoryEntry {
+	for i, entry := range entries {
+		if file, ok := p.files[entry.OriginalPath]; ok {
+			entries[i].FileContents = file.updated
+		}
+	}
+	return entries
+}
+
+// Diff returns a unified diff of every planned file.
+func (p *UpdatePlan) Diff() string {
+	var result string
+	for _, path := range p.fileOrder {
+		file := p.files[path]
+		name := filepath.ToSlash(relativePathFromWd(path))
+		result += diff.Unified("a/"+name, "b/"+name, file.original, file.updated)
+	}
+	return result
+}
+
+func (p *UpdatePlan) writeJSON(w io.Writer) error {
+	encoded, err := json.MarshalIndent(struct {
+		Changes []PlannedChange `json:"changes"`
+	}{p.Changes}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "unable to JSON encode the plan")
+	}
+
+	_, err = fmt.Fprintln(w, string(encoded))
+	return err
+}
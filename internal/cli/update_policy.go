@@ -0,0 +1,379 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/goccy/go-yaml"
+	"github.com/rwx-research/mint-cli/internal/api"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+const UpdatePolicyFileName = "update.yml"
+
+// UpdatePolicy restricts which versions `mint update leaves` may pick. It is read from
+// .mint/update.yml, for example:
+//
+//	ignore:
+//	  - mint/legacy-leaf
+//	minimum-release-age: 7d
+//	leaves:
+//	  mint/install-node:
+//	    constraint: "~1.2"
+//	  mint/install-go:
+//	    patch-only: true
+type UpdatePolicy struct {
+	Ignore            []string                    `yaml:"ignore"`
+	MinimumReleaseAge string                      `yaml:"minimum-release-age"`
+	Leaves            map[string]LeafUpdatePolicy `yaml:"leaves"`
+}
+
+type LeafUpdatePolicy struct {
+	Constraint        string `yaml:"constraint"`
+	Ignore            bool   `yaml:"ignore"`
+	MinimumReleaseAge string `yaml:"minimum-release-age"`
+	PatchOnly         bool   `yaml:"patch-only"`
+}
+
+func updatePolicyPath(mintDirectoryPath string) string {
+	return filepath.Join(mintDirectoryPath, UpdatePolicyFileName)
+}
+
+// isUpdatePolicyEntry reports whether the entry is the update policy at the root of the .mint directory.
+func isUpdatePolicyEntry(entry MintDirectoryEntry) bool {
+	return entry.Path == filepath.ToSlash(filepath.Join(".mint", UpdatePolicyFileName))
+}
+
+// readUpdatePolicy reads and validates the update policy in the given .mint directory. When
+// there is no policy, it returns nil without an error.
+func readUpdatePolicy(mintDirectoryPath string) (*UpdatePolicy, error) {
+	if mintDirectoryPath == "" {
+		return nil, nil
+	}
+
+	path := updatePolicyPath(mintDirectoryPath)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to read %q", path)
+	}
+
+	policy := UpdatePolicy{}
+	if err := yaml.UnmarshalWithOptions(content, &policy, yaml.Strict()); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %q", path)
+	}
+
+	if err := policy.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "invalid update policy %q", path)
+	}
+
+	return &policy, nil
+}
+
+func (p UpdatePolicy) Validate() error {
+	if _, err := parseReleaseAge(p.MinimumReleaseAge); err != nil {
+		return err
+	}
+
+	for leaf, leafPolicy := range p.Leaves {
+		if leafPolicy.Constraint != "" {
+			if _, err := semver.NewConstraint(leafPolicy.Constraint); err != nil {
+				return errors.Wrapf(err, "invalid constraint %q for leaf %q", leafPolicy.Constraint, leaf)
+			}
+		}
+
+		if _, err := parseReleaseAge(leafPolicy.MinimumReleaseAge); err != nil {
+			return errors.Wrapf(err, "leaf %q", leaf)
+		}
+	}
+
+	return nil
+}
+
+// leafVersionPicker picks the version to replace the current version of a leaf with, see
+// UpdateLeavesConfig.ReplacementVersionPicker.
+type leafVersionPicker = func(versions api.LeafVersionsResult, leaf string, current string) (string, error)
+
+// Picker wraps the given version picker so it honors the policy. The wrapped picker still
+// decides how far a leaf may move (eg. only within its major version); the policy can only
+// narrow that down further.
+func (p UpdatePolicy) Picker(picker leafVersionPicker) leafVersionPicker {
+	return func(versions api.LeafVersionsResult, leaf string, current string) (string, error) {
+		leafPolicy := p.Leaves[leaf]
+		if leafPolicy.Ignore || slices.Contains(p.Ignore, leaf) {
+			return "", fmt.Errorf("Leaf %q is ignored by %s; skipping it.", leaf, UpdatePolicyFileName)
+		}
+
+		target, err := picker(versions, leaf, current)
+		if err != nil {
+			return "", err
+		}
+
+		pickers, err := p.pickersFor(leaf, current)
+		if err != nil {
+			return "", err
+		}
+
+		if len(pickers) == 0 {
+			return target, nil
+		}
+
+		targetVersion, err := semver.NewVersion(target)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to parse version %q of leaf %q", target, leaf)
+		}
+
+		// Never go further than the underlying picker would, never downgrade a leaf, and stay
+		// within the current major version when the underlying picker did
+		constraint := fmt.Sprintf("<=%s", targetVersion)
+		if current != "" {
+			currentVersion, err := semver.NewVersion(current)
+			if err != nil {
+				return "", errors.Wrapf(err, "unable to parse version %q of leaf %q", current, leaf)
+			}
+
+			constraint = fmt.Sprintf("%s, >=%s", constraint, currentVersion)
+			if targetVersion.Major() == currentVersion.Major() {
+				constraint = fmt.Sprintf("%s, <%d", constraint, currentVersion.Major()+1)
+			}
+		}
+
+		within, err := PickLatestVersionSatisfying(constraint)
+		if err != nil {
+			return "", err
+		}
+
+		return pickLatestVersionOfAll(append([]leafVersionPicker{within}, pickers...)...)(versions, leaf, current)
+	}
+}
+
+// pickersFor returns the pickers of the rules of the policy which apply to a leaf.
+func (p UpdatePolicy) pickersFor(leaf string, current string) ([]leafVersionPicker, error) {
+	leafPolicy := p.Leaves[leaf]
+	pickers := make([]leafVersionPicker, 0)
+
+	if leafPolicy.Constraint != "" {
+		picker, err := PickLatestVersionSatisfying(leafPolicy.Constraint)
+		if err != nil {
+			return nil, err
+		}
+		pickers = append(pickers, picker)
+	}
+
+	if leafPolicy.PatchOnly && current != "" {
+		pickers = append(pickers, PickLatestPatchVersion)
+	}
+
+	minimumReleaseAge := p.MinimumReleaseAge
+	if leafPolicy.MinimumReleaseAge != "" {
+		minimumReleaseAge = leafPolicy.MinimumReleaseAge
+	}
+	age, err := parseReleaseAge(minimumReleaseAge)
+	if err != nil {
+		return nil, err
+	}
+	if age > 0 {
+		pickers = append(pickers, PickLatestVersionOlderThan(age))
+	}
+
+	return pickers, nil
+}
+
+// pickLatestVersionOfAll composes pickers into one picking the latest version all of them agree
+// on. Each picker is asked again among the versions no later than the previous pick, until they
+// all pick the same version.
+func pickLatestVersionOfAll(pickers ...leafVersionPicker) leafVersionPicker {
+	return func(versions api.LeafVersionsResult, leaf string, current string) (string, error) {
+		picked := ""
+		agreed := 0
+		for i := 0; agreed < len(pickers); i = (i + 1) % len(pickers) {
+			version, err := pickers[i](versions, leaf, current)
+			if err != nil {
+				return "", err
+			}
+
+			if version == picked {
+				agreed++
+				continue
+			}
+
+			bound, err := semver.NewVersion(version)
+			if err != nil {
+				return "", errors.Wrapf(err, "unable to parse version %q of leaf %q", version, leaf)
+			}
+
+			versions = versionsNoLaterThan(versions, leaf, bound)
+			picked = version
+			agreed = 1
+		}
+
+		return picked, nil
+	}
+}
+
+// versionsNoLaterThan returns the versions of a leaf up to and including bound.
+func versionsNoLaterThan(versions api.LeafVersionsResult, leaf string, bound *semver.Version) api.LeafVersionsResult {
+	limited := make([]api.LeafVersionDetails, 0, len(versions.Versions[leaf]))
+	for _, details := range versions.Versions[leaf] {
+		if version, err := semver.NewVersion(details.Version); err == nil && !version.GreaterThan(bound) {
+			limited = append(limited, details)
+		}
+	}
+
+	versions.Versions = maps.Clone(versions.Versions)
+	versions.Versions[leaf] = limited
+	return versions
+}
+
+// PickLatestVersionSatisfying returns a picker for the latest version of a leaf matching the
+// given semver constraint, eg. "~1.2" or "<3".
+func PickLatestVersionSatisfying(constraint string) (leafVersionPicker, error) {
+	filter, err := constraintFilter(constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(versions api.LeafVersionsResult, leaf string, _ string) (string, error) {
+		version, err := pickLatestLeafVersion(versions, leaf, filter)
+		if err != nil {
+			return "", err
+		}
+		if version == "" {
+			return "", fmt.Errorf("No version of leaf %q satisfies %q; skipping it.", leaf, constraint)
+		}
+		return version, nil
+	}, nil
+}
+
+// PickLatestPatchVersion picks the latest version of a leaf with the same major and minor
+// version as the current one.
+func PickLatestPatchVersion(versions api.LeafVersionsResult, leaf string, current string) (string, error) {
+	if current == "" {
+		return PickLatestMajorVersion(versions, leaf, current)
+	}
+
+	filter, err := patchFilter(current)
+	if err != nil {
+		return "", err
+	}
+
+	version, err := pickLatestLeafVersion(versions, leaf, filter)
+	if err != nil {
+		return "", err
+	}
+	if version == "" {
+		return "", fmt.Errorf("Unable to find version %q for leaf %q; skipping it.", current, leaf)
+	}
+	return version, nil
+}
+
+// PickLatestVersionOlderThan returns a picker for the latest version of a leaf that was
+// published at least the given duration ago.
+func PickLatestVersionOlderThan(age time.Duration) leafVersionPicker {
+	return func(versions api.LeafVersionsResult, leaf string, _ string) (string, error) {
+		version, err := pickLatestLeafVersion(versions, leaf, releaseAgeFilter(age, time.Now()))
+		if err != nil {
+			return "", err
+		}
+		if version == "" {
+			return "", fmt.Errorf("No version of leaf %q is older than %s; skipping it.", leaf, age)
+		}
+		return version, nil
+	}
+}
+
+type leafVersionFilter func(details api.LeafVersionDetails, version *semver.Version) bool
+
+// pickLatestLeafVersion returns the latest version of a leaf passing all filters, or an empty
+// string when none does.
+func pickLatestLeafVersion(versions api.LeafVersionsResult, leaf string, filters ...leafVersionFilter) (string, error) {
+	allVersions, ok := versions.Versions[leaf]
+	if !ok {
+		if _, found := versions.LatestMajor[leaf]; found {
+			return "", fmt.Errorf("Unable to find the published versions of leaf %q; skipping it.", leaf)
+		}
+		return "", fmt.Errorf("Unable to find the leaf %q; skipping it.", leaf)
+	}
+
+	var latest *semver.Version
+	var latestOriginal string
+	for _, details := range allVersions {
+		version, err := semver.NewVersion(details.Version)
+		if err != nil {
+			continue
+		}
+
+		if slices.ContainsFunc(filters, func(filter leafVersionFilter) bool {
+			return !filter(details, version)
+		}) {
+			continue
+		}
+
+		if latest == nil || version.GreaterThan(latest) {
+			latest = version
+			latestOriginal = details.Version
+		}
+	}
+
+	return latestOriginal, nil
+}
+
+func constraintFilter(constraint string) (leafVersionFilter, error) {
+	constraints, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid constraint %q", constraint)
+	}
+
+	return func(_ api.LeafVersionDetails, version *semver.Version) bool {
+		return constraints.Check(version)
+	}, nil
+}
+
+func patchFilter(current string) (leafVersionFilter, error) {
+	currentVersion, err := semver.NewVersion(current)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid version %q", current)
+	}
+
+	return func(_ api.LeafVersionDetails, version *semver.Version) bool {
+		return version.Major() == currentVersion.Major() && version.Minor() == currentVersion.Minor()
+	}, nil
+}
+
+func releaseAgeFilter(age time.Duration, now time.Time) leafVersionFilter {
+	return func(details api.LeafVersionDetails, _ *semver.Version) bool {
+		return !details.PublishedAt.IsZero() && !details.PublishedAt.After(now.Add(-age))
+	}
+}
+
+// parseReleaseAge parses durations such as "7d", "2w", or anything time.ParseDuration accepts.
+func parseReleaseAge(age string) (time.Duration, error) {
+	if age == "" {
+		return 0, nil
+	}
+
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if count, found := strings.CutSuffix(age, suffix); found {
+			n, err := strconv.Atoi(count)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid minimum release age %q", age)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(age)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid minimum release age %q", age)
+	}
+	return duration, nil
+}
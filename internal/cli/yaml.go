@@ -2,7 +2,6 @@ package cli
 
 import (
 	"fmt"
-	"io/fs"
 	"os"
 	"slices"
 	"strings"
@@ -13,10 +12,21 @@ import (
 	"github.com/rwx-research/mint-cli/internal/errors"
 )
 
+// ErrAliasedNode is returned when an edit would modify a node through an alias. The node belongs
+// to the definition of its anchor, so the edit would also apply to every other alias of it.
+var ErrAliasedNode = errors.New("editing an alias would modify every node sharing its anchor")
+
 type YAMLDoc struct {
 	astFile  *ast.File
 	original string
 	latest   *string
+
+	// JSON documents are read like YAML, but modified as text to preserve their formatting
+	json bool
+
+	// Documents of a multi-document file read and modify the file of their root
+	root  *YAMLDoc
+	index int
 }
 
 func ParseYAMLDoc(content string) (*YAMLDoc, error) {
@@ -24,8 +34,12 @@ func ParseYAMLDoc(content string) (*YAMLDoc, error) {
 	if err != nil {
 		return nil, err
 	}
-	latest := astFile.String()
 
+	if isJSON([]byte(content)) {
+		return &YAMLDoc{astFile: astFile, original: content, latest: &content, json: true}, nil
+	}
+
+	latest := astFile.String()
 	return &YAMLDoc{astFile: astFile, original: latest, latest: &latest}, nil
 }
 
@@ -38,20 +52,44 @@ func ParseYAMLFile(path string) (*YAMLDoc, error) {
 	return ParseYAMLDoc(string(content))
 }
 
+// Documents returns each document of a multi-document file. Documents share the file they
+// belong to, so changes made through one are reflected in the file as a whole and written
+// along with every other document, separators and comments. Single-document files are
+// returned as is.
+func (doc *YAMLDoc) Documents() []*YAMLDoc {
+	root := doc.rootDoc()
+	if len(root.astFile.Docs) <= 1 {
+		return []*YAMLDoc{root}
+	}
+
+	docs := make([]*YAMLDoc, len(root.astFile.Docs))
+	for i := range root.astFile.Docs {
+		docs[i] = &YAMLDoc{root: root, index: i}
+	}
+	return docs
+}
+
 func (doc *YAMLDoc) Bytes() []byte {
 	return []byte(doc.String())
 }
 
 func (doc *YAMLDoc) String() string {
-	if doc.latest == nil {
-		s := doc.astFile.String()
-		doc.latest = &s
+	root := doc.rootDoc()
+	if root.latest == nil {
+		s := root.astFile.String()
+		root.latest = &s
 	}
-	return *doc.latest
+	return *root.latest
+}
+
+// IsJSON reports whether the document is written in JSON. JSON documents are modified as
+// text, preserving their key order and indentation.
+func (doc *YAMLDoc) IsJSON() bool {
+	return doc.rootDoc().json
 }
 
 func (doc *YAMLDoc) HasChanges() bool {
-	return doc.original != doc.String()
+	return doc.rootDoc().original != doc.String()
 }
 
 func (doc *YAMLDoc) HasBase() bool {
@@ -63,23 +101,25 @@ func (doc *YAMLDoc) HasTasks() bool {
 }
 
 func (doc *YAMLDoc) IsRunDefinition() bool {
-	if len(doc.astFile.Docs) != 1 {
-		// Multi-document files are not supported
+	file := doc.file()
+	if len(file.Docs) != 1 {
+		// Multi-document files need to be inspected one document at a time, see Documents
 		return false
 	}
 
-	yamlDoc := doc.astFile.Docs[0]
-	return yamlDoc.Body.Type() == ast.MappingType && doc.HasTasks()
+	yamlDoc := file.Docs[0]
+	return yamlDoc.Body != nil && yamlDoc.Body.Type() == ast.MappingType && doc.HasTasks()
 }
 
 func (doc *YAMLDoc) IsListOfTasks() bool {
-	if len(doc.astFile.Docs) != 1 {
-		// Multi-document files are not supported
+	file := doc.file()
+	if len(file.Docs) != 1 {
+		// Multi-document files need to be inspected one document at a time, see Documents
 		return false
 	}
 
-	yamlDoc := doc.astFile.Docs[0]
-	return yamlDoc.Body.Type() == ast.SequenceType
+	yamlDoc := file.Docs[0]
+	return yamlDoc.Body != nil && yamlDoc.Body.Type() == ast.SequenceType
 }
 
 func (doc *YAMLDoc) ReadStringAtPath(yamlPath string) (string, error) {
@@ -88,6 +128,11 @@ func (doc *YAMLDoc) ReadStringAtPath(yamlPath string) (string, error) {
 		return "", err
 	}
 
+	// Quoted strings, including every string of JSON documents, are read without their quotes
+	if stringNode, ok := node.(*ast.StringNode); ok {
+		return stringNode.Value, nil
+	}
+
 	return node.String(), nil
 }
 
@@ -104,34 +149,34 @@ func (doc *YAMLDoc) InsertBefore(beforeYamlPath string, value any) error {
 		return errors.New("must provide a root yaml field in the form of \"$.fieldname\"")
 	}
 
-	p, err := yaml.PathString(beforeYamlPath)
-	if err != nil {
-		panic(err)
+	if doc.IsJSON() {
+		return doc.editJSON(func(editor *jsonEditor) error {
+			return editor.InsertBefore(beforeYamlPath, value)
+		})
 	}
 
 	// We can't use doc.astFile because it may have already been modified and
 	// we need the original index for the relative yaml node.
-	reparsedFile, err := parser.ParseBytes([]byte(doc.astFile.String()), parser.ParseComments)
-	if err != nil {
-		return err
-	}
-
-	relativeNode, err := p.FilterFile(reparsedFile)
+	contents := doc.String()
+	reparsedFile, err := parser.ParseBytes([]byte(contents), parser.ParseComments)
 	if err != nil {
 		return err
 	}
 
-	// token: value for the given beforeYamlPath
-	// token.Prev: the separator token, eg. ":"
-	// token.Prev.Prev: key for the given beforeYamlPath
-	token := relativeNode.GetToken()
-	if token.Prev == nil {
-		return errors.New("unexpected token structure: token.Prev is nil")
+	// The key is found in the root mapping rather than from the tokens preceding its value,
+	// which may be comments
+	var key ast.Node
+	for _, value := range mappingValues(doc.scope(reparsedFile).Docs[0].Body) {
+		if scalarText(value.Key) == strings.TrimPrefix(beforeYamlPath, "$.") {
+			key = value.Key
+			break
+		}
 	}
-	if token.Prev.Prev == nil {
-		return errors.New("unexpected token structure: token.Prev.Prev is nil")
+	if key == nil {
+		return errors.Errorf("unable to find %s", beforeYamlPath)
 	}
-	idx := token.Prev.Prev.Position.Offset - 1
+	// Offsets aren't reliable past the first document of a file, unlike lines and columns
+	idx := offsetOfPosition(contents, key.GetToken().Position.Line, key.GetToken().Position.Column)
 
 	node, err := yaml.NewEncoder(nil).EncodeToNode(value)
 	if err != nil {
@@ -139,7 +184,7 @@ func (doc *YAMLDoc) InsertBefore(beforeYamlPath string, value any) error {
 	}
 
 	toInsert := fmt.Appendf([]byte(node.String()), "\n\n")
-	result := slices.Insert([]byte(doc.astFile.String()), idx, toInsert...)
+	result := slices.Insert([]byte(contents), idx, toInsert...)
 
 	err = doc.reparseAst(string(result))
 	if err != nil {
@@ -150,6 +195,16 @@ func (doc *YAMLDoc) InsertBefore(beforeYamlPath string, value any) error {
 }
 
 func (doc *YAMLDoc) MergeAtPath(yamlPath string, value any) error {
+	if doc.IsJSON() {
+		return doc.editJSON(func(editor *jsonEditor) error {
+			return editor.MergeAtPath(yamlPath, value)
+		})
+	}
+
+	if err := doc.ensureNotAliased(yamlPath); err != nil {
+		return err
+	}
+
 	p, err := yaml.PathString(yamlPath)
 	if err != nil {
 		panic(err)
@@ -160,7 +215,7 @@ func (doc *YAMLDoc) MergeAtPath(yamlPath string, value any) error {
 		return err
 	}
 
-	err = p.MergeFromNode(doc.astFile, node)
+	err = p.MergeFromNode(doc.file(), node)
 	if err != nil {
 		return err
 	}
@@ -170,22 +225,43 @@ func (doc *YAMLDoc) MergeAtPath(yamlPath string, value any) error {
 }
 
 func (doc *YAMLDoc) ReplaceAtPath(yamlPath string, replacement any) error {
-	p, err := yaml.PathString(yamlPath)
+	if doc.IsJSON() {
+		return doc.editJSON(func(editor *jsonEditor) error {
+			return editor.ReplaceAtPath(yamlPath, replacement)
+		})
+	}
+
+	// Positions need to match the current contents, which may have been modified
+	contents := doc.String()
+	reparsedFile, err := parser.ParseBytes([]byte(contents), parser.ParseComments)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	// Ensure the path exists
-	if _, err := p.FilterFile(doc.astFile); err != nil {
+	matches, err := resolveYAMLPath(doc.scope(reparsedFile), yamlPath)
+	if err != nil {
 		return err
 	}
+	if matches[0].alias != "" {
+		return aliasedNodeError(yamlPath, matches[0].alias)
+	}
 
 	node, err := yaml.NewEncoder(nil).EncodeToNode(replacement)
 	if err != nil {
 		return err
 	}
 
-	err = p.ReplaceWithNode(doc.astFile, node)
+	// Scalars are replaced as text, keeping their anchor
+	if replaced, ok := replaceScalarText(contents, matches[0].node, node); ok {
+		return doc.reparseAst(replaced)
+	}
+
+	p, err := yaml.PathString(yamlPath)
+	if err != nil {
+		panic(err)
+	}
+
+	err = p.ReplaceWithNode(doc.file(), node)
 	if err != nil {
 		return err
 	}
@@ -194,11 +270,60 @@ func (doc *YAMLDoc) ReplaceAtPath(yamlPath string, replacement any) error {
 	return nil
 }
 
+// SetLineCommentAtPath sets the comment following the scalar at the given path on its line,
+// replacing any existing one. JSON has no comments, so JSON documents return an error.
+func (doc *YAMLDoc) SetLineCommentAtPath(yamlPath string, comment string) error {
+	if doc.IsJSON() {
+		return errors.New("JSON documents can't have comments")
+	}
+
+	// Positions need to match the current contents, which may have been modified
+	contents := doc.String()
+	reparsedFile, err := parser.ParseBytes([]byte(contents), parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	matches, err := resolveYAMLPath(doc.scope(reparsedFile), yamlPath)
+	if err != nil {
+		return err
+	}
+	if matches[0].alias != "" {
+		return aliasedNodeError(yamlPath, matches[0].alias)
+	}
+
+	node := matches[0].node
+	text := strings.TrimSpace(node.GetToken().Origin)
+	if _, ok := node.(ast.ScalarNode); !ok || text == "" || strings.Contains(text, "\n") {
+		return fmt.Errorf("expected a single-line scalar at %q", yamlPath)
+	}
+
+	position := node.GetToken().Position
+	start := offsetOfPosition(contents, position.Line, position.Column) + len(text)
+	end := start
+	if existing := node.GetComment(); existing != nil && existing.GetToken() != nil && existing.GetToken().Position.Line == position.Line {
+		// The existing comment runs to the end of the line
+		end = start + strings.IndexByte(contents[start:]+"\n", '\n')
+	}
+
+	return doc.reparseAst(contents[:start] + " # " + comment + contents[end:])
+}
+
 func (doc *YAMLDoc) SetAtPath(yamlPath string, value any) error {
+	if doc.IsJSON() {
+		return doc.editJSON(func(editor *jsonEditor) error {
+			return editor.SetAtPath(yamlPath, value)
+		})
+	}
+
 	pathParts := strings.Split(yamlPath, ".")
 	field := pathParts[len(pathParts)-1]
 
 	parent := strings.Join(pathParts[0:len(pathParts)-1], ".")
+	if err := doc.ensureNotAliased(parent); err != nil {
+		return err
+	}
+
 	path, err := yaml.PathString(parent)
 	if err != nil {
 		panic(err)
@@ -211,7 +336,7 @@ func (doc *YAMLDoc) SetAtPath(yamlPath string, value any) error {
 		return err
 	}
 
-	err = path.MergeFromNode(doc.astFile, node)
+	err = path.MergeFromNode(doc.file(), node)
 	if err != nil {
 		return err
 	}
@@ -220,14 +345,34 @@ func (doc *YAMLDoc) SetAtPath(yamlPath string, value any) error {
 	return nil
 }
 
-func (doc *YAMLDoc) ForEachNode(yamlPath string, f func(node ast.Node) error) error {
-	node, err := doc.getNodeAtPath(yamlPath)
+// AppendToSequence adds value as the last item of the block sequence at the given path. The
+// item is inserted as text with the indentation of the existing items, so the comments and
+// formatting of the rest of the document are preserved.
+func (doc *YAMLDoc) AppendToSequence(yamlPath string, value any) error {
+	if doc.IsJSON() {
+		return doc.editJSON(func(editor *jsonEditor) error {
+			return editor.AppendToSequence(yamlPath, value)
+		})
+	}
+
+	if err := doc.ensureNotAliased(yamlPath); err != nil {
+		return err
+	}
+
+	// Positions need to match the current contents, which may have been modified
+	contents := doc.String()
+	reparsedFile, err := parser.ParseBytes([]byte(contents), parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	p, err := yaml.PathString(yamlPath)
+	if err != nil {
+		panic(err)
+	}
+
+	node, err := p.FilterFile(doc.scope(reparsedFile))
 	if err != nil {
-		// The yamlPath isn't compatible with the underlying YAML doc, for instance
-		// an sequence of strings where we expect a sequence of maps.
-		if errors.Is(err, yaml.ErrInvalidQuery) || errors.Is(err, yaml.ErrNotFoundNode) {
-			return nil
-		}
 		return err
 	}
 
@@ -235,12 +380,95 @@ func (doc *YAMLDoc) ForEachNode(yamlPath string, f func(node ast.Node) error) er
 	if !ok {
 		return fmt.Errorf("expected sequence node, got %T", node)
 	}
+	if seqNode.IsFlowStyle {
+		return errors.New("unable to append to a flow style sequence")
+	}
 
-	for _, valueNode := range seqNode.Values {
-		if valueNode == nil {
+	encoded, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	start := seqNode.GetToken().Position
+	indent := strings.Repeat(" ", start.Column-1)
+
+	var item strings.Builder
+	for i, line := range strings.Split(strings.TrimRight(string(encoded), "\n"), "\n") {
+		if i == 0 {
+			item.WriteString(indent + "- " + line + "\n")
+		} else {
+			item.WriteString(indent + "  " + line + "\n")
+		}
+	}
+
+	lines := strings.SplitAfter(contents, "\n")
+	insertAt := start.Line
+	for i := start.Line; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
 			continue
 		}
-		if err := f(valueNode); err != nil {
+
+		// The sequence ends with its document, even when its items aren't indented
+		if isDocumentMarker(lines[i]) {
+			break
+		}
+
+		lineIndent := len(lines[i]) - len(strings.TrimLeft(lines[i], " "))
+		if lineIndent < len(indent) || (lineIndent == len(indent) && !strings.HasPrefix(trimmed, "-")) {
+			break
+		}
+		insertAt = i + 1
+	}
+
+	if !strings.HasSuffix(lines[insertAt-1], "\n") {
+		lines[insertAt-1] += "\n"
+	}
+	lines = slices.Insert(lines, insertAt, item.String())
+
+	return doc.reparseAst(strings.Join(lines, ""))
+}
+
+// isDocumentMarker reports whether the line starts or ends a document, ie. is "---" or "...".
+func isDocumentMarker(line string) bool {
+	for _, marker := range []string{"---", "..."} {
+		if rest, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), marker); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return true
+		}
+	}
+	return false
+}
+
+// ForEachNode calls f with every item of the sequence at the given path, or with every node
+// matching a path with a wildcard such as "$.tasks[*].call". Anchored nodes are looked into and
+// aliases are followed, but nodes shared through aliases are only visited once, at the definition
+// of their anchor; editing them at their path updates every alias at once.
+func (doc *YAMLDoc) ForEachNode(yamlPath string, f func(node ast.Node) error) error {
+	if !strings.Contains(yamlPath, "[*]") {
+		node, err := doc.getNodeAtPath(yamlPath)
+		if err != nil {
+			return ignoreIncompatiblePath(err)
+		}
+
+		if _, ok := node.(*ast.SequenceNode); !ok {
+			return fmt.Errorf("expected sequence node, got %T", node)
+		}
+		yamlPath += "[*]"
+	}
+
+	matches, err := resolveYAMLPath(doc.file(), yamlPath)
+	if err != nil {
+		return ignoreIncompatiblePath(err)
+	}
+
+	visited := make(map[ast.Node]bool)
+	for _, match := range matches {
+		if visited[match.node] {
+			continue
+		}
+		visited[match.node] = true
+
+		if err := f(match.node); err != nil {
 			return err
 		}
 	}
@@ -248,22 +476,16 @@ func (doc *YAMLDoc) ForEachNode(yamlPath string, f func(node ast.Node) error) er
 }
 
 func (doc *YAMLDoc) WriteFile(path string) error {
-	// Inherit permissions from the existing file if it exists
-	mode := fs.FileMode(0644)
-	if fi, err := os.Stat(path); err == nil {
-		mode = fi.Mode()
-	}
-
-	return os.WriteFile(path, doc.Bytes(), mode)
+	return writeFile(path, doc.Bytes())
 }
 
 func (doc *YAMLDoc) getNodeAtPath(yamlPath string) (ast.Node, error) {
-	p, err := yaml.PathString(yamlPath)
+	matches, err := resolveYAMLPath(doc.file(), yamlPath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return p.FilterFile(doc.astFile)
+	return matches[0].node, nil
 }
 
 func (doc *YAMLDoc) hasPath(yamlPath string) bool {
@@ -271,8 +493,78 @@ func (doc *YAMLDoc) hasPath(yamlPath string) bool {
 	return err == nil
 }
 
+// ensureNotAliased returns ErrAliasedNode when the node at the given path is found through an
+// alias. Paths that don't exist are left for the edit itself to report.
+func (doc *YAMLDoc) ensureNotAliased(yamlPath string) error {
+	matches, err := resolveYAMLPath(doc.file(), yamlPath)
+	if err != nil || matches[0].alias == "" {
+		return nil
+	}
+	return aliasedNodeError(yamlPath, matches[0].alias)
+}
+
+func aliasedNodeError(yamlPath string, alias string) error {
+	return errors.Wrapf(ErrAliasedNode, "unable to edit %s through the alias *%s", yamlPath, alias)
+}
+
+// ignoreIncompatiblePath ignores errors of paths that aren't compatible with the underlying YAML
+// doc, for instance a sequence of strings where we expect a sequence of maps.
+func ignoreIncompatiblePath(err error) error {
+	if errors.Is(err, yaml.ErrInvalidQuery) || errors.Is(err, yaml.ErrNotFoundNode) {
+		return nil
+	}
+	return err
+}
+
+// replaceScalarText replaces the text of a scalar written on a single line with the given scalar.
+func replaceScalarText(contents string, node ast.Node, replacement ast.Node) (string, bool) {
+	if _, ok := node.(ast.ScalarNode); !ok {
+		return "", false
+	}
+	if _, ok := node.(*ast.LiteralNode); ok {
+		return "", false
+	}
+	if _, ok := replacement.(ast.ScalarNode); !ok {
+		return "", false
+	}
+
+	text := strings.TrimSpace(node.GetToken().Origin)
+	replacementText := replacement.String()
+	if text == "" || strings.Contains(text, "\n") || strings.Contains(replacementText, "\n") {
+		return "", false
+	}
+
+	position := node.GetToken().Position
+	offset := offsetOfPosition(contents, position.Line, position.Column)
+	if !strings.HasPrefix(contents[offset:], text) {
+		return "", false
+	}
+
+	// Like replaced nodes, replaced scalars lose the comment following them on their line
+	end := offset + len(text)
+	if comment := node.GetComment(); comment != nil && comment.GetToken() != nil && comment.GetToken().Position.Line == position.Line {
+		end += strings.IndexByte(contents[end:]+"\n", '\n')
+	}
+
+	return contents[:offset] + replacementText + contents[end:], true
+}
+
+// editJSON applies an edit to the text of a JSON document.
+func (doc *YAMLDoc) editJSON(edit func(editor *jsonEditor) error) error {
+	editor, err := newJSONEditor(doc.String())
+	if err != nil {
+		return err
+	}
+
+	if err := edit(editor); err != nil {
+		return err
+	}
+
+	return doc.reparseAst(editor.src)
+}
+
 func (doc *YAMLDoc) modified() {
-	doc.latest = nil
+	doc.rootDoc().latest = nil
 }
 
 func (doc *YAMLDoc) reparseAst(contents string) error {
@@ -281,7 +573,50 @@ func (doc *YAMLDoc) reparseAst(contents string) error {
 		return err
 	}
 
-	doc.astFile = astFile
-	doc.latest = nil
+	root := doc.rootDoc()
+	root.astFile = astFile
+	root.latest = nil
+	if root.json {
+		// The AST of JSON documents doesn't preserve their formatting, unlike their contents
+		root.latest = &contents
+	}
 	return nil
 }
+
+// offsetOfPosition returns the byte offset of the given 1-based line and column in contents.
+func offsetOfPosition(contents string, line int, column int) int {
+	offset := 0
+	lines := strings.SplitAfter(contents, "\n")
+	for i := 0; i < line-1 && i < len(lines); i++ {
+		offset += len(lines[i])
+	}
+
+	if line-1 < len(lines) {
+		runes := []rune(lines[line-1])
+		offset += len(string(runes[:min(column-1, len(runes))]))
+	}
+
+	return offset
+}
+
+func (doc *YAMLDoc) rootDoc() *YAMLDoc {
+	if doc.root != nil {
+		return doc.root
+	}
+	return doc
+}
+
+// file returns the parts of the file this doc reads and modifies: the whole file, or only
+// its document for documents of a multi-document file.
+func (doc *YAMLDoc) file() *ast.File {
+	return doc.scope(doc.rootDoc().astFile)
+}
+
+// scope returns the document of this doc in the given file, which must have the same documents
+// as the file of this doc, eg. after reparsing it.
+func (doc *YAMLDoc) scope(file *ast.File) *ast.File {
+	if doc.root == nil {
+		return file
+	}
+	return &ast.File{Name: file.Name, Docs: []*ast.DocumentNode{file.Docs[doc.index]}}
+}
@@ -0,0 +1,259 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// yamlPathSegment is a segment of the subset of YAML paths used to read and edit run
+// definitions, eg. "$.tasks[*].call".
+type yamlPathSegment struct {
+	key        string
+	index      int
+	isIndex    bool
+	isWildcard bool
+}
+
+// yamlMatch is a node found at a YAML path. Anchors are followed to the nodes they name, so
+// nodes found through an alias or a merge key belong to the definition of their anchor.
+type yamlMatch struct {
+	node ast.Node
+
+	// alias is the name of the first alias followed to find the node, if any
+	alias string
+}
+
+// yamlAnchors maps the anchors of a YAML document to the nodes they name.
+type yamlAnchors map[string]ast.Node
+
+// parseYAMLPath parses a path such as "$.tasks[0].call", "$.tasks[*].call" or "$.'a.b'".
+func parseYAMLPath(path string) ([]yamlPathSegment, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("invalid path %q", path)
+	}
+
+	segments := make([]yamlPathSegment, 0)
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "[*]"):
+			segments = append(segments, yamlPathSegment{isWildcard: true})
+			rest = rest[3:]
+		case strings.HasPrefix(rest, "[") && strings.Contains(rest, "]"):
+			end := strings.Index(rest, "]")
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			segments = append(segments, yamlPathSegment{index: index, isIndex: true})
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, ".'") || strings.HasPrefix(rest, `."`):
+			end := strings.IndexByte(rest[2:], rest[1])
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			segments = append(segments, yamlPathSegment{key: rest[2 : end+2]})
+			rest = rest[end+3:]
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			segments = append(segments, yamlPathSegment{key: rest[1 : end+1]})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+	}
+
+	return segments, nil
+}
+
+func formatYAMLPath(segments []yamlPathSegment) string {
+	var path strings.Builder
+	path.WriteString("$")
+	for _, segment := range segments {
+		switch {
+		case segment.isWildcard:
+			path.WriteString("[*]")
+		case segment.isIndex:
+			fmt.Fprintf(&path, "[%d]", segment.index)
+		case strings.ContainsAny(segment.key, ".[]"):
+			fmt.Fprintf(&path, ".'%s'", segment.key)
+		default:
+			fmt.Fprintf(&path, ".%s", segment.key)
+		}
+	}
+	return path.String()
+}
+
+// resolveYAMLPath finds the nodes at the given path of the first document of file that has
+// any. Unlike the paths of goccy/go-yaml, anchored nodes are looked into, aliases are
+// followed to their anchor and keys are looked up in the mappings merged with `<<`.
+//
+// Paths with a wildcard match every item of a sequence; items that can't match the rest of
+// the path are skipped. Other paths match a single node.
+func resolveYAMLPath(file *ast.File, yamlPath string) ([]yamlMatch, error) {
+	segments, err := parseYAMLPath(yamlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = errors.Wrapf(yaml.ErrNotFoundNode, "failed to find path ( %s )", yamlPath)
+	for _, doc := range file.Docs {
+		if doc.Body == nil {
+			continue
+		}
+
+		var matches []yamlMatch
+		matches, err = newYAMLAnchors(doc).lookup(doc.Body, segments)
+		if err == nil && len(matches) > 0 {
+			return matches, nil
+		}
+	}
+
+	return nil, err
+}
+
+func newYAMLAnchors(doc *ast.DocumentNode) yamlAnchors {
+	anchors := make(yamlAnchors)
+	ast.Walk(anchors, doc)
+	return anchors
+}
+
+func (anchors yamlAnchors) Visit(node ast.Node) ast.Visitor {
+	if anchor, ok := node.(*ast.AnchorNode); ok && anchor.Name != nil {
+		anchors[anchor.Name.GetToken().Value] = anchor.Value
+	}
+	return anchors
+}
+
+func (anchors yamlAnchors) lookup(root ast.Node, segments []yamlPathSegment) ([]yamlMatch, error) {
+	matches := []yamlMatch{{node: root}}
+	wildcard := false
+
+	for i, segment := range segments {
+		next := make([]yamlMatch, 0, len(matches))
+		for _, match := range matches {
+			match = anchors.deref(match)
+			if match.node == nil {
+				continue
+			}
+
+			switch {
+			case segment.isWildcard || segment.isIndex:
+				seqNode, ok := match.node.(*ast.SequenceNode)
+				if !ok {
+					if wildcard {
+						continue
+					}
+					return nil, errors.Wrapf(yaml.ErrInvalidQuery, "expected a sequence at %s, got %s", formatYAMLPath(segments[:i]), match.node.Type())
+				}
+
+				for j, item := range seqNode.Values {
+					if item != nil && (segment.isWildcard || j == segment.index) {
+						next = append(next, yamlMatch{node: item, alias: match.alias})
+					}
+				}
+			default:
+				switch match.node.(type) {
+				case *ast.MappingNode, *ast.MappingValueNode:
+				default:
+					if wildcard {
+						continue
+					}
+					return nil, errors.Wrapf(yaml.ErrInvalidQuery, "expected a mapping at %s, got %s", formatYAMLPath(segments[:i]), match.node.Type())
+				}
+
+				if member, ok := anchors.member(match, segment.key, make(map[ast.Node]bool)); ok {
+					next = append(next, member)
+				}
+			}
+		}
+
+		wildcard = wildcard || segment.isWildcard
+		if len(next) == 0 && !wildcard {
+			return nil, errors.Wrapf(yaml.ErrNotFoundNode, "failed to find path ( %s )", formatYAMLPath(segments))
+		}
+		matches = next
+	}
+
+	resolved := make([]yamlMatch, 0, len(matches))
+	for _, match := range matches {
+		if match = anchors.deref(match); match.node != nil {
+			resolved = append(resolved, match)
+		}
+	}
+	return resolved, nil
+}
+
+// member finds the value of the given key of a mapping. Keys of the mapping itself take
+// precedence over the keys merged into it.
+func (anchors yamlAnchors) member(mapping yamlMatch, key string, visited map[ast.Node]bool) (yamlMatch, bool) {
+	if visited[mapping.node] {
+		return yamlMatch{}, false
+	}
+	visited[mapping.node] = true
+
+	values := mappingValues(mapping.node)
+	for _, value := range values {
+		if _, ok := value.Key.(*ast.MergeKeyNode); !ok && scalarText(value.Key) == key {
+			return yamlMatch{node: value.Value, alias: mapping.alias}, true
+		}
+	}
+
+	for _, value := range values {
+		if _, ok := value.Key.(*ast.MergeKeyNode); !ok {
+			continue
+		}
+
+		merged := anchors.deref(yamlMatch{node: value.Value, alias: mapping.alias})
+		sources := []yamlMatch{merged}
+		if seqNode, ok := merged.node.(*ast.SequenceNode); ok {
+			sources = sources[:0]
+			for _, item := range seqNode.Values {
+				sources = append(sources, anchors.deref(yamlMatch{node: item, alias: merged.alias}))
+			}
+		}
+
+		for _, source := range sources {
+			if member, ok := anchors.member(source, key, visited); ok {
+				return member, true
+			}
+		}
+	}
+
+	return yamlMatch{}, false
+}
+
+// deref returns the node named by an anchor, or by the anchor an alias refers to.
+func (anchors yamlAnchors) deref(match yamlMatch) yamlMatch {
+	for {
+		switch node := match.node.(type) {
+		case *ast.AnchorNode:
+			match.node = node.Value
+		case *ast.TagNode:
+			match.node = node.Value
+		case *ast.AliasNode:
+			name := node.Value.GetToken().Value
+			anchored, ok := anchors[name]
+			if !ok || anchored == nil {
+				return match
+			}
+			if match.alias == "" {
+				match.alias = name
+			}
+			match.node = anchored
+		default:
+			return match
+		}
+	}
+}
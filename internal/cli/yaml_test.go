@@ -2,6 +2,7 @@ package cli_test
 
 import (
 	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
@@ -127,6 +128,30 @@ tasks:
 `))
 		})
 
+		It("inserts before a path whose value starts with a comment", func() {
+			contents := `# A run definition
+
+tasks:
+  # The first task
+  - key: task1
+`
+
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = doc.InsertBefore("$.tasks", map[string]any{"base": map[string]any{"os": "linux"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(doc.String()).To(Equal(`# A run definition
+
+base:
+  os: linux
+
+tasks:
+  # The first task
+  - key: task1
+`))
+		})
+
 		It("errors when the path is not found", func() {
 			contents := `
 tasks:
@@ -279,6 +304,35 @@ tasks:
 		})
 	})
 
+	Context("SetLineCommentAtPath", func() {
+		It("adds or replaces the comment following a scalar", func() {
+			doc, err := cli.ParseYAMLDoc(`
+tasks:
+  - key: task1
+    call: mint/setup-node 1.0.0
+  - key: task2
+    call: "mint/setup-ruby 1.0.0"   # old comment
+`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.SetLineCommentAtPath("$.tasks[0].call", "first")).To(Succeed())
+			Expect(doc.SetLineCommentAtPath("$.tasks[1].call", "second")).To(Succeed())
+			Expect(doc.String()).To(Equal(`tasks:
+  - key: task1
+    call: mint/setup-node 1.0.0 # first
+  - key: task2
+    call: "mint/setup-ruby 1.0.0" # second
+`))
+		})
+
+		It("errors in JSON documents", func() {
+			doc, err := cli.ParseYAMLDoc(`{"tasks": [{"key": "task1", "call": "mint/setup-node 1.0.0"}]}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.SetLineCommentAtPath("$.tasks[0].call", "first")).To(MatchError("JSON documents can't have comments"))
+		})
+	})
+
 	Context("SetAtPath", func() {
 		It("sets and overwrites a yaml object at a specific path", func() {
 			contents := `
@@ -325,4 +379,372 @@ tasks:
 `))
 		})
 	})
+
+	Context("AppendToSequence", func() {
+		It("appends an item after the last item of the sequence", func() {
+			contents := `
+tasks:
+  - key: task1 # another line comment
+    run: echo hello
+
+  # The second task
+  - key: task2
+    use: [task1]
+    run: |
+      echo world
+
+# Trailing settings
+concurrency-pools:
+  - id: pool
+`
+
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = doc.AppendToSequence("$.tasks", yaml.MapSlice{
+				{Key: "key", Value: "node"},
+				{Key: "call", Value: "mint/install-node 1.2.3"},
+				{Key: "with", Value: yaml.MapSlice{{Key: "node-version", Value: "20"}}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(doc.String()).To(Equal(`tasks:
+  - key: task1 # another line comment
+    run: echo hello
+
+  # The second task
+  - key: task2
+    use: [task1]
+    run: |
+      echo world
+  - key: node
+    call: mint/install-node 1.2.3
+    with:
+      node-version: "20"
+
+# Trailing settings
+concurrency-pools:
+  - id: pool
+`))
+		})
+
+		It("appends to a top-level sequence", func() {
+			contents := `- key: task1
+- key: task2
+`
+
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = doc.AppendToSequence("$", yaml.MapSlice{{Key: "key", Value: "task3"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(doc.String()).To(Equal(`- key: task1
+- key: task2
+- key: task3
+`))
+		})
+
+		It("errors when the path is not a sequence", func() {
+			doc, err := cli.ParseYAMLDoc("tasks:\n  key: task1\n")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = doc.AppendToSequence("$.tasks", yaml.MapSlice{{Key: "key", Value: "task2"}})
+			Expect(err).To(MatchError("expected sequence node, got *ast.MappingNode"))
+		})
+	})
+
+	Context("Documents", func() {
+		contents := `# the run
+base:
+  os: ubuntu 24.04
+  tag: 1.0
+
+tasks:
+  - key: a
+    call: mint/setup-node 1.0.0 # pinned
+---
+# shared tasks
+- key: b
+  call: mint/setup-node 1.0.0
+...
+---
+tasks:
+  - key: c
+    run: echo c
+`
+
+		It("returns the file itself when it has a single document", func() {
+			doc, err := cli.ParseYAMLDoc("tasks:\n  - key: a\n")
+			Expect(err).NotTo(HaveOccurred())
+
+			documents := doc.Documents()
+			Expect(documents).To(HaveLen(1))
+			Expect(documents[0]).To(BeIdenticalTo(doc))
+		})
+
+		It("inspects every document separately", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(doc.IsRunDefinition()).To(BeFalse())
+
+			documents := doc.Documents()
+			Expect(documents).To(HaveLen(3))
+
+			Expect(documents[0].IsRunDefinition()).To(BeTrue())
+			Expect(documents[0].HasBase()).To(BeTrue())
+			Expect(documents[1].IsListOfTasks()).To(BeTrue())
+			Expect(documents[1].IsRunDefinition()).To(BeFalse())
+			Expect(documents[2].IsRunDefinition()).To(BeTrue())
+			Expect(documents[2].HasBase()).To(BeFalse())
+		})
+
+		It("iterates over the nodes of a single document", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			var keys []string
+			err = doc.Documents()[2].ForEachNode("$.tasks", func(node ast.Node) error {
+				keys = append(keys, node.(*ast.MappingNode).Values[0].Value.String())
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]string{"c"}))
+
+			err = doc.Documents()[2].ForEachNode("$", func(node ast.Node) error {
+				return errors.New("the document isn't a sequence")
+			})
+			Expect(err).To(MatchError("expected sequence node, got *ast.MappingNode"))
+		})
+
+		It("replaces within a single document and keeps the rest of the file intact", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.Documents()[1].ReplaceAtPath("$[0].call", "mint/setup-node 1.1.0")).To(Succeed())
+			Expect(doc.HasChanges()).To(BeTrue())
+			Expect(doc.String()).To(Equal(`# the run
+base:
+  os: ubuntu 24.04
+  tag: 1.0
+
+tasks:
+  - key: a
+    call: mint/setup-node 1.0.0 # pinned
+---
+# shared tasks
+- key: b
+  call: mint/setup-node 1.1.0
+...
+---
+tasks:
+  - key: c
+    run: echo c
+`))
+		})
+
+		It("inserts into a single document", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			documents := doc.Documents()
+			Expect(documents[2].InsertBefore("$.tasks", map[string]any{"base": map[string]any{"os": "ubuntu 24.04"}})).To(Succeed())
+			Expect(documents[2].HasBase()).To(BeTrue())
+			Expect(documents[0].TryReadStringAtPath("$.tasks[0].key")).To(Equal("a"))
+			Expect(doc.String()).To(HaveSuffix(`---
+base:
+  os: ubuntu 24.04
+
+tasks:
+  - key: c
+    run: echo c
+`))
+			Expect(doc.String()).To(HavePrefix("# the run\nbase:\n  os: ubuntu 24.04\n  tag: 1.0\n"))
+		})
+	})
+
+	Context("anchors and aliases", func() {
+		contents := `x-defaults: &defaults
+  call: mint/setup-node 1.0.0 # shared
+base: &base
+  os: ubuntu 24.04
+tasks:
+  - key: a
+    <<: *defaults
+  - key: b
+    call: &leaf mint/setup-go 1.0.0
+  - key: c
+    call: *leaf
+  - key: d
+    <<: *defaults
+`
+
+		It("visits nodes shared through aliases once, at their anchor", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			var paths []string
+			err = doc.ForEachNode("$.tasks[*].call", func(node ast.Node) error {
+				paths = append(paths, node.GetPath())
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(paths).To(Equal([]string{"$.x-defaults.call", "$.tasks[1].call"}))
+		})
+
+		It("iterates over anchored and aliased sequence items", func() {
+			doc, err := cli.ParseYAMLDoc("tasks:\n  - &a\n    key: a\n  - key: b\n  - *a\n")
+			Expect(err).NotTo(HaveOccurred())
+
+			var keys []string
+			err = doc.ForEachNode("$.tasks[*].key", func(node ast.Node) error {
+				keys = append(keys, node.String())
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]string{"a", "b"}))
+		})
+
+		It("reads through merge keys and aliases", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.ReadStringAtPath("$.tasks[0].call")).To(Equal("mint/setup-node 1.0.0"))
+			Expect(doc.ReadStringAtPath("$.tasks[2].call")).To(Equal("mint/setup-go 1.0.0"))
+		})
+
+		It("updates anchor definitions without expanding their aliases", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.ReplaceAtPath("$.x-defaults.call", "mint/setup-node 1.1.0")).To(Succeed())
+			Expect(doc.ReplaceAtPath("$.tasks[1].call", "mint/setup-go 1.1.0")).To(Succeed())
+			Expect(doc.String()).To(Equal(`x-defaults: &defaults
+  call: mint/setup-node 1.1.0
+base: &base
+  os: ubuntu 24.04
+tasks:
+  - key: a
+    <<: *defaults
+  - key: b
+    call: &leaf mint/setup-go 1.1.0
+  - key: c
+    call: *leaf
+  - key: d
+    <<: *defaults
+`))
+		})
+
+		It("refuses to edit nodes through an alias", func() {
+			doc, err := cli.ParseYAMLDoc(contents + "other:\n  base: *base\n")
+			Expect(err).NotTo(HaveOccurred())
+
+			err = doc.ReplaceAtPath("$.tasks[2].call", "mint/setup-go 1.1.0")
+			Expect(errors.Is(err, cli.ErrAliasedNode)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("unable to edit $.tasks[2].call through the alias *leaf")))
+
+			err = doc.ReplaceAtPath("$.tasks[0].call", "mint/setup-node 1.1.0")
+			Expect(errors.Is(err, cli.ErrAliasedNode)).To(BeTrue())
+
+			err = doc.MergeAtPath("$.other.base", map[string]any{"tag": 1.2})
+			Expect(errors.Is(err, cli.ErrAliasedNode)).To(BeTrue())
+			Expect(doc.HasChanges()).To(BeFalse())
+		})
+	})
+
+	Context("JSON documents", func() {
+		contents := `{
+  "base": {"os": "ubuntu 24.04", "tag": "1.0"},
+  "tasks": [
+    {
+      "key": "a",
+      "call": "mint/setup-node 1.0.0"
+    }
+  ]
+}
+`
+
+		It("reads strings without their quotes", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.IsJSON()).To(BeTrue())
+			Expect(doc.IsRunDefinition()).To(BeTrue())
+			Expect(doc.TryReadStringAtPath("$.base.tag")).To(Equal("1.0"))
+			Expect(doc.TryReadStringAtPath("$.tasks[0].call")).To(Equal("mint/setup-node 1.0.0"))
+		})
+
+		It("replaces values, preserving the formatting of the rest of the document", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.ReplaceAtPath("$.tasks[0].call", "mint/setup-node 1.1.0")).To(Succeed())
+			Expect(doc.HasChanges()).To(BeTrue())
+			Expect(doc.String()).To(Equal(`{
+  "base": {"os": "ubuntu 24.04", "tag": "1.0"},
+  "tasks": [
+    {
+      "key": "a",
+      "call": "mint/setup-node 1.1.0"
+    }
+  ]
+}
+`))
+		})
+
+		It("merges objects in the style of the object merged into", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.MergeAtPath("$.base", map[string]any{"tag": "1.1", "arch": "arm64"})).To(Succeed())
+			Expect(doc.SetAtPath("$.tasks[0].use", "b")).To(Succeed())
+			Expect(doc.String()).To(Equal(`{
+  "base": {"os": "ubuntu 24.04", "tag": "1.1", "arch": "arm64"},
+  "tasks": [
+    {
+      "key": "a",
+      "call": "mint/setup-node 1.0.0",
+      "use": "b"
+    }
+  ]
+}
+`))
+		})
+
+		It("inserts before a root field and appends to sequences, indenting like the surrounding values", func() {
+			doc, err := cli.ParseYAMLDoc(`{"tasks": []}`)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.InsertBefore("$.tasks", map[string]any{"on": map[string]any{"github": map[string]any{"push": map[string]any{}}}})).To(Succeed())
+			Expect(doc.AppendToSequence("$.tasks", yaml.MapSlice{
+				{Key: "key", Value: "b"},
+				{Key: "run", Value: "echo <b> && exit 0"},
+			})).To(Succeed())
+			Expect(doc.String()).To(Equal(`{"on": {"github": {"push": {}}}, "tasks": [{"key": "b", "run": "echo <b> && exit 0"}]}`))
+
+			doc, err = cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(doc.AppendToSequence("$.tasks", yaml.MapSlice{{Key: "key", Value: "b"}, {Key: "run", Value: "echo b"}})).To(Succeed())
+			Expect(doc.String()).To(HaveSuffix(`  "tasks": [
+    {
+      "key": "a",
+      "call": "mint/setup-node 1.0.0"
+    },
+    {
+      "key": "b",
+      "run": "echo b"
+    }
+  ]
+}
+`))
+		})
+
+		It("errors when the path is not found", func() {
+			doc, err := cli.ParseYAMLDoc(contents)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = doc.ReplaceAtPath("$.tasks[1].call", "mint/setup-node 1.1.0")
+			Expect(errors.Is(err, yaml.ErrNotFoundNode)).To(BeTrue())
+			Expect(doc.HasChanges()).To(BeFalse())
+		})
+	})
 })
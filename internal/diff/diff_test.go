@@ -0,0 +1,98 @@
+package diff_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rwx-research/mint-cli/internal/diff"
+)
+
+var _ = Describe("Unified", func() {
+	It("returns an empty string for equal contents", func() {
+		Expect(diff.Unified("a/foo.yml", "b/foo.yml", "a\nb\n", "a\nb\n")).To(Equal(""))
+	})
+
+	It("includes three lines of context around changes", func() {
+		from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+		to := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n"
+
+		Expect(diff.Unified("a/foo.yml", "b/foo.yml", from, to)).To(Equal(`--- a/foo.yml
++++ b/foo.yml
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`))
+	})
+
+	It("splits distant changes into separate hunks", func() {
+		from := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+		to := "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"
+
+		Expect(diff.Unified("a/foo.yml", "b/foo.yml", from, to)).To(Equal(`--- a/foo.yml
++++ b/foo.yml
+@@ -1,4 +1,4 @@
+-1
++one
+ 2
+ 3
+ 4
+@@ -9,4 +9,4 @@
+ 9
+ 10
+ 11
+-12
++twelve
+`))
+	})
+
+	It("merges changes with overlapping context", func() {
+		from := "1\n2\n3\n4\n5\n6\n"
+		to := "one\n2\n3\n4\n5\nsix\n"
+
+		Expect(diff.Unified("a/foo.yml", "b/foo.yml", from, to)).To(Equal(`--- a/foo.yml
++++ b/foo.yml
+@@ -1,6 +1,6 @@
+-1
++one
+ 2
+ 3
+ 4
+ 5
+-6
++six
+`))
+	})
+
+	It("handles insertions and deletions", func() {
+		Expect(diff.Unified("a/foo.yml", "b/foo.yml", "a\nc\n", "a\nb\nc\n")).To(Equal(`--- a/foo.yml
++++ b/foo.yml
+@@ -1,2 +1,3 @@
+ a
++b
+ c
+`))
+
+		Expect(diff.Unified("a/foo.yml", "b/foo.yml", "a\n", "")).To(Equal(`--- a/foo.yml
++++ b/foo.yml
+@@ -1 +0,0 @@
+-a
+`))
+	})
+
+	It("marks a missing newline at the end of the file", func() {
+		Expect(diff.Unified("a/foo.yml", "b/foo.yml", "a\nb", "a\nb\n")).To(Equal(`--- a/foo.yml
++++ b/foo.yml
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`))
+	})
+})
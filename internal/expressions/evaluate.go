@@ -0,0 +1,232 @@
+package expressions
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+func (n literalNode) evaluate(ctx Context) (any, error) {
+	return n.value, nil
+}
+
+func (n contextNode) evaluate(ctx Context) (any, error) {
+	if !isKnownContext(n.name) {
+		return nil, errors.Errorf("unknown context %q", n.name)
+	}
+
+	value, ok := ctx[n.name]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnavailable, "the %s context", n.name)
+	}
+	return normalize(value), nil
+}
+
+// evaluate returns the value of a property, or null when it doesn't exist. Properties of null
+// are null as well, so `init.missing.nested` is null rather than an error.
+func (n propertyNode) evaluate(ctx Context) (any, error) {
+	object, err := n.object.evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	property, err := n.property.evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch object := object.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return object[ToString(property)], nil
+	case []any:
+		index, ok := toNumber(property)
+		if !ok || index != math.Trunc(index) {
+			return nil, errors.Errorf("unable to index an array with %s", describe(property))
+		}
+		if index < 0 || int(index) >= len(object) {
+			return nil, nil
+		}
+		return object[int(index)], nil
+	default:
+		return nil, errors.Errorf("unable to read the property %q of %s", ToString(property), describe(object))
+	}
+}
+
+func (n notNode) evaluate(ctx Context) (any, error) {
+	operand, err := n.operand.evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return !Truthy(operand), nil
+}
+
+// evaluate evaluates a binary operation. Logical operators short-circuit and return one of
+// their operands, so `init.branch || 'main'` defaults to 'main'.
+func (n binaryNode) evaluate(ctx Context) (any, error) {
+	left, err := n.left.evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "||":
+		if Truthy(left) {
+			return left, nil
+		}
+		return n.right.evaluate(ctx)
+	case "&&":
+		if !Truthy(left) {
+			return left, nil
+		}
+		return n.right.evaluate(ctx)
+	}
+
+	right, err := n.right.evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.operator {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	default:
+		return compare(n.operator, left, right)
+	}
+}
+
+func (n callNode) evaluate(ctx Context) (any, error) {
+	arguments := make([]any, len(n.arguments))
+	for i, argument := range n.arguments {
+		value, err := argument.evaluate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		arguments[i] = value
+	}
+
+	value, err := functions[n.name](arguments)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s()", n.name)
+	}
+	return value, nil
+}
+
+// equal compares two values. Numbers are compared to strings as numbers, since init parameters
+// are always strings.
+func equal(left any, right any) bool {
+	if leftNumber, ok := left.(float64); ok {
+		rightNumber, ok := toNumber(right)
+		return ok && leftNumber == rightNumber
+	}
+	if rightNumber, ok := right.(float64); ok {
+		leftNumber, ok := toNumber(left)
+		return ok && leftNumber == rightNumber
+	}
+
+	switch left := left.(type) {
+	case nil, string, bool:
+		return left == right
+	default:
+		// Maps and arrays are only equal to themselves, which can't be told apart from equal copies
+		return false
+	}
+}
+
+func compare(operator string, left any, right any) (any, error) {
+	leftString, leftIsString := left.(string)
+	rightString, rightIsString := right.(string)
+	if leftIsString && rightIsString {
+		return compareOrdered(operator, leftString, rightString), nil
+	}
+
+	leftNumber, leftOk := toNumber(left)
+	rightNumber, rightOk := toNumber(right)
+	if !leftOk || !rightOk {
+		return nil, errors.Errorf("unable to compare %s with %s", describe(left), describe(right))
+	}
+	return compareOrdered(operator, leftNumber, rightNumber), nil
+}
+
+func compareOrdered[T string | float64](operator string, left T, right T) bool {
+	switch operator {
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	default:
+		return left >= right
+	}
+}
+
+func toNumber(value any) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case string:
+		number, err := strconv.ParseFloat(value, 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// normalize converts the values of contexts to the types expressions work with.
+func normalize(value any) any {
+	switch value := value.(type) {
+	case map[string]string:
+		normalized := make(map[string]any, len(value))
+		for key, item := range value {
+			normalized[key] = item
+		}
+		return normalized
+	case map[string]any:
+		normalized := make(map[string]any, len(value))
+		for key, item := range value {
+			normalized[key] = normalize(item)
+		}
+		return normalized
+	case []string:
+		normalized := make([]any, len(value))
+		for i, item := range value {
+			normalized[i] = item
+		}
+		return normalized
+	case []any:
+		normalized := make([]any, len(value))
+		for i, item := range value {
+			normalized[i] = normalize(item)
+		}
+		return normalized
+	case int:
+		return float64(value)
+	default:
+		return value
+	}
+}
+
+func describe(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("the string %q", value)
+	case float64:
+		return fmt.Sprintf("the number %s", ToString(value))
+	case bool:
+		return fmt.Sprintf("the boolean %t", value)
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
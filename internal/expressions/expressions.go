@@ -0,0 +1,142 @@
+package expressions
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+// ErrUnavailable is returned when an expression refers to a context that isn't known
+// locally, eg. the outputs of other tasks, which are only known while running.
+var ErrUnavailable = errors.New("not available locally")
+
+// Contexts are the contexts an expression may refer to.
+var Contexts = []string{"init", "run", "event", "tasks", "vaults"}
+
+// Context holds the values of the contexts expressions can refer to, keyed by their name, eg.
+// "init" or "run". Values are strings, numbers, booleans, nil, maps and slices of them.
+// Contexts that are missing are unavailable: expressions referring to them fail with
+// ErrUnavailable.
+type Context map[string]any
+
+const (
+	expressionStart = "${{"
+	expressionEnd   = "}}"
+)
+
+// Evaluate parses and evaluates an expression, without its `${{ }}` delimiters.
+func Evaluate(expression string, ctx Context) (any, error) {
+	parsed, err := Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed.Evaluate(ctx)
+}
+
+// ContainsExpression reports whether str contains a `${{ }}` expression.
+func ContainsExpression(str string) bool {
+	return strings.Contains(str, expressionStart)
+}
+
+// Interpolate evaluates every `${{ }}` expression of str and replaces it with its value,
+// formatted with ToString.
+func Interpolate(str string, ctx Context) (string, error) {
+	return interpolate(str, ctx, false)
+}
+
+// InterpolateAvailable is like Interpolate, but leaves the expressions that refer to
+// unavailable contexts as they are, eg. `${{ tasks.build.values.version }}`.
+func InterpolateAvailable(str string, ctx Context) (string, error) {
+	return interpolate(str, ctx, true)
+}
+
+func interpolate(str string, ctx Context, keepUnavailable bool) (string, error) {
+	var result strings.Builder
+
+	rest := str
+	for {
+		start := strings.Index(rest, expressionStart)
+		if start == -1 {
+			result.WriteString(rest)
+			return result.String(), nil
+		}
+		result.WriteString(rest[:start])
+		rest = rest[start+len(expressionStart):]
+
+		end := expressionEndIndex(rest)
+		if end == -1 {
+			return "", errors.Errorf("unterminated expression in %q", str)
+		}
+
+		value, err := Evaluate(rest[:end], ctx)
+		if keepUnavailable && errors.Is(err, ErrUnavailable) {
+			result.WriteString(expressionStart + rest[:end] + expressionEnd)
+		} else if err != nil {
+			return "", errors.Wrapf(err, "unable to evaluate %q", strings.TrimSpace(rest[:end]))
+		} else {
+			result.WriteString(ToString(value))
+		}
+		rest = rest[end+len(expressionEnd):]
+	}
+}
+
+// expressionEndIndex returns the index of the `}}` closing an expression, ignoring the ones
+// within strings.
+func expressionEndIndex(str string) int {
+	quoted := false
+	for i := 0; i < len(str); i++ {
+		switch {
+		case str[i] == '\'':
+			quoted = !quoted
+		case !quoted && strings.HasPrefix(str[i:], expressionEnd):
+			return i
+		}
+	}
+	return -1
+}
+
+// ToString formats a value the way it's interpolated into strings: null is empty, numbers don't
+// have trailing zeros, and maps and slices are encoded as JSON.
+func ToString(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(encoded)
+	}
+}
+
+// Truthy reports whether a value is considered true by conditions and logical operators: every
+// value except false, null, 0 and the empty string.
+func Truthy(value any) bool {
+	switch value := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case float64:
+		return value != 0
+	case string:
+		return value != ""
+	default:
+		return true
+	}
+}
+
+func isKnownContext(name string) bool {
+	return slices.Contains(Contexts, name)
+}
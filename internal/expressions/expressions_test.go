@@ -0,0 +1,114 @@
+package expressions_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rwx-research/mint-cli/internal/errors"
+	"github.com/rwx-research/mint-cli/internal/expressions"
+)
+
+var _ = Describe("Evaluate", func() {
+	ctx := expressions.Context{
+		"init": map[string]string{"branch": "main", "count": "3", "empty": ""},
+		"run":  map[string]any{"mint-dir": ".mint", "labels": []any{"a", "b"}},
+	}
+
+	DescribeTable("evaluates expressions",
+		func(expression string, expected any) {
+			value, err := expressions.Evaluate(expression, ctx)
+			Expect(err).NotTo(HaveOccurred())
+			if expected == nil {
+				Expect(value).To(BeNil())
+			} else {
+				Expect(value).To(Equal(expected))
+			}
+		},
+		Entry("literals", "'it''s'", "it's"),
+		Entry("numbers", "1.5", 1.5),
+		Entry("null", "null", nil),
+		Entry("context properties", "run.mint-dir", ".mint"),
+		Entry("indexed properties", "init['branch']", "main"),
+		Entry("array items", "run.labels[1]", "b"),
+		Entry("missing properties", "init.missing.nested", nil),
+		Entry("defaults", "init.missing || 'fallback'", "fallback"),
+		Entry("empty strings as falsy", "init.empty || 'fallback'", "fallback"),
+		Entry("and", "init.branch && 'yes'", "yes"),
+		Entry("negation", "!init.missing", true),
+		Entry("equality", "init.branch == 'main'", true),
+		Entry("inequality", "init.branch != 'main'", false),
+		Entry("numbers compared to strings", "init.count == 3", true),
+		Entry("comparisons", "init.count >= 2 && init.count < 4", true),
+		Entry("precedence", "false && true || true", true),
+		Entry("parentheses", "false && (true || true)", false),
+		Entry("starts-with", "starts-with(init.branch, 'ma')", true),
+		Entry("ends-with", "ends-with(run.mint-dir, 'mint')", true),
+		Entry("contains on strings", "contains(init.branch, 'ai')", true),
+		Entry("contains on arrays", "contains(run.labels, 'c')", false),
+		Entry("join", "join(run.labels, ' ')", "a b"),
+		Entry("to-json", "to-json(run.labels)", `["a","b"]`),
+		Entry("from-json", "from-json('{\"a\": [1]}').a[0]", 1.0),
+	)
+
+	It("reports contexts that aren't available locally", func() {
+		_, err := expressions.Evaluate("tasks.build.values.version", ctx)
+		Expect(errors.Is(err, expressions.ErrUnavailable)).To(BeTrue())
+		Expect(err).To(MatchError("the tasks context: not available locally"))
+	})
+
+	It("short-circuits before unavailable contexts", func() {
+		value, err := expressions.Evaluate("init.branch || event.git.branch", ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value).To(Equal("main"))
+	})
+
+	DescribeTable("reports invalid expressions",
+		func(expression string, message string) {
+			_, err := expressions.Evaluate(expression, ctx)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+		Entry("unknown contexts", "foo.bar", `unknown context "foo"`),
+		Entry("unknown functions", "upper(init.branch)", `unknown function "upper" at position 1`),
+		Entry("double quoted strings", `init.branch == "main"`, "strings are single quoted"),
+		Entry("unterminated strings", "'main", "unterminated string at position 1"),
+		Entry("trailing tokens", "init.branch init.count", `unexpected "init" at position 13`),
+		Entry("missing parentheses", "(init.branch", `expected ")" at position 13, got end of expression`),
+		Entry("incomplete operations", "init.branch ==", `unexpected end of expression at position 15`),
+		Entry("wrong argument counts", "starts-with(init.branch)", "starts-with(): expected 2 arguments, got 1"),
+		Entry("invalid comparisons", "run.labels > 1", "unable to compare an array with the number 1"),
+	)
+})
+
+var _ = Describe("Interpolate", func() {
+	ctx := expressions.Context{
+		"init": map[string]string{"name": "world"},
+		"run":  map[string]string{"mint-dir": ".mint"},
+	}
+
+	It("replaces every expression with its value", func() {
+		Expect(expressions.Interpolate("${{ run.mint-dir }}/embed.yml", ctx)).To(Equal(".mint/embed.yml"))
+		Expect(expressions.Interpolate("hello ${{ init.name }}, ${{ init.missing || '}}' }}", ctx)).To(Equal("hello world, }}"))
+	})
+
+	It("leaves strings without expressions as is", func() {
+		Expect(expressions.Interpolate("mint/setup-node 1.2.3", ctx)).To(Equal("mint/setup-node 1.2.3"))
+		Expect(expressions.ContainsExpression("mint/setup-node 1.2.3")).To(BeFalse())
+		Expect(expressions.ContainsExpression("${{ run.mint-dir }}/embed.yml")).To(BeTrue())
+	})
+
+	It("can leave the expressions referring to unavailable contexts as they are", func() {
+		Expect(expressions.InterpolateAvailable("${{ init.name }}-${{ tasks.build.values.version }}", ctx)).To(Equal("world-${{ tasks.build.values.version }}"))
+
+		_, err := expressions.InterpolateAvailable("${{ init.name ) }}", ctx)
+		Expect(err).To(MatchError(ContainSubstring(`unable to evaluate "init.name )"`)))
+	})
+
+	It("reports the expression that couldn't be evaluated", func() {
+		_, err := expressions.Interpolate("${{ event.git.sha }}.yml", ctx)
+		Expect(errors.Is(err, expressions.ErrUnavailable)).To(BeTrue())
+		Expect(err).To(MatchError(`unable to evaluate "event.git.sha": the event context: not available locally`))
+
+		_, err = expressions.Interpolate("${{ run.mint-dir", ctx)
+		Expect(err).To(MatchError(`unterminated expression in "${{ run.mint-dir"`))
+	})
+})
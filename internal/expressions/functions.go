@@ -0,0 +1,95 @@
+package expressions
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+type function func(arguments []any) (any, error)
+
+// functions are the functions expressions can call, by name.
+var functions = map[string]function{
+	"contains": func(arguments []any) (any, error) {
+		if err := expectArguments(arguments, 2, 2); err != nil {
+			return nil, err
+		}
+
+		if items, ok := arguments[0].([]any); ok {
+			for _, item := range items {
+				if equal(item, arguments[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(ToString(arguments[0]), ToString(arguments[1])), nil
+	},
+	"starts-with": func(arguments []any) (any, error) {
+		if err := expectArguments(arguments, 2, 2); err != nil {
+			return nil, err
+		}
+		return strings.HasPrefix(ToString(arguments[0]), ToString(arguments[1])), nil
+	},
+	"ends-with": func(arguments []any) (any, error) {
+		if err := expectArguments(arguments, 2, 2); err != nil {
+			return nil, err
+		}
+		return strings.HasSuffix(ToString(arguments[0]), ToString(arguments[1])), nil
+	},
+	"join": func(arguments []any) (any, error) {
+		if err := expectArguments(arguments, 1, 2); err != nil {
+			return nil, err
+		}
+
+		separator := ","
+		if len(arguments) == 2 {
+			separator = ToString(arguments[1])
+		}
+
+		items, ok := arguments[0].([]any)
+		if !ok {
+			return ToString(arguments[0]), nil
+		}
+
+		strs := make([]string, len(items))
+		for i, item := range items {
+			strs[i] = ToString(item)
+		}
+		return strings.Join(strs, separator), nil
+	},
+	"to-json": func(arguments []any) (any, error) {
+		if err := expectArguments(arguments, 1, 1); err != nil {
+			return nil, err
+		}
+
+		encoded, err := json.Marshal(arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	},
+	"from-json": func(arguments []any) (any, error) {
+		if err := expectArguments(arguments, 1, 1); err != nil {
+			return nil, err
+		}
+
+		var value any
+		if err := json.Unmarshal([]byte(ToString(arguments[0])), &value); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON")
+		}
+		return value, nil
+	},
+}
+
+func expectArguments(arguments []any, min int, max int) error {
+	if len(arguments) >= min && len(arguments) <= max {
+		return nil
+	}
+
+	if min == max {
+		return errors.Errorf("expected %d arguments, got %d", min, len(arguments))
+	}
+	return errors.Errorf("expected %d to %d arguments, got %d", min, max, len(arguments))
+}
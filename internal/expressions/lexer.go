@@ -0,0 +1,148 @@
+package expressions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenDot
+	tokenComma
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBracket
+	tokenRightBracket
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	number float64
+	offset int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!"}
+
+// tokenize splits an expression into tokens. Identifiers may contain dashes, eg. `run.mint-dir`,
+// and strings are single quoted, with quotes escaped by doubling them: 'it”s'.
+func tokenize(expression string) ([]token, error) {
+	tokens := make([]token, 0)
+	for pos := 0; pos < len(expression); {
+		char := expression[pos]
+		switch {
+		case char == ' ' || char == '\t' || char == '\n' || char == '\r':
+			pos++
+		case char == '.':
+			tokens = append(tokens, token{kind: tokenDot, text: ".", offset: pos})
+			pos++
+		case char == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", offset: pos})
+			pos++
+		case char == '(':
+			tokens = append(tokens, token{kind: tokenLeftParen, text: "(", offset: pos})
+			pos++
+		case char == ')':
+			tokens = append(tokens, token{kind: tokenRightParen, text: ")", offset: pos})
+			pos++
+		case char == '[':
+			tokens = append(tokens, token{kind: tokenLeftBracket, text: "[", offset: pos})
+			pos++
+		case char == ']':
+			tokens = append(tokens, token{kind: tokenRightBracket, text: "]", offset: pos})
+			pos++
+		case char == '\'':
+			value, end, err := scanString(expression, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value, offset: pos})
+			pos = end
+		case isDigit(char) || (char == '-' && pos+1 < len(expression) && isDigit(expression[pos+1])):
+			end := pos + 1
+			for end < len(expression) && (isDigit(expression[end]) || expression[end] == '.' || expression[end] == 'e' || expression[end] == 'E' ||
+				((expression[end] == '-' || expression[end] == '+') && (expression[end-1] == 'e' || expression[end-1] == 'E'))) {
+				end++
+			}
+			number, err := strconv.ParseFloat(expression[pos:end], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", expression[pos:end], pos+1)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expression[pos:end], number: number, offset: pos})
+			pos = end
+		case isIdentifierStart(char):
+			end := pos + 1
+			for end < len(expression) && isIdentifierPart(expression[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdentifier, text: expression[pos:end], offset: pos})
+			pos = end
+		default:
+			operator := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(expression[pos:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				if char == '"' {
+					return nil, fmt.Errorf("unexpected %q at position %d, strings are single quoted", char, pos+1)
+				}
+				return nil, fmt.Errorf("unexpected %q at position %d", char, pos+1)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: operator, offset: pos})
+			pos += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, offset: len(expression)}), nil
+}
+
+// scanString reads the single quoted string starting at start, returning its value and the
+// offset following its closing quote.
+func scanString(expression string, start int) (string, int, error) {
+	var value strings.Builder
+	for pos := start + 1; pos < len(expression); pos++ {
+		if expression[pos] != '\'' {
+			value.WriteByte(expression[pos])
+			continue
+		}
+
+		if pos+1 < len(expression) && expression[pos+1] == '\'' {
+			value.WriteByte('\'')
+			pos++
+			continue
+		}
+
+		return value.String(), pos + 1, nil
+	}
+
+	return "", 0, fmt.Errorf("unterminated string at position %d", start+1)
+}
+
+func isDigit(char byte) bool {
+	return char >= '0' && char <= '9'
+}
+
+func isIdentifierStart(char byte) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z')
+}
+
+func isIdentifierPart(char byte) bool {
+	return isIdentifierStart(char) || isDigit(char) || char == '-'
+}
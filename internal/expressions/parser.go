@@ -0,0 +1,248 @@
+package expressions
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Expression is a parsed expression, which can be evaluated any number of times.
+type Expression struct {
+	source string
+	root   node
+}
+
+type node interface {
+	evaluate(ctx Context) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+type contextNode struct {
+	name string
+}
+
+type propertyNode struct {
+	object   node
+	property node
+}
+
+type notNode struct {
+	operand node
+}
+
+type binaryNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+type callNode struct {
+	name      string
+	arguments []node
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse parses an expression, without its `${{ }}` delimiters.
+func Parse(expression string) (*Expression, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at position %d", next, next.offset+1)
+	}
+
+	return &Expression{source: expression, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Evaluate evaluates the expression with the given contexts.
+func (e *Expression) Evaluate(ctx Context) (any, error) {
+	return e.root.evaluate(ctx)
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseBinary([]string{"&&"}, p.parseEquality)
+}
+
+func (p *parser) parseEquality() (node, error) {
+	return p.parseBinary([]string{"==", "!="}, p.parseComparison)
+}
+
+func (p *parser) parseComparison() (node, error) {
+	return p.parseBinary([]string{"<", "<=", ">", ">="}, p.parseUnary)
+}
+
+// parseBinary parses left-associative operations of the given operators, with operands parsed
+// by the next level of precedence.
+func (p *parser) parseBinary(operators []string, parseOperand func() (node, error)) (node, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		next := p.peek()
+		if next.kind != tokenOperator || !slices.Contains(operators, next.text) {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{operator: next.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if next := p.peek(); next.kind == tokenOperator && next.text == "!" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	object, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek().kind {
+		case tokenDot:
+			p.pos++
+			property := p.next()
+			if property.kind != tokenIdentifier {
+				return nil, fmt.Errorf("expected a property name at position %d, got %s", property.offset+1, property)
+			}
+			object = propertyNode{object: object, property: literalNode{value: property.text}}
+		case tokenLeftBracket:
+			p.pos++
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(tokenRightBracket, "]"); err != nil {
+				return nil, err
+			}
+			object = propertyNode{object: object, property: index}
+		default:
+			return object, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	next := p.next()
+	switch next.kind {
+	case tokenString:
+		return literalNode{value: next.text}, nil
+	case tokenNumber:
+		return literalNode{value: next.number}, nil
+	case tokenLeftParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case tokenIdentifier:
+		switch next.text {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		}
+
+		if p.peek().kind == tokenLeftParen {
+			return p.parseCall(next)
+		}
+		return contextNode{name: next.text}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s at position %d", next, next.offset+1)
+	}
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	if _, ok := functions[name.text]; !ok {
+		return nil, fmt.Errorf("unknown function %q at position %d", name.text, name.offset+1)
+	}
+	p.pos++
+
+	call := callNode{name: name.text, arguments: make([]node, 0)}
+	if p.peek().kind == tokenRightParen {
+		p.pos++
+		return call, nil
+	}
+
+	for {
+		argument, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.arguments = append(call.arguments, argument)
+
+		next := p.next()
+		switch next.kind {
+		case tokenComma:
+			continue
+		case tokenRightParen:
+			return call, nil
+		default:
+			return nil, fmt.Errorf("expected \",\" or \")\" at position %d, got %s", next.offset+1, next)
+		}
+	}
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	next := p.next()
+	if next.kind != kind {
+		return fmt.Errorf("expected %q at position %d, got %s", text, next.offset+1, next)
+	}
+	return nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	next := p.tokens[p.pos]
+	if next.kind != tokenEOF {
+		p.pos++
+	}
+	return next
+}
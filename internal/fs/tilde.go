@@ -0,0 +1,27 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+var tildeSlash = fmt.Sprintf("~%v", string(os.PathSeparator))
+
+// ExpandTilde replaces a leading "~" in the given path with the home directory of the current user.
+func ExpandTilde(dir string) (string, error) {
+	user, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasPrefix(dir, tildeSlash) {
+		return filepath.Join(user.HomeDir, strings.TrimPrefix(dir, tildeSlash)), nil
+	} else if dir == "~" {
+		return user.HomeDir, nil
+	} else {
+		return dir, nil
+	}
+}
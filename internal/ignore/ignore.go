@@ -0,0 +1,191 @@
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rwx-research/mint-cli/internal/errors"
+)
+
+type pattern struct {
+	text    string
+	source  string
+	base    string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// Matcher matches paths against patterns in the syntax of .gitignore files. Patterns are added in
+// order of precedence, the last matching pattern deciding whether a path is ignored.
+type Matcher struct {
+	patterns []pattern
+}
+
+// Match is the pattern that decided whether a path is ignored.
+type Match struct {
+	// Source is the file and line of the pattern, eg. .mint/.mintignore:3
+	Source  string
+	Pattern string
+	Ignored bool
+}
+
+// AddFile adds the patterns of the ignore file at filePath, which apply to the paths under base.
+// A missing file is not an error.
+func (m *Matcher) AddFile(filePath string, source string, base string) error {
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "unable to read %q", filePath)
+	}
+
+	m.Add(string(contents), source, base)
+	return nil
+}
+
+// Add adds the patterns in contents, which apply to the paths under base. base and the paths
+// matched are slash-separated and relative to the same directory, with "" being that directory.
+func (m *Matcher) Add(contents string, source string, base string) {
+	for i, line := range strings.Split(contents, "\n") {
+		p, ok := parsePattern(line)
+		if !ok {
+			continue
+		}
+
+		p.text = strings.TrimSpace(line)
+		if source != "" {
+			p.source = fmt.Sprintf("%s:%d", source, i+1)
+		}
+		p.base = strings.Trim(base, "/")
+		m.patterns = append(m.patterns, p)
+	}
+}
+
+// Ignored reports whether the path is ignored.
+func (m *Matcher) Ignored(filePath string, isDir bool) bool {
+	match, ok := m.Match(filePath, isDir)
+	return ok && match.Ignored
+}
+
+// Match returns the last pattern matching the path, if any.
+func (m *Matcher) Match(filePath string, isDir bool) (Match, bool) {
+	if m == nil {
+		return Match{}, false
+	}
+
+	filePath = strings.Trim(filePath, "/")
+	for i := len(m.patterns) - 1; i >= 0; i-- {
+		p := m.patterns[i]
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		rel := filePath
+		if p.base != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(filePath, p.base+"/"); !ok {
+				continue
+			}
+		}
+
+		if p.re.MatchString(rel) {
+			return Match{Source: p.source, Pattern: p.text, Ignored: !p.negate}, true
+		}
+	}
+
+	return Match{}, false
+}
+
+// parsePattern parses a line of an ignore file, returning false for blank lines and comments.
+func parsePattern(line string) (pattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	line = trimTrailingSpaces(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	p := pattern{}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return pattern{}, false
+	}
+
+	// Patterns with a slash before their end are relative to the base, others match at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := translate(line)
+	if anchored || strings.HasPrefix(line, "**/") {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return pattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// translate converts a glob to a regular expression, where * and ? don't match a slash and **
+// matches any number of directories.
+func translate(glob string) string {
+	var expr strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case glob[i:] == "**" && (i == 0 || glob[i-1] == '/'):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				expr.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expr.String()
+}
+
+// trimTrailingSpaces removes trailing spaces, unless they're escaped with a backslash.
+func trimTrailingSpaces(line string) string {
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
@@ -0,0 +1,92 @@
+package ignore_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/rwx-research/mint-cli/internal/ignore"
+)
+
+var _ = Describe("Matcher", func() {
+	var matcher *ignore.Matcher
+
+	BeforeEach(func() {
+		matcher = new(ignore.Matcher)
+	})
+
+	It("skips blank lines and comments", func() {
+		matcher.Add("\n# *.yml\n\n", ".mintignore", "")
+
+		Expect(matcher.Ignored("ci.yml", false)).To(BeFalse())
+	})
+
+	It("matches patterns without a slash at any depth", func() {
+		matcher.Add("*.swp\n", ".mintignore", "")
+
+		Expect(matcher.Ignored(".ci.yml.swp", false)).To(BeTrue())
+		Expect(matcher.Ignored("nested/path/.ci.yml.swp", false)).To(BeTrue())
+		Expect(matcher.Ignored("ci.yml", false)).To(BeFalse())
+	})
+
+	It("matches patterns with a slash relative to the base", func() {
+		matcher.Add("/fixtures\nsome/data\n", ".mintignore", ".mint")
+
+		Expect(matcher.Ignored(".mint/fixtures", true)).To(BeTrue())
+		Expect(matcher.Ignored(".mint/nested/fixtures", true)).To(BeFalse())
+		Expect(matcher.Ignored(".mint/some/data", false)).To(BeTrue())
+		Expect(matcher.Ignored(".mint/nested/some/data", false)).To(BeFalse())
+		Expect(matcher.Ignored("fixtures", true)).To(BeFalse())
+	})
+
+	It("only matches directories with patterns ending in a slash", func() {
+		matcher.Add("tmp/\n", ".mintignore", "")
+
+		Expect(matcher.Ignored("nested/tmp", true)).To(BeTrue())
+		Expect(matcher.Ignored("nested/tmp", false)).To(BeFalse())
+	})
+
+	It("matches wildcards, character classes and double asterisks", func() {
+		matcher.Add("file?.txt\n[ab].yml\nlogs/**\n**/cache/*.bin\ndocs/**/*.md\n", ".mintignore", "")
+
+		Expect(matcher.Ignored("file1.txt", false)).To(BeTrue())
+		Expect(matcher.Ignored("file12.txt", false)).To(BeFalse())
+		Expect(matcher.Ignored("a.yml", false)).To(BeTrue())
+		Expect(matcher.Ignored("c.yml", false)).To(BeFalse())
+		Expect(matcher.Ignored("logs/nested/run.log", false)).To(BeTrue())
+		Expect(matcher.Ignored("deep/cache/data.bin", false)).To(BeTrue())
+		Expect(matcher.Ignored("cache/data.bin", false)).To(BeTrue())
+		Expect(matcher.Ignored("deep/cache/nested/data.bin", false)).To(BeFalse())
+		Expect(matcher.Ignored("docs/README.md", false)).To(BeTrue())
+		Expect(matcher.Ignored("docs/a/b/README.md", false)).To(BeTrue())
+		Expect(matcher.Ignored("*", false)).To(BeFalse())
+	})
+
+	It("decides by the last matching pattern", func() {
+		matcher.Add("*.json\n!keep.json\n", ".gitignore", "")
+		matcher.Add("keep.json\n", ".mintignore", "")
+		matcher.Add("!other.json\n", ".mintignore", "")
+
+		Expect(matcher.Ignored("some.json", false)).To(BeTrue())
+		Expect(matcher.Ignored("keep.json", false)).To(BeTrue())
+		Expect(matcher.Ignored("other.json", false)).To(BeFalse())
+	})
+
+	It("returns the source of the matching pattern", func() {
+		matcher.Add("# fixtures\n*.bin\n", ".mint/.mintignore", "")
+
+		match, ok := matcher.Match("data.bin", false)
+		Expect(ok).To(BeTrue())
+		Expect(match).To(Equal(ignore.Match{Source: ".mint/.mintignore:2", Pattern: "*.bin", Ignored: true}))
+
+		_, ok = matcher.Match("data.yml", false)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("escapes special characters", func() {
+		matcher.Add("\\#notes\n\\!important\nspace\\ \n", ".mintignore", "")
+
+		Expect(matcher.Ignored("#notes", false)).To(BeTrue())
+		Expect(matcher.Ignored("!important", false)).To(BeTrue())
+		Expect(matcher.Ignored("space ", false)).To(BeTrue())
+	})
+})
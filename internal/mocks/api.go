@@ -7,6 +7,8 @@ import (
 
 type API struct {
 	MockInitiateRun            func(api.InitiateRunConfig) (*api.InitiateRunResult, error)
+	MockFindMissingBlobs       func(api.FindMissingBlobsConfig) (*api.FindMissingBlobsResult, error)
+	MockUploadBlob             func(api.UploadBlobConfig) error
 	MockGetDebugConnectionInfo func(runID string) (api.DebugConnectionInfo, error)
 	MockObtainAuthCode         func(api.ObtainAuthCodeConfig) (*api.ObtainAuthCodeResult, error)
 	MockAcquireToken           func(tokenUrl string) (*api.AcquireTokenResult, error)
@@ -17,6 +19,14 @@ type API struct {
 	MockInitiateDispatch       func(api.InitiateDispatchConfig) (*api.InitiateDispatchResult, error)
 	MockGetDispatch            func(api.GetDispatchConfig) (*api.GetDispatchResult, error)
 	MockResolveBaseLayer       func(api.ResolveBaseLayerConfig) (api.ResolveBaseLayerResult, error)
+	MockListBaseLayers         func() (*api.ListBaseLayersResult, error)
+	MockGetBaseLayerMigration  func(api.GetBaseLayerMigrationConfig) (*api.BaseLayerMigrationResult, error)
+	MockResolveLeafDigests     func(api.ResolveLeafDigestsConfig) (*api.ResolveLeafDigestsResult, error)
+	MockGetLeafReleases        func(api.GetLeafReleasesConfig) (*api.LeafReleasesResult, error)
+	MockSearchLeaves           func(api.SearchLeavesConfig) (*api.SearchLeavesResult, error)
+	MockGetLeaf                func(api.GetLeafConfig) (*api.LeafDetails, error)
+	MockResolveLeafVersions    func(api.ResolveLeafVersionsConfig) (*api.LeafVersionsResult, error)
+	MockResolveLeafGitRef      func(api.ResolveLeafGitRefConfig) (*api.ResolveLeafGitRefResult, error)
 }
 
 func (c *API) InitiateRun(cfg api.InitiateRunConfig) (*api.InitiateRunResult, error) {
@@ -27,6 +37,22 @@ func (c *API) InitiateRun(cfg api.InitiateRunConfig) (*api.InitiateRunResult, er
 	return nil, errors.New("MockInitiateRun was not configured")
 }
 
+func (c *API) FindMissingBlobs(cfg api.FindMissingBlobsConfig) (*api.FindMissingBlobsResult, error) {
+	if c.MockFindMissingBlobs != nil {
+		return c.MockFindMissingBlobs(cfg)
+	}
+
+	return nil, errors.New("MockFindMissingBlobs was not configured")
+}
+
+func (c *API) UploadBlob(cfg api.UploadBlobConfig) error {
+	if c.MockUploadBlob != nil {
+		return c.MockUploadBlob(cfg)
+	}
+
+	return errors.New("MockUploadBlob was not configured")
+}
+
 func (c *API) GetDebugConnectionInfo(runID string) (api.DebugConnectionInfo, error) {
 	if c.MockGetDebugConnectionInfo != nil {
 		return c.MockGetDebugConnectionInfo(runID)
@@ -106,3 +132,67 @@ func (c *API) ResolveBaseLayer(cfg api.ResolveBaseLayerConfig) (api.ResolveBaseL
 
 	return api.ResolveBaseLayerResult{}, errors.New("MockResolveBaseLayer was not configured")
 }
+
+func (c *API) ListBaseLayers() (*api.ListBaseLayersResult, error) {
+	if c.MockListBaseLayers != nil {
+		return c.MockListBaseLayers()
+	}
+
+	return nil, errors.New("MockListBaseLayers was not configured")
+}
+
+func (c *API) GetBaseLayerMigration(cfg api.GetBaseLayerMigrationConfig) (*api.BaseLayerMigrationResult, error) {
+	if c.MockGetBaseLayerMigration != nil {
+		return c.MockGetBaseLayerMigration(cfg)
+	}
+
+	return nil, errors.New("MockGetBaseLayerMigration was not configured")
+}
+
+func (c *API) ResolveLeafDigests(cfg api.ResolveLeafDigestsConfig) (*api.ResolveLeafDigestsResult, error) {
+	if c.MockResolveLeafDigests != nil {
+		return c.MockResolveLeafDigests(cfg)
+	}
+
+	return nil, errors.New("MockResolveLeafDigests was not configured")
+}
+
+func (c *API) GetLeafReleases(cfg api.GetLeafReleasesConfig) (*api.LeafReleasesResult, error) {
+	if c.MockGetLeafReleases != nil {
+		return c.MockGetLeafReleases(cfg)
+	}
+
+	return nil, errors.New("MockGetLeafReleases was not configured")
+}
+
+func (c *API) SearchLeaves(cfg api.SearchLeavesConfig) (*api.SearchLeavesResult, error) {
+	if c.MockSearchLeaves != nil {
+		return c.MockSearchLeaves(cfg)
+	}
+
+	return nil, errors.New("MockSearchLeaves was not configured")
+}
+
+func (c *API) GetLeaf(cfg api.GetLeafConfig) (*api.LeafDetails, error) {
+	if c.MockGetLeaf != nil {
+		return c.MockGetLeaf(cfg)
+	}
+
+	return nil, errors.New("MockGetLeaf was not configured")
+}
+
+func (c *API) ResolveLeafVersions(cfg api.ResolveLeafVersionsConfig) (*api.LeafVersionsResult, error) {
+	if c.MockResolveLeafVersions != nil {
+		return c.MockResolveLeafVersions(cfg)
+	}
+
+	return nil, errors.New("MockResolveLeafVersions was not configured")
+}
+
+func (c *API) ResolveLeafGitRef(cfg api.ResolveLeafGitRefConfig) (*api.ResolveLeafGitRefResult, error) {
+	if c.MockResolveLeafGitRef != nil {
+		return c.MockResolveLeafGitRef(cfg)
+	}
+
+	return nil, errors.New("MockResolveLeafGitRef was not configured")
+}